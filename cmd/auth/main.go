@@ -2,16 +2,44 @@ package main
 
 import (
 	"log"
+	"os"
+	"strings"
+	// profile time zones are validated against the IANA database, which
+	// minimal container images do not ship
+	_ "time/tzdata"
 
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/config"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/application"
 )
 
+const usage = "usage: auth [serve|migrate|config print] [flags] [args]"
+
 func main() {
-	cfg, err := config.NewConfig()
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	cfg, rest, err := config.NewConfig(args)
 	if err != nil {
 		log.Fatalf("Config error: %s", err)
 	}
 
-	application.Run(cfg)
+	switch command {
+	case "serve":
+		application.Run(cfg)
+	case "migrate":
+		if err := application.Migrate(cfg, rest); err != nil {
+			log.Fatalf("Migrate error: %s", err)
+		}
+	case "config":
+		if len(rest) != 1 || rest[0] != "print" {
+			log.Fatal(usage)
+		}
+		if err := config.Print(os.Stdout, cfg); err != nil {
+			log.Fatalf("Config error: %s", err)
+		}
+	default:
+		log.Fatal(usage)
+	}
 }
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/config"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/application"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+)
+
+const usage = `usage: authctl [--config FILE] COMMAND [flags]
+
+commands:
+  users export [--format csv|ndjson] [--filter EXPR] [--out FILE]
+  users import [--format csv|ndjson] [--dry-run] FILE|-
+  audit verify`
+
+func main() {
+	log.SetFlags(0)
+	fs := flag.NewFlagSet("authctl", flag.ExitOnError)
+	fs.Usage = func() { log.Print(usage) }
+	configPath := fs.String("config", "", "path to a config file (default $CONFIG_FILE, then .env and the environment)")
+	_ = fs.Parse(os.Args[1:])
+	args := fs.Args()
+	if len(args) < 2 {
+		log.Fatal(usage)
+	}
+
+	var configArgs []string
+	if *configPath != "" {
+		configArgs = []string{"--config", *configPath}
+	}
+	cfg, _, err := config.NewConfig(configArgs)
+	if err != nil {
+		log.Fatalf("Config error: %s", err)
+	}
+
+	switch args[0] + " " + args[1] {
+	case "users export":
+		err = exportUsers(cfg, args[2:])
+	case "users import":
+		err = importUsers(cfg, args[2:])
+	case "audit verify":
+		err = verifyAudit(cfg, args[2:])
+	default:
+		log.Fatal(usage)
+	}
+	if err != nil {
+		log.Fatalf("%s %s: %s", args[0], args[1], err)
+	}
+}
+
+func exportUsers(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("users export", flag.ExitOnError)
+	var req dto.ExportUsersDTO
+	fs.StringVar(&req.Format, "format", "csv", "csv or ndjson")
+	fs.StringVar(&req.Filter, "filter", "", "filter expression, e.g. 'status:active created_at>2025-01-01'")
+	out := fs.String("out", "-", "output file, - for stdout")
+	_ = fs.Parse(args)
+	if fs.NArg() != 0 {
+		log.Fatal(usage)
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	buffered := bufio.NewWriter(w)
+	if err := application.ExportUsers(cfg, buffered, req); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+func importUsers(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("users import", flag.ExitOnError)
+	var req dto.ImportUsersDTO
+	fs.StringVar(&req.Format, "format", "csv", "csv or ndjson")
+	fs.BoolVar(&req.DryRun, "dry-run", false, "check every row without creating users")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal(usage)
+	}
+
+	r := io.Reader(os.Stdin)
+	if path := fs.Arg(0); path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	report, err := application.ImportUsers(cfg, r, req)
+	if err != nil && report.Rows == 0 {
+		return err
+	}
+	// an import that stopped half-way has created users; show which
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if encErr := encoder.Encode(report); encErr != nil {
+		return encErr
+	}
+	return err
+}
+
+// verifyAudit prints the result of checking the audit log's hash chain and
+// exits with status 1 when the chain is broken.
+func verifyAudit(cfg *config.Config, args []string) error {
+	if len(args) != 0 {
+		log.Fatal(usage)
+	}
+	result, err := application.VerifyAudit(cfg)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		return err
+	}
+	if !result.Valid {
+		os.Exit(1)
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/ilyakaznacheev/cleanenv"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configFileEnv  = "CONFIG_FILE"
+	legacyEnvFile  = "./.env"
+	secretFileSufx = "_FILE"
+	redactedValue  = "[REDACTED]"
+)
+
+type field struct {
+	env         string
+	flag        string
+	path        string
+	def         string
+	description string
+	secret      bool
+	value       reflect.Value
+}
+
+// NewConfig builds the effective configuration from, in increasing order of
+// precedence: field defaults, the config file (--config or CONFIG_FILE, falling
+// back to ./.env), environment variables including NAME_FILE secrets, and
+// command-line flags. It returns the arguments left over after flag parsing.
+func NewConfig(args []string) (*Config, []string, error) {
+	cfg := &Config{}
+	fields := collectFields(cfg)
+	args0 := args
+
+	fs := flag.NewFlagSet("auth", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv(configFileEnv), "path to a YAML, TOML, JSON or .env config file")
+	byFlag := make(map[string]field, len(fields))
+	for _, f := range fields {
+		fs.String(f.flag, f.def, fmt.Sprintf("%s (env %s)", f.description, f.env))
+		byFlag[f.flag] = f
+	}
+	// Flags may appear before or after subcommand arguments
+	// (e.g. `migrate --config app.yaml up`), so keep parsing past positionals.
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+
+	for _, f := range fields {
+		if f.def == "" {
+			continue
+		}
+		if err := setValue(f.value, f.def); err != nil {
+			return nil, nil, fmt.Errorf("invalid default for %s: %w", f.env, err)
+		}
+	}
+
+	if *path != "" {
+		if err := cleanenv.ReadConfig(*path, cfg); err != nil {
+			return nil, nil, fmt.Errorf("failed to read config file %s: %w", *path, err)
+		}
+	} else {
+		parseConfigFiles([]string{legacyEnvFile}, cfg)
+		if err := cleanenv.ReadEnv(cfg); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := applySecretFiles(fields); err != nil {
+		return nil, nil, err
+	}
+
+	var flagErr error
+	fs.Visit(func(fl *flag.Flag) {
+		f, ok := byFlag[fl.Name]
+		if !ok || flagErr != nil {
+			return
+		}
+		if err := setValue(f.value, fl.Value.String()); err != nil {
+			flagErr = fmt.Errorf("invalid value for --%s: %w", f.flag, err)
+		}
+	})
+	if flagErr != nil {
+		return nil, nil, flagErr
+	}
+
+	if err := validateConfig(cfg); err != nil {
+		return nil, nil, err
+	}
+
+	cfg.args = args0
+	return cfg, rest, nil
+}
+
+// Reload builds a fresh configuration from the same sources and flags the
+// current one was loaded from.
+func (c *Config) Reload() (*Config, error) {
+	cfg, _, err := NewConfig(c.args)
+	return cfg, err
+}
+
+// Print writes the effective configuration as YAML with secrets redacted.
+func Print(w io.Writer, cfg *Config) error {
+	redacted := *cfg
+	for _, f := range collectFields(&redacted) {
+		if !f.secret {
+			continue
+		}
+		switch {
+		case f.value.Kind() == reflect.String && f.value.String() != "":
+			f.value.SetString(redactedValue)
+		case f.value.Kind() == reflect.Slice && f.value.Len() > 0:
+			// replace rather than overwrite in place: the copy shares the slice with cfg
+			f.value.Set(reflect.ValueOf([]string{redactedValue}))
+		}
+	}
+
+	out, err := yaml.Marshal(&redacted)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+func parseConfigFiles(files []string, cfg *Config) {
+	for _, path := range files {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		err := cleanenv.ReadConfig(path, cfg)
+		if err != nil {
+			log.Printf("WARN: config error: %v\n", err)
+		}
+	}
+}
+
+// applySecretFiles lets secrets be mounted as files: NAME_FILE=/run/secrets/x
+// sets NAME to the file contents.
+func applySecretFiles(fields []field) error {
+	for _, f := range fields {
+		path, ok := os.LookupEnv(f.env + secretFileSufx)
+		if !ok || path == "" {
+			continue
+		}
+		if _, set := os.LookupEnv(f.env); set {
+			return fmt.Errorf("both %s and %s%s are set", f.env, f.env, secretFileSufx)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s%s: %w", f.env, secretFileSufx, err)
+		}
+		if err := setValue(f.value, strings.TrimRight(string(content), "\r\n")); err != nil {
+			return fmt.Errorf("invalid value in %s%s: %w", f.env, secretFileSufx, err)
+		}
+	}
+	return nil
+}
+
+func collectFields(cfg any) []field {
+	var fields []field
+	var walk func(v reflect.Value, path, prefix string)
+	walk = func(v reflect.Value, path, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			fv := v.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			if fv.Kind() == reflect.Struct {
+				walk(fv, path+"."+sf.Name, prefix+sf.Tag.Get("env-prefix"))
+				continue
+			}
+			env := sf.Tag.Get("env")
+			if env == "" {
+				continue
+			}
+			env = prefix + env
+			fields = append(fields, field{
+				env:         env,
+				flag:        strings.ReplaceAll(strings.ToLower(env), "_", "-"),
+				path:        path + "." + sf.Name,
+				def:         sf.Tag.Get("default"),
+				description: sf.Tag.Get("env-description"),
+				secret:      sf.Tag.Get("secret") == "true",
+				value:       fv,
+			})
+		}
+	}
+	v := reflect.ValueOf(cfg).Elem()
+	walk(v, v.Type().Name(), "")
+	return fields
+}
+
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported slice type %s", v.Type())
+		}
+		parts := make([]string, 0)
+		for _, p := range strings.Split(raw, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				parts = append(parts, p)
+			}
+		}
+		v.Set(reflect.ValueOf(parts))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+var configValidator = func() *validator.Validate {
+	v := validator.New()
+	v.RegisterStructValidation(validateTestPhones, TestPhones{})
+	return v
+}()
+
+func validateConfig(cfg *Config) error {
+	err := configValidator.Struct(cfg)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+
+	envByPath := make(map[string]string)
+	for _, f := range collectFields(cfg) {
+		envByPath[f.path] = f.env
+	}
+
+	msgs := make([]string, 0, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		// list items (Config.OTP.Channels[1]) are reported under the list's name
+		namespace, _, _ := strings.Cut(fe.StructNamespace(), "[")
+		name, ok := envByPath[namespace]
+		if !ok {
+			name = fe.StructNamespace()
+		}
+		msgs = append(msgs, validationMessage(name, fe))
+	}
+	return fmt.Errorf("invalid configuration: %s", strings.Join(msgs, "; "))
+}
+
+func validationMessage(name string, fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", name)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of [%s], got %q", name, fe.Param(), fe.Value())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", name, fe.Param())
+	case "min", "gte":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("%s must be at least %s characters long", name, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s", name, fe.Param())
+	case "max", "lte":
+		return fmt.Sprintf("%s must be at most %s", name, fe.Param())
+	case "required_with":
+		return fmt.Sprintf("%s is required when %s is set", name, strings.ReplaceAll(fe.Param(), " ", " or "))
+	case "contains":
+		return fmt.Sprintf("%s must contain %s", name, fe.Param())
+	case "len":
+		return fmt.Sprintf("%s must be exactly %s characters long", name, fe.Param())
+	case "fqdn":
+		return fmt.Sprintf("%s must be a domain name, got %q", name, fe.Value())
+	case "alphanum":
+		return fmt.Sprintf("%s must contain only letters and digits", name)
+	case "numeric":
+		return fmt.Sprintf("%s must be numeric, got %q", name, fe.Value())
+	default:
+		return fmt.Sprintf("%s failed the %q check", name, fe.Tag())
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Runtime holds the settings that can change without a restart.
+type Runtime struct {
+	LogLevel string `json:"log_level"`
+	OTP      OTP    `json:"otp"`
+}
+
+// Settings holds the current Runtime and swaps it atomically on Reload, which
+// is triggered by SIGHUP or the admin API. Settings outside Runtime (ports,
+// DSNs, secrets) still require a restart.
+type Settings struct {
+	cfg       *Config
+	current   atomic.Pointer[Runtime]
+	mu        sync.Mutex
+	listeners []func(Runtime)
+}
+
+func NewSettings(cfg *Config) *Settings {
+	s := &Settings{cfg: cfg}
+	rt := runtimeOf(cfg)
+	s.current.Store(&rt)
+	return s
+}
+
+func (s *Settings) Get() Runtime {
+	return *s.current.Load()
+}
+
+// OnReload registers fn to be called with the new Runtime after every reload.
+func (s *Settings) OnReload(fn func(Runtime)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Reload re-reads the configuration sources; on a validation error the
+// current settings are kept. A non-nil approve is called with the current and
+// the new settings before they are applied, and an error from it keeps the
+// current ones too.
+func (s *Settings) Reload(approve func(from, to Runtime) error) (Runtime, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg, err := s.cfg.Reload()
+	if err != nil {
+		return s.Get(), err
+	}
+
+	rt := runtimeOf(cfg)
+	if approve != nil {
+		if err := approve(s.Get(), rt); err != nil {
+			return s.Get(), err
+		}
+	}
+	s.current.Store(&rt)
+	for _, fn := range s.listeners {
+		fn(rt)
+	}
+	return rt, nil
+}
+
+func runtimeOf(cfg *Config) Runtime {
+	return Runtime{
+		LogLevel: cfg.Log.Level,
+		OTP:      cfg.OTP,
+	}
+}
@@ -0,0 +1,9 @@
+package database
+
+import "embed"
+
+// Migrations holds the SQL migration files compiled into the binary so the
+// service does not depend on the working directory it is launched from.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
@@ -0,0 +1,21 @@
+CREATE TABLE message_outbox (
+  id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  dedupe_key varchar(128) NOT NULL UNIQUE,
+  channel varchar(16) NOT NULL,
+  recipient varchar(255) NOT NULL,
+  -- cleared once the message is delivered or can no longer be retried
+  body text,
+  status varchar(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'processing', 'sent', 'dead')),
+  attempts integer NOT NULL DEFAULT 0,
+  max_attempts integer NOT NULL,
+  next_attempt_at timestamptz NOT NULL DEFAULT now(),
+  locked_until timestamptz,
+  last_error text,
+  expires_at timestamptz,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  updated_at timestamptz NOT NULL DEFAULT now(),
+  sent_at timestamptz
+);
+
+CREATE INDEX message_outbox_due_idx ON message_outbox (next_attempt_at) WHERE status IN ('pending', 'processing');
+CREATE INDEX message_outbox_status_idx ON message_outbox (status, created_at);
@@ -0,0 +1,3 @@
+ALTER TABLE message_outbox
+  DROP COLUMN IF EXISTS provider_message_id,
+  DROP COLUMN IF EXISTS provider;
@@ -0,0 +1,3 @@
+ALTER TABLE message_outbox
+  ADD COLUMN provider varchar(32),
+  ADD COLUMN provider_message_id varchar(128);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS message_outbox_provider_message_idx;
+
+ALTER TABLE message_outbox
+  DROP COLUMN IF EXISTS delivery_reported_at,
+  DROP COLUMN IF EXISTS delivery_error,
+  DROP COLUMN IF EXISTS delivery_status;
@@ -0,0 +1,8 @@
+ALTER TABLE message_outbox
+  ADD COLUMN delivery_status varchar(16),
+  ADD COLUMN delivery_error varchar(128),
+  ADD COLUMN delivery_reported_at timestamptz;
+
+-- delivery reports are matched by the provider's message ID
+CREATE INDEX message_outbox_provider_message_idx ON message_outbox (provider, provider_message_id)
+  WHERE provider_message_id IS NOT NULL;
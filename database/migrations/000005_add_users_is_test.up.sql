@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN is_test boolean NOT NULL DEFAULT false;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS users_email_key;
+
+ALTER TABLE users
+  DROP COLUMN IF EXISTS updated_at,
+  DROP COLUMN IF EXISTS avatar_url,
+  DROP COLUMN IF EXISTS timezone,
+  DROP COLUMN IF EXISTS locale,
+  DROP COLUMN IF EXISTS email,
+  DROP COLUMN IF EXISTS display_name;
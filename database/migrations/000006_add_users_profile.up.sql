@@ -0,0 +1,12 @@
+ALTER TABLE users
+  ADD COLUMN display_name varchar(64),
+  ADD COLUMN email varchar(254),
+  ADD COLUMN locale varchar(35),
+  ADD COLUMN timezone varchar(64),
+  ADD COLUMN avatar_url varchar(2048),
+  ADD COLUMN updated_at timestamptz NOT NULL DEFAULT now();
+
+UPDATE users SET updated_at = created_at;
+
+-- an email address belongs to at most one account, regardless of case
+CREATE UNIQUE INDEX users_email_key ON users (lower(email)) WHERE email IS NOT NULL;
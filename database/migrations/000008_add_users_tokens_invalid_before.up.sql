@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN tokens_invalid_before timestamptz;
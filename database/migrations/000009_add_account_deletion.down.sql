@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS message_outbox_recipient_idx;
+DROP INDEX IF EXISTS users_purge_after_idx;
+
+ALTER TABLE users
+  DROP COLUMN IF EXISTS purge_after,
+  DROP COLUMN IF EXISTS deleted_at;
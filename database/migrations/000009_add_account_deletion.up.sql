@@ -0,0 +1,8 @@
+ALTER TABLE users
+  ADD COLUMN deleted_at timestamptz,
+  ADD COLUMN purge_after timestamptz;
+
+CREATE INDEX users_purge_after_idx ON users (purge_after) WHERE deleted_at IS NOT NULL;
+
+-- exports and purges look up the messages sent to a user
+CREATE INDEX message_outbox_recipient_idx ON message_outbox (recipient);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS user_status_history;
+
+ALTER TABLE users
+  DROP COLUMN IF EXISTS suspended_until,
+  DROP COLUMN IF EXISTS status_reason,
+  DROP COLUMN IF EXISTS status;
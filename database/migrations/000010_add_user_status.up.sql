@@ -0,0 +1,17 @@
+ALTER TABLE users
+  ADD COLUMN status varchar(16) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'suspended', 'banned')),
+  ADD COLUMN status_reason text,
+  ADD COLUMN suspended_until timestamptz;
+
+CREATE TABLE user_status_history (
+  id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  user_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  status varchar(16) NOT NULL,
+  reason text,
+  suspended_until timestamptz,
+  -- the admin who made the change
+  actor_id integer REFERENCES users (id) ON DELETE SET NULL,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX user_status_history_user_idx ON user_status_history (user_id, created_at);
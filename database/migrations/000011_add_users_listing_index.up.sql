@@ -0,0 +1,2 @@
+-- keyset pagination by created_at; phone and id are already unique
+CREATE INDEX users_created_at_idx ON users (created_at, id) WHERE deleted_at IS NULL;
@@ -0,0 +1,6 @@
+-- trigram indexes let substring and prefix filters (ILIKE '%...%') use an index
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX users_phone_trgm_idx ON users USING gin (phone gin_trgm_ops);
+CREATE INDEX users_email_trgm_idx ON users USING gin (email gin_trgm_ops);
+CREATE INDEX users_display_name_trgm_idx ON users USING gin (display_name gin_trgm_ops);
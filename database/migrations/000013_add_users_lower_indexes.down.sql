@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS users_timezone_lower_idx;
+DROP INDEX IF EXISTS users_locale_lower_idx;
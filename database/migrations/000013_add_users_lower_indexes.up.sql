@@ -0,0 +1,3 @@
+-- locale and timezone filters match regardless of case
+CREATE INDEX users_locale_lower_idx ON users (lower(locale)) WHERE deleted_at IS NULL;
+CREATE INDEX users_timezone_lower_idx ON users (lower(timezone)) WHERE deleted_at IS NULL;
@@ -0,0 +1,17 @@
+CREATE TABLE auth_events (
+  id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  -- null when the phone has no account
+  user_id integer REFERENCES users (id) ON DELETE CASCADE,
+  phone varchar(20),
+  kind varchar(32) NOT NULL,
+  outcome varchar(16) NOT NULL,
+  reason text,
+  channel varchar(16),
+  device_id varchar(128),
+  ip varchar(64),
+  user_agent varchar(512),
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX auth_events_user_idx ON auth_events (user_id, id);
+CREATE INDEX auth_events_created_at_idx ON auth_events (created_at);
@@ -0,0 +1,20 @@
+-- Refresh tokens are stored so they can be used once, revoked and their reuse
+-- detected. A family is one sign-in: every refresh replaces its token with a
+-- new one in the same family.
+CREATE TABLE refresh_tokens (
+  -- the token's jti claim
+  id varchar(64) PRIMARY KEY,
+  family_id varchar(64) NOT NULL,
+  user_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  device_id varchar(128),
+  ip varchar(64),
+  user_agent varchar(512),
+  created_at timestamptz NOT NULL DEFAULT now(),
+  expires_at timestamptz NOT NULL,
+  -- set when the token was traded for a new pair
+  used_at timestamptz,
+  revoked_at timestamptz
+);
+
+CREATE INDEX refresh_tokens_user_idx ON refresh_tokens (user_id, created_at);
+CREATE INDEX refresh_tokens_family_idx ON refresh_tokens (family_id);
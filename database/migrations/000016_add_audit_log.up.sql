@@ -0,0 +1,31 @@
+CREATE TABLE audit_log (
+  -- assigned in sequence by the writer, so a missing entry leaves a gap
+  id bigint PRIMARY KEY,
+  -- no foreign key: purging a user must not rewrite their entries
+  actor_id integer,
+  action varchar(64) NOT NULL,
+  target_type varchar(32),
+  target_id varchar(64),
+  request_id varchar(128),
+  ip varchar(64),
+  -- json, not jsonb, keeps the exact text the hash was computed over
+  diff json NOT NULL,
+  created_at timestamptz NOT NULL,
+  prev_hash char(64),
+  hash char(64) NOT NULL
+);
+
+CREATE INDEX audit_log_actor_idx ON audit_log (actor_id, id);
+CREATE INDEX audit_log_target_idx ON audit_log (target_type, target_id, id);
+CREATE INDEX audit_log_request_idx ON audit_log (request_id);
+
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE OR DELETE ON audit_log
+  FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+  FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
@@ -2,27 +2,37 @@ package application
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/config"
 	_ "github.com/MostajeranMohammad/dekamond-auth-challenge/docs"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/controllers"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/guards"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/routes"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/usecases"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/logger"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/mailer"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/ratelimit"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/requestid"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/secretbox"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/sms"
 	ginzap "github.com/gin-contrib/zap"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/redis/go-redis/v9"
 	swaggerfiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 // @title Dekamond Auth Challenge API
@@ -32,7 +42,15 @@ import (
 // @in							header
 // @name						Authorization
 func Run(cfg *config.Config) {
-	l, zapLogger := logger.New(cfg.Log.Level)
+	settings := config.NewSettings(cfg)
+	logLevel := zap.NewAtomicLevelAt(logger.ParseLevel(cfg.Log.Level))
+	l, zapLogger := logger.New(logLevel)
+	settings.OnReload(func(rt config.Runtime) {
+		logLevel.SetLevel(logger.ParseLevel(rt.LogLevel))
+	})
+	watchReloadSignal(settings, l)
+
+	logTestPhones(cfg, l)
 
 	db, err := pgxpool.New(context.Background(), cfg.PG.DSN)
 	if err != nil {
@@ -44,35 +62,27 @@ func Run(cfg *config.Config) {
 		l.Fatal("Failed to ping the database:", err)
 	}
 
-	if cfg.PG.RunMigrations {
-		func() {
-			sqlDB := stdlib.OpenDB(*db.Config().ConnConfig)
-			defer sqlDB.Close()
-
-			driver, err := postgres.WithInstance(sqlDB, &postgres.Config{})
-			if err != nil {
-				l.Fatal("Failed to create driver:", err)
-			}
-			defer driver.Close()
-
-			m, err := migrate.NewWithDatabaseInstance(
-				"file://database/migrations",
-				"postgres",
-				driver,
-			)
-			if err != nil {
-				l.Fatal("Failed to create migrations", err)
-			}
+	func() {
+		m, closeMigrator, err := newMigrator(db)
+		if err != nil {
+			l.Fatal(err)
+		}
+		defer closeMigrator()
 
+		if cfg.PG.RunMigrations {
 			err = m.Up()
 			if err != nil {
-				if err.Error() != "no change" {
+				if !errors.Is(err, migrate.ErrNoChange) {
 					l.Fatal("Failed to run migrations:", err)
 				}
 				l.Info("No migrations to run")
 			}
-		}()
-	}
+		}
+
+		if err := ensureSchemaClean(m); err != nil {
+			l.Fatal(err)
+		}
+	}()
 
 	redisDB := redis.NewClient(&redis.Options{
 		Addr:     cfg.Redis.Addr,
@@ -85,28 +95,170 @@ func Run(cfg *config.Config) {
 	}
 
 	userRepository := repositories.NewUserRepository(db)
+	outboxRepository := repositories.NewOutboxRepository(db)
+	authEventRepository := repositories.NewAuthEventRepository(db)
+	auditRepository := repositories.NewAuditRepository(db)
+	refreshTokenRepository := repositories.NewRefreshTokenRepository(db)
 
+	if cfg.Audit.Key == "" {
+		l.Warn("AUDIT_LOG_KEY is not set: the audit log is chained but anyone who can write to the database can rehash it")
+	}
+	auditUsecase := usecases.NewAuditUsecase(auditRepository, cfg.Audit.Key, l)
 	jwtUsecase := usecases.NewJwtUsecase(cfg.AUTH.JwtSecret)
-	otpUsecase := usecases.NewOtpUsecase(redisDB, l)
-	authUsecase := usecases.NewAuthUsecase(userRepository, jwtUsecase, cfg, otpUsecase)
+	rateLimitStore := ratelimit.NewRedisStore(redisDB)
+	senders := make(map[string]usecases.MessageSender, len(entities.Channels))
+	for _, channel := range entities.Channels {
+		senders[channel] = usecases.NewConsoleSender(l, channel)
+	}
+	smsRouter, err := newSmsRouter(cfg.SMS, l)
+	if err != nil {
+		l.Fatal(err)
+	}
+	senders[entities.ChannelSMS] = usecases.NewSmsSender(smsRouter)
+	mail, err := mailer.New(cfg.Mail.URL, cfg.Mail.From, l)
+	if err != nil {
+		l.Fatal(err)
+	}
+	senders[entities.ChannelEmail] = usecases.NewEmailSender(mail)
+	outboxBox, err := secretbox.New(cfg.Outbox.BodyKey)
+	if err != nil {
+		l.Fatal(err)
+	}
+	outboxUsecase := usecases.NewOutboxUsecase(outboxRepository, l, cfg.Outbox, senders, outboxBox)
+	go outboxUsecase.Run(context.Background())
+	otpUsecase := usecases.NewOtpUsecase(redisDB, l, settings, ratelimit.NewLimiter(rateLimitStore, "otp:ratelimit:"), outboxUsecase, cfg.AUTH.OtpPepper)
+	activityUsecase := usecases.NewActivityUsecase(authEventRepository, cfg.AuthEvents, l)
+	go activityUsecase.RunPurge(context.Background())
+	authUsecase := usecases.NewAuthUsecase(userRepository, refreshTokenRepository, jwtUsecase, cfg, otpUsecase, activityUsecase)
 	usersService := usecases.NewUsersService(userRepository)
+	userTransferUsecase := usecases.NewUserTransferUsecase(userRepository)
+	phoneChangeUsecase := usecases.NewPhoneChangeUsecase(userRepository, refreshTokenRepository, otpUsecase, jwtUsecase)
+	accountUsecase := usecases.NewAccountUsecase(userRepository, outboxRepository, authEventRepository, auditRepository, refreshTokenRepository, otpUsecase, cfg.AccountDeletion, l)
+	go accountUsecase.RunPurge(context.Background())
+	emailVerificationUsecase := usecases.NewEmailVerificationUsecase(userRepository, mail, ratelimit.NewLimiter(rateLimitStore, "email:ratelimit:"), settings, cfg, l)
 
 	authController := controllers.NewAuthController(l, authUsecase)
-	usersController := controllers.NewUsersController(l, usersService)
+	usersController := controllers.NewUsersController(l, usersService, emailVerificationUsecase, phoneChangeUsecase, accountUsecase, activityUsecase, auditUsecase)
+	adminController := controllers.NewAdminController(l, settings, outboxUsecase, otpUsecase, usersService, userTransferUsecase, activityUsecase, auditUsecase)
+	webhookVerifier, err := newWebhookVerifier(cfg.SMS)
+	if err != nil {
+		l.Fatal(err)
+	}
+	webhookController := controllers.NewWebhookController(l, webhookVerifier, outboxUsecase)
 
-	authGuard := guards.NewAuthGuard(authUsecase)
+	authGuard := guards.NewAuthGuard(authUsecase, cfg.AUTH.AdminPhones)
 
-	ginApp := gin.New()
-	ginApp.Use(ginzap.Ginzap(zapLogger, time.RFC3339, true))
+	ginApp, err := newEngine(cfg.HTTP)
+	if err != nil {
+		l.Fatal(err)
+	}
+	ginApp.Use(requestid.Middleware())
+	ginApp.Use(ginzap.GinzapWithConfig(zapLogger, &ginzap.Config{
+		TimeFormat:   time.RFC3339,
+		UTC:          true,
+		DefaultLevel: zapcore.InfoLevel,
+		Context: func(c *gin.Context) []zapcore.Field {
+			return []zapcore.Field{zap.String("request_id", requestid.FromContext(c.Request.Context()))}
+		},
+	}))
 
 	v1 := ginApp.Group("/api/v1")
 
-	routes.RegisterAuthV1Router(v1, authController, authGuard)
+	authRateLimit := ratelimit.Middleware(ratelimit.NewLimiter(rateLimitStore, "http:ratelimit:auth:"), func(c *gin.Context) []ratelimit.Rule {
+		otpSettings := settings.Get().OTP
+		return []ratelimit.Rule{{
+			Limit: ratelimit.Limit{
+				Name:      "ip",
+				Rate:      otpSettings.RateLimits.HTTPPerIP,
+				Period:    otpSettings.RateWindow,
+				Algorithm: ratelimit.Algorithm(otpSettings.RateLimits.Algorithm),
+			},
+			Key: c.ClientIP(),
+		}}
+	})
+
+	routes.RegisterAuthV1Router(v1, authController, authGuard, authRateLimit)
 	routes.RegisterUserV1Router(v1, usersController, authGuard)
+	routes.RegisterAdminV1Router(v1, adminController, authGuard)
+	routes.RegisterWebhookV1Router(v1, webhookController)
 	ginApp.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 
 	if err := ginApp.Run(":" + cfg.HTTP.Port); err != nil {
 		l.Fatal("ginApp.Run failed", err)
 	}
+}
+
+func logTestPhones(cfg *config.Config, l logger.Logger) {
+	configured := len(cfg.TestPhones.Numbers) + len(cfg.TestPhones.Prefixes)
+	if configured == 0 {
+		return
+	}
+	if active := cfg.ActiveTestPhones(); active.OTP == "" {
+		l.Info("test phones are configured but ignored in production (set TEST_PHONES_IN_PRODUCTION to enable them)")
+		return
+	}
+	l.Warn("test phones enabled in %s: %d numbers and %d prefixes get a fixed OTP without SMS or rate limits",
+		cfg.App.Env, len(cfg.TestPhones.Numbers), len(cfg.TestPhones.Prefixes))
+}
 
+func newSmsRouter(cfg config.SMS, l logger.Logger) (*sms.Router, error) {
+	providers := make([]sms.Provider, 0, len(cfg.Providers))
+	for _, spec := range cfg.Providers {
+		provider, err := sms.ParseProvider(spec, l)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+	smsRoutes := make([]sms.Route, 0, len(cfg.Routes))
+	for _, spec := range cfg.Routes {
+		route, err := sms.ParseRoute(spec)
+		if err != nil {
+			return nil, err
+		}
+		smsRoutes = append(smsRoutes, route)
+	}
+	return sms.NewRouter(providers, smsRoutes, sms.RouterOptions{
+		BreakerFailures: cfg.BreakerFailures,
+		BreakerCooldown: cfg.BreakerCooldown,
+		AttemptTimeout:  cfg.ProviderTimeout,
+	})
+}
+
+func newWebhookVerifier(cfg config.SMS) (*sms.WebhookVerifier, error) {
+	secrets := make(map[string]string, len(cfg.WebhookSecrets))
+	for _, spec := range cfg.WebhookSecrets {
+		provider, secret, err := sms.ParseWebhookSecret(spec)
+		if err != nil {
+			return nil, err
+		}
+		secrets[provider] = secret
+	}
+	return sms.NewWebhookVerifier(secrets, cfg.WebhookTolerance), nil
+}
+
+// newEngine trusts X-Forwarded-For only from the configured proxies, so
+// clients cannot pick the IP that rate limits and audit entries see.
+func newEngine(cfg config.HTTP) (*gin.Engine, error) {
+	engine := gin.New()
+	if err := engine.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid HTTP_TRUSTED_PROXIES: %w", err)
+	}
+	engine.TrustedPlatform = cfg.TrustedPlatform
+	return engine, nil
+}
+
+// watchReloadSignal reloads runtime settings whenever the process receives SIGHUP.
+func watchReloadSignal(settings *config.Settings, l logger.Logger) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			if _, err := settings.Reload(nil); err != nil {
+				l.Error("settings reload on SIGHUP failed, keeping current settings: %v", err)
+				continue
+			}
+			l.Info("settings reloaded on SIGHUP")
+		}
+	}()
 }
@@ -0,0 +1,65 @@
+package application
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/config"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/ratelimit"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEngine_ClientIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	request := func(engine *gin.Engine, remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+	newLimited := func(cfg config.HTTP) *gin.Engine {
+		engine, err := newEngine(cfg)
+		require.NoError(t, err)
+		limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), "test:")
+		engine.Use(ratelimit.Middleware(limiter, func(c *gin.Context) []ratelimit.Rule {
+			return []ratelimit.Rule{{
+				Limit: ratelimit.Limit{Name: "ip", Rate: 1, Period: time.Minute, Algorithm: ratelimit.GCRA},
+				Key:   c.ClientIP(),
+			}}
+		}))
+		engine.GET("/", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+		return engine
+	}
+
+	t.Run("forged X-Forwarded-For does not change the limiter key", func(t *testing.T) {
+		engine := newLimited(config.HTTP{})
+
+		first := request(engine, "203.0.113.7:1234", "198.51.100.1")
+		assert.Equal(t, http.StatusOK, first.Code)
+		assert.Equal(t, "203.0.113.7", first.Body.String())
+		assert.Equal(t, http.StatusTooManyRequests, request(engine, "203.0.113.7:1234", "198.51.100.2").Code)
+	})
+
+	t.Run("trusted proxies forward the client IP", func(t *testing.T) {
+		engine := newLimited(config.HTTP{TrustedProxies: []string{"10.0.0.0/8"}})
+
+		first := request(engine, "10.0.0.2:1234", "198.51.100.1")
+		assert.Equal(t, http.StatusOK, first.Code)
+		assert.Equal(t, "198.51.100.1", first.Body.String())
+		assert.Equal(t, http.StatusOK, request(engine, "10.0.0.2:1234", "198.51.100.2").Code)
+		assert.Equal(t, http.StatusTooManyRequests, request(engine, "10.0.0.3:1234", "198.51.100.1").Code)
+	})
+
+	t.Run("invalid proxy", func(t *testing.T) {
+		_, err := newEngine(config.HTTP{TrustedProxies: []string{"not-an-ip"}})
+		assert.ErrorContains(t, err, "HTTP_TRUSTED_PROXIES")
+	})
+}
@@ -0,0 +1,74 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/config"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/usecases"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/logger"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// ExportUsers runs `authctl users export`. Like exports through the admin
+// API it is audited, with no actor, before any user is written out.
+func ExportUsers(cfg *config.Config, w io.Writer, req dto.ExportUsersDTO) error {
+	db, err := pgxpool.New(context.Background(), cfg.PG.DSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = cliAudit(cfg, db).Record(context.Background(), entities.AuditEntry{
+		Action:     entities.AuditUsersExport,
+		TargetType: "users",
+	}, map[string]any{"via": "authctl", "format": req.Format, "filter": req.Filter})
+	if err != nil {
+		return fmt.Errorf("record audit entry: %w", err)
+	}
+	transfer := usecases.NewUserTransferUsecase(repositories.NewUserRepository(db))
+	return transfer.Export(context.Background(), w, req)
+}
+
+// ImportUsers runs `authctl users import`. Like imports through the admin API
+// it is audited, with no actor, before the first user is created.
+func ImportUsers(cfg *config.Config, r io.Reader, req dto.ImportUsersDTO) (entities.UserImportReport, error) {
+	db, err := pgxpool.New(context.Background(), cfg.PG.DSN)
+	if err != nil {
+		return entities.UserImportReport{}, err
+	}
+	defer db.Close()
+
+	if !req.DryRun {
+		err = cliAudit(cfg, db).Record(context.Background(), entities.AuditEntry{
+			Action:     entities.AuditUsersImport,
+			TargetType: "users",
+		}, map[string]any{"via": "authctl", "format": req.Format})
+		if err != nil {
+			return entities.UserImportReport{}, fmt.Errorf("record audit entry: %w", err)
+		}
+	}
+	transfer := usecases.NewUserTransferUsecase(repositories.NewUserRepository(db))
+	return transfer.Import(context.Background(), r, req)
+}
+
+func cliAudit(cfg *config.Config, db *pgxpool.Pool) usecases.AuditUsecase {
+	l, _ := logger.New(zap.NewAtomicLevelAt(zap.ErrorLevel))
+	return usecases.NewAuditUsecase(repositories.NewAuditRepository(db), cfg.Audit.Key, l)
+}
+
+// VerifyAudit runs `authctl audit verify`.
+func VerifyAudit(cfg *config.Config) (entities.AuditVerification, error) {
+	db, err := pgxpool.New(context.Background(), cfg.PG.DSN)
+	if err != nil {
+		return entities.AuditVerification{}, err
+	}
+	defer db.Close()
+
+	return cliAudit(cfg, db).Verify(context.Background())
+}
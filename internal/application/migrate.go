@@ -0,0 +1,150 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/config"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/database"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// migrationLockTimeout bounds how long a replica waits on the postgres
+// advisory lock held by another replica that is applying migrations.
+const migrationLockTimeout = 5 * time.Minute
+
+const migrateUsage = "usage: migrate up [N] | down N | force VERSION | version"
+
+// newMigrator builds a migrate instance backed by the embedded migration files.
+// The postgres driver serializes Up/Down/Steps/Force across processes with
+// pg_advisory_lock, so concurrent replicas never apply the same migration twice.
+func newMigrator(db *pgxpool.Pool) (*migrate.Migrate, func(), error) {
+	sqlDB := stdlib.OpenDB(*db.Config().ConnConfig)
+
+	driver, err := postgres.WithInstance(sqlDB, &postgres.Config{})
+	if err != nil {
+		sqlDB.Close()
+		return nil, nil, fmt.Errorf("failed to create migration driver: %w", err)
+	}
+
+	source, err := iofs.New(database.Migrations, "migrations")
+	if err != nil {
+		driver.Close()
+		sqlDB.Close()
+		return nil, nil, fmt.Errorf("failed to open embedded migrations: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+	if err != nil {
+		source.Close()
+		driver.Close()
+		sqlDB.Close()
+		return nil, nil, fmt.Errorf("failed to create migrator: %w", err)
+	}
+	m.LockTimeout = migrationLockTimeout
+
+	return m, func() {
+		m.Close()
+		sqlDB.Close()
+	}, nil
+}
+
+// ensureSchemaClean refuses to continue when a previous migration failed
+// half-way, since serving traffic against a partially migrated schema is unsafe.
+func ensureSchemaClean(m *migrate.Migrate) error {
+	version, dirty, err := m.Version()
+	if err != nil {
+		if errors.Is(err, migrate.ErrNilVersion) {
+			return nil
+		}
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("database schema is dirty at version %d; fix it manually and run `migrate force %d`", version, version)
+	}
+	return nil
+}
+
+// Migrate runs the migrate subcommand: up [N], down N, force VERSION or version.
+func Migrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	db, err := pgxpool.New(context.Background(), cfg.PG.DSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m, closeMigrator, err := newMigrator(db)
+	if err != nil {
+		return err
+	}
+	defer closeMigrator()
+
+	switch args[0] {
+	case "up":
+		if len(args) > 1 {
+			n, stepsErr := parseSteps(args[1])
+			if stepsErr != nil {
+				return stepsErr
+			}
+			err = m.Steps(n)
+		} else {
+			err = m.Up()
+		}
+	case "down":
+		if len(args) < 2 {
+			return errors.New("migrate down requires the number of steps to roll back")
+		}
+		n, stepsErr := parseSteps(args[1])
+		if stepsErr != nil {
+			return stepsErr
+		}
+		err = m.Steps(-n)
+	case "force":
+		if len(args) < 2 {
+			return errors.New("migrate force requires a version")
+		}
+		v, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		err = m.Force(v)
+	case "version":
+		version, dirty, versionErr := m.Version()
+		if errors.Is(versionErr, migrate.ErrNilVersion) {
+			fmt.Println("no migrations applied")
+			return nil
+		}
+		if versionErr != nil {
+			return versionErr
+		}
+		fmt.Printf("version %d (dirty: %t)\n", version, dirty)
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	if errors.Is(err, migrate.ErrNoChange) {
+		fmt.Println("no change")
+		return nil
+	}
+	return err
+}
+
+func parseSteps(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid number of steps %q", s)
+	}
+	return n, nil
+}
@@ -0,0 +1,548 @@
+package controllers
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/config"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/usecases"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/logger"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/pagination"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+)
+
+type adminController struct {
+	logger   logger.Logger
+	settings *config.Settings
+	outbox   usecases.OutboxUsecase
+	otp      usecases.OtpUsecase
+	users    usecases.UsersService
+	transfer usecases.UserTransferUsecase
+	activity usecases.ActivityUsecase
+	audit    usecases.AuditUsecase
+}
+
+// maxImportBodySize caps the size of an uploaded import file.
+const maxImportBodySize = 32 << 20
+
+func NewAdminController(logger logger.Logger, settings *config.Settings, outbox usecases.OutboxUsecase, otp usecases.OtpUsecase, users usecases.UsersService, transfer usecases.UserTransferUsecase, activity usecases.ActivityUsecase, audit usecases.AuditUsecase) AdminController {
+	return &adminController{
+		logger:   logger,
+		settings: settings,
+		outbox:   outbox,
+		otp:      otp,
+		users:    users,
+		transfer: transfer,
+		activity: activity,
+		audit:    audit,
+	}
+}
+
+// @Summary		Get runtime settings
+// @Description	Returns the hot-reloadable settings currently in effect
+// @Tags			Admin
+// @Produce		json
+// @Success		200
+// @Failure		401
+// @Failure		403
+// @Router			/api/v1/admin/settings [get]
+// @Security		BearerAuth
+func (ac *adminController) GetSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, ac.settings.Get())
+}
+
+// @Summary		Reload runtime settings
+// @Description	Re-reads the config file and environment and applies log level and OTP settings without a restart.
+// @Description	The change is recorded in the audit log before it is applied and refused when it cannot be.
+// @Tags			Admin
+// @Produce		json
+// @Success		200
+// @Failure		401
+// @Failure		403
+// @Failure		422
+// @Router			/api/v1/admin/settings/reload [post]
+// @Security		BearerAuth
+func (ac *adminController) ReloadSettings(c *gin.Context) {
+	var auditErr error
+	rt, err := ac.settings.Reload(func(from, to config.Runtime) error {
+		auditErr = recordAudit(c, ac.audit, entities.AuditSettingsReload, "settings", "", auditChange{From: from, To: to})
+		return auditErr
+	})
+	if auditErr != nil {
+		auditUnavailable(c)
+		return
+	}
+	if err != nil {
+		ac.logger.Warn("settings reload rejected: %v", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	ac.logger.Info("settings reloaded via admin API")
+	c.JSON(http.StatusOK, rt)
+}
+
+// @Summary		List outbox messages
+// @Description	Lists queued, delivered and dead-lettered messages, newest first. Message bodies are never returned.
+// @Description	Each listing is recorded in the audit log first and refused when it cannot be.
+// @Tags			Admin
+// @Produce		json
+// @Param			status	query	string	false	"pending, processing, sent or dead"
+// @Param			limit	query	int		false	"Page size"	default(50)
+// @Success		200
+// @Failure		400
+// @Failure		401
+// @Failure		403
+// @Router			/api/v1/admin/outbox [get]
+// @Security		BearerAuth
+func (ac *adminController) ListOutboxMessages(c *gin.Context) {
+	status := entities.OutboxStatus(c.Query("status"))
+	switch status {
+	case "", entities.OutboxStatusPending, entities.OutboxStatusProcessing, entities.OutboxStatusSent, entities.OutboxStatusDead:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of pending, processing, sent, dead"})
+		return
+	}
+	limit64, _ := strconv.ParseUint(c.DefaultQuery("limit", "50"), 10, 32)
+	limit := min(max(uint32(limit64), 1), 500)
+
+	// recipients are personal data
+	if err := recordAudit(c, ac.audit, entities.AuditOutboxList, "outbox", "", gin.H{"status": status, "limit": limit}); err != nil {
+		auditUnavailable(c)
+		return
+	}
+	messages, err := ac.outbox.ListMessages(c.Request.Context(), status, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, messages)
+}
+
+// @Summary		Outbox statistics
+// @Description	Returns message counts per status, messages stuck with an expired worker lease and the oldest undelivered message
+// @Tags			Admin
+// @Produce		json
+// @Success		200
+// @Failure		401
+// @Failure		403
+// @Router			/api/v1/admin/outbox/stats [get]
+// @Security		BearerAuth
+func (ac *adminController) GetOutboxStats(c *gin.Context) {
+	stats, err := ac.outbox.Stats(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// @Summary		Retry a dead-lettered message
+// @Description	Queues a dead-lettered message again with a fresh attempt budget, as long as it has not expired.
+// @Description	The retry is recorded in the audit log first and refused when it cannot be.
+// @Tags			Admin
+// @Produce		json
+// @Param			id	path	int	true	"Message ID"
+// @Success		200
+// @Failure		400
+// @Failure		401
+// @Failure		403
+// @Failure		404
+// @Router			/api/v1/admin/outbox/{id}/retry [post]
+// @Security		BearerAuth
+func (ac *adminController) RetryOutboxMessage(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message id"})
+		return
+	}
+
+	if err := recordAudit(c, ac.audit, entities.AuditOutboxRetry, "outbox_message", c.Param("id"), nil); err != nil {
+		auditUnavailable(c)
+		return
+	}
+	err = ac.outbox.Retry(c.Request.Context(), id)
+	if errors.Is(err, usecases.ErrOutboxMessageNotRetryable) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "message requeued"})
+}
+
+// @Summary		OTP deliveries
+// @Description	Lists the messages sent for an OTP challenge, including resends, with the provider that sent each one
+// @Description	and its latest delivery report, for support staff answering "I never got the code". Each lookup is recorded in the
+// @Description	audit log first and refused when it cannot be.
+// @Tags			Admin
+// @Produce		json
+// @Param			challengeId	path	string	true	"OTP challenge ID"
+// @Success		200
+// @Failure		401
+// @Failure		403
+// @Failure		404
+// @Router			/api/v1/admin/otp/{challengeId}/deliveries [get]
+// @Security		BearerAuth
+func (ac *adminController) GetOtpDeliveries(c *gin.Context) {
+	if err := recordAudit(c, ac.audit, entities.AuditOtpDeliveriesView, "otp_challenge", c.Param("challengeId"), nil); err != nil {
+		auditUnavailable(c)
+		return
+	}
+	messages, err := ac.otp.Deliveries(c.Request.Context(), c.Param("challengeId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(messages) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no messages for this challenge"})
+		return
+	}
+	c.JSON(http.StatusOK, messages)
+}
+
+// @Summary		Set user status
+// @Description	Suspends a user until suspended_until, bans them or makes them active again. Suspended and banned users
+// @Description	cannot request or verify login codes and their tokens are refused. Every change is kept in the status history.
+// @Description	A valid change is recorded in the audit log before it is made and refused when it cannot be.
+// @Tags			Admin
+// @Accept			json
+// @Produce		json
+// @Param			id		path	int					true	"User ID"
+// @Param			body	body	dto.SetUserStatusDTO	true	"New status"
+// @Success		200
+// @Failure		400
+// @Failure		401
+// @Failure		403
+// @Failure		404
+// @Router			/api/v1/admin/users/{id}/status [put]
+// @Security		BearerAuth
+func (ac *adminController) SetUserStatus(c *gin.Context) {
+	admin, ok := c.MustGet("user").(entities.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user from context"})
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	var body dto.SetUserStatusDTO
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, err := ac.users.GetUser(c.Request.Context(), uint32(id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the change is checked and recorded before it is made, so a status
+	// change never goes through without its audit entry
+	err = ac.users.CheckStatusChange(admin, uint32(id), body)
+	var validationErrs validator.ValidationErrors
+	if errors.Is(err, usecases.ErrCannotChangeOwnStatus) || errors.Is(err, usecases.ErrSuspensionInPast) || errors.As(err, &validationErrs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = recordAudit(c, ac.audit, entities.AuditUserStatusChange, "user", c.Param("id"), gin.H{
+		"status":          auditChange{From: before.Status, To: body.Status},
+		"suspended_until": auditChange{From: before.SuspendedUntil, To: body.SuspendedUntil},
+		"reason":          strings.TrimSpace(body.Reason),
+	})
+	if err != nil {
+		auditUnavailable(c)
+		return
+	}
+
+	user, err := ac.users.SetStatus(c.Request.Context(), admin, uint32(id), body)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		ac.logger.Info("admin %d set user %d to %s", admin.Id, user.Id, user.Status)
+		c.JSON(http.StatusOK, userResponse(user))
+	}
+}
+
+// @Summary		User status history
+// @Description	Lists a user's status changes with reason and acting admin, newest first. Each lookup is recorded in the
+// @Description	audit log first and refused when it cannot be.
+// @Tags			Admin
+// @Produce		json
+// @Param			id	path	int	true	"User ID"
+// @Success		200
+// @Failure		400
+// @Failure		401
+// @Failure		403
+// @Router			/api/v1/admin/users/{id}/status-history [get]
+// @Security		BearerAuth
+func (ac *adminController) GetUserStatusHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	if err := recordAudit(c, ac.audit, entities.AuditUserStatusHistoryView, "user", c.Param("id"), nil); err != nil {
+		auditUnavailable(c)
+		return
+	}
+	history, err := ac.users.StatusHistory(c.Request.Context(), uint32(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// @Summary		User login history
+// @Description	Lists a user's OTP requests, code verifications and token refreshes, newest first, with outcome, IP address and user agent.
+// @Description	Each lookup is recorded in the audit log first and refused when it cannot be.
+// @Tags			Admin
+// @Produce		json
+// @Param			id		path	int		true	"User ID"
+// @Param			kind	query	string	false	"Event kind"	Enums(otp_request, otp_verify, token_refresh)
+// @Param			limit	query	int		false	"Page size, at most 100"	default(20)
+// @Param			cursor	query	string	false	"next_cursor of the previous page"
+// @Success		200
+// @Failure		400
+// @Failure		401
+// @Failure		403
+// @Router			/api/v1/admin/users/{id}/activity [get]
+// @Security		BearerAuth
+func (ac *adminController) GetUserActivity(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	if err := recordAudit(c, ac.audit, entities.AuditUserActivityView, "user", c.Param("id"), gin.H{"kind": c.Query("kind")}); err != nil {
+		auditUnavailable(c)
+		return
+	}
+	listActivity(c, ac.activity, uint32(id))
+}
+
+// @Summary		Export users
+// @Description	Streams every user matching the filter as CSV with a header row or as NDJSON, ordered by id.
+// @Description	The export is recorded in the audit log before the first row and refused when it cannot be.
+// @Description	Takes the same filter, search, created_from and created_to parameters as the users listing.
+// @Tags			Admin
+// @Produce		text/csv
+// @Produce		application/x-ndjson
+// @Param			format			query	string	false	"csv or ndjson"	default(csv)
+// @Param			filter			query	string	false	"Filter expression, e.g. status:active created_at>2025-01-01"
+// @Param			search			query	string	false	"Search by phone number"
+// @Param			created_from	query	string	false	"Created from (RFC3339)"
+// @Param			created_to		query	string	false	"Created to (RFC3339)"
+// @Success		200
+// @Failure		400
+// @Failure		401
+// @Failure		403
+// @Router			/api/v1/admin/users/export [get]
+// @Security		BearerAuth
+func (ac *adminController) ExportUsers(c *gin.Context) {
+	admin, ok := c.MustGet("user").(entities.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user from context"})
+		return
+	}
+	var req dto.ExportUsersDTO
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	contentType, ext := "text/csv; charset=utf-8", "csv"
+	if req.Format == "ndjson" {
+		contentType, ext = "application/x-ndjson", "ndjson"
+	}
+	// recorded up front: once rows are streamed the export cannot be undone
+	err := recordAudit(c, ac.audit, entities.AuditUsersExport, "users", "", gin.H{
+		"format":       ext,
+		"filter":       req.Filter,
+		"search":       req.Search,
+		"created_from": req.CreatedFrom,
+		"created_to":   req.CreatedTo,
+	})
+	if err != nil {
+		auditUnavailable(c)
+		return
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="users-%s.%s"`, time.Now().UTC().Format("20060102-150405"), ext))
+
+	err = ac.transfer.Export(c.Request.Context(), c.Writer, req)
+	if err == nil {
+		ac.logger.Info("admin %d exported users as %s", admin.Id, ext)
+		return
+	}
+	if c.Writer.Written() {
+		// The status line is gone; all we can do is cut the stream short.
+		ac.logger.Error("user export by admin %d failed mid-stream: %v", admin.Id, err)
+		return
+	}
+	c.Writer.Header().Del("Content-Type")
+	c.Writer.Header().Del("Content-Disposition")
+	var validationErrs validator.ValidationErrors
+	if errors.Is(err, usecases.ErrInvalidFilter) || errors.As(err, &validationErrs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// @Summary		Import users
+// @Description	Pre-registers users from the request body: CSV with a header row (phone, email, display_name, locale, timezone;
+// @Description	only phone is required) or NDJSON with the same fields. Phones are normalized, rows repeating a phone or email
+// @Description	and users that already exist are skipped. With dry_run nothing is created. Returns a per-row report.
+// @Description	Users are created in batches; when the file turns out unreadable half-way, the error response carries the
+// @Description	report of the rows before it, whose users were created. Imports other than dry runs are recorded in the audit log
+// @Description	before the first row and refused when they cannot be.
+// @Tags			Admin
+// @Accept			text/csv
+// @Accept			application/x-ndjson
+// @Produce		json
+// @Param			format	query	string	false	"csv or ndjson"	default(csv)
+// @Param			dry_run	query	bool	false	"Check the file without creating users"
+// @Success		200
+// @Failure		400
+// @Failure		401
+// @Failure		403
+// @Router			/api/v1/admin/users/import [post]
+// @Security		BearerAuth
+func (ac *adminController) ImportUsers(c *gin.Context) {
+	admin, ok := c.MustGet("user").(entities.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user from context"})
+		return
+	}
+	var req dto.ImportUsersDTO
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// batches are committed as they go, so the import is recorded before the
+	// first one; dry runs create nothing and are not
+	if !req.DryRun {
+		if err := recordAudit(c, ac.audit, entities.AuditUsersImport, "users", "", gin.H{"format": req.Format}); err != nil {
+			auditUnavailable(c)
+			return
+		}
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBodySize)
+	report, err := ac.transfer.Import(c.Request.Context(), body, req)
+	if report.Rows > 0 {
+		ac.logger.Info("admin %d imported users (dry run: %t): %d rows, %d created, %d skipped, %d failed",
+			admin.Id, report.DryRun, report.Rows, report.Created, report.Skipped, report.Failed)
+	}
+
+	var validationErrs validator.ValidationErrors
+	switch {
+	case errors.Is(err, usecases.ErrInvalidImport), errors.As(err, &validationErrs):
+		importError(c, http.StatusBadRequest, err, report)
+	case err != nil:
+		importError(c, http.StatusInternalServerError, err, report)
+	default:
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// importError reports why an import stopped, with the report of the rows
+// read up to then when there were any.
+func importError(c *gin.Context, status int, err error, report entities.UserImportReport) {
+	response := gin.H{"error": err.Error()}
+	if report.Rows > 0 {
+		response["report"] = report
+	}
+	c.JSON(status, response)
+}
+
+// @Summary		Audit log
+// @Description	Lists audit log entries for admin operations and security-relevant account changes, newest first.
+// @Description	Each entry carries the hash of the one before it; run authctl audit verify to check the chain.
+// @Description	Each lookup is recorded in the audit log first and refused when it cannot be.
+// @Tags			Admin
+// @Produce		json
+// @Param			actor_id	query	int		false	"Acting user ID"
+// @Param			action		query	string	false	"Action, e.g. user.status_change"
+// @Param			target_type	query	string	false	"Target type, e.g. user"
+// @Param			target_id	query	string	false	"Target ID"
+// @Param			request_id	query	string	false	"X-Request-ID of the request that made the change"
+// @Param			from		query	string	false	"Created from (RFC3339)"
+// @Param			to			query	string	false	"Created to (RFC3339)"
+// @Param			limit		query	int		false	"Page size, at most 100"	default(50)
+// @Param			cursor		query	string	false	"next_cursor of the previous page"
+// @Success		200
+// @Failure		400
+// @Failure		401
+// @Failure		403
+// @Router			/api/v1/admin/audit [get]
+// @Security		BearerAuth
+func (ac *adminController) GetAuditLog(c *gin.Context) {
+	var query dto.ListAuditDTO
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := recordAudit(c, ac.audit, entities.AuditLogView, "audit_log", "", gin.H{
+		"actor_id":    query.ActorID,
+		"action":      query.Action,
+		"target_type": query.TargetType,
+		"target_id":   query.TargetID,
+		"request_id":  query.RequestID,
+		"from":        query.From,
+		"to":          query.To,
+		"cursor":      query.Cursor,
+	})
+	if err != nil {
+		auditUnavailable(c)
+		return
+	}
+	page, err := ac.audit.List(c.Request.Context(), query)
+	var validationErrs validator.ValidationErrors
+	switch {
+	case errors.Is(err, usecases.ErrInvalidPageSize), errors.Is(err, pagination.ErrInvalidCursor), errors.As(err, &validationErrs):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, page)
+	}
+}
+
+// @Summary		Service metrics
+// @Description	Returns the process's expvar metrics, including per-provider SMS counters (sent, failed, skipped) and circuit breaker state under sms_providers
+// @Description	and delivery report counts per provider and status under sms_delivery_reports
+// @Tags			Admin
+// @Produce		json
+// @Success		200
+// @Failure		401
+// @Failure		403
+// @Router			/api/v1/admin/metrics [get]
+// @Security		BearerAuth
+func (ac *adminController) GetMetrics(c *gin.Context) {
+	expvar.Handler().ServeHTTP(c.Writer, c.Request)
+}
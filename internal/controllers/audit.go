@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/usecases"
+	"github.com/gin-gonic/gin"
+)
+
+// auditChange is the diff of a field that changed.
+type auditChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// recordAudit adds an entry for the signed-in user's request to the audit log.
+func recordAudit(c *gin.Context, audit usecases.AuditUsecase, action entities.AuditAction, targetType, targetID string, diff any) error {
+	entry := entities.AuditEntry{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         c.ClientIP(),
+	}
+	if user, ok := c.Get("user"); ok {
+		if user, ok := user.(entities.User); ok {
+			entry.ActorID = &user.Id
+		}
+	}
+	return audit.Record(c.Request.Context(), entry, diff)
+}
+
+// auditUnavailable answers an admin request that could not be audited. Admin
+// requests are recorded before anything is shown or changed, so it is refused.
+func auditUnavailable(c *gin.Context) {
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "request refused: it could not be recorded in the audit log"})
+}
@@ -1,12 +1,17 @@
 package controllers
 
 import (
+	"errors"
+	"math"
 	"net/http"
 
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/usecases"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/logger"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/ratelimit"
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 )
 
 type authController struct {
@@ -22,7 +27,9 @@ func NewAuthController(logger logger.Logger, authService usecases.AuthService) A
 }
 
 // @Summary		Request login OTP
-// @Description	Generates and sends OTP for the given phone number
+// @Description	Generates and sends OTP for the given phone number and returns the challenge_id needed to verify it,
+// @Description	with expires_in, resend_after (seconds) and the remaining verification attempts and OTP requests.
+// @Description	Suspended and banned accounts, and email requests without a verified address, get the same response but no code.
 // @Tags			Auth
 // @Accept			json
 // @Produce		json
@@ -30,6 +37,7 @@ func NewAuthController(logger logger.Logger, authService usecases.AuthService) A
 // @Success		200
 // @Failure		400
 // @Failure		401
+// @Failure		429
 // @Router			/api/v1/auth/request-otp [post]
 func (ac *authController) LoginOtp(c *gin.Context) {
 	var body dto.LoginDTO
@@ -37,17 +45,76 @@ func (ac *authController) LoginOtp(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	body.Client = clientInfo(c)
 
-	err := ac.authService.LoginRequestOtp(c.Request.Context(), body)
+	status, err := ac.authService.LoginRequestOtp(c.Request.Context(), body)
 	if err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		otpRequestError(c, err)
+		return
+	}
+	response := otpStatusResponse(status)
+	response["message"] = "otp sent successfully."
+	c.JSON(200, response)
+}
+
+// @Summary		Resend login OTP
+// @Description	Sends a new code for a pending challenge, e.g. via a voice call instead of SMS. The previous code stops working.
+// @Tags			Auth
+// @Accept			json
+// @Produce		json
+// @Param			body	body	dto.ResendOtpDTO	true	"Resend OTP"
+// @Success		200
+// @Failure		400
+// @Failure		403
+// @Failure		429
+// @Router			/api/v1/auth/resend-otp [post]
+func (ac *authController) ResendLoginOtp(c *gin.Context) {
+	var body dto.ResendOtpDTO
+	if err := c.ShouldBind(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	body.Client = clientInfo(c)
+
+	status, err := ac.authService.ResendLoginOtp(c.Request.Context(), body)
+	if err != nil {
+		otpRequestError(c, err)
+		return
+	}
+	response := otpStatusResponse(status)
+	response["message"] = "otp sent successfully."
+	c.JSON(200, response)
+}
+
+// @Summary		Login OTP status
+// @Description	Returns how long a challenge stays valid, the verification attempts left and how many seconds
+// @Description	to wait before requesting a new code. Without a challenge_id only the resend information is returned.
+// @Tags			Auth
+// @Produce		json
+// @Param			challenge_id	query	string	false	"Challenge ID"
+// @Param			phone			query	string	true	"Phone number"
+// @Param			device_id		query	string	false	"Device ID"
+// @Success		200
+// @Failure		400
+// @Router			/api/v1/auth/otp-status [get]
+func (ac *authController) LoginOtpStatus(c *gin.Context) {
+	var query dto.OtpStatusQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"message": "otp sms sent successfully."})
+	query.Client = clientInfo(c)
+
+	status, err := ac.authService.LoginOtpStatus(c.Request.Context(), query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, otpStatusResponse(status))
 }
 
 // @Summary		Verify login OTP
-// @Description	Verifies OTP, creates user if needed, and returns JWT
+// @Description	Verifies the OTP for a challenge, creates user if needed, and returns JWT
 // @Tags			Auth
 // @Accept			json
 // @Produce		json
@@ -62,14 +129,110 @@ func (ac *authController) VerifyLoginOTP(c *gin.Context) {
 		return
 	}
 
-	jwt, err := ac.authService.VerifyLoginOTP(c.Request.Context(), body)
+	body.Client = clientInfo(c)
+
+	tokens, err := ac.authService.VerifyLoginOTP(c.Request.Context(), body)
 	if err != nil {
 		ac.logger.Error("Failed to verifying signUp otp", err)
 		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"jwt": jwt,
-	})
+	c.JSON(http.StatusOK, tokens)
+}
+
+// @Summary		Refresh tokens
+// @Description	Trades a refresh token for a new JWT and refresh token. Each refresh token can be traded once; trading it a
+// @Description	second time signs out its session. Refresh tokens last 7 days and stop working when the account is deleted,
+// @Description	suspended or banned, changes its phone number or signs out.
+// @Tags			Auth
+// @Accept			json
+// @Produce		json
+// @Param			body	body	dto.RefreshTokenDTO	true	"Refresh token"
+// @Success		200
+// @Failure		400
+// @Failure		401
+// @Failure		403
+// @Router			/api/v1/auth/refresh [post]
+func (ac *authController) RefreshToken(c *gin.Context) {
+	var body dto.RefreshTokenDTO
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	body.Client = clientInfo(c)
+
+	tokens, err := ac.authService.RefreshToken(c.Request.Context(), body)
+	var blocked *usecases.UserBlockedError
+	var validationErrs validator.ValidationErrors
+	switch {
+	case errors.As(err, &blocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.As(err, &validationErrs):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+// @Summary		Sign out
+// @Description	Signs out the session the refresh token belongs to: neither it nor the refresh tokens traded for it can be
+// @Description	used again. JWTs already issued stay valid until they expire.
+// @Tags			Auth
+// @Accept			json
+// @Param			body	body	dto.RefreshTokenDTO	true	"Refresh token"
+// @Success		204
+// @Failure		400
+// @Failure		401
+// @Router			/api/v1/auth/logout [post]
+func (ac *authController) Logout(c *gin.Context) {
+	var body dto.RefreshTokenDTO
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := ac.authService.Logout(c.Request.Context(), body)
+	var validationErrs validator.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrs):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func clientInfo(c *gin.Context) dto.ClientInfo {
+	return dto.ClientInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+func otpRequestError(c *gin.Context, err error) {
+	var exceeded *ratelimit.ExceededError
+	if errors.As(err, &exceeded) {
+		ratelimit.SetHeaders(c.Writer.Header(), exceeded.Result)
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+}
+
+// otpStatusResponse reports durations in whole seconds, rounded up so clients
+// never retry too early.
+func otpStatusResponse(status entities.OtpStatus) gin.H {
+	return gin.H{
+		"challenge_id":       status.ChallengeID,
+		"active":             status.Active,
+		"channel":            status.Channel,
+		"expires_in":         int(math.Ceil(status.ExpiresIn.Seconds())),
+		"attempts_remaining": status.AttemptsRemaining,
+		"resend_after":       int(math.Ceil(status.ResendAfter.Seconds())),
+		"requests_remaining": status.RequestsRemaining,
+	}
 }
@@ -5,11 +5,44 @@ import "github.com/gin-gonic/gin"
 type (
 	AuthController interface {
 		LoginOtp(c *gin.Context)
+		ResendLoginOtp(c *gin.Context)
+		LoginOtpStatus(c *gin.Context)
 		VerifyLoginOTP(c *gin.Context)
+		RefreshToken(c *gin.Context)
+		Logout(c *gin.Context)
 	}
 
 	UsersController interface {
 		GetUser(c *gin.Context)
+		UpdateProfile(c *gin.Context)
+		SendEmailVerification(c *gin.Context)
+		VerifyEmail(c *gin.Context)
+		RequestPhoneChange(c *gin.Context)
+		VerifyPhoneChange(c *gin.Context)
+		RequestAccountDeletion(c *gin.Context)
+		DeleteAccount(c *gin.Context)
+		ExportData(c *gin.Context)
+		GetActivity(c *gin.Context)
 		GetAllUsers(c *gin.Context)
 	}
+
+	AdminController interface {
+		GetSettings(c *gin.Context)
+		ReloadSettings(c *gin.Context)
+		ListOutboxMessages(c *gin.Context)
+		GetOutboxStats(c *gin.Context)
+		RetryOutboxMessage(c *gin.Context)
+		GetMetrics(c *gin.Context)
+		GetOtpDeliveries(c *gin.Context)
+		SetUserStatus(c *gin.Context)
+		GetUserStatusHistory(c *gin.Context)
+		GetUserActivity(c *gin.Context)
+		ExportUsers(c *gin.Context)
+		ImportUsers(c *gin.Context)
+		GetAuditLog(c *gin.Context)
+	}
+
+	WebhookController interface {
+		SmsDeliveryReport(c *gin.Context)
+	}
 )
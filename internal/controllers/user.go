@@ -1,23 +1,43 @@
 package controllers
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
-	"time"
 
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/usecases"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/logger"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/pagination"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/ratelimit"
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 )
 
 type usersController struct {
-	usersService usecases.UsersService
+	usersService      usecases.UsersService
+	emailVerification usecases.EmailVerificationUsecase
+	phoneChange       usecases.PhoneChangeUsecase
+	account           usecases.AccountUsecase
+	activity          usecases.ActivityUsecase
+	audit             usecases.AuditUsecase
+	logger            logger.Logger
 }
 
-func NewUsersController(l logger.Logger, usersService usecases.UsersService) UsersController {
+func NewUsersController(l logger.Logger, usersService usecases.UsersService, emailVerification usecases.EmailVerificationUsecase, phoneChange usecases.PhoneChangeUsecase, account usecases.AccountUsecase, activity usecases.ActivityUsecase, audit usecases.AuditUsecase) UsersController {
 	return &usersController{
-		usersService: usersService,
+		usersService:      usersService,
+		emailVerification: emailVerification,
+		phoneChange:       phoneChange,
+		account:           account,
+		activity:          activity,
+		audit:             audit,
+		logger:            l,
 	}
 }
 
@@ -41,48 +61,495 @@ func (uc *usersController) GetUser(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, user)
+	c.JSON(http.StatusOK, userResponse(user))
+}
+
+// @Summary Update profile
+// @Description Partially updates the signed-in user's profile. Omitted fields are left unchanged and an empty string clears a field.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param body body dto.UpdateProfileDTO true "Profile fields to change"
+// @Success 200
+// @Failure 400
+// @Failure 401
+// @Failure 409
+// @Router /api/v1/users/profile [patch]
+// @Security		BearerAuth
+func (uc *usersController) UpdateProfile(c *gin.Context) {
+	user, ok := c.MustGet("user").(entities.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user from context"})
+		return
+	}
+	var body dto.UpdateProfileDTO
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := uc.usersService.UpdateProfile(c.Request.Context(), user.Id, body)
+	var validationErrs validator.ValidationErrors
+	switch {
+	case errors.Is(err, usecases.ErrEmailTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, &validationErrs):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, userResponse(user))
+	}
+}
+
+// @Summary Send email verification link
+// @Description Emails a signed link to the address on the profile. Rate limited like OTP requests.
+// @Tags Users
+// @Produce json
+// @Success 202
+// @Failure 400
+// @Failure 401
+// @Failure 409
+// @Failure 429
+// @Router /api/v1/users/profile/email/send-verification [post]
+// @Security		BearerAuth
+func (uc *usersController) SendEmailVerification(c *gin.Context) {
+	user, ok := c.MustGet("user").(entities.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user from context"})
+		return
+	}
+
+	err := uc.emailVerification.SendVerification(c.Request.Context(), user.Id, clientInfo(c))
+	var exceeded *ratelimit.ExceededError
+	switch {
+	case errors.As(err, &exceeded):
+		ratelimit.SetHeaders(c.Writer.Header(), exceeded.Result)
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, usecases.ErrNoEmail):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, usecases.ErrEmailAlreadyVerified):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusAccepted, gin.H{"message": "verification email sent"})
+	}
+}
+
+// @Summary Verify email address
+// @Description Confirms the profile's email address with the token from the verification link.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param body body dto.VerifyEmailDTO true "Token from the verification link"
+// @Success 200
+// @Failure 400
+// @Failure 401
+// @Router /api/v1/users/profile/email/verify [post]
+// @Security		BearerAuth
+func (uc *usersController) VerifyEmail(c *gin.Context) {
+	user, ok := c.MustGet("user").(entities.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user from context"})
+		return
+	}
+	var body dto.VerifyEmailDTO
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := uc.emailVerification.Verify(c.Request.Context(), user.Id, body)
+	var validationErrs validator.ValidationErrors
+	switch {
+	case errors.Is(err, usecases.ErrInvalidVerificationToken), errors.As(err, &validationErrs):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, userResponse(user))
+	}
+}
+
+// @Summary Request phone number change
+// @Description Sends codes to the current and the new phone number, or the current number's code to the verified email
+// @Description when old_phone_lost is set. Returns both challenges in the format of request-otp.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param body body dto.RequestPhoneChangeDTO true "New phone number"
+// @Success 200
+// @Failure 400
+// @Failure 401
+// @Failure 403
+// @Failure 409
+// @Failure 429
+// @Router /api/v1/users/profile/phone/request-otp [post]
+// @Security		BearerAuth
+func (uc *usersController) RequestPhoneChange(c *gin.Context) {
+	user, ok := c.MustGet("user").(entities.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user from context"})
+		return
+	}
+	var body dto.RequestPhoneChangeDTO
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	body.Client = clientInfo(c)
+
+	status, err := uc.phoneChange.RequestOtp(c.Request.Context(), user.Id, body)
+	var validationErrs validator.ValidationErrors
+	switch {
+	case errors.Is(err, usecases.ErrPhoneTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, usecases.ErrSamePhone), errors.Is(err, usecases.ErrPhoneChangeFallbackUnavailable), errors.As(err, &validationErrs):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		otpRequestError(c, err)
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"current": otpStatusResponse(status.Current),
+			"new":     otpStatusResponse(status.New),
+		})
+	}
+}
+
+// @Summary Verify phone number change
+// @Description Checks both codes, moves the account to the new number and signs out every session.
+// @Description Returns a new jwt and refresh token for the caller.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param body body dto.VerifyPhoneChangeDTO true "Codes for both challenges"
+// @Success 200
+// @Failure 400
+// @Failure 401
+// @Failure 403
+// @Failure 409
+// @Router /api/v1/users/profile/phone/verify [post]
+// @Security		BearerAuth
+func (uc *usersController) VerifyPhoneChange(c *gin.Context) {
+	user, ok := c.MustGet("user").(entities.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user from context"})
+		return
+	}
+	var body dto.VerifyPhoneChangeDTO
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	body.Client = clientInfo(c)
+
+	tokens, err := uc.phoneChange.Verify(c.Request.Context(), user.Id, body)
+	var validationErrs validator.ValidationErrors
+	switch {
+	case errors.Is(err, usecases.ErrPhoneTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, usecases.ErrSamePhone), errors.As(err, &validationErrs):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		// the phone has changed and the new tokens must still reach the user
+		err := recordAudit(c, uc.audit, entities.AuditPhoneChange, "user", strconv.FormatUint(uint64(user.Id), 10), gin.H{
+			"phone": auditChange{From: user.Phone, To: body.NewPhone},
+		})
+		if err != nil {
+			uc.logger.Error("phone change of user %d was not recorded in the audit log: %v", user.Id, err)
+		}
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+// @Summary Request account deletion OTP
+// @Description Sends the code that confirms DELETE /api/v1/users/profile, in the format of request-otp.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param body body dto.RequestAccountDeletionDTO false "Delivery options"
+// @Success 200
+// @Failure 400
+// @Failure 401
+// @Failure 403
+// @Failure 429
+// @Router /api/v1/users/profile/delete/request-otp [post]
+// @Security		BearerAuth
+func (uc *usersController) RequestAccountDeletion(c *gin.Context) {
+	user, ok := c.MustGet("user").(entities.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user from context"})
+		return
+	}
+	var body dto.RequestAccountDeletionDTO
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	body.Client = clientInfo(c)
+
+	status, err := uc.account.RequestDeletionOtp(c.Request.Context(), user.Id, body)
+	var validationErrs validator.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrs):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		otpRequestError(c, err)
+	default:
+		c.JSON(http.StatusOK, otpStatusResponse(status))
+	}
+}
+
+// @Summary Delete account
+// @Description Deletes the signed-in user's account after checking a code from delete/request-otp and signs out every session.
+// @Description The account is purged after the grace period; signing in again before then restores it.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param body body dto.DeleteAccountDTO true "Deletion OTP"
+// @Success 200
+// @Failure 400
+// @Failure 401
+// @Failure 403
+// @Router /api/v1/users/profile [delete]
+// @Security		BearerAuth
+func (uc *usersController) DeleteAccount(c *gin.Context) {
+	user, ok := c.MustGet("user").(entities.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user from context"})
+		return
+	}
+	var body dto.DeleteAccountDTO
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := uc.account.Delete(c.Request.Context(), user.Id, body)
+	var validationErrs validator.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrs):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		// the account is deleted either way
+		err := recordAudit(c, uc.audit, entities.AuditAccountDelete, "user", strconv.FormatUint(uint64(user.Id), 10), gin.H{
+			"purge_after": user.PurgeAfter,
+		})
+		if err != nil {
+			uc.logger.Error("deletion of user %d was not recorded in the audit log: %v", user.Id, err)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "account deleted",
+			"purge_after": user.PurgeAfter,
+		})
+	}
+}
+
+// @Summary Export account data
+// @Description Downloads everything stored about the signed-in user: as one JSON document, or as a ZIP archive with one JSON file per section.
+// @Tags Users
+// @Produce json
+// @Produce application/zip
+// @Param format query string false "json or zip" default(json)
+// @Success 200
+// @Failure 400
+// @Failure 401
+// @Router /api/v1/users/profile/export [get]
+// @Security		BearerAuth
+func (uc *usersController) ExportData(c *gin.Context) {
+	user, ok := c.MustGet("user").(entities.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user from context"})
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "zip" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or zip"})
+		return
+	}
+
+	export, err := uc.account.Export(c.Request.Context(), user.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := recordAudit(c, uc.audit, entities.AuditDataExport, "user", strconv.FormatUint(uint64(user.Id), 10), gin.H{"format": format}); err != nil {
+		auditUnavailable(c)
+		return
+	}
+	filename := fmt.Sprintf("account-%d-%s", user.Id, export.ExportedAt.Format("20060102T150405Z"))
+	if format == "json" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		c.IndentedJSON(http.StatusOK, export)
+		return
+	}
+
+	archive, err := exportArchive(export)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, filename))
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+// @Summary Login history
+// @Description Lists the signed-in user's OTP requests, code verifications and token refreshes, newest first,
+// @Description with the outcome, IP address and user agent of each. History is kept for AUTH_EVENTS_RETENTION.
+// @Tags Users
+// @Produce json
+// @Param kind query string false "Event kind" Enums(otp_request, otp_verify, token_refresh)
+// @Param limit query int false "Page size, at most 100" default(20)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200
+// @Failure 400
+// @Failure 401
+// @Router /api/v1/users/profile/activity [get]
+// @Security		BearerAuth
+func (uc *usersController) GetActivity(c *gin.Context) {
+	user, ok := c.MustGet("user").(entities.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user from context"})
+		return
+	}
+	listActivity(c, uc.activity, user.Id)
+}
+
+// listActivity writes one page of the user's login history.
+func listActivity(c *gin.Context, activity usecases.ActivityUsecase, userID uint32) {
+	var query dto.ListAuthEventsDTO
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := activity.List(c.Request.Context(), userID, query)
+	var validationErrs validator.ValidationErrors
+	switch {
+	case errors.Is(err, usecases.ErrInvalidPageSize), errors.Is(err, pagination.ErrInvalidCursor), errors.As(err, &validationErrs):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, page)
+	}
+}
+
+// exportArchive puts each section of export into its own JSON file.
+func exportArchive(export entities.UserExport) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, file := range []struct {
+		name    string
+		content any
+	}{
+		{"user.json", export.User},
+		{"messages.json", export.Messages},
+		{"auth_events.json", export.AuthEvents},
+		{"status_history.json", export.StatusHistory},
+		{"audit_log.json", export.AuditLog},
+		{"sessions.json", export.Sessions},
+	} {
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: export.ExportedAt})
+		if err != nil {
+			return nil, err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 // @Summary List users
+// @Description Admin only. Lists users with keyset pagination. The response has the page's items, a next_cursor to pass as cursor
+// @Description for the next page (absent on the last page) and, with include_total, the number of matching users.
+// @Description filter takes space-separated terms that must all match: phone, email and display_name match a substring
+// @Description or, with a leading ^, a prefix; locale, timezone and status match exactly; email_verified and is_test take
+// @Description true or false; created_at takes >, >=, < or <= and a date or RFC 3339 time. A leading - negates a term.
+// @Description Each listing is recorded in the audit log first and refused when it cannot be.
 // @Tags Users
 // @Produce json
-// @Param page query int false "Page number" default(1)
-// @Param limit query int false "Page size" default(10)
+// @Param limit query int false "Page size, at most 100" default(10)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Sort field" Enums(id, created_at, phone) default(id)
+// @Param order query string false "Sort direction" Enums(asc, desc) default(asc)
+// @Param filter query string false "Filter expression, e.g. status:active created_at>2025-01-01 phone:^98912"
 // @Param search query string false "Search by phone"
 // @Param created_from query string false "Created from (RFC3339)"
 // @Param created_to query string false "Created to (RFC3339)"
+// @Param include_total query bool false "Count the matching users"
 // @Success 200
+// @Failure 400
+// @Failure 401
+// @Failure 403
 // @Router /api/v1/users [get]
 // @Security		BearerAuth
 func (uc *usersController) GetAllUsers(c *gin.Context) {
-	page64, _ := strconv.ParseUint(c.DefaultQuery("page", "1"), 10, 32)
-	limit64, _ := strconv.ParseUint(c.DefaultQuery("limit", "10"), 10, 32)
-	page := uint32(page64)
-	limit := uint32(limit64)
-
-	search := c.Query("search")
-	var searchPtr *string
-	if search != "" {
-		searchPtr = &search
+	var query dto.ListUsersDTO
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	var fromPtr, toPtr *time.Time
-	if v := c.Query("created_from"); v != "" {
-		if t, err := time.Parse(time.RFC3339, v); err == nil {
-			fromPtr = &t
-		}
+	err := recordAudit(c, uc.audit, entities.AuditUsersList, "users", "", gin.H{
+		"filter":       query.Filter,
+		"search":       query.Search,
+		"created_from": query.CreatedFrom,
+		"created_to":   query.CreatedTo,
+		"cursor":       query.Cursor,
+	})
+	if err != nil {
+		auditUnavailable(c)
+		return
 	}
-	if v := c.Query("created_to"); v != "" {
-		if t, err := time.Parse(time.RFC3339, v); err == nil {
-			toPtr = &t
+	page, err := uc.usersService.ListUsers(c.Request.Context(), query)
+	var validationErrs validator.ValidationErrors
+	switch {
+	case errors.Is(err, usecases.ErrInvalidPageSize), errors.Is(err, usecases.ErrInvalidFilter),
+		errors.Is(err, pagination.ErrInvalidCursor), errors.As(err, &validationErrs):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		items := make([]dto.UserResponse, len(page.Items))
+		for i, user := range page.Items {
+			items[i] = userResponse(user)
 		}
+		c.JSON(http.StatusOK, entities.Page[dto.UserResponse]{Items: items, NextCursor: page.NextCursor, Total: page.Total})
 	}
+}
 
-	users, err := uc.usersService.GetAllUsers(c.Request.Context(), page, limit, searchPtr, fromPtr, toPtr)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+func userResponse(u entities.User) dto.UserResponse {
+	return dto.UserResponse{
+		ID:              u.Id,
+		Phone:           u.Phone,
+		Email:           u.Email,
+		EmailVerified:   u.EmailVerified,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		DisplayName:     u.DisplayName,
+		Locale:          u.Locale,
+		Timezone:        u.Timezone,
+		AvatarURL:       u.AvatarURL,
+		Status:          string(u.Status),
+		StatusReason:    u.StatusReason,
+		SuspendedUntil:  u.SuspendedUntil,
+		IsTest:          u.IsTest,
+		DeletedAt:       u.DeletedAt,
+		PurgeAfter:      u.PurgeAfter,
+		CreatedAt:       u.CreatedAt,
+		UpdatedAt:       u.UpdatedAt,
 	}
-	c.JSON(http.StatusOK, users)
 }
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/usecases"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/logger"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/sms"
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookBody bounds what is read before the signature is checked.
+const maxWebhookBody = 64 << 10
+
+type webhookController struct {
+	logger   logger.Logger
+	verifier *sms.WebhookVerifier
+	outbox   usecases.OutboxUsecase
+}
+
+func NewWebhookController(logger logger.Logger, verifier *sms.WebhookVerifier, outbox usecases.OutboxUsecase) WebhookController {
+	return &webhookController{
+		logger:   logger,
+		verifier: verifier,
+		outbox:   outbox,
+	}
+}
+
+// @Summary		SMS delivery report
+// @Description	Receives a delivery report (DLR) from an SMS provider. The provider signs "<X-Signature-Timestamp>.<body>"
+// @Description	with HMAC-SHA256 and its webhook secret and sends the hex digest in X-Signature.
+// @Description	Reports for unknown message IDs get 404 so the provider retries them.
+// @Tags			Webhooks
+// @Accept			json
+// @Produce		json
+// @Param			provider				path	string					true	"Provider name from SMS_PROVIDERS"
+// @Param			X-Signature				header	string					true	"sha256=<hex HMAC>"
+// @Param			X-Signature-Timestamp	header	string					true	"Unix timestamp"
+// @Param			report					body	dto.DeliveryReportDTO	true	"Delivery report"
+// @Success		200
+// @Failure		400
+// @Failure		401
+// @Failure		404
+// @Router			/api/v1/webhooks/sms/{provider} [post]
+func (wc *webhookController) SmsDeliveryReport(c *gin.Context) {
+	provider := c.Param("provider")
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := wc.verifier.Verify(provider, c.GetHeader(sms.TimestampHeader), c.GetHeader(sms.SignatureHeader), body); err != nil {
+		wc.logger.Warn("rejected delivery report from %s: %v", provider, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var report dto.DeliveryReportDTO
+	if err := json.Unmarshal(body, &report); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = wc.outbox.RecordDeliveryReport(c.Request.Context(), provider, report)
+	if errors.Is(err, usecases.ErrDeliveryReportUnmatched) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "report recorded"})
+}
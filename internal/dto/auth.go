@@ -1,12 +1,51 @@
 package dto
 
 type (
+	// ClientInfo is filled in by controllers from the HTTP request, never from the body.
+	ClientInfo struct {
+		IP        string `json:"-"`
+		UserAgent string `json:"-"`
+	}
+
 	LoginDTO struct {
-		Phone string `json:"phone" validate:"required,min=8,max=20"`
+		Phone    string `json:"phone" validate:"required,min=8,max=20"`
+		DeviceID string `json:"device_id" validate:"omitempty,max=128"`
+		// Channel defaults to sms.
+		Channel string `json:"channel" validate:"omitempty,oneof=sms voice email telegram whatsapp"`
+		// Platform selects an SMS format the app can autofill the code from.
+		Platform string     `json:"platform" validate:"omitempty,oneof=android ios web"`
+		Client   ClientInfo `json:"-"`
+	}
+
+	// ResendOtpDTO issues a fresh code for a pending challenge, optionally
+	// over another channel ("send via call instead").
+	ResendOtpDTO struct {
+		ChallengeID string     `json:"challenge_id" validate:"required,max=64"`
+		Phone       string     `json:"phone" validate:"required,min=8,max=20"`
+		DeviceID    string     `json:"device_id" validate:"omitempty,max=128"`
+		Channel     string     `json:"channel" validate:"required,oneof=sms voice email telegram whatsapp"`
+		Platform    string     `json:"platform" validate:"omitempty,oneof=android ios web"`
+		Client      ClientInfo `json:"-"`
+	}
+
+	OtpStatusQuery struct {
+		ChallengeID string     `form:"challenge_id" validate:"omitempty,max=64"`
+		Phone       string     `form:"phone" validate:"required,min=8,max=20"`
+		DeviceID    string     `form:"device_id" validate:"omitempty,max=128"`
+		Client      ClientInfo `form:"-"`
 	}
 
 	VerifyLoginOTP struct {
-		Phone string `json:"phone" validate:"required,min=8,max=20"`
-		OTP   string `json:"otp" validate:"required,len=5,numeric"`
+		ChallengeID string `json:"challenge_id" validate:"required,max=64"`
+		Phone       string `json:"phone" validate:"required,min=8,max=20"`
+		DeviceID    string `json:"device_id" validate:"omitempty,max=128"`
+		// the exact length and alphabet are checked against the active OTP policy
+		OTP    string     `json:"otp" validate:"required,min=4,max=10,alphanum"`
+		Client ClientInfo `json:"-"`
+	}
+
+	RefreshTokenDTO struct {
+		RefreshToken string     `json:"refresh_token" validate:"required,max=2048"`
+		Client       ClientInfo `json:"-"`
 	}
 )
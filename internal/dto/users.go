@@ -0,0 +1,153 @@
+package dto
+
+import "time"
+
+// UpdateProfileDTO is a partial update: omitted fields are left unchanged and
+// an empty string clears a field.
+type UpdateProfileDTO struct {
+	DisplayName *string `json:"display_name" validate:"omitempty,max=64"`
+	Email       *string `json:"email" validate:"omitempty,max=254,len=0|email"`
+	// Locale is a BCP 47 language tag such as "en" or "fa-IR".
+	Locale *string `json:"locale" validate:"omitempty,max=35,len=0|bcp47_language_tag"`
+	// Timezone is an IANA time zone name such as "Asia/Tehran".
+	Timezone  *string `json:"timezone" validate:"omitempty,max=64,len=0|timezone"`
+	AvatarURL *string `json:"avatar_url" validate:"omitempty,max=2048,len=0|http_url"`
+}
+
+// UserFilterDTO selects users for listings and exports. Filter is a filter
+// expression such as `status:active created_at>2025-01-01 phone:^98912`;
+// Search, CreatedFrom and CreatedTo are shorthands for phone and created_at
+// terms.
+type UserFilterDTO struct {
+	Filter      string     `form:"filter" validate:"omitempty,max=1024"`
+	Search      string     `form:"search" validate:"omitempty,max=20"`
+	CreatedFrom *time.Time `form:"created_from"`
+	CreatedTo   *time.Time `form:"created_to"`
+}
+
+// ListUsersDTO selects one page of users. Limit defaults to 10. Cursor is the
+// next_cursor of the previous page and only works with the sort and order it
+// was issued for.
+type ListUsersDTO struct {
+	UserFilterDTO
+	Limit        *int   `form:"limit"`
+	Cursor       string `form:"cursor" validate:"omitempty,max=512"`
+	Sort         string `form:"sort" validate:"omitempty,oneof=id created_at phone"`
+	Order        string `form:"order" validate:"omitempty,oneof=asc desc"`
+	IncludeTotal bool   `form:"include_total"`
+}
+
+// ExportUsersDTO streams every user matching the filter, ordered by id.
+type ExportUsersDTO struct {
+	UserFilterDTO
+	Format string `form:"format" validate:"omitempty,oneof=csv ndjson"`
+}
+
+// ImportUsersDTO pre-registers users from a CSV file with a header row or
+// from NDJSON, one object per line. DryRun checks every row without creating
+// any user.
+type ImportUsersDTO struct {
+	Format string `form:"format" validate:"omitempty,oneof=csv ndjson"`
+	DryRun bool   `form:"dry_run"`
+}
+
+// ImportUserDTO is one row of an import, with the same rules as a profile
+// update.
+type ImportUserDTO struct {
+	Phone       string `json:"phone" validate:"required,min=8,max=20"`
+	Email       string `json:"email" validate:"omitempty,max=254,email"`
+	DisplayName string `json:"display_name" validate:"omitempty,max=64"`
+	Locale      string `json:"locale" validate:"omitempty,max=35,bcp47_language_tag"`
+	Timezone    string `json:"timezone" validate:"omitempty,max=64,timezone"`
+}
+
+type VerifyEmailDTO struct {
+	Token string `json:"token" validate:"required,max=512"`
+}
+
+// RequestPhoneChangeDTO sends codes to the current and the new phone number.
+type RequestPhoneChangeDTO struct {
+	NewPhone string `json:"new_phone" validate:"required,min=8,max=20"`
+	DeviceID string `json:"device_id" validate:"omitempty,max=128"`
+	// Channel for both codes, defaults to sms.
+	Channel  string `json:"channel" validate:"omitempty,oneof=sms voice telegram whatsapp"`
+	Platform string `json:"platform" validate:"omitempty,oneof=android ios web"`
+	// OldPhoneLost sends the code for the current number to the account's
+	// verified email address instead.
+	OldPhoneLost bool       `json:"old_phone_lost"`
+	Client       ClientInfo `json:"-"`
+}
+
+type VerifyPhoneChangeDTO struct {
+	CurrentChallengeID string     `json:"current_challenge_id" validate:"required,max=64"`
+	CurrentOTP         string     `json:"current_otp" validate:"required,min=4,max=10,alphanum"`
+	NewChallengeID     string     `json:"new_challenge_id" validate:"required,max=64"`
+	NewPhone           string     `json:"new_phone" validate:"required,min=8,max=20"`
+	NewOTP             string     `json:"new_otp" validate:"required,min=4,max=10,alphanum"`
+	DeviceID           string     `json:"device_id" validate:"omitempty,max=128"`
+	Client             ClientInfo `json:"-"`
+}
+
+type RequestAccountDeletionDTO struct {
+	DeviceID string `json:"device_id" validate:"omitempty,max=128"`
+	// Channel defaults to sms; email needs a verified address.
+	Channel  string     `json:"channel" validate:"omitempty,oneof=sms voice email telegram whatsapp"`
+	Platform string     `json:"platform" validate:"omitempty,oneof=android ios web"`
+	Client   ClientInfo `json:"-"`
+}
+
+type DeleteAccountDTO struct {
+	ChallengeID string `json:"challenge_id" validate:"required,max=64"`
+	DeviceID    string `json:"device_id" validate:"omitempty,max=128"`
+	OTP         string `json:"otp" validate:"required,min=4,max=10,alphanum"`
+}
+
+type SetUserStatusDTO struct {
+	Status string `json:"status" validate:"required,oneof=active suspended banned"`
+	// Reason is required to suspend or ban a user.
+	Reason         string     `json:"reason" validate:"required_unless=Status active,max=500"`
+	SuspendedUntil *time.Time `json:"suspended_until" validate:"required_if=Status suspended,excluded_unless=Status suspended"`
+}
+
+// ListAuthEventsDTO selects one page of a user's login history, newest first.
+// Limit defaults to 20.
+type ListAuthEventsDTO struct {
+	Kind   string `form:"kind" validate:"omitempty,oneof=otp_request otp_verify token_refresh"`
+	Limit  *int   `form:"limit"`
+	Cursor string `form:"cursor" validate:"omitempty,max=512"`
+}
+
+// ListAuditDTO selects one page of the audit log, newest first. Limit
+// defaults to 50.
+type ListAuditDTO struct {
+	ActorID    *uint32    `form:"actor_id"`
+	Action     string     `form:"action" validate:"omitempty,max=64"`
+	TargetType string     `form:"target_type" validate:"omitempty,max=32"`
+	TargetID   string     `form:"target_id" validate:"omitempty,max=64"`
+	RequestID  string     `form:"request_id" validate:"omitempty,max=128"`
+	From       *time.Time `form:"from"`
+	To         *time.Time `form:"to"`
+	Limit      *int       `form:"limit"`
+	Cursor     string     `form:"cursor" validate:"omitempty,max=512"`
+}
+
+// UserResponse is a user as the API returns it.
+type UserResponse struct {
+	ID              uint32     `json:"id"`
+	Phone           string     `json:"phone"`
+	Email           string     `json:"email,omitempty"`
+	EmailVerified   bool       `json:"email_verified"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	DisplayName     string     `json:"display_name,omitempty"`
+	Locale          string     `json:"locale,omitempty"`
+	Timezone        string     `json:"timezone,omitempty"`
+	AvatarURL       string     `json:"avatar_url,omitempty"`
+	Status          string     `json:"status"`
+	StatusReason    string     `json:"status_reason,omitempty"`
+	SuspendedUntil  *time.Time `json:"suspended_until,omitempty"`
+	IsTest          bool       `json:"is_test"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+	PurgeAfter      *time.Time `json:"purge_after,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,12 @@
+package dto
+
+import "time"
+
+// DeliveryReportDTO is the body of an SMS delivery report webhook.
+type DeliveryReportDTO struct {
+	MessageID string `json:"message_id" validate:"required,max=128"`
+	Status    string `json:"status" validate:"required,oneof=accepted delivered undelivered failed expired rejected"`
+	ErrorCode string `json:"error_code" validate:"max=128"`
+	// Timestamp is when the carrier reported the status; it defaults to the time the report arrives.
+	Timestamp *time.Time `json:"timestamp"`
+}
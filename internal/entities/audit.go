@@ -0,0 +1,70 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditSettingsReload   AuditAction = "settings.reload"
+	AuditOutboxRetry      AuditAction = "outbox.retry"
+	AuditUserStatusChange AuditAction = "user.status_change"
+	AuditUsersExport      AuditAction = "users.export"
+	AuditUsersImport      AuditAction = "users.import"
+	AuditPhoneChange      AuditAction = "user.phone_change"
+	AuditAccountDelete    AuditAction = "user.account_delete"
+	AuditDataExport       AuditAction = "user.data_export"
+	// Admin views of personal data are audited like changes.
+	AuditOutboxList            AuditAction = "outbox.list"
+	AuditOtpDeliveriesView     AuditAction = "otp.deliveries_view"
+	AuditUserStatusHistoryView AuditAction = "user.status_history_view"
+	AuditUserActivityView      AuditAction = "user.activity_view"
+	AuditUsersList             AuditAction = "users.list"
+	AuditLogView               AuditAction = "audit.view"
+)
+
+// AuditEntry is one entry of the audit log. Each entry's Hash covers its
+// fields and the previous entry's hash, so changing, removing or reordering
+// entries breaks the chain from that point on.
+type AuditEntry struct {
+	ID int64 `json:"id"`
+	// ActorID is the user who acted, nil for the system.
+	ActorID    *uint32     `json:"actor_id,omitempty"`
+	Action     AuditAction `json:"action"`
+	TargetType string      `json:"target_type,omitempty"`
+	TargetID   string      `json:"target_id,omitempty"`
+	RequestID  string      `json:"request_id,omitempty"`
+	IP         string      `json:"ip,omitempty"`
+	// Diff is the change made, or the parameters of the operation.
+	Diff      json.RawMessage `json:"diff"`
+	CreatedAt time.Time       `json:"created_at"`
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash"`
+}
+
+// AuditQuery lists entries newest first, starting below BeforeID when it is
+// set. Zero fields do not filter.
+type AuditQuery struct {
+	ActorID    *uint32
+	Action     AuditAction
+	TargetType string
+	TargetID   string
+	RequestID  string
+	From       *time.Time
+	To         *time.Time
+	BeforeID   int64
+	Limit      int
+}
+
+// AuditVerification is the result of checking the whole chain. When it is
+// not Valid, BrokenAt is the first entry that does not check out.
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Entries  int64  `json:"entries"`
+	LastID   int64  `json:"last_id"`
+	LastHash string `json:"last_hash"`
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Problem  string `json:"problem,omitempty"`
+}
@@ -0,0 +1,46 @@
+package entities
+
+import "time"
+
+type AuthEventKind string
+
+const (
+	AuthEventOtpRequest   AuthEventKind = "otp_request"
+	AuthEventOtpVerify    AuthEventKind = "otp_verify"
+	AuthEventTokenRefresh AuthEventKind = "token_refresh"
+)
+
+type AuthEventOutcome string
+
+const (
+	AuthEventSuccess AuthEventOutcome = "success"
+	AuthEventFailure AuthEventOutcome = "failure"
+)
+
+// AuthEvent is one entry of a user's login history.
+type AuthEvent struct {
+	ID int64 `json:"id"`
+	// UserID is nil for phones without an account.
+	UserID  *uint32          `json:"user_id,omitempty"`
+	Phone   string           `json:"phone,omitempty"`
+	Kind    AuthEventKind    `json:"kind"`
+	Outcome AuthEventOutcome `json:"outcome"`
+	// Reason says why a failed attempt failed.
+	Reason    string    `json:"reason,omitempty"`
+	Channel   string    `json:"channel,omitempty"`
+	DeviceID  string    `json:"device_id,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuthEventQuery lists a user's events newest first, starting below BeforeID
+// when it is set. A Limit of 0 lists them all. Phone, when set, also matches
+// the events recorded for it without an account.
+type AuthEventQuery struct {
+	UserID   uint32
+	Phone    string
+	Kind     AuthEventKind
+	BeforeID int64
+	Limit    int
+}
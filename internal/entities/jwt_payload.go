@@ -1,5 +1,18 @@
 package entities
 
+import "time"
+
 type JwtPayload struct {
 	UserId uint32 `json:"user_id"`
+	// IssuedAt is zero for tokens issued before the iat claim was added.
+	IssuedAt time.Time `json:"issued_at"`
+	// TokenID is a refresh token's jti, the ID it is stored under.
+	TokenID string `json:"token_id,omitempty"`
+}
+
+// AuthTokens are issued on sign-in: a short-lived access token and a refresh
+// token that gets a new pair once it expires.
+type AuthTokens struct {
+	AccessToken  string `json:"jwt"`
+	RefreshToken string `json:"refresh_token"`
 }
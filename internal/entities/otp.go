@@ -0,0 +1,64 @@
+package entities
+
+import "time"
+
+type OtpPurpose string
+
+const (
+	OtpPurposeLogin           OtpPurpose = "login"
+	OtpPurposePhoneChange     OtpPurpose = "phone_change"
+	OtpPurposeAccountDeletion OtpPurpose = "account_deletion"
+)
+
+// OtpChallenge binds a pending OTP code to the phone, device and purpose it
+// was requested for. Clients refer to it by its opaque ID.
+type OtpChallenge struct {
+	ID        string
+	Purpose   OtpPurpose
+	Phone     string
+	DeviceID  string
+	RequestIP string
+	// Channel the current code was delivered over, e.g. ChannelSMS.
+	Channel   string
+	CreatedAt time.Time
+	Attempts  int
+	// Resends counts codes reissued for this challenge, e.g. to switch channel.
+	Resends int
+	// Platform of the requesting client picks an SMS format its OTP autofill
+	// understands. It only applies to the request it came with.
+	Platform string
+}
+
+// OtpAnswer is a code given for a challenge.
+type OtpAnswer struct {
+	Challenge OtpChallenge
+	Code      string
+}
+
+// Client platforms.
+const (
+	PlatformAndroid = "android"
+	PlatformIOS     = "ios"
+	PlatformWeb     = "web"
+)
+
+// PhoneChangeStatus reports the two challenges of a phone number change: one
+// for the current number, or the verified email when it was lost, and one for
+// the new number.
+type PhoneChangeStatus struct {
+	Current OtpStatus
+	New     OtpStatus
+}
+
+// OtpStatus tells clients how long a challenge stays valid and when they may
+// ask for a new code.
+type OtpStatus struct {
+	ChallengeID       string
+	Active            bool
+	Channel           string
+	ExpiresIn         time.Duration
+	AttemptsRemaining int
+	// ResendAfter is zero when a new code may be requested right away.
+	ResendAfter       time.Duration
+	RequestsRemaining int
+}
@@ -0,0 +1,91 @@
+package entities
+
+import "time"
+
+type OutboxStatus string
+
+const (
+	OutboxStatusPending    OutboxStatus = "pending"
+	OutboxStatusProcessing OutboxStatus = "processing"
+	OutboxStatusSent       OutboxStatus = "sent"
+	// OutboxStatusDead marks dead letters: messages that ran out of attempts
+	// or expired before they could be delivered.
+	OutboxStatusDead OutboxStatus = "dead"
+)
+
+// Delivery channels. Email needs an address on the account; the others
+// reach the user through their phone number.
+const (
+	ChannelSMS      = "sms"
+	ChannelVoice    = "voice"
+	ChannelEmail    = "email"
+	ChannelTelegram = "telegram"
+	ChannelWhatsApp = "whatsapp"
+)
+
+var Channels = []string{ChannelSMS, ChannelVoice, ChannelEmail, ChannelTelegram, ChannelWhatsApp}
+
+// OutboxMessage is a message waiting for, or done with, asynchronous delivery.
+// DedupeKey makes enqueueing idempotent.
+type OutboxMessage struct {
+	ID            int64        `json:"id"`
+	DedupeKey     string       `json:"dedupe_key"`
+	Channel       string       `json:"channel"`
+	Recipient     string       `json:"recipient"`
+	Body          string       `json:"-"`
+	Status        OutboxStatus `json:"status"`
+	Attempts      int          `json:"attempts"`
+	MaxAttempts   int          `json:"max_attempts"`
+	NextAttemptAt time.Time    `json:"next_attempt_at"`
+	LastError     string       `json:"last_error,omitempty"`
+	ExpiresAt     *time.Time   `json:"expires_at,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+	SentAt        *time.Time   `json:"sent_at,omitempty"`
+	// Provider and ProviderMessageID record which gateway delivered the message.
+	Provider          string `json:"provider,omitempty"`
+	ProviderMessageID string `json:"provider_message_id,omitempty"`
+	// The Delivery* fields hold the latest delivery report from the provider.
+	DeliveryStatus     DeliveryStatus `json:"delivery_status,omitempty"`
+	DeliveryError      string         `json:"delivery_error,omitempty"`
+	DeliveryReportedAt *time.Time     `json:"delivery_reported_at,omitempty"`
+}
+
+// DeliveryStatus is what a provider's delivery report (DLR) says happened to
+// a message after the provider accepted it.
+type DeliveryStatus string
+
+const (
+	// DeliveryStatusAccepted means the carrier has the message but has not
+	// reached the handset yet. Every other status is final.
+	DeliveryStatusAccepted    DeliveryStatus = "accepted"
+	DeliveryStatusDelivered   DeliveryStatus = "delivered"
+	DeliveryStatusUndelivered DeliveryStatus = "undelivered"
+	DeliveryStatusFailed      DeliveryStatus = "failed"
+	DeliveryStatusExpired     DeliveryStatus = "expired"
+	DeliveryStatusRejected    DeliveryStatus = "rejected"
+)
+
+// DeliveryReport is a delivery report matched to a message by the provider's message ID.
+type DeliveryReport struct {
+	Provider          string
+	ProviderMessageID string
+	Status            DeliveryStatus
+	ErrorCode         string
+	ReportedAt        time.Time
+}
+
+// Delivery is a sender's receipt for an accepted message.
+type Delivery struct {
+	Provider          string
+	ProviderMessageID string
+}
+
+type OutboxStats struct {
+	Counts map[OutboxStatus]int `json:"counts"`
+	// Stuck counts messages whose worker lease ran out without a result.
+	Stuck           int        `json:"stuck"`
+	OldestPendingAt *time.Time `json:"oldest_pending_at,omitempty"`
+	// DeliveryReports counts messages by provider and their latest delivery status.
+	DeliveryReports map[string]map[DeliveryStatus]int `json:"delivery_reports"`
+}
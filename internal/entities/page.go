@@ -0,0 +1,9 @@
+package entities
+
+// Page is one page of a keyset-paginated list. NextCursor is empty on the last
+// page and Total is only set when it was asked for.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+}
@@ -0,0 +1,20 @@
+package entities
+
+import "time"
+
+// RefreshToken is a stored refresh token. Trading it for a new pair marks it
+// used and stores its successor in the same family, so a family is one
+// sign-in on one device.
+type RefreshToken struct {
+	// ID is the token's jti claim; it is not exported with the account data.
+	ID        string     `json:"-"`
+	FamilyID  string     `json:"session_id"`
+	UserID    uint32     `json:"user_id"`
+	DeviceID  string     `json:"device_id,omitempty"`
+	IP        string     `json:"ip,omitempty"`
+	UserAgent string     `json:"user_agent,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
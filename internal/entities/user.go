@@ -3,7 +3,180 @@ package entities
 import "time"
 
 type User struct {
-	Id        uint32
-	Phone     string
-	CreatedAt time.Time
+	Id    uint32 `json:"id"`
+	Phone string `json:"phone"`
+	// IsTest marks accounts created through a test phone number (TEST_PHONES).
+	IsTest      bool   `json:"is_test"`
+	DisplayName string `json:"display_name,omitempty"`
+	Email       string `json:"email,omitempty"`
+	// EmailVerified is set once the user proved they own Email, at EmailVerifiedAt.
+	EmailVerified   bool       `json:"email_verified"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	Locale          string     `json:"locale,omitempty"`
+	Timezone        string     `json:"timezone,omitempty"`
+	AvatarURL       string     `json:"avatar_url,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	// TokensInvalidBefore revokes every token issued before it, e.g. after a
+	// phone number change.
+	TokensInvalidBefore *time.Time `json:"-"`
+	// DeletedAt is set for accounts deleted by their user; they are purged
+	// after PurgeAfter unless the user signs in again.
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	PurgeAfter *time.Time `json:"purge_after,omitempty"`
+	// Status blocks suspended and banned users from signing in and from
+	// using their tokens.
+	Status         UserStatus `json:"status"`
+	StatusReason   string     `json:"status_reason,omitempty"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+}
+
+type UserStatus string
+
+const (
+	UserStatusActive    UserStatus = "active"
+	UserStatusSuspended UserStatus = "suspended"
+	UserStatusBanned    UserStatus = "banned"
+)
+
+// Blocked reports whether the user is banned, or suspended and the
+// suspension has not run out by now.
+func (u User) Blocked(now time.Time) bool {
+	switch u.Status {
+	case UserStatusBanned:
+		return true
+	case UserStatusSuspended:
+		return u.SuspendedUntil == nil || now.Before(*u.SuspendedUntil)
+	default:
+		return false
+	}
+}
+
+// UserStatusChange is one entry of a user's status history. ActorID is the
+// admin who made the change, nil once that account is gone.
+type UserStatusChange struct {
+	ID             int64      `json:"id"`
+	UserID         uint32     `json:"user_id"`
+	Status         UserStatus `json:"status"`
+	Reason         string     `json:"reason,omitempty"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+	ActorID        *uint32    `json:"actor_id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// UserExport is everything stored about a user, for data export requests.
+type UserExport struct {
+	ExportedAt time.Time `json:"exported_at"`
+	User       User      `json:"user"`
+	// Messages are the codes sent to the user, without their bodies.
+	Messages []OutboxMessage `json:"messages"`
+	// AuthEvents is the login history still kept, including attempts made
+	// before the phone had an account.
+	AuthEvents    []AuthEvent        `json:"auth_events"`
+	StatusHistory []UserStatusChange `json:"status_history"`
+	// AuditLog lists the user's own audited actions and those done to the
+	// account.
+	AuditLog []AuditEntry `json:"audit_log"`
+	// Sessions are the refresh tokens that can still be traded for a new pair.
+	Sessions []RefreshToken `json:"sessions"`
+}
+
+// UserProfileUpdate changes the non-nil fields; an empty string clears a field.
+type UserProfileUpdate struct {
+	DisplayName *string
+	Email       *string
+	Locale      *string
+	Timezone    *string
+	AvatarURL   *string
+}
+
+type UserSortField string
+
+const (
+	UserSortID        UserSortField = "id"
+	UserSortCreatedAt UserSortField = "created_at"
+	UserSortPhone     UserSortField = "phone"
+)
+
+// UserFilter narrows a user listing to the users matching every condition.
+// Deleted users are always left out.
+type UserFilter struct {
+	Conditions []UserCondition
+}
+
+type UserFilterField string
+
+const (
+	UserFieldPhone         UserFilterField = "phone"
+	UserFieldEmail         UserFilterField = "email"
+	UserFieldEmailVerified UserFilterField = "email_verified"
+	UserFieldDisplayName   UserFilterField = "display_name"
+	UserFieldLocale        UserFilterField = "locale"
+	UserFieldTimezone      UserFilterField = "timezone"
+	UserFieldStatus        UserFilterField = "status"
+	UserFieldIsTest        UserFilterField = "is_test"
+	UserFieldCreatedAt     UserFilterField = "created_at"
+)
+
+type UserFilterOp string
+
+const (
+	UserFilterEq       UserFilterOp = "eq"
+	UserFilterContains UserFilterOp = "contains"
+	UserFilterPrefix   UserFilterOp = "prefix"
+	UserFilterGt       UserFilterOp = "gt"
+	UserFilterGte      UserFilterOp = "gte"
+	UserFilterLt       UserFilterOp = "lt"
+	UserFilterLte      UserFilterOp = "lte"
+)
+
+// UserCondition compares Field with Value, which is a string, a bool or a
+// time.Time depending on the field. Text comparisons ignore case.
+type UserCondition struct {
+	Field   UserFilterField
+	Op      UserFilterOp
+	Value   any
+	Negated bool
+}
+
+// UserQuery selects up to Limit users matching the filter in Sort order,
+// ties broken by id, starting after After.
+type UserQuery struct {
+	UserFilter
+	Sort  UserSortField
+	Desc  bool
+	After *UserCursor
+	Limit int
+}
+
+// UserCursor is the sort key of the last user on a page. Sort and Desc tie
+// it to the order it was issued for.
+type UserCursor struct {
+	Sort      UserSortField `json:"s"`
+	Desc      bool          `json:"d,omitempty"`
+	CreatedAt *time.Time    `json:"c,omitempty"`
+	Phone     string        `json:"p,omitempty"`
+	ID        uint32        `json:"i"`
+}
+
+// UserImportReport sums up a bulk import. Rows count from 1 for the first
+// data row, not counting a CSV header.
+type UserImportReport struct {
+	DryRun bool `json:"dry_run"`
+	Rows   int  `json:"rows"`
+	// Created is the number of users created, or that would be created on
+	// a dry run.
+	Created int                  `json:"created"`
+	Skipped int                  `json:"skipped"`
+	Failed  int                  `json:"failed"`
+	Errors  []UserImportRowError `json:"errors"`
+}
+
+// UserImportRowError explains why a row was skipped or failed. Rows for
+// already registered phones are skipped; invalid rows fail.
+type UserImportRowError struct {
+	Row     int    `json:"row"`
+	Phone   string `json:"phone,omitempty"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Error   string `json:"error"`
 }
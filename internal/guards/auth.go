@@ -1,21 +1,31 @@
 package guards
 
 import (
+	"errors"
 	"net/http"
+	"slices"
 
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/usecases"
 	"github.com/gin-gonic/gin"
 )
 
 type authGuard struct {
 	authService usecases.AuthService
+	adminPhones []string
 }
 
-func NewAuthGuard(authService usecases.AuthService) AuthGuard {
-	return &authGuard{authService: authService}
+func NewAuthGuard(authService usecases.AuthService, adminPhones []string) AuthGuard {
+	return &authGuard{authService: authService, adminPhones: adminPhones}
 }
 func (ag *authGuard) JwtGuard(c *gin.Context) {
 	user, err := ag.authService.ValidateToken(c.Request.Context(), c.GetHeader("Authorization"))
+	var blocked *usecases.UserBlockedError
+	if errors.As(err, &blocked) {
+		c.JSON(http.StatusForbidden, gin.H{"error": blocked.Error()})
+		c.Abort()
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{})
 		c.Abort()
@@ -25,3 +35,21 @@ func (ag *authGuard) JwtGuard(c *gin.Context) {
 	c.Set("user", user)
 	c.Next()
 }
+
+// AdminGuard must run after JwtGuard.
+func (ag *authGuard) AdminGuard(c *gin.Context) {
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{})
+		c.Abort()
+		return
+	}
+
+	if u, ok := user.(entities.User); !ok || !slices.Contains(ag.adminPhones, u.Phone) {
+		c.JSON(http.StatusForbidden, gin.H{})
+		c.Abort()
+		return
+	}
+
+	c.Next()
+}
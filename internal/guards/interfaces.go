@@ -7,5 +7,6 @@ import (
 type (
 	AuthGuard interface {
 		JwtGuard(c *gin.Context)
+		AdminGuard(c *gin.Context)
 	}
 )
@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const auditColumns = `id, actor_id, action, COALESCE(target_type, ''), COALESCE(target_id, ''),
+	COALESCE(request_id, ''), COALESCE(ip, ''), diff, created_at, COALESCE(prev_hash, ''), hash`
+
+type auditRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAuditRepository(db *pgxpool.Pool) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+// Append takes a lock that only other appends wait on, so entries are chained
+// one after another even with several service instances.
+func (r *auditRepository) Append(ctx context.Context, entry entities.AuditEntry, seal func(*entities.AuditEntry)) (entities.AuditEntry, error) {
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `LOCK TABLE audit_log IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+			return err
+		}
+		var lastID int64
+		var lastHash string
+		err := tx.QueryRow(ctx, `SELECT id, hash FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&lastID, &lastHash)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		entry.ID = lastID + 1
+		entry.PrevHash = lastHash
+		seal(&entry)
+
+		_, err = tx.Exec(ctx,
+			`INSERT INTO audit_log (id, actor_id, action, target_type, target_id, request_id, ip, diff, created_at, prev_hash, hash)
+			VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), $8, $9, NULLIF($10, ''), $11)`,
+			entry.ID, entry.ActorID, string(entry.Action), entry.TargetType, entry.TargetID, entry.RequestID, entry.IP,
+			string(entry.Diff), entry.CreatedAt, entry.PrevHash, entry.Hash,
+		)
+		return err
+	})
+	if err != nil {
+		return entities.AuditEntry{}, err
+	}
+	return entry, nil
+}
+
+func (r *auditRepository) List(ctx context.Context, q entities.AuditQuery) ([]entities.AuditEntry, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT `+auditColumns+`
+		FROM audit_log
+		WHERE ($1::integer IS NULL OR actor_id = $1)
+			AND ($2 = '' OR action = $2)
+			AND ($3 = '' OR target_type = $3)
+			AND ($4 = '' OR target_id = $4)
+			AND ($5 = '' OR request_id = $5)
+			AND ($6::timestamptz IS NULL OR created_at >= $6)
+			AND ($7::timestamptz IS NULL OR created_at <= $7)
+			AND ($8 = 0 OR id < $8)
+		ORDER BY id DESC
+		LIMIT $9`,
+		q.ActorID, string(q.Action), q.TargetType, q.TargetID, q.RequestID, q.From, q.To, q.BeforeID, q.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, scanAuditEntry)
+}
+
+func (r *auditRepository) ListForUser(ctx context.Context, userID uint32) ([]entities.AuditEntry, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT `+auditColumns+`
+		FROM audit_log
+		WHERE actor_id = $1 OR (target_type = 'user' AND target_id = $1::text)
+		ORDER BY id DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, scanAuditEntry)
+}
+
+func (r *auditRepository) ListAfter(ctx context.Context, afterID int64, limit int) ([]entities.AuditEntry, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT `+auditColumns+` FROM audit_log WHERE id > $1 ORDER BY id LIMIT $2`,
+		afterID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, scanAuditEntry)
+}
+
+func scanAuditEntry(row pgx.CollectableRow) (entities.AuditEntry, error) {
+	var e entities.AuditEntry
+	var actorID *int32
+	var diff string
+	err := row.Scan(&e.ID, &actorID, &e.Action, &e.TargetType, &e.TargetID, &e.RequestID, &e.IP,
+		&diff, &e.CreatedAt, &e.PrevHash, &e.Hash)
+	if actorID != nil {
+		actor := uint32(*actorID)
+		e.ActorID = &actor
+	}
+	e.Diff = []byte(diff)
+	return e, err
+}
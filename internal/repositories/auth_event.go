@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type authEventRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAuthEventRepository(db *pgxpool.Pool) AuthEventRepository {
+	return &authEventRepository{db: db}
+}
+
+// Record attributes an event without a user to the account that has its phone, if any.
+func (r *authEventRepository) Record(ctx context.Context, e entities.AuthEvent) error {
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO auth_events (user_id, phone, kind, outcome, reason, channel, device_id, ip, user_agent)
+		VALUES (
+			COALESCE($1, (SELECT id FROM users WHERE phone = $2)),
+			NULLIF($2, ''), $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, '')
+		)`,
+		e.UserID, e.Phone, string(e.Kind), string(e.Outcome), e.Reason, e.Channel, e.DeviceID, e.IP, e.UserAgent,
+	)
+	return err
+}
+
+func (r *authEventRepository) List(ctx context.Context, q entities.AuthEventQuery) ([]entities.AuthEvent, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT id, user_id, COALESCE(phone, ''), kind, outcome, COALESCE(reason, ''), COALESCE(channel, ''),
+			COALESCE(device_id, ''), COALESCE(ip, ''), COALESCE(user_agent, ''), created_at
+		FROM auth_events
+		WHERE (user_id = $1 OR ($5 <> '' AND user_id IS NULL AND phone = $5))
+			AND ($2 = '' OR kind = $2)
+			AND ($3 = 0 OR id < $3)
+		ORDER BY id DESC
+		LIMIT NULLIF($4, 0)`,
+		q.UserID, string(q.Kind), q.BeforeID, q.Limit, q.Phone,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (entities.AuthEvent, error) {
+		var e entities.AuthEvent
+		var userID *int32
+		err := row.Scan(&e.ID, &userID, &e.Phone, &e.Kind, &e.Outcome, &e.Reason, &e.Channel,
+			&e.DeviceID, &e.IP, &e.UserAgent, &e.CreatedAt)
+		if userID != nil {
+			id := uint32(*userID)
+			e.UserID = &id
+		}
+		return e, err
+	})
+}
+
+func (r *authEventRepository) Purge(ctx context.Context, before time.Time, limit int) (int64, error) {
+	tag, err := r.db.Exec(ctx,
+		`DELETE FROM auth_events WHERE id IN (
+			SELECT id FROM auth_events
+			WHERE created_at < $1
+			ORDER BY created_at
+			LIMIT $2
+		)`,
+		before, limit,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
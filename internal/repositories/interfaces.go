@@ -12,6 +12,78 @@ type (
 		GetUserByPhone(ctx context.Context, phone string) (entities.User, error)
 		GetUserById(ctx context.Context, id uint32) (entities.User, error)
 		CreateUser(ctx context.Context, user entities.User) (entities.User, error)
-		GetAllUsers(ctx context.Context, skip, limit uint32, phoneSearchTerm *string, creationFrom, creationTo *time.Time) ([]entities.User, error)
+		// CreateUsers skips users whose phone or email is taken and returns the others.
+		CreateUsers(ctx context.Context, users []entities.User) ([]entities.User, error)
+		TakenPhonesAndEmails(ctx context.Context, phones, emails []string) ([]string, []string, error)
+		// UpdateProfile fails on the users_email_key index when another account has the email.
+		UpdateProfile(ctx context.Context, id uint32, update entities.UserProfileUpdate) (entities.User, error)
+		ChangePhone(ctx context.Context, id uint32, oldPhone, newPhone string) (entities.User, error)
+		MarkEmailVerified(ctx context.Context, id uint32, email string) (entities.User, error)
+		SoftDelete(ctx context.Context, id uint32, purgeAfter time.Time) (entities.User, error)
+		// Restore undoes SoftDelete.
+		Restore(ctx context.Context, id uint32) (entities.User, error)
+		PurgeDeleted(ctx context.Context, limit int) (int64, error)
+		SetStatus(ctx context.Context, change entities.UserStatusChange) (entities.User, error)
+		// ListStatusHistory returns the user's status changes, newest first.
+		ListStatusHistory(ctx context.Context, userID uint32) ([]entities.UserStatusChange, error)
+		ListUsers(ctx context.Context, query entities.UserQuery) ([]entities.User, error)
+		CountUsers(ctx context.Context, filter entities.UserFilter) (int64, error)
+	}
+
+	OutboxRepository interface {
+		// Enqueue reports false when a message with the same dedupe key exists.
+		Enqueue(ctx context.Context, message entities.OutboxMessage) (bool, error)
+		// Claim locks up to limit due messages for lease and counts an attempt on each.
+		Claim(ctx context.Context, limit int, lease time.Duration) ([]entities.OutboxMessage, error)
+		MarkSent(ctx context.Context, id int64, delivery entities.Delivery) error
+		MarkFailed(ctx context.Context, id int64, lastError string, nextAttemptAt time.Time) error
+		MarkDead(ctx context.Context, id int64, lastError string) error
+		List(ctx context.Context, status entities.OutboxStatus, limit uint32) ([]entities.OutboxMessage, error)
+		// ListByDedupeKey returns the message with dedupeKey and those keyed "dedupeKey:..." (resends), oldest first.
+		ListByDedupeKey(ctx context.Context, dedupeKey string) ([]entities.OutboxMessage, error)
+		// ListByRecipients returns the messages sent to any of recipients, oldest first.
+		ListByRecipients(ctx context.Context, recipients []string) ([]entities.OutboxMessage, error)
+		// RecordDelivery stores a delivery report unless a newer one is already stored.
+		// It reports false when no message has the report's provider message ID.
+		RecordDelivery(ctx context.Context, report entities.DeliveryReport) (bool, error)
+		Stats(ctx context.Context) (entities.OutboxStats, error)
+		// Requeue schedules a dead message again if its body was kept.
+		Requeue(ctx context.Context, id int64) error
+		// Purge clears expired bodies and deletes finished messages older than before.
+		Purge(ctx context.Context, before time.Time) (int64, error)
+	}
+
+	AuthEventRepository interface {
+		Record(ctx context.Context, event entities.AuthEvent) error
+		// List returns the events matching the query, newest first.
+		List(ctx context.Context, query entities.AuthEventQuery) ([]entities.AuthEvent, error)
+		// Purge deletes up to limit events created before before.
+		Purge(ctx context.Context, before time.Time, limit int) (int64, error)
+	}
+
+	RefreshTokenRepository interface {
+		// Create stores token and deletes the user's expired tokens.
+		Create(ctx context.Context, token entities.RefreshToken) error
+		// Use marks the token used. It reports false, with the token as stored,
+		// when the token was already used or revoked.
+		Use(ctx context.Context, id string) (entities.RefreshToken, bool, error)
+		// RevokeFamily revokes every token of the family the token belongs to.
+		RevokeFamily(ctx context.Context, id string) error
+		// ListSessions returns the latest token of each of the user's live
+		// families, newest first: signed out and expired ones are left out.
+		ListSessions(ctx context.Context, userID uint32) ([]entities.RefreshToken, error)
+	}
+
+	// AuditRepository is append-only: the table refuses updates and deletes.
+	AuditRepository interface {
+		// Append numbers entry after the last one, links it to the last
+		// one's hash and lets seal compute its own before storing it.
+		Append(ctx context.Context, entry entities.AuditEntry, seal func(*entities.AuditEntry)) (entities.AuditEntry, error)
+		// List returns the entries matching the query, newest first.
+		List(ctx context.Context, query entities.AuditQuery) ([]entities.AuditEntry, error)
+		// ListForUser returns the entries the user made or that target them, newest first.
+		ListForUser(ctx context.Context, userID uint32) ([]entities.AuditEntry, error)
+		// ListAfter returns up to limit entries after afterID, oldest first.
+		ListAfter(ctx context.Context, afterID int64, limit int) ([]entities.AuditEntry, error)
 	}
 )
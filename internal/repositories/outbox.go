@@ -0,0 +1,259 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const outboxColumns = `id, dedupe_key, channel, recipient, COALESCE(body, ''), status, attempts, max_attempts,
+	next_attempt_at, COALESCE(last_error, ''), expires_at, created_at, updated_at, sent_at,
+	COALESCE(provider, ''), COALESCE(provider_message_id, ''), COALESCE(delivery_status, ''),
+	COALESCE(delivery_error, ''), delivery_reported_at`
+
+type outboxRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewOutboxRepository(db *pgxpool.Pool) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) Enqueue(ctx context.Context, m entities.OutboxMessage) (bool, error) {
+	tag, err := r.db.Exec(ctx,
+		`INSERT INTO message_outbox (dedupe_key, channel, recipient, body, max_attempts, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (dedupe_key) DO NOTHING`,
+		m.DedupeKey, m.Channel, m.Recipient, m.Body, m.MaxAttempts, m.ExpiresAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Claim uses SKIP LOCKED so several service instances can poll the same table.
+// Messages whose lease ran out (a worker crashed mid-delivery) are claimed again.
+func (r *outboxRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]entities.OutboxMessage, error) {
+	rows, err := r.db.Query(ctx,
+		`UPDATE message_outbox SET
+			status = 'processing',
+			attempts = attempts + 1,
+			locked_until = now() + $2 * interval '1 millisecond',
+			updated_at = now()
+		WHERE id IN (
+			SELECT id FROM message_outbox
+			WHERE (status = 'pending' AND next_attempt_at <= now())
+				OR (status = 'processing' AND locked_until < now())
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+outboxColumns,
+		limit, lease.Milliseconds(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return collectOutboxMessages(rows)
+}
+
+func (r *outboxRepository) MarkSent(ctx context.Context, id int64, delivery entities.Delivery) error {
+	_, err := r.db.Exec(ctx,
+		`UPDATE message_outbox SET status = 'sent', body = NULL, last_error = NULL, locked_until = NULL,
+			provider = NULLIF($2, ''), provider_message_id = NULLIF($3, ''), sent_at = now(), updated_at = now()
+		WHERE id = $1`,
+		id, delivery.Provider, delivery.ProviderMessageID,
+	)
+	return err
+}
+
+func (r *outboxRepository) MarkFailed(ctx context.Context, id int64, lastError string, nextAttemptAt time.Time) error {
+	_, err := r.db.Exec(ctx,
+		`UPDATE message_outbox SET status = 'pending', last_error = $2, next_attempt_at = $3, locked_until = NULL,
+			updated_at = now()
+		WHERE id = $1`,
+		id, lastError, nextAttemptAt,
+	)
+	return err
+}
+
+// MarkDead keeps the body of a dead letter until it expires so it can be requeued.
+func (r *outboxRepository) MarkDead(ctx context.Context, id int64, lastError string) error {
+	_, err := r.db.Exec(ctx,
+		`UPDATE message_outbox SET status = 'dead', last_error = $2, locked_until = NULL, updated_at = now(),
+			body = CASE WHEN expires_at IS NOT NULL AND expires_at <= now() THEN NULL ELSE body END
+		WHERE id = $1`,
+		id, lastError,
+	)
+	return err
+}
+
+func (r *outboxRepository) List(ctx context.Context, status entities.OutboxStatus, limit uint32) ([]entities.OutboxMessage, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT `+outboxColumns+` FROM message_outbox
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at DESC
+		LIMIT $2`,
+		string(status), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return collectOutboxMessages(rows)
+}
+
+func (r *outboxRepository) ListByDedupeKey(ctx context.Context, dedupeKey string) ([]entities.OutboxMessage, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT `+outboxColumns+` FROM message_outbox
+		WHERE dedupe_key = $1 OR starts_with(dedupe_key, $1 || ':')
+		ORDER BY created_at, id`,
+		dedupeKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return collectOutboxMessages(rows)
+}
+
+func (r *outboxRepository) ListByRecipients(ctx context.Context, recipients []string) ([]entities.OutboxMessage, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT `+outboxColumns+` FROM message_outbox
+		WHERE recipient = ANY($1)
+		ORDER BY created_at, id`,
+		recipients,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return collectOutboxMessages(rows)
+}
+
+// RecordDelivery ignores reports older than the stored one, since providers
+// may deliver "accepted" after "delivered" when they retry webhooks.
+func (r *outboxRepository) RecordDelivery(ctx context.Context, report entities.DeliveryReport) (bool, error) {
+	var matched bool
+	err := r.db.QueryRow(ctx,
+		`WITH target AS (
+			SELECT id, delivery_reported_at FROM message_outbox
+			WHERE provider = $1 AND provider_message_id = $2
+			FOR UPDATE
+		), updated AS (
+			UPDATE message_outbox m SET delivery_status = $3, delivery_error = NULLIF($4, ''),
+				delivery_reported_at = $5, updated_at = now()
+			FROM target t
+			WHERE m.id = t.id AND (t.delivery_reported_at IS NULL OR t.delivery_reported_at <= $5)
+			RETURNING m.id
+		)
+		SELECT EXISTS (SELECT 1 FROM target)`,
+		report.Provider, report.ProviderMessageID, string(report.Status), report.ErrorCode, report.ReportedAt,
+	).Scan(&matched)
+	return matched, err
+}
+
+func (r *outboxRepository) Stats(ctx context.Context) (entities.OutboxStats, error) {
+	stats := entities.OutboxStats{Counts: map[entities.OutboxStatus]int{}}
+
+	rows, err := r.db.Query(ctx, `SELECT status, count(*) FROM message_outbox GROUP BY status`)
+	if err != nil {
+		return entities.OutboxStats{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return entities.OutboxStats{}, err
+		}
+		stats.Counts[entities.OutboxStatus(status)] = count
+	}
+	if err := rows.Err(); err != nil {
+		return entities.OutboxStats{}, err
+	}
+
+	err = r.db.QueryRow(ctx,
+		`SELECT
+			count(*) FILTER (WHERE status = 'processing' AND locked_until < now()),
+			min(created_at) FILTER (WHERE status IN ('pending', 'processing'))
+		FROM message_outbox`,
+	).Scan(&stats.Stuck, &stats.OldestPendingAt)
+	if err != nil {
+		return entities.OutboxStats{}, err
+	}
+
+	stats.DeliveryReports = map[string]map[entities.DeliveryStatus]int{}
+	rows, err = r.db.Query(ctx,
+		`SELECT provider, delivery_status, count(*) FROM message_outbox
+		WHERE provider IS NOT NULL AND delivery_status IS NOT NULL
+		GROUP BY provider, delivery_status`,
+	)
+	if err != nil {
+		return entities.OutboxStats{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var provider, status string
+		var count int
+		if err := rows.Scan(&provider, &status, &count); err != nil {
+			return entities.OutboxStats{}, err
+		}
+		if stats.DeliveryReports[provider] == nil {
+			stats.DeliveryReports[provider] = map[entities.DeliveryStatus]int{}
+		}
+		stats.DeliveryReports[provider][entities.DeliveryStatus(status)] = count
+	}
+	if err := rows.Err(); err != nil {
+		return entities.OutboxStats{}, err
+	}
+	return stats, nil
+}
+
+func (r *outboxRepository) Requeue(ctx context.Context, id int64) error {
+	var requeued int64
+	return r.db.QueryRow(ctx,
+		`UPDATE message_outbox SET status = 'pending', attempts = 0, next_attempt_at = now(), updated_at = now()
+		WHERE id = $1 AND status = 'dead' AND body IS NOT NULL AND (expires_at IS NULL OR expires_at > now())
+		RETURNING id`,
+		id,
+	).Scan(&requeued)
+}
+
+func (r *outboxRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	_, err := r.db.Exec(ctx,
+		`UPDATE message_outbox SET body = NULL WHERE status = 'dead' AND body IS NOT NULL AND expires_at <= now()`,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	tag, err := r.db.Exec(ctx,
+		`DELETE FROM message_outbox WHERE status IN ('sent', 'dead') AND updated_at < $1`,
+		before,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func collectOutboxMessages(rows pgx.Rows) ([]entities.OutboxMessage, error) {
+	defer rows.Close()
+
+	messages := make([]entities.OutboxMessage, 0)
+	for rows.Next() {
+		var m entities.OutboxMessage
+		var status, deliveryStatus string
+		if err := rows.Scan(&m.ID, &m.DedupeKey, &m.Channel, &m.Recipient, &m.Body, &status, &m.Attempts, &m.MaxAttempts,
+			&m.NextAttemptAt, &m.LastError, &m.ExpiresAt, &m.CreatedAt, &m.UpdatedAt, &m.SentAt,
+			&m.Provider, &m.ProviderMessageID, &deliveryStatus, &m.DeliveryError, &m.DeliveryReportedAt); err != nil {
+			return nil, err
+		}
+		m.Status = entities.OutboxStatus(status)
+		m.DeliveryStatus = entities.DeliveryStatus(deliveryStatus)
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const refreshTokenColumns = `id, family_id, user_id, COALESCE(device_id, ''), COALESCE(ip, ''),
+	COALESCE(user_agent, ''), created_at, expires_at, used_at, revoked_at`
+
+type refreshTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRefreshTokenRepository(db *pgxpool.Pool) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+func (r *refreshTokenRepository) Create(ctx context.Context, t entities.RefreshToken) error {
+	_, err := r.db.Exec(ctx,
+		`WITH expired AS (
+			DELETE FROM refresh_tokens WHERE user_id = $3 AND expires_at < now()
+		)
+		INSERT INTO refresh_tokens (id, family_id, user_id, device_id, ip, user_agent, expires_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7)`,
+		t.ID, t.FamilyID, t.UserID, t.DeviceID, t.IP, t.UserAgent, t.ExpiresAt,
+	)
+	return err
+}
+
+// Use locks the token, so of two concurrent refreshes with the same token
+// only the first marks it used and the second sees it as reused.
+func (r *refreshTokenRepository) Use(ctx context.Context, id string) (entities.RefreshToken, bool, error) {
+	var token entities.RefreshToken
+	var fresh bool
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var err error
+		token, err = scanRefreshToken(tx.QueryRow(ctx,
+			`SELECT `+refreshTokenColumns+` FROM refresh_tokens WHERE id = $1 FOR UPDATE`,
+			id,
+		))
+		if err != nil || token.UsedAt != nil || token.RevokedAt != nil {
+			return err
+		}
+		fresh = true
+		_, err = tx.Exec(ctx, `UPDATE refresh_tokens SET used_at = now() WHERE id = $1`, id)
+		return err
+	})
+	return token, fresh, err
+}
+
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, id string) error {
+	_, err := r.db.Exec(ctx,
+		`UPDATE refresh_tokens SET revoked_at = now()
+		WHERE family_id = (SELECT family_id FROM refresh_tokens WHERE id = $1) AND revoked_at IS NULL`,
+		id,
+	)
+	return err
+}
+
+func (r *refreshTokenRepository) ListSessions(ctx context.Context, userID uint32) ([]entities.RefreshToken, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT `+refreshTokenColumns+`
+		FROM refresh_tokens
+		WHERE user_id = $1 AND used_at IS NULL AND revoked_at IS NULL AND expires_at > now()
+		ORDER BY created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (entities.RefreshToken, error) {
+		return scanRefreshToken(row)
+	})
+}
+
+func scanRefreshToken(row pgx.Row) (entities.RefreshToken, error) {
+	var t entities.RefreshToken
+	var userID int32
+	err := row.Scan(&t.ID, &t.FamilyID, &userID, &t.DeviceID, &t.IP, &t.UserAgent,
+		&t.CreatedAt, &t.ExpiresAt, &t.UsedAt, &t.RevokedAt)
+	t.UserID = uint32(userID)
+	return t, err
+}
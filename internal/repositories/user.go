@@ -2,14 +2,20 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const userColumns = `id, phone, is_test, COALESCE(display_name, ''), COALESCE(email, ''), COALESCE(locale, ''),
+	COALESCE(timezone, ''), COALESCE(avatar_url, ''), created_at, updated_at, email_verified_at,
+	tokens_invalid_before, deleted_at, purge_after, status, COALESCE(status_reason, ''), suspended_until`
+
 type userRepository struct {
 	db *pgxpool.Pool
 }
@@ -19,85 +25,285 @@ func NewUserRepository(db *pgxpool.Pool) UserRepository {
 }
 
 func (r *userRepository) GetUserByPhone(ctx context.Context, phone string) (entities.User, error) {
-	var u entities.User
-	var id32 int32
-
-	err := r.db.QueryRow(ctx,
-		`SELECT id, phone, created_at FROM users WHERE phone = $1`,
+	return scanUser(r.db.QueryRow(ctx,
+		`SELECT `+userColumns+` FROM users WHERE phone = $1`,
 		phone,
-	).Scan(&id32, &u.Phone, &u.CreatedAt)
-	if err != nil {
-		return entities.User{}, err
-	}
-
-	u.Id = uint32(id32)
-	return u, nil
+	))
 }
 
 func (r *userRepository) GetUserById(ctx context.Context, id uint32) (entities.User, error) {
-	var u entities.User
-	var id32 int32
-
-	err := r.db.QueryRow(ctx,
-		`SELECT id, phone, created_at FROM users WHERE id = $1`,
+	return scanUser(r.db.QueryRow(ctx,
+		`SELECT `+userColumns+` FROM users WHERE id = $1`,
 		id,
-	).Scan(&id32, &u.Phone, &u.CreatedAt)
+	))
+}
+
+func (r *userRepository) CreateUser(ctx context.Context, user entities.User) (entities.User, error) {
+	return scanUser(r.db.QueryRow(ctx,
+		`INSERT INTO users (phone, is_test) VALUES ($1, $2) RETURNING `+userColumns,
+		user.Phone, user.IsTest,
+	))
+}
+
+// CreateUsers inserts users in one statement and returns the ones created.
+// Users whose phone or email is taken by then are left out.
+func (r *userRepository) CreateUsers(ctx context.Context, users []entities.User) ([]entities.User, error) {
+	phones := make([]string, len(users))
+	emails := make([]string, len(users))
+	names := make([]string, len(users))
+	locales := make([]string, len(users))
+	timezones := make([]string, len(users))
+	for i, u := range users {
+		phones[i], emails[i], names[i], locales[i], timezones[i] = u.Phone, u.Email, u.DisplayName, u.Locale, u.Timezone
+	}
+	rows, err := r.db.Query(ctx,
+		`INSERT INTO users (phone, email, display_name, locale, timezone)
+		SELECT phone, NULLIF(email, ''), NULLIF(display_name, ''), NULLIF(locale, ''), NULLIF(timezone, '')
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[]) AS t (phone, email, display_name, locale, timezone)
+		ON CONFLICT DO NOTHING
+		RETURNING `+userColumns,
+		phones, emails, names, locales, timezones,
+	)
 	if err != nil {
-		return entities.User{}, err
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (entities.User, error) {
+		return scanUser(row)
+	})
+}
+
+// TakenPhonesAndEmails returns which of phones and emails already belong to a
+// user, deleted ones included. Emails are compared case-insensitively and
+// returned in lower case.
+func (r *userRepository) TakenPhonesAndEmails(ctx context.Context, phones, emails []string) ([]string, []string, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT phone, '' FROM users WHERE phone = ANY($1)
+		UNION ALL
+		SELECT '', lower(email) FROM users WHERE lower(email) = ANY($2)`,
+		phones, emails,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var takenPhones, takenEmails []string
+	for rows.Next() {
+		var phone, email string
+		if err := rows.Scan(&phone, &email); err != nil {
+			return nil, nil, err
+		}
+		if phone != "" {
+			takenPhones = append(takenPhones, phone)
+		} else {
+			takenEmails = append(takenEmails, email)
+		}
 	}
+	return takenPhones, takenEmails, rows.Err()
+}
 
-	u.Id = uint32(id32)
-	return u, nil
+func (r *userRepository) UpdateProfile(ctx context.Context, id uint32, update entities.UserProfileUpdate) (entities.User, error) {
+	sets := []string{"updated_at = now()"}
+	args := []any{id}
+	for _, field := range []struct {
+		column string
+		value  *string
+	}{
+		{"display_name", update.DisplayName},
+		{"email", update.Email},
+		{"locale", update.Locale},
+		{"timezone", update.Timezone},
+		{"avatar_url", update.AvatarURL},
+	} {
+		if field.value == nil {
+			continue
+		}
+		args = append(args, *field.value)
+		sets = append(sets, fmt.Sprintf("%s = NULLIF($%d, '')", field.column, len(args)))
+		if field.column == "email" {
+			// a new address has to be verified again
+			sets = append(sets, fmt.Sprintf("email_verified_at = CASE WHEN lower(email) = lower($%d) THEN email_verified_at END", len(args)))
+		}
+	}
+
+	return scanUser(r.db.QueryRow(ctx,
+		`UPDATE users SET `+strings.Join(sets, ", ")+` WHERE id = $1 RETURNING `+userColumns,
+		args...,
+	))
 }
 
-func (r *userRepository) CreateUser(ctx context.Context, user entities.User) (entities.User, error) {
-	var u entities.User
-	var id32 int32
+// ChangePhone moves the account from oldPhone to newPhone and revokes its
+// tokens. It fails with pgx.ErrNoRows if the phone is no longer oldPhone.
+// Tokens carry whole seconds, so revocation is truncated to match.
+func (r *userRepository) ChangePhone(ctx context.Context, id uint32, oldPhone, newPhone string) (entities.User, error) {
+	return scanUser(r.db.QueryRow(ctx,
+		`WITH updated AS (
+			UPDATE users SET phone = $3, tokens_invalid_before = date_trunc('second', now()), updated_at = now()
+			WHERE id = $1 AND phone = $2
+			RETURNING `+userColumns+`
+		), revoked AS (
+			UPDATE refresh_tokens SET revoked_at = now()
+			WHERE user_id IN (SELECT id FROM updated) AND revoked_at IS NULL
+		)
+		SELECT * FROM updated`,
+		id, oldPhone, newPhone,
+	))
+}
+
+// SoftDelete marks the account deleted and revokes its tokens. It fails with
+// pgx.ErrNoRows if the account is already deleted.
+func (r *userRepository) SoftDelete(ctx context.Context, id uint32, purgeAfter time.Time) (entities.User, error) {
+	return scanUser(r.db.QueryRow(ctx,
+		`WITH updated AS (
+			UPDATE users SET deleted_at = now(), purge_after = $2,
+				tokens_invalid_before = date_trunc('second', now()), updated_at = now()
+			WHERE id = $1 AND deleted_at IS NULL
+			RETURNING `+userColumns+`
+		), revoked AS (
+			UPDATE refresh_tokens SET revoked_at = now()
+			WHERE user_id IN (SELECT id FROM updated) AND revoked_at IS NULL
+		)
+		SELECT * FROM updated`,
+		id, purgeAfter,
+	))
+}
+
+func (r *userRepository) Restore(ctx context.Context, id uint32) (entities.User, error) {
+	return scanUser(r.db.QueryRow(ctx,
+		`UPDATE users SET deleted_at = NULL, purge_after = NULL, updated_at = now()
+		WHERE id = $1
+		RETURNING `+userColumns,
+		id,
+	))
+}
 
+// PurgeDeleted deletes up to limit accounts past their grace period together
+// with the messages sent to them and the login attempts recorded for their
+// phone before it had an account; the rest of their history cascades.
+func (r *userRepository) PurgeDeleted(ctx context.Context, limit int) (int64, error) {
+	var purged int64
 	err := r.db.QueryRow(ctx,
-		`INSERT INTO users (phone) VALUES ($1) RETURNING id, phone, created_at`,
-		user.Phone,
-	).Scan(&id32, &u.Phone, &u.CreatedAt)
+		`WITH purged AS (
+			DELETE FROM users WHERE id IN (
+				SELECT id FROM users
+				WHERE deleted_at IS NOT NULL AND purge_after <= now()
+				ORDER BY purge_after
+				LIMIT $1
+			)
+			RETURNING phone, email
+		), messages AS (
+			DELETE FROM message_outbox
+			WHERE recipient IN (SELECT phone FROM purged UNION SELECT email FROM purged WHERE email IS NOT NULL)
+		), events AS (
+			DELETE FROM auth_events
+			WHERE user_id IS NULL AND phone IN (SELECT phone FROM purged)
+		)
+		SELECT count(*) FROM purged`,
+		limit,
+	).Scan(&purged)
+	return purged, err
+}
+
+// SetStatus changes the user's status and records the change in the history.
+func (r *userRepository) SetStatus(ctx context.Context, change entities.UserStatusChange) (entities.User, error) {
+	return scanUser(r.db.QueryRow(ctx,
+		`WITH updated AS (
+			UPDATE users SET status = $2, status_reason = NULLIF($3, ''), suspended_until = $4, updated_at = now()
+			WHERE id = $1
+			RETURNING `+userColumns+`
+		), history AS (
+			INSERT INTO user_status_history (user_id, status, reason, suspended_until, actor_id)
+			SELECT id, $2, NULLIF($3, ''), $4, $5 FROM updated
+		)
+		SELECT * FROM updated`,
+		change.UserID, string(change.Status), change.Reason, change.SuspendedUntil, change.ActorID,
+	))
+}
+
+func (r *userRepository) ListStatusHistory(ctx context.Context, userID uint32) ([]entities.UserStatusChange, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT id, user_id, status, COALESCE(reason, ''), suspended_until, actor_id, created_at
+		FROM user_status_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC`,
+		userID,
+	)
 	if err != nil {
-		return entities.User{}, err
+		return nil, err
 	}
-
-	u.Id = uint32(id32)
-	return u, nil
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (entities.UserStatusChange, error) {
+		var change entities.UserStatusChange
+		var userID int32
+		var actorID *int32
+		err := row.Scan(&change.ID, &userID, &change.Status, &change.Reason, &change.SuspendedUntil, &actorID, &change.CreatedAt)
+		change.UserID = uint32(userID)
+		if actorID != nil {
+			actor := uint32(*actorID)
+			change.ActorID = &actor
+		}
+		return change, err
+	})
 }
 
-func (r *userRepository) GetAllUsers(ctx context.Context, skip, limit uint32, phoneSearchTerm *string, creationFrom, creationTo *time.Time) ([]entities.User, error) {
-	var sb strings.Builder
-	args := make([]any, 0, 5)
-	conds := make([]string, 0, 3)
-	idx := 1
+// MarkEmailVerified fails with pgx.ErrNoRows if the user's email is no longer email.
+func (r *userRepository) MarkEmailVerified(ctx context.Context, id uint32, email string) (entities.User, error) {
+	return scanUser(r.db.QueryRow(ctx,
+		`UPDATE users SET email_verified_at = COALESCE(email_verified_at, now()), updated_at = now()
+		WHERE id = $1 AND lower(email) = lower($2)
+		RETURNING `+userColumns,
+		id, email,
+	))
+}
 
-	sb.WriteString("SELECT id, phone, created_at FROM users")
+// userSortColumns maps sort fields to their column.
+var userSortColumns = map[entities.UserSortField]string{
+	entities.UserSortID:        "id",
+	entities.UserSortCreatedAt: "created_at",
+	entities.UserSortPhone:     "phone",
+}
 
-	if phoneSearchTerm != nil && *phoneSearchTerm != "" {
-		conds = append(conds, fmt.Sprintf("phone ILIKE $%d", idx))
-		args = append(args, "%"+*phoneSearchTerm+"%")
-		idx++
+// ListUsers pages through users with keyset pagination: rows come after
+// (sort column, id) of q.After, so deep pages cost as much as the first one.
+func (r *userRepository) ListUsers(ctx context.Context, q entities.UserQuery) ([]entities.User, error) {
+	column, ok := userSortColumns[q.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort field %q", q.Sort)
 	}
-	if creationFrom != nil {
-		conds = append(conds, fmt.Sprintf("created_at >= $%d", idx))
-		args = append(args, *creationFrom)
-		idx++
+	conds, args, err := userFilterConds(q.UserFilter)
+	if err != nil {
+		return nil, err
 	}
-	if creationTo != nil {
-		conds = append(conds, fmt.Sprintf("created_at <= $%d", idx))
-		args = append(args, *creationTo)
-		idx++
+	op, dir := ">", "ASC"
+	if q.Desc {
+		op, dir = "<", "DESC"
 	}
 
-	if len(conds) > 0 {
-		sb.WriteString(" WHERE ")
-		sb.WriteString(strings.Join(conds, " AND "))
+	if q.After != nil {
+		switch q.Sort {
+		case entities.UserSortID:
+			conds = append(conds, fmt.Sprintf("id %s $%d", op, len(args)+1))
+			args = append(args, int64(q.After.ID))
+		case entities.UserSortCreatedAt:
+			if q.After.CreatedAt == nil {
+				return nil, errors.New("created_at cursor without created_at")
+			}
+			conds = append(conds, fmt.Sprintf("(created_at, id) %s ($%d, $%d)", op, len(args)+1, len(args)+2))
+			args = append(args, *q.After.CreatedAt, int64(q.After.ID))
+		case entities.UserSortPhone:
+			conds = append(conds, fmt.Sprintf("(phone, id) %s ($%d, $%d)", op, len(args)+1, len(args)+2))
+			args = append(args, q.After.Phone, int64(q.After.ID))
+		}
 	}
 
-	sb.WriteString(" ORDER BY id ASC")
-	sb.WriteString(fmt.Sprintf(" OFFSET $%d LIMIT $%d", idx, idx+1))
-	args = append(args, int64(skip), int64(limit))
+	var sb strings.Builder
+	sb.WriteString("SELECT " + userColumns + " FROM users WHERE ")
+	sb.WriteString(strings.Join(conds, " AND "))
+	if q.Sort == entities.UserSortID {
+		sb.WriteString(" ORDER BY id " + dir)
+	} else {
+		sb.WriteString(fmt.Sprintf(" ORDER BY %s %s, id %s", column, dir, dir))
+	}
+	sb.WriteString(fmt.Sprintf(" LIMIT $%d", len(args)+1))
+	args = append(args, q.Limit)
 
 	rows, err := r.db.Query(ctx, sb.String(), args...)
 	if err != nil {
@@ -105,14 +311,12 @@ func (r *userRepository) GetAllUsers(ctx context.Context, skip, limit uint32, ph
 	}
 	defer rows.Close()
 
-	users := make([]entities.User, 0, limit)
+	users := make([]entities.User, 0, q.Limit)
 	for rows.Next() {
-		var u entities.User
-		var id32 int32
-		if err := rows.Scan(&id32, &u.Phone, &u.CreatedAt); err != nil {
+		u, err := scanUser(rows)
+		if err != nil {
 			return nil, err
 		}
-		u.Id = uint32(id32)
 		users = append(users, u)
 	}
 	if err := rows.Err(); err != nil {
@@ -121,3 +325,114 @@ func (r *userRepository) GetAllUsers(ctx context.Context, skip, limit uint32, ph
 
 	return users, nil
 }
+
+func (r *userRepository) CountUsers(ctx context.Context, filter entities.UserFilter) (int64, error) {
+	conds, args, err := userFilterConds(filter)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	err = r.db.QueryRow(ctx, "SELECT count(*) FROM users WHERE "+strings.Join(conds, " AND "), args...).Scan(&total)
+	return total, err
+}
+
+// userEffectiveStatus is the status as entities.User.Blocked enforces it: a
+// suspension that has run out counts as active.
+const userEffectiveStatus = `(CASE WHEN status = 'suspended' AND suspended_until <= now() THEN 'active' ELSE status END)`
+
+// userFilterColumns are the expressions conditions compare, per field.
+var userFilterColumns = map[entities.UserFilterField]string{
+	entities.UserFieldPhone:         "phone",
+	entities.UserFieldEmail:         "email",
+	entities.UserFieldEmailVerified: "(email_verified_at IS NOT NULL)",
+	entities.UserFieldDisplayName:   "display_name",
+	entities.UserFieldLocale:        "locale",
+	entities.UserFieldTimezone:      "timezone",
+	entities.UserFieldStatus:        userEffectiveStatus,
+	entities.UserFieldIsTest:        "is_test",
+	entities.UserFieldCreatedAt:     "created_at",
+}
+
+// userFilterFoldedColumns match equality filters regardless of case, through
+// the lower() indexes of migration 000013. Other columns hold one spelling
+// (status is checked to be lowercase) and are compared as they are.
+var userFilterFoldedColumns = map[entities.UserFilterField]bool{
+	entities.UserFieldLocale:   true,
+	entities.UserFieldTimezone: true,
+}
+
+var userFilterComparisons = map[entities.UserFilterOp]string{
+	entities.UserFilterGt:  ">",
+	entities.UserFilterGte: ">=",
+	entities.UserFilterLt:  "<",
+	entities.UserFilterLte: "<=",
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// userFilterConds compiles the filter into SQL conditions. Only column names
+// and operators from the maps above reach the SQL; values are always bound.
+func userFilterConds(filter entities.UserFilter) ([]string, []any, error) {
+	conds := []string{"deleted_at IS NULL"}
+	args := make([]any, 0, len(filter.Conditions))
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	for _, c := range filter.Conditions {
+		column, ok := userFilterColumns[c.Field]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown filter field %q", c.Field)
+		}
+		var cond string
+		switch c.Op {
+		case entities.UserFilterEq:
+			if text, ok := c.Value.(string); ok && userFilterFoldedColumns[c.Field] {
+				cond = fmt.Sprintf("lower(%s) = lower(%s)", column, arg(text))
+			} else {
+				cond = fmt.Sprintf("%s = %s", column, arg(c.Value))
+			}
+		case entities.UserFilterContains, entities.UserFilterPrefix:
+			text, ok := c.Value.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("%s filter on %q needs a string", c.Op, c.Field)
+			}
+			pattern := likeEscaper.Replace(text) + "%"
+			if c.Op == entities.UserFilterContains {
+				pattern = "%" + pattern
+			}
+			cond = fmt.Sprintf("%s ILIKE %s", column, arg(pattern))
+			// Phones are stored as entered, so a prefix without + also
+			// matches numbers that have one.
+			if c.Field == entities.UserFieldPhone && c.Op == entities.UserFilterPrefix && !strings.HasPrefix(text, "+") {
+				cond = fmt.Sprintf("(%s OR %s ILIKE %s)", cond, column, arg("+"+pattern))
+			}
+		default:
+			op, ok := userFilterComparisons[c.Op]
+			if !ok {
+				return nil, nil, fmt.Errorf("unknown filter operator %q", c.Op)
+			}
+			cond = fmt.Sprintf("%s %s %s", column, op, arg(c.Value))
+		}
+		if c.Negated {
+			cond = fmt.Sprintf("NOT COALESCE(%s, false)", cond)
+		}
+		conds = append(conds, cond)
+	}
+	return conds, args, nil
+}
+
+func scanUser(row pgx.Row) (entities.User, error) {
+	var u entities.User
+	var id32 int32
+	err := row.Scan(&id32, &u.Phone, &u.IsTest, &u.DisplayName, &u.Email, &u.Locale,
+		&u.Timezone, &u.AvatarURL, &u.CreatedAt, &u.UpdatedAt, &u.EmailVerifiedAt,
+		&u.TokensInvalidBefore, &u.DeletedAt, &u.PurgeAfter, &u.Status, &u.StatusReason, &u.SuspendedUntil)
+	if err != nil {
+		return entities.User{}, err
+	}
+	u.Id = uint32(id32)
+	u.EmailVerified = u.EmailVerifiedAt != nil
+	return u, nil
+}
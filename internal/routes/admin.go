@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/controllers"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/guards"
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterAdminV1Router(ginEngine *gin.RouterGroup, adminController controllers.AdminController, authGuard guards.AuthGuard) {
+	adminGroup := ginEngine.Group("/admin", authGuard.JwtGuard, authGuard.AdminGuard)
+
+	adminGroup.GET("/settings", adminController.GetSettings)
+	adminGroup.POST("/settings/reload", adminController.ReloadSettings)
+	adminGroup.GET("/outbox", adminController.ListOutboxMessages)
+	adminGroup.GET("/outbox/stats", adminController.GetOutboxStats)
+	adminGroup.POST("/outbox/:id/retry", adminController.RetryOutboxMessage)
+	adminGroup.GET("/metrics", adminController.GetMetrics)
+	adminGroup.GET("/audit", adminController.GetAuditLog)
+	adminGroup.GET("/otp/:challengeId/deliveries", adminController.GetOtpDeliveries)
+	adminGroup.GET("/users/export", adminController.ExportUsers)
+	adminGroup.POST("/users/import", adminController.ImportUsers)
+	adminGroup.PUT("/users/:id/status", adminController.SetUserStatus)
+	adminGroup.GET("/users/:id/status-history", adminController.GetUserStatusHistory)
+	adminGroup.GET("/users/:id/activity", adminController.GetUserActivity)
+}
@@ -6,9 +6,13 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterAuthV1Router(ginEngine *gin.RouterGroup, authController controllers.AuthController, authGuard guards.AuthGuard) {
-	authGroup := ginEngine.Group("/auth")
+func RegisterAuthV1Router(ginEngine *gin.RouterGroup, authController controllers.AuthController, authGuard guards.AuthGuard, middlewares ...gin.HandlerFunc) {
+	authGroup := ginEngine.Group("/auth", middlewares...)
 
 	authGroup.POST("/request-otp", authController.LoginOtp)
+	authGroup.POST("/resend-otp", authController.ResendLoginOtp)
+	authGroup.GET("/otp-status", authController.LoginOtpStatus)
 	authGroup.POST("/verify-otp", authController.VerifyLoginOTP)
+	authGroup.POST("/refresh", authController.RefreshToken)
+	authGroup.POST("/logout", authController.Logout)
 }
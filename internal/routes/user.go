@@ -10,5 +10,15 @@ func RegisterUserV1Router(ginEngine *gin.RouterGroup, usersController controller
 	usersGroup := ginEngine.Group("/users")
 
 	usersGroup.GET("/profile", authGuard.JwtGuard, usersController.GetUser)
-	usersGroup.GET("/", authGuard.JwtGuard, usersController.GetAllUsers)
+	usersGroup.PATCH("/profile", authGuard.JwtGuard, usersController.UpdateProfile)
+	usersGroup.DELETE("/profile", authGuard.JwtGuard, usersController.DeleteAccount)
+	usersGroup.POST("/profile/delete/request-otp", authGuard.JwtGuard, usersController.RequestAccountDeletion)
+	usersGroup.GET("/profile/export", authGuard.JwtGuard, usersController.ExportData)
+	usersGroup.GET("/profile/activity", authGuard.JwtGuard, usersController.GetActivity)
+	usersGroup.POST("/profile/email/send-verification", authGuard.JwtGuard, usersController.SendEmailVerification)
+	usersGroup.POST("/profile/email/verify", authGuard.JwtGuard, usersController.VerifyEmail)
+	usersGroup.POST("/profile/phone/request-otp", authGuard.JwtGuard, usersController.RequestPhoneChange)
+	usersGroup.POST("/profile/phone/verify", authGuard.JwtGuard, usersController.VerifyPhoneChange)
+	// the listing exposes every user's contact details and status
+	usersGroup.GET("/", authGuard.JwtGuard, authGuard.AdminGuard, usersController.GetAllUsers)
 }
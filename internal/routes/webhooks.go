@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+// Webhooks are authenticated by their signatures, not by JWT.
+func RegisterWebhookV1Router(ginEngine *gin.RouterGroup, webhookController controllers.WebhookController) {
+	webhookGroup := ginEngine.Group("/webhooks")
+
+	webhookGroup.POST("/sms/:provider", webhookController.SmsDeliveryReport)
+}
@@ -0,0 +1,197 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/config"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/logger"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+// accountPurgeBatch bounds how many accounts one purge statement deletes.
+const accountPurgeBatch = 500
+
+var ErrAccountDeleted = errors.New("account is already deleted")
+
+type account struct {
+	userRepository   repositories.UserRepository
+	outboxRepository repositories.OutboxRepository
+	authEvents       repositories.AuthEventRepository
+	auditRepository  repositories.AuditRepository
+	refreshTokens    repositories.RefreshTokenRepository
+	otpUsecase       OtpUsecase
+	cfg              config.AccountDeletion
+	l                logger.Logger
+	now              func() time.Time
+}
+
+func NewAccountUsecase(userRepository repositories.UserRepository, outboxRepository repositories.OutboxRepository, authEvents repositories.AuthEventRepository, auditRepository repositories.AuditRepository, refreshTokens repositories.RefreshTokenRepository, otpUsecase OtpUsecase, cfg config.AccountDeletion, l logger.Logger) AccountUsecase {
+	return &account{
+		userRepository:   userRepository,
+		outboxRepository: outboxRepository,
+		authEvents:       authEvents,
+		auditRepository:  auditRepository,
+		refreshTokens:    refreshTokens,
+		otpUsecase:       otpUsecase,
+		cfg:              cfg,
+		l:                l,
+		now:              time.Now,
+	}
+}
+
+func (a *account) RequestDeletionOtp(ctx context.Context, userID uint32, req dto.RequestAccountDeletionDTO) (entities.OtpStatus, error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return entities.OtpStatus{}, err
+	}
+	user, err := a.userRepository.GetUserById(ctx, userID)
+	if err != nil {
+		return entities.OtpStatus{}, err
+	}
+
+	challenge := entities.OtpChallenge{
+		Purpose:   entities.OtpPurposeAccountDeletion,
+		Phone:     user.Phone,
+		DeviceID:  req.DeviceID,
+		RequestIP: req.Client.IP,
+		Channel:   req.Channel,
+		Platform:  req.Platform,
+	}
+	if challenge.Channel == "" {
+		challenge.Channel = entities.ChannelSMS
+	}
+	if err := a.otpUsecase.ValidateChannel(challenge.Channel); err != nil {
+		return entities.OtpStatus{}, err
+	}
+	recipient := user.Phone
+	if challenge.Channel == entities.ChannelEmail {
+		if !user.EmailVerified {
+			return entities.OtpStatus{}, errEmailChannelUnavailable
+		}
+		recipient = user.Email
+	}
+	if err := a.otpUsecase.CheckRateLimit(ctx, challenge); err != nil {
+		return entities.OtpStatus{}, err
+	}
+	return issueOtp(ctx, a.otpUsecase, challenge, recipient)
+}
+
+// Delete soft-deletes the account once the code is verified and signs out
+// every session. Signing in again within the grace period restores it.
+func (a *account) Delete(ctx context.Context, userID uint32, req dto.DeleteAccountDTO) (entities.User, error) {
+	req.OTP = strings.TrimSpace(req.OTP)
+	if err := utils.ValidateStruct(req); err != nil {
+		return entities.User{}, err
+	}
+	if err := a.otpUsecase.ValidateFormat(entities.OtpPurposeAccountDeletion, req.OTP); err != nil {
+		return entities.User{}, err
+	}
+	user, err := a.userRepository.GetUserById(ctx, userID)
+	if err != nil {
+		return entities.User{}, err
+	}
+	challenge := entities.OtpChallenge{
+		ID:       req.ChallengeID,
+		Purpose:  entities.OtpPurposeAccountDeletion,
+		Phone:    user.Phone,
+		DeviceID: req.DeviceID,
+	}
+	if err := a.otpUsecase.VerifyOTP(ctx, challenge, req.OTP); err != nil {
+		return entities.User{}, err
+	}
+
+	user, err = a.userRepository.SoftDelete(ctx, userID, a.now().Add(a.cfg.GracePeriod))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return entities.User{}, ErrAccountDeleted
+	}
+	if err != nil {
+		return entities.User{}, err
+	}
+	a.l.Info("user %d deleted their account, purging after %s", user.Id, user.PurgeAfter.Format(time.RFC3339))
+	return user, nil
+}
+
+func (a *account) Export(ctx context.Context, userID uint32) (entities.UserExport, error) {
+	user, err := a.userRepository.GetUserById(ctx, userID)
+	if err != nil {
+		return entities.UserExport{}, err
+	}
+	recipients := []string{user.Phone}
+	if user.Email != "" {
+		recipients = append(recipients, user.Email)
+	}
+	messages, err := a.outboxRepository.ListByRecipients(ctx, recipients)
+	if err != nil {
+		return entities.UserExport{}, err
+	}
+	events, err := a.authEvents.List(ctx, entities.AuthEventQuery{UserID: userID, Phone: user.Phone})
+	if err != nil {
+		return entities.UserExport{}, err
+	}
+	history, err := a.userRepository.ListStatusHistory(ctx, userID)
+	if err != nil {
+		return entities.UserExport{}, err
+	}
+	audit, err := a.auditRepository.ListForUser(ctx, userID)
+	if err != nil {
+		return entities.UserExport{}, err
+	}
+	sessions, err := a.refreshTokens.ListSessions(ctx, userID)
+	if err != nil {
+		return entities.UserExport{}, err
+	}
+	for i, entry := range audit {
+		// an admin's IP address is theirs, not the user's
+		if entry.ActorID == nil || *entry.ActorID != userID {
+			audit[i].IP = ""
+		}
+	}
+	return entities.UserExport{
+		ExportedAt:    a.now().UTC(),
+		User:          user,
+		Messages:      messages,
+		AuthEvents:    events,
+		StatusHistory: history,
+		AuditLog:      audit,
+		Sessions:      sessions,
+	}, nil
+}
+
+// RunPurge deletes accounts past their grace period every cfg.PurgeInterval
+// until ctx is done.
+func (a *account) RunPurge(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.PurgeInterval)
+	defer ticker.Stop()
+	for {
+		a.purge(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *account) purge(ctx context.Context) {
+	var total int64
+	for ctx.Err() == nil {
+		purged, err := a.userRepository.PurgeDeleted(ctx, accountPurgeBatch)
+		if err != nil {
+			a.l.Error("failed to purge deleted accounts: %v", err)
+			break
+		}
+		total += purged
+		if purged < accountPurgeBatch {
+			break
+		}
+	}
+	if total > 0 {
+		a.l.Info("purged %d deleted accounts", total)
+	}
+}
@@ -0,0 +1,183 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/config"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories/mockrepositories"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/usecases/mockusecases"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+type accountMocks struct {
+	users      *mockrepositories.MockUserRepository
+	outbox     *mockrepositories.MockOutboxRepository
+	authEvents *mockrepositories.MockAuthEventRepository
+	audit      *mockrepositories.MockAuditRepository
+	sessions   *mockrepositories.MockRefreshTokenRepository
+	otp        *mockusecases.MockOtpUsecase
+}
+
+func newTestAccount(t *testing.T) (*account, accountMocks) {
+	ctrl := gomock.NewController(t)
+	mocks := accountMocks{
+		users:      mockrepositories.NewMockUserRepository(ctrl),
+		outbox:     mockrepositories.NewMockOutboxRepository(ctrl),
+		authEvents: mockrepositories.NewMockAuthEventRepository(ctrl),
+		audit:      mockrepositories.NewMockAuditRepository(ctrl),
+		sessions:   mockrepositories.NewMockRefreshTokenRepository(ctrl),
+		otp:        mockusecases.NewMockOtpUsecase(ctrl),
+	}
+	mockLogger := &MockLogger{}
+	mockLogger.On("Info", mock.Anything, mock.Anything)
+	mockLogger.On("Error", mock.Anything, mock.Anything)
+
+	cfg := config.AccountDeletion{GracePeriod: 30 * 24 * time.Hour, PurgeInterval: time.Hour}
+	a := NewAccountUsecase(mocks.users, mocks.outbox, mocks.authEvents, mocks.audit, mocks.sessions, mocks.otp, cfg, mockLogger).(*account)
+	a.now = func() time.Time { return time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC) }
+	return a, mocks
+}
+
+func TestAccountUsecase_RequestDeletionOtp(t *testing.T) {
+	ctx := context.Background()
+	user := entities.User{Id: 7, Phone: "+1000000001", Email: "ada@example.com"}
+
+	t.Run("sends the code to the account's phone", func(t *testing.T) {
+		a, m := newTestAccount(t)
+		challenge := entities.OtpChallenge{
+			Purpose:   entities.OtpPurposeAccountDeletion,
+			Phone:     "+1000000001",
+			DeviceID:  "device-a",
+			RequestIP: "10.0.0.1",
+			Channel:   entities.ChannelSMS,
+		}
+		saved := challenge
+		saved.ID = "challenge-1"
+		m.users.EXPECT().GetUserById(ctx, uint32(7)).Return(user, nil)
+		m.otp.EXPECT().ValidateChannel(entities.ChannelSMS).Return(nil)
+		m.otp.EXPECT().CheckRateLimit(ctx, challenge).Return(nil)
+		m.otp.EXPECT().GenerateOTP(entities.OtpPurposeAccountDeletion).Return("123456", nil)
+		m.otp.EXPECT().SaveOTP(ctx, challenge, "123456").Return("challenge-1", nil)
+		m.otp.EXPECT().SendOTP(ctx, saved, "+1000000001", "123456").Return(nil)
+		m.otp.EXPECT().GetStatus(ctx, saved).Return(entities.OtpStatus{ChallengeID: "challenge-1", Active: true}, nil)
+
+		status, err := a.RequestDeletionOtp(ctx, 7, dto.RequestAccountDeletionDTO{DeviceID: "device-a", Client: dto.ClientInfo{IP: "10.0.0.1"}})
+		require.NoError(t, err)
+		assert.Equal(t, "challenge-1", status.ChallengeID)
+	})
+
+	t.Run("email needs a verified address", func(t *testing.T) {
+		a, m := newTestAccount(t)
+		m.users.EXPECT().GetUserById(ctx, uint32(7)).Return(user, nil)
+		m.otp.EXPECT().ValidateChannel(entities.ChannelEmail).Return(nil)
+
+		_, err := a.RequestDeletionOtp(ctx, 7, dto.RequestAccountDeletionDTO{Channel: entities.ChannelEmail})
+		assert.ErrorIs(t, err, errEmailChannelUnavailable)
+	})
+}
+
+func TestAccountUsecase_Delete(t *testing.T) {
+	ctx := context.Background()
+	user := entities.User{Id: 7, Phone: "+1000000001"}
+	req := dto.DeleteAccountDTO{ChallengeID: "challenge-1", DeviceID: "device-a", OTP: "123456"}
+	challenge := entities.OtpChallenge{ID: "challenge-1", Purpose: entities.OtpPurposeAccountDeletion, Phone: "+1000000001", DeviceID: "device-a"}
+	purgeAfter := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	t.Run("soft-deletes after the grace period", func(t *testing.T) {
+		a, m := newTestAccount(t)
+		deleted := user
+		deleted.PurgeAfter = &purgeAfter
+		m.otp.EXPECT().ValidateFormat(entities.OtpPurposeAccountDeletion, "123456").Return(nil)
+		m.users.EXPECT().GetUserById(ctx, uint32(7)).Return(user, nil)
+		m.otp.EXPECT().VerifyOTP(ctx, challenge, "123456").Return(nil)
+		m.users.EXPECT().SoftDelete(ctx, uint32(7), purgeAfter).Return(deleted, nil)
+
+		got, err := a.Delete(ctx, 7, req)
+		require.NoError(t, err)
+		assert.Equal(t, &purgeAfter, got.PurgeAfter)
+	})
+
+	t.Run("needs a valid code", func(t *testing.T) {
+		a, m := newTestAccount(t)
+		m.otp.EXPECT().ValidateFormat(entities.OtpPurposeAccountDeletion, "123456").Return(nil)
+		m.users.EXPECT().GetUserById(ctx, uint32(7)).Return(user, nil)
+		m.otp.EXPECT().VerifyOTP(ctx, challenge, "123456").Return(errInvalidOtp)
+
+		_, err := a.Delete(ctx, 7, req)
+		assert.ErrorIs(t, err, errInvalidOtp)
+	})
+
+	t.Run("already deleted", func(t *testing.T) {
+		a, m := newTestAccount(t)
+		m.otp.EXPECT().ValidateFormat(entities.OtpPurposeAccountDeletion, "123456").Return(nil)
+		m.users.EXPECT().GetUserById(ctx, uint32(7)).Return(user, nil)
+		m.otp.EXPECT().VerifyOTP(ctx, challenge, "123456").Return(nil)
+		m.users.EXPECT().SoftDelete(ctx, uint32(7), purgeAfter).Return(entities.User{}, pgx.ErrNoRows)
+
+		_, err := a.Delete(ctx, 7, req)
+		assert.ErrorIs(t, err, ErrAccountDeleted)
+	})
+}
+
+func TestAccountUsecase_Export(t *testing.T) {
+	ctx := context.Background()
+	a, m := newTestAccount(t)
+	user := entities.User{Id: 7, Phone: "+1000000001", Email: "ada@example.com"}
+	messages := []entities.OutboxMessage{{ID: 1, Recipient: "+1000000001"}, {ID: 2, Recipient: "ada@example.com"}}
+	m.users.EXPECT().GetUserById(ctx, uint32(7)).Return(user, nil)
+	m.outbox.EXPECT().ListByRecipients(ctx, []string{"+1000000001", "ada@example.com"}).Return(messages, nil)
+	events := []entities.AuthEvent{{ID: 3, UserID: &user.Id, Kind: entities.AuthEventOtpVerify, Outcome: entities.AuthEventSuccess}}
+	m.authEvents.EXPECT().List(ctx, entities.AuthEventQuery{UserID: 7, Phone: "+1000000001"}).Return(events, nil)
+	history := []entities.UserStatusChange{{ID: 4, UserID: 7, Status: entities.UserStatusActive}}
+	m.users.EXPECT().ListStatusHistory(ctx, uint32(7)).Return(history, nil)
+	admin := uint32(1)
+	m.audit.EXPECT().ListForUser(ctx, uint32(7)).Return([]entities.AuditEntry{
+		{ID: 6, ActorID: &admin, Action: entities.AuditUserStatusChange, TargetType: "user", TargetID: "7", IP: "10.0.0.9"},
+		{ID: 5, ActorID: &user.Id, Action: entities.AuditPhoneChange, TargetType: "user", TargetID: "7", IP: "10.0.0.1"},
+	}, nil)
+	sessions := []entities.RefreshToken{{ID: "token-2", FamilyID: "token-1", UserID: 7, DeviceID: "device-1"}}
+	m.sessions.EXPECT().ListSessions(ctx, uint32(7)).Return(sessions, nil)
+
+	export, err := a.Export(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, entities.UserExport{
+		ExportedAt:    a.now(),
+		User:          user,
+		Messages:      messages,
+		AuthEvents:    events,
+		StatusHistory: history,
+		AuditLog: []entities.AuditEntry{
+			{ID: 6, ActorID: &admin, Action: entities.AuditUserStatusChange, TargetType: "user", TargetID: "7"},
+			{ID: 5, ActorID: &user.Id, Action: entities.AuditPhoneChange, TargetType: "user", TargetID: "7", IP: "10.0.0.1"},
+		},
+		Sessions: sessions,
+	}, export)
+}
+
+func TestAccountUsecase_Purge(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("purges in batches until none are left", func(t *testing.T) {
+		a, m := newTestAccount(t)
+		gomock.InOrder(
+			m.users.EXPECT().PurgeDeleted(ctx, accountPurgeBatch).Return(int64(accountPurgeBatch), nil),
+			m.users.EXPECT().PurgeDeleted(ctx, accountPurgeBatch).Return(int64(3), nil),
+		)
+		a.purge(ctx)
+	})
+
+	t.Run("stops on errors", func(t *testing.T) {
+		a, m := newTestAccount(t)
+		m.users.EXPECT().PurgeDeleted(ctx, accountPurgeBatch).Return(int64(0), errors.New("connection reset"))
+		a.purge(ctx)
+	})
+}
@@ -0,0 +1,140 @@
+package usecases
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/config"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/logger"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/pagination"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/utils"
+)
+
+const (
+	defaultActivityPageSize = 20
+	// activityPurgeBatch bounds how many events one purge statement deletes.
+	activityPurgeBatch = 5000
+	// maxEventTextLen truncates user agents and failure reasons, which come
+	// from the client or from arbitrary errors.
+	maxEventTextLen = 512
+)
+
+type activity struct {
+	repository repositories.AuthEventRepository
+	cfg        config.AuthEvents
+	l          logger.Logger
+	now        func() time.Time
+}
+
+func NewActivityUsecase(repository repositories.AuthEventRepository, cfg config.AuthEvents, l logger.Logger) ActivityUsecase {
+	return &activity{
+		repository: repository,
+		cfg:        cfg,
+		l:          l,
+		now:        time.Now,
+	}
+}
+
+// Record stores the event with the outcome err implies. It only logs when the
+// event cannot be stored, so a login never fails for lack of history.
+func (a *activity) Record(ctx context.Context, event entities.AuthEvent, err error) {
+	event.Outcome = entities.AuthEventSuccess
+	if err != nil {
+		event.Outcome = entities.AuthEventFailure
+		event.Reason = truncate(err.Error(), maxEventTextLen)
+	}
+	event.UserAgent = truncate(event.UserAgent, maxEventTextLen)
+	// the request may be over, the history should still be written
+	if err := a.repository.Record(context.WithoutCancel(ctx), event); err != nil {
+		a.l.Error("failed to record %s event: %v", event.Kind, err)
+	}
+}
+
+func (a *activity) List(ctx context.Context, userID uint32, req dto.ListAuthEventsDTO) (entities.Page[entities.AuthEvent], error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return entities.Page[entities.AuthEvent]{}, err
+	}
+	limit := defaultActivityPageSize
+	if req.Limit != nil {
+		limit = *req.Limit
+	}
+	if limit < 1 || limit > maxUsersPageSize {
+		return entities.Page[entities.AuthEvent]{}, ErrInvalidPageSize
+	}
+	query := entities.AuthEventQuery{
+		UserID: userID,
+		Kind:   entities.AuthEventKind(req.Kind),
+		// One extra row tells whether there is a next page.
+		Limit: limit + 1,
+	}
+	if req.Cursor != "" {
+		if err := pagination.DecodeCursor(req.Cursor, &query.BeforeID); err != nil {
+			return entities.Page[entities.AuthEvent]{}, err
+		}
+		if query.BeforeID <= 0 {
+			return entities.Page[entities.AuthEvent]{}, pagination.ErrInvalidCursor
+		}
+	}
+
+	events, err := a.repository.List(ctx, query)
+	if err != nil {
+		return entities.Page[entities.AuthEvent]{}, err
+	}
+	page := entities.Page[entities.AuthEvent]{Items: events}
+	if len(events) > limit {
+		page.Items = events[:limit]
+		if page.NextCursor, err = pagination.EncodeCursor(page.Items[limit-1].ID); err != nil {
+			return entities.Page[entities.AuthEvent]{}, err
+		}
+	}
+	return page, nil
+}
+
+// RunPurge deletes events older than cfg.Retention every cfg.PurgeInterval
+// until ctx is done.
+func (a *activity) RunPurge(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.PurgeInterval)
+	defer ticker.Stop()
+	for {
+		a.purge(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *activity) purge(ctx context.Context) {
+	before := a.now().Add(-a.cfg.Retention)
+	var total int64
+	for ctx.Err() == nil {
+		purged, err := a.repository.Purge(ctx, before, activityPurgeBatch)
+		if err != nil {
+			a.l.Error("failed to purge login history: %v", err)
+			break
+		}
+		total += purged
+		if purged < activityPurgeBatch {
+			break
+		}
+	}
+	if total > 0 {
+		a.l.Info("purged %d login history events older than %s", total, a.cfg.Retention)
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	// do not cut a multi-byte character in half
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
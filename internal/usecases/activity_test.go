@@ -0,0 +1,156 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/config"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories/mockrepositories"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestActivity(t *testing.T) (*activity, *mockrepositories.MockAuthEventRepository, *MockLogger) {
+	ctrl := gomock.NewController(t)
+	repo := mockrepositories.NewMockAuthEventRepository(ctrl)
+	mockLogger := &MockLogger{}
+	mockLogger.On("Info", mock.Anything, mock.Anything)
+	mockLogger.On("Error", mock.Anything, mock.Anything)
+
+	a := NewActivityUsecase(repo, config.AuthEvents{Retention: 90 * 24 * time.Hour, PurgeInterval: time.Hour}, mockLogger).(*activity)
+	a.now = func() time.Time { return time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC) }
+	return a, repo, mockLogger
+}
+
+func TestActivity_Record(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		a, repo, _ := newTestActivity(t)
+		repo.EXPECT().Record(gomock.Any(), entities.AuthEvent{
+			Kind:      entities.AuthEventOtpRequest,
+			Outcome:   entities.AuthEventSuccess,
+			Phone:     "+989121234567",
+			IP:        "10.0.0.1",
+			UserAgent: "app/1.0",
+		}).Return(nil)
+
+		a.Record(ctx, entities.AuthEvent{Kind: entities.AuthEventOtpRequest, Phone: "+989121234567", IP: "10.0.0.1", UserAgent: "app/1.0"}, nil)
+	})
+
+	t.Run("failure keeps the reason and long texts are cut", func(t *testing.T) {
+		a, repo, _ := newTestActivity(t)
+		repo.EXPECT().Record(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, e entities.AuthEvent) error {
+			assert.Equal(t, entities.AuthEventFailure, e.Outcome)
+			assert.Equal(t, "invalid otp", e.Reason)
+			assert.Len(t, e.UserAgent, maxEventTextLen-1)
+			return nil
+		})
+
+		// a two-byte character straddles the limit
+		userAgent := strings.Repeat("a", maxEventTextLen-1) + "é"
+		a.Record(ctx, entities.AuthEvent{Kind: entities.AuthEventOtpVerify, UserAgent: userAgent}, errors.New("invalid otp"))
+	})
+
+	t.Run("storage errors are only logged", func(t *testing.T) {
+		a, repo, mockLogger := newTestActivity(t)
+		repo.EXPECT().Record(gomock.Any(), gomock.Any()).Return(errors.New("connection reset"))
+
+		a.Record(ctx, entities.AuthEvent{Kind: entities.AuthEventTokenRefresh}, nil)
+		mockLogger.AssertCalled(t, "Error", "failed to record %s event: %v", mock.Anything)
+	})
+
+	t.Run("a cancelled request is still recorded", func(t *testing.T) {
+		a, repo, _ := newTestActivity(t)
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		repo.EXPECT().Record(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, _ entities.AuthEvent) error {
+			return ctx.Err()
+		})
+
+		a.Record(cancelled, entities.AuthEvent{Kind: entities.AuthEventOtpVerify}, nil)
+	})
+}
+
+func TestActivity_List(t *testing.T) {
+	ctx := context.Background()
+	events := func(ids ...int64) []entities.AuthEvent {
+		list := make([]entities.AuthEvent, len(ids))
+		for i, id := range ids {
+			list[i] = entities.AuthEvent{ID: id, Kind: entities.AuthEventOtpVerify}
+		}
+		return list
+	}
+
+	t.Run("pages with a cursor", func(t *testing.T) {
+		a, repo, _ := newTestActivity(t)
+		limit := 2
+		repo.EXPECT().List(ctx, entities.AuthEventQuery{UserID: 7, Kind: entities.AuthEventOtpVerify, Limit: 3}).Return(events(9, 8, 5), nil)
+
+		page, err := a.List(ctx, 7, dto.ListAuthEventsDTO{Kind: "otp_verify", Limit: &limit})
+		require.NoError(t, err)
+		assert.Equal(t, events(9, 8), page.Items)
+		require.NotEmpty(t, page.NextCursor)
+
+		repo.EXPECT().List(ctx, entities.AuthEventQuery{UserID: 7, Kind: entities.AuthEventOtpVerify, BeforeID: 8, Limit: 3}).Return(events(5), nil)
+		page, err = a.List(ctx, 7, dto.ListAuthEventsDTO{Kind: "otp_verify", Limit: &limit, Cursor: page.NextCursor})
+		require.NoError(t, err)
+		assert.Equal(t, events(5), page.Items)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("defaults to 20", func(t *testing.T) {
+		a, repo, _ := newTestActivity(t)
+		repo.EXPECT().List(ctx, entities.AuthEventQuery{UserID: 7, Limit: defaultActivityPageSize + 1}).Return(nil, nil)
+
+		page, err := a.List(ctx, 7, dto.ListAuthEventsDTO{})
+		require.NoError(t, err)
+		assert.Empty(t, page.Items)
+	})
+
+	t.Run("rejects bad queries", func(t *testing.T) {
+		a, _, _ := newTestActivity(t)
+		tooMany := maxUsersPageSize + 1
+		_, err := a.List(ctx, 7, dto.ListAuthEventsDTO{Limit: &tooMany})
+		assert.ErrorIs(t, err, ErrInvalidPageSize)
+
+		_, err = a.List(ctx, 7, dto.ListAuthEventsDTO{Cursor: "not-a-cursor"})
+		assert.ErrorIs(t, err, pagination.ErrInvalidCursor)
+
+		negative, err := pagination.EncodeCursor(-1)
+		require.NoError(t, err)
+		_, err = a.List(ctx, 7, dto.ListAuthEventsDTO{Cursor: negative})
+		assert.ErrorIs(t, err, pagination.ErrInvalidCursor)
+
+		_, err = a.List(ctx, 7, dto.ListAuthEventsDTO{Kind: "password_reset"})
+		assert.Error(t, err)
+	})
+}
+
+func TestActivity_Purge(t *testing.T) {
+	ctx := context.Background()
+	cutoff := time.Date(2025, 10, 3, 12, 0, 0, 0, time.UTC)
+
+	t.Run("purges in batches until none are left", func(t *testing.T) {
+		a, repo, _ := newTestActivity(t)
+		gomock.InOrder(
+			repo.EXPECT().Purge(ctx, cutoff, activityPurgeBatch).Return(int64(activityPurgeBatch), nil),
+			repo.EXPECT().Purge(ctx, cutoff, activityPurgeBatch).Return(int64(3), nil),
+		)
+		a.purge(ctx)
+	})
+
+	t.Run("stops on errors", func(t *testing.T) {
+		a, repo, _ := newTestActivity(t)
+		repo.EXPECT().Purge(ctx, cutoff, activityPurgeBatch).Return(int64(0), errors.New("connection reset"))
+		a.purge(ctx)
+	})
+}
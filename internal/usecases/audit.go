@@ -0,0 +1,175 @@
+package usecases
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/logger"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/pagination"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/requestid"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/utils"
+)
+
+const (
+	defaultAuditPageSize = 50
+	// auditVerifyBatch is how many entries Verify reads at a time.
+	auditVerifyBatch = 1000
+)
+
+type audit struct {
+	repository repositories.AuditRepository
+	key        []byte
+	l          logger.Logger
+	now        func() time.Time
+}
+
+// NewAuditUsecase chains entries with HMAC-SHA256 under key. An empty key
+// still chains them, but anyone able to edit the table can also rehash it.
+func NewAuditUsecase(repository repositories.AuditRepository, key string, l logger.Logger) AuditUsecase {
+	return &audit{
+		repository: repository,
+		key:        []byte(key),
+		l:          l,
+		now:        time.Now,
+	}
+}
+
+// Record appends entry with diff as its JSON diff. The request ID comes from
+// ctx. Failures are logged and returned, so callers can refuse to go on with
+// an action that would not be accounted for.
+func (a *audit) Record(ctx context.Context, entry entities.AuditEntry, diff any) error {
+	var err error
+	if entry.Diff, err = json.Marshal(diff); err != nil {
+		a.l.Error("failed to record audit entry %s: %v", entry.Action, err)
+		return err
+	}
+	if entry.RequestID == "" {
+		entry.RequestID = requestid.FromContext(ctx)
+	}
+	// Postgres keeps microseconds; the hash must cover what is read back.
+	entry.CreatedAt = a.now().UTC().Truncate(time.Microsecond)
+	_, err = a.repository.Append(context.WithoutCancel(ctx), entry, func(e *entities.AuditEntry) {
+		e.Hash = a.hash(*e)
+	})
+	if err != nil {
+		a.l.Error("failed to record audit entry %s: %v", entry.Action, err)
+		return err
+	}
+	return nil
+}
+
+func (a *audit) List(ctx context.Context, req dto.ListAuditDTO) (entities.Page[entities.AuditEntry], error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return entities.Page[entities.AuditEntry]{}, err
+	}
+	limit := defaultAuditPageSize
+	if req.Limit != nil {
+		limit = *req.Limit
+	}
+	if limit < 1 || limit > maxUsersPageSize {
+		return entities.Page[entities.AuditEntry]{}, ErrInvalidPageSize
+	}
+	query := entities.AuditQuery{
+		ActorID:    req.ActorID,
+		Action:     entities.AuditAction(req.Action),
+		TargetType: req.TargetType,
+		TargetID:   req.TargetID,
+		RequestID:  req.RequestID,
+		From:       req.From,
+		To:         req.To,
+		// One extra row tells whether there is a next page.
+		Limit: limit + 1,
+	}
+	if req.Cursor != "" {
+		if err := pagination.DecodeCursor(req.Cursor, &query.BeforeID); err != nil {
+			return entities.Page[entities.AuditEntry]{}, err
+		}
+		if query.BeforeID <= 0 {
+			return entities.Page[entities.AuditEntry]{}, pagination.ErrInvalidCursor
+		}
+	}
+
+	entries, err := a.repository.List(ctx, query)
+	if err != nil {
+		return entities.Page[entities.AuditEntry]{}, err
+	}
+	page := entities.Page[entities.AuditEntry]{Items: entries}
+	if len(entries) > limit {
+		page.Items = entries[:limit]
+		if page.NextCursor, err = pagination.EncodeCursor(page.Items[limit-1].ID); err != nil {
+			return entities.Page[entities.AuditEntry]{}, err
+		}
+	}
+	return page, nil
+}
+
+// Verify walks the whole log from the first entry and stops at the first one
+// that is missing, out of place or altered. Entries cut from the end leave a
+// valid but shorter chain; compare LastID and LastHash with an earlier run
+// to catch that.
+func (a *audit) Verify(ctx context.Context) (entities.AuditVerification, error) {
+	var result entities.AuditVerification
+	for {
+		entries, err := a.repository.ListAfter(ctx, result.LastID, auditVerifyBatch)
+		if err != nil {
+			return entities.AuditVerification{}, err
+		}
+		for _, e := range entries {
+			problem := ""
+			switch {
+			case e.ID != result.LastID+1:
+				problem = fmt.Sprintf("entries %d to %d are missing", result.LastID+1, e.ID-1)
+			case e.PrevHash != result.LastHash:
+				problem = fmt.Sprintf("does not link to entry %d", result.LastID)
+			case !hmac.Equal([]byte(e.Hash), []byte(a.hash(e))):
+				problem = "does not match its hash"
+			}
+			if problem != "" {
+				result.BrokenAt = e.ID
+				result.Problem = problem
+				return result, nil
+			}
+			result.Entries++
+			result.LastID = e.ID
+			result.LastHash = e.Hash
+		}
+		if len(entries) < auditVerifyBatch {
+			result.Valid = true
+			return result, nil
+		}
+	}
+}
+
+// hash covers every field but Hash itself. Fields are length-prefixed so no
+// two different entries hash the same input.
+func (a *audit) hash(e entities.AuditEntry) string {
+	h := hmac.New(sha256.New, a.key)
+	actor := ""
+	if e.ActorID != nil {
+		actor = strconv.FormatUint(uint64(*e.ActorID), 10)
+	}
+	for _, field := range []string{
+		strconv.FormatInt(e.ID, 10),
+		e.PrevHash,
+		actor,
+		string(e.Action),
+		e.TargetType,
+		e.TargetID,
+		e.RequestID,
+		e.IP,
+		string(e.Diff),
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	} {
+		fmt.Fprintf(h, "%d:%s;", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,257 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories/mockrepositories"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/pagination"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// auditStore backs a mock repository with a slice, like the append-only table.
+type auditStore struct {
+	entries []entities.AuditEntry
+}
+
+func newTestAudit(t *testing.T, key string) (*audit, *mockrepositories.MockAuditRepository, *auditStore) {
+	ctrl := gomock.NewController(t)
+	repo := mockrepositories.NewMockAuditRepository(ctrl)
+	mockLogger := &MockLogger{}
+	mockLogger.On("Error", mock.Anything, mock.Anything)
+
+	store := &auditStore{}
+	repo.EXPECT().Append(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, e entities.AuditEntry, seal func(*entities.AuditEntry)) (entities.AuditEntry, error) {
+			if n := len(store.entries); n > 0 {
+				e.ID, e.PrevHash = store.entries[n-1].ID+1, store.entries[n-1].Hash
+			} else {
+				e.ID = 1
+			}
+			seal(&e)
+			store.entries = append(store.entries, e)
+			return e, nil
+		})
+	repo.EXPECT().ListAfter(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, afterID int64, limit int) ([]entities.AuditEntry, error) {
+			var page []entities.AuditEntry
+			for _, e := range store.entries {
+				if e.ID > afterID && len(page) < limit {
+					page = append(page, e)
+				}
+			}
+			return page, nil
+		})
+
+	a := NewAuditUsecase(repo, key, mockLogger).(*audit)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 123456789, time.UTC)
+	a.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	return a, repo, store
+}
+
+func seedAudit(a *audit, n int) {
+	ctx := requestid.NewContext(context.Background(), "req-1")
+	admin := uint32(1)
+	for i := range n {
+		a.Record(ctx, entities.AuditEntry{
+			ActorID:    &admin,
+			Action:     entities.AuditUserStatusChange,
+			TargetType: "user",
+			TargetID:   "7",
+			IP:         "10.0.0.1",
+		}, map[string]any{"status": map[string]string{"from": "active", "to": "banned"}, "n": i})
+	}
+}
+
+func TestAudit_Record(t *testing.T) {
+	a, _, store := newTestAudit(t, "key")
+	seedAudit(a, 2)
+
+	require.Len(t, store.entries, 2)
+	first, second := store.entries[0], store.entries[1]
+	assert.Equal(t, "req-1", first.RequestID)
+	assert.JSONEq(t, `{"status":{"from":"active","to":"banned"},"n":0}`, string(first.Diff))
+	assert.Equal(t, time.Date(2026, 1, 1, 12, 0, 1, 123456000, time.UTC), first.CreatedAt)
+	assert.Empty(t, first.PrevHash)
+	assert.Len(t, first.Hash, 64)
+	assert.Equal(t, first.Hash, second.PrevHash)
+	assert.NotEqual(t, first.Hash, second.Hash)
+}
+
+func TestAudit_RecordFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mockrepositories.NewMockAuditRepository(ctrl)
+	mockLogger := &MockLogger{}
+	mockLogger.On("Error", mock.Anything, mock.Anything)
+	a := NewAuditUsecase(repo, "key", mockLogger)
+
+	appendErr := errors.New("connection reset")
+	repo.EXPECT().Append(gomock.Any(), gomock.Any(), gomock.Any()).Return(entities.AuditEntry{}, appendErr)
+	err := a.Record(context.Background(), entities.AuditEntry{Action: entities.AuditUserActivityView, TargetType: "user", TargetID: "7"}, nil)
+	assert.ErrorIs(t, err, appendErr)
+	mockLogger.AssertCalled(t, "Error", mock.Anything, mock.Anything)
+
+	err = a.Record(context.Background(), entities.AuditEntry{Action: entities.AuditOutboxList}, func() {})
+	assert.Error(t, err, "an unmarshallable diff is not stored")
+}
+
+func TestAudit_Verify(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("intact chain", func(t *testing.T) {
+		a, _, store := newTestAudit(t, "key")
+		seedAudit(a, auditVerifyBatch+2)
+
+		result, err := a.Verify(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, entities.AuditVerification{
+			Valid:    true,
+			Entries:  auditVerifyBatch + 2,
+			LastID:   auditVerifyBatch + 2,
+			LastHash: store.entries[auditVerifyBatch+1].Hash,
+		}, result)
+	})
+
+	t.Run("empty log", func(t *testing.T) {
+		a, _, _ := newTestAudit(t, "key")
+		result, err := a.Verify(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, entities.AuditVerification{Valid: true}, result)
+	})
+
+	tampered := map[string]struct {
+		tamper  func(entries []entities.AuditEntry) []entities.AuditEntry
+		at      int64
+		entries int64
+		problem string
+	}{
+		"edited diff": {
+			tamper: func(entries []entities.AuditEntry) []entities.AuditEntry {
+				entries[2].Diff = json.RawMessage(`{"status":{"from":"active","to":"active"},"n":2}`)
+				return entries
+			},
+			at:      3,
+			entries: 2,
+			problem: "does not match its hash",
+		},
+		"edited and rehashed without the key": {
+			tamper: func(entries []entities.AuditEntry) []entities.AuditEntry {
+				forger := &audit{}
+				entries[2].TargetID = "8"
+				entries[2].Hash = forger.hash(entries[2])
+				return entries
+			},
+			at:      3,
+			entries: 2,
+			problem: "does not match its hash",
+		},
+		"deleted entry": {
+			tamper: func(entries []entities.AuditEntry) []entities.AuditEntry {
+				return append(entries[:1:1], entries[2:]...)
+			},
+			at:      3,
+			entries: 1,
+			problem: "entries 2 to 2 are missing",
+		},
+		"deleted and renumbered": {
+			tamper: func(entries []entities.AuditEntry) []entities.AuditEntry {
+				entries = append(entries[:1:1], entries[2:]...)
+				for i := range entries {
+					entries[i].ID = int64(i + 1)
+				}
+				return entries
+			},
+			at:      2,
+			entries: 1,
+			problem: "does not link to entry 1",
+		},
+	}
+	for name, tt := range tampered {
+		t.Run(name, func(t *testing.T) {
+			a, _, store := newTestAudit(t, "key")
+			seedAudit(a, 4)
+			store.entries = tt.tamper(store.entries)
+
+			result, err := a.Verify(ctx)
+			require.NoError(t, err)
+			assert.False(t, result.Valid)
+			assert.Equal(t, tt.at, result.BrokenAt)
+			assert.Equal(t, tt.problem, result.Problem)
+			assert.Equal(t, tt.entries, result.Entries)
+		})
+	}
+
+	t.Run("another key", func(t *testing.T) {
+		a, _, store := newTestAudit(t, "key")
+		seedAudit(a, 2)
+		other, _, otherStore := newTestAudit(t, "other-key")
+		otherStore.entries = store.entries
+
+		result, err := other.Verify(ctx)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		assert.Equal(t, int64(1), result.BrokenAt)
+	})
+}
+
+func TestAudit_List(t *testing.T) {
+	ctx := context.Background()
+	entries := func(ids ...int64) []entities.AuditEntry {
+		list := make([]entities.AuditEntry, len(ids))
+		for i, id := range ids {
+			list[i] = entities.AuditEntry{ID: id, Action: entities.AuditUsersExport}
+		}
+		return list
+	}
+
+	t.Run("filters and pages", func(t *testing.T) {
+		a, repo, _ := newTestAudit(t, "key")
+		admin := uint32(1)
+		from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		limit := 2
+		repo.EXPECT().List(ctx, entities.AuditQuery{ActorID: &admin, Action: entities.AuditUsersExport, From: &from, Limit: 3}).
+			Return(entries(9, 8, 5), nil)
+
+		req := dto.ListAuditDTO{ActorID: &admin, Action: "users.export", From: &from, Limit: &limit}
+		page, err := a.List(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, entries(9, 8), page.Items)
+
+		repo.EXPECT().List(ctx, entities.AuditQuery{ActorID: &admin, Action: entities.AuditUsersExport, From: &from, BeforeID: 8, Limit: 3}).
+			Return(entries(5), nil)
+		req.Cursor = page.NextCursor
+		page, err = a.List(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, entries(5), page.Items)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("rejects bad queries", func(t *testing.T) {
+		a, _, _ := newTestAudit(t, "key")
+		zero := 0
+		_, err := a.List(ctx, dto.ListAuditDTO{Limit: &zero})
+		assert.ErrorIs(t, err, ErrInvalidPageSize)
+
+		_, err = a.List(ctx, dto.ListAuditDTO{Cursor: "not-a-cursor"})
+		assert.ErrorIs(t, err, pagination.ErrInvalidCursor)
+	})
+
+	t.Run("storage errors", func(t *testing.T) {
+		a, repo, _ := newTestAudit(t, "key")
+		repo.EXPECT().List(ctx, gomock.Any()).Return(nil, errors.New("connection reset"))
+		_, err := a.List(ctx, dto.ListAuditDTO{})
+		assert.EqualError(t, err, "connection reset")
+	})
+}
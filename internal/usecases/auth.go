@@ -3,76 +3,350 @@ package usecases
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/config"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	errEmailChannelUnavailable = errors.New("otp delivery via email needs a verified email address on the account")
+	errTokenRevoked            = errors.New("token has been revoked")
+	errRefreshTokenReused      = errors.New("refresh token was already used, the session has been signed out")
 )
 
 type authService struct {
 	userRepository repositories.UserRepository
+	refreshTokens  repositories.RefreshTokenRepository
 	jwtUsecase     JwtUsecase
 	cfg            *config.Config
 	otpUsecase     OtpUsecase
+	activity       ActivityUsecase
 }
 
 func NewAuthUsecase(
 	userRepository repositories.UserRepository,
+	refreshTokens repositories.RefreshTokenRepository,
 	jwtUsecase JwtUsecase,
 	cfg *config.Config,
 	otpUsecase OtpUsecase,
+	activity ActivityUsecase,
 ) AuthService {
 	return &authService{
 		userRepository: userRepository,
+		refreshTokens:  refreshTokens,
 		jwtUsecase:     jwtUsecase,
 		cfg:            cfg,
 		otpUsecase:     otpUsecase,
+		activity:       activity,
 	}
 }
 
-func (a *authService) LoginRequestOtp(ctx context.Context, req dto.LoginDTO) (err error) {
+func (a *authService) LoginRequestOtp(ctx context.Context, req dto.LoginDTO) (status entities.OtpStatus, err error) {
 	if err := utils.ValidateStruct(req); err != nil {
-		return err
+		return entities.OtpStatus{}, err
+	}
+	var withheld error
+	defer func() {
+		outcome := err
+		if outcome == nil {
+			outcome = withheld
+		}
+		a.activity.Record(ctx, otpRequestEvent(req.Phone, req.Channel, req.DeviceID, req.Client), outcome)
+	}()
+	challenge := entities.OtpChallenge{
+		Purpose:   entities.OtpPurposeLogin,
+		Phone:     req.Phone,
+		DeviceID:  req.DeviceID,
+		RequestIP: req.Client.IP,
+		Channel:   req.Channel,
+		Platform:  req.Platform,
+	}
+	if challenge.Channel == "" {
+		challenge.Channel = entities.ChannelSMS
+	}
+	if err := a.otpUsecase.ValidateChannel(challenge.Channel); err != nil {
+		return entities.OtpStatus{}, err
 	}
 	// generate and save otp
-	code, err := a.otpUsecase.GenerateOTP()
+	code, isTest, err := a.loginOtp(ctx, challenge)
 	if err != nil {
-		return err
+		return entities.OtpStatus{}, err
 	}
-	if err := a.otpUsecase.SaveOTP(ctx, req.Phone, code); err != nil {
-		return err
+	recipient, withheld, err := a.otpRecipient(ctx, challenge)
+	if err != nil {
+		return entities.OtpStatus{}, err
+	}
+	challenge.ID, err = a.otpUsecase.SaveOTP(ctx, challenge, code)
+	if err != nil {
+		return entities.OtpStatus{}, err
+	}
+	if !isTest && withheld == nil {
+		if err := a.otpUsecase.SendOTP(ctx, challenge, recipient, code); err != nil {
+			return entities.OtpStatus{}, err
+		}
+	}
+	return a.otpUsecase.GetStatus(ctx, challenge)
+}
+
+// ResendLoginOtp sends a fresh code for a pending login challenge, over the
+// same or another channel. It counts against the same limits as a new request.
+func (a *authService) ResendLoginOtp(ctx context.Context, req dto.ResendOtpDTO) (status entities.OtpStatus, err error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return entities.OtpStatus{}, err
+	}
+	var withheld error
+	defer func() {
+		outcome := err
+		if outcome == nil {
+			outcome = withheld
+		}
+		a.activity.Record(ctx, otpRequestEvent(req.Phone, req.Channel, req.DeviceID, req.Client), outcome)
+	}()
+	challenge := entities.OtpChallenge{
+		ID:        req.ChallengeID,
+		Purpose:   entities.OtpPurposeLogin,
+		Phone:     req.Phone,
+		DeviceID:  req.DeviceID,
+		RequestIP: req.Client.IP,
+		Channel:   req.Channel,
+		Platform:  req.Platform,
+	}
+	if err := a.otpUsecase.ValidateChannel(challenge.Channel); err != nil {
+		return entities.OtpStatus{}, err
+	}
+	code, isTest, err := a.loginOtp(ctx, challenge)
+	if err != nil {
+		return entities.OtpStatus{}, err
+	}
+	recipient, withheld, err := a.otpRecipient(ctx, challenge)
+	if err != nil {
+		return entities.OtpStatus{}, err
+	}
+	challenge, err = a.otpUsecase.ReissueOTP(ctx, challenge, code)
+	if err != nil {
+		return entities.OtpStatus{}, err
+	}
+	if !isTest && withheld == nil {
+		if err := a.otpUsecase.SendOTP(ctx, challenge, recipient, code); err != nil {
+			return entities.OtpStatus{}, err
+		}
+	}
+	return a.otpUsecase.GetStatus(ctx, challenge)
+}
+
+// loginOtp picks the code for a login challenge. Test phones get the fixed
+// TEST_PHONE_OTP and skip the OTP rate limits; isTest tells the caller not to
+// send it.
+func (a *authService) loginOtp(ctx context.Context, challenge entities.OtpChallenge) (code string, isTest bool, err error) {
+	if testPhones := a.cfg.ActiveTestPhones(); testPhones.Match(challenge.Phone) {
+		if err := a.otpUsecase.ValidateFormat(entities.OtpPurposeLogin, testPhones.OTP); err != nil {
+			return "", false, fmt.Errorf("TEST_PHONE_OTP does not fit the login OTP policy: %w", err)
+		}
+		return testPhones.OTP, true, nil
+	}
+	if err := a.otpUsecase.CheckRateLimit(ctx, challenge); err != nil {
+		return "", false, err
+	}
+	code, err = a.otpUsecase.GenerateOTP(entities.OtpPurposeLogin)
+	return code, false, err
+}
+
+// otpRecipient resolves where a login code goes on the challenge's channel.
+// withheld says why no code may be sent: the user is suspended or banned, or
+// asked for email without a verified address. Callers then answer as if the
+// code was sent, so the response does not tell whether the phone has an
+// account or in what state.
+func (a *authService) otpRecipient(ctx context.Context, challenge entities.OtpChallenge) (recipient string, withheld, err error) {
+	user, err := a.userRepository.GetUserByPhone(ctx, challenge.Phone)
+	registered := err == nil
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, err
+	}
+	if registered {
+		if err := checkNotBlocked(user); err != nil {
+			return "", err, nil
+		}
+	}
+	if challenge.Channel != entities.ChannelEmail {
+		return challenge.Phone, nil, nil
+	}
+	if !registered || !user.EmailVerified {
+		return "", errEmailChannelUnavailable, nil
+	}
+	return user.Email, nil, nil
+}
+
+func (a *authService) LoginOtpStatus(ctx context.Context, query dto.OtpStatusQuery) (entities.OtpStatus, error) {
+	if err := utils.ValidateStruct(query); err != nil {
+		return entities.OtpStatus{}, err
 	}
-	return a.otpUsecase.SendOtpSms(ctx, req.Phone, code)
+	return a.otpUsecase.GetStatus(ctx, entities.OtpChallenge{
+		ID:        query.ChallengeID,
+		Purpose:   entities.OtpPurposeLogin,
+		Phone:     query.Phone,
+		DeviceID:  query.DeviceID,
+		RequestIP: query.Client.IP,
+	})
 }
 
-func (a *authService) VerifyLoginOTP(ctx context.Context, body dto.VerifyLoginOTP) (jwt string, err error) {
+func (a *authService) VerifyLoginOTP(ctx context.Context, body dto.VerifyLoginOTP) (tokens entities.AuthTokens, err error) {
+	// codes are often pasted with the whitespace around them
+	body.OTP = strings.TrimSpace(body.OTP)
 	if err := utils.ValidateStruct(body); err != nil {
-		return "", err
+		return entities.AuthTokens{}, err
+	}
+	event := entities.AuthEvent{
+		Kind:      entities.AuthEventOtpVerify,
+		Phone:     body.Phone,
+		DeviceID:  body.DeviceID,
+		IP:        body.Client.IP,
+		UserAgent: body.Client.UserAgent,
+	}
+	defer func() { a.activity.Record(ctx, event, err) }()
+
+	if err := a.otpUsecase.ValidateFormat(entities.OtpPurposeLogin, body.OTP); err != nil {
+		return entities.AuthTokens{}, err
+	}
+	challenge := entities.OtpChallenge{
+		ID:       body.ChallengeID,
+		Purpose:  entities.OtpPurposeLogin,
+		Phone:    body.Phone,
+		DeviceID: body.DeviceID,
 	}
-	if err := a.otpUsecase.VerifyOTP(ctx, body.Phone, body.OTP); err != nil {
-		return "", err
+	if err := a.otpUsecase.VerifyOTP(ctx, challenge, body.OTP); err != nil {
+		return entities.AuthTokens{}, err
 	}
 	// find or create user
 	user, err := a.userRepository.GetUserByPhone(ctx, body.Phone)
 	if err != nil {
 		// try create if not found
 		if strings.Contains(err.Error(), "no rows") || strings.Contains(strings.ToLower(err.Error()), "not found") {
-			user, err = a.userRepository.CreateUser(ctx, entities.User{Phone: body.Phone})
+			user, err = a.userRepository.CreateUser(ctx, entities.User{
+				Phone:  body.Phone,
+				IsTest: a.cfg.ActiveTestPhones().Match(body.Phone),
+			})
 			if err != nil {
-				return "", err
+				return entities.AuthTokens{}, err
 			}
 		} else {
-			return "", err
+			return entities.AuthTokens{}, err
 		}
 	}
-	token, err := a.jwtUsecase.GenerateToken(entities.JwtPayload{UserId: user.Id})
+	if err := checkNotBlocked(user); err != nil {
+		return entities.AuthTokens{}, err
+	}
+	if user.DeletedAt != nil {
+		// signing in within the grace period cancels the deletion
+		if user, err = a.userRepository.Restore(ctx, user.Id); err != nil {
+			return entities.AuthTokens{}, err
+		}
+	}
+	event.UserID = &user.Id
+	return issueTokens(ctx, a.jwtUsecase, a.refreshTokens, user.Id, "", body.DeviceID, body.Client)
+}
+
+// RefreshToken issues new tokens for the refresh token's user, unless the
+// account was deleted, blocked or signed out since the token was issued.
+// Every refresh token is good for one trade; presenting one a second time
+// means it leaked, so its whole session is signed out.
+func (a *authService) RefreshToken(ctx context.Context, body dto.RefreshTokenDTO) (tokens entities.AuthTokens, err error) {
+	if err := utils.ValidateStruct(body); err != nil {
+		return entities.AuthTokens{}, err
+	}
+	payload, err := a.jwtUsecase.ValidateRefreshToken(body.RefreshToken)
+	if err != nil {
+		// without a valid token there is no user to attribute the attempt to
+		return entities.AuthTokens{}, err
+	}
+	event := entities.AuthEvent{
+		UserID:    &payload.UserId,
+		Kind:      entities.AuthEventTokenRefresh,
+		IP:        body.Client.IP,
+		UserAgent: body.Client.UserAgent,
+	}
+	defer func() { a.activity.Record(ctx, event, err) }()
+	user, err := a.activeUser(ctx, payload)
+	if err != nil {
+		return entities.AuthTokens{}, err
+	}
+	if payload.TokenID == "" {
+		// issued before refresh tokens were stored
+		return entities.AuthTokens{}, errTokenRevoked
+	}
+	stored, fresh, err := a.refreshTokens.Use(ctx, payload.TokenID)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return entities.AuthTokens{}, errTokenRevoked
+	case err != nil:
+		return entities.AuthTokens{}, err
+	}
+	event.DeviceID = stored.DeviceID
+	if !fresh {
+		if stored.UsedAt != nil && stored.RevokedAt == nil {
+			if err := a.refreshTokens.RevokeFamily(ctx, stored.ID); err != nil {
+				return entities.AuthTokens{}, err
+			}
+			return entities.AuthTokens{}, errRefreshTokenReused
+		}
+		return entities.AuthTokens{}, errTokenRevoked
+	}
+	return issueTokens(ctx, a.jwtUsecase, a.refreshTokens, user.Id, stored.FamilyID, stored.DeviceID, body.Client)
+}
+
+// Logout signs out the refresh token's session. The access tokens already
+// issued for it stay valid until they expire.
+func (a *authService) Logout(ctx context.Context, body dto.RefreshTokenDTO) error {
+	if err := utils.ValidateStruct(body); err != nil {
+		return err
+	}
+	payload, err := a.jwtUsecase.ValidateRefreshToken(body.RefreshToken)
 	if err != nil {
-		return "", err
+		return err
+	}
+	if payload.TokenID == "" {
+		return errTokenRevoked
 	}
-	return token, nil
+	return a.refreshTokens.RevokeFamily(ctx, payload.TokenID)
+}
+
+// issueTokens stores a new refresh token in familyID, or starts a new family
+// when familyID is empty, and signs it with an access token.
+func issueTokens(ctx context.Context, jwtUsecase JwtUsecase, refreshTokens repositories.RefreshTokenRepository, userID uint32, familyID, deviceID string, client dto.ClientInfo) (entities.AuthTokens, error) {
+	token, err := jwtUsecase.GenerateToken(entities.JwtPayload{UserId: userID})
+	if err != nil {
+		return entities.AuthTokens{}, err
+	}
+	id, err := newChallengeID()
+	if err != nil {
+		return entities.AuthTokens{}, err
+	}
+	if familyID == "" {
+		familyID = id
+	}
+	err = refreshTokens.Create(ctx, entities.RefreshToken{
+		ID:        id,
+		FamilyID:  familyID,
+		UserID:    userID,
+		DeviceID:  deviceID,
+		IP:        client.IP,
+		UserAgent: truncate(client.UserAgent, maxEventTextLen),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	})
+	if err != nil {
+		return entities.AuthTokens{}, err
+	}
+	refreshToken, err := jwtUsecase.GenerateRefreshToken(entities.JwtPayload{UserId: userID, TokenID: id})
+	if err != nil {
+		return entities.AuthTokens{}, err
+	}
+	return entities.AuthTokens{AccessToken: token, RefreshToken: refreshToken}, nil
 }
 
 func (a *authService) ValidateToken(ctx context.Context, token string) (entities.User, error) {
@@ -88,5 +362,34 @@ func (a *authService) ValidateToken(ctx context.Context, token string) (entities
 	if err != nil {
 		return entities.User{}, err
 	}
-	return a.userRepository.GetUserById(ctx, payload.UserId)
+	return a.activeUser(ctx, payload)
+}
+
+// activeUser loads the token's user and refuses tokens that were revoked.
+func (a *authService) activeUser(ctx context.Context, payload entities.JwtPayload) (entities.User, error) {
+	user, err := a.userRepository.GetUserById(ctx, payload.UserId)
+	if err != nil {
+		return entities.User{}, err
+	}
+	if user.DeletedAt != nil || user.TokensInvalidBefore != nil && payload.IssuedAt.Before(*user.TokensInvalidBefore) {
+		return entities.User{}, errTokenRevoked
+	}
+	if err := checkNotBlocked(user); err != nil {
+		return entities.User{}, err
+	}
+	return user, nil
+}
+
+func otpRequestEvent(phone, channel, deviceID string, client dto.ClientInfo) entities.AuthEvent {
+	if channel == "" {
+		channel = entities.ChannelSMS
+	}
+	return entities.AuthEvent{
+		Kind:      entities.AuthEventOtpRequest,
+		Phone:     phone,
+		Channel:   channel,
+		DeviceID:  deviceID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
 }
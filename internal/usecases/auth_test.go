@@ -10,13 +10,62 @@ import (
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/config"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories/mockrepositories"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/usecases/mockusecases"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/ratelimit"
+	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
 
+func loginChallenge(phone string) entities.OtpChallenge {
+	return entities.OtpChallenge{Purpose: entities.OtpPurposeLogin, Phone: phone, Channel: entities.ChannelSMS}
+}
+
+func verifiedChallenge(phone string) entities.OtpChallenge {
+	return entities.OtpChallenge{ID: "challenge-1", Purpose: entities.OtpPurposeLogin, Phone: phone}
+}
+
+func sentChallenge(phone string) entities.OtpChallenge {
+	challenge := verifiedChallenge(phone)
+	challenge.Channel = entities.ChannelSMS
+	return challenge
+}
+
+func sentStatus() entities.OtpStatus {
+	return entities.OtpStatus{
+		ChallengeID:       "challenge-1",
+		Active:            true,
+		ExpiresIn:         2 * time.Minute,
+		AttemptsRemaining: 5,
+		ResendAfter:       time.Minute,
+		RequestsRemaining: 2,
+	}
+}
+
+// ignoreActivity accepts any login history; TestAuthService_Activity checks it.
+func ignoreActivity(ctrl *gomock.Controller) ActivityUsecase {
+	activity := mockusecases.NewMockActivityUsecase(ctrl)
+	activity.EXPECT().Record(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+	return activity
+}
+
+// storeRefreshTokens accepts every refresh token issued.
+func storeRefreshTokens(ctrl *gomock.Controller) repositories.RefreshTokenRepository {
+	refreshTokens := mockrepositories.NewMockRefreshTokenRepository(ctrl)
+	refreshTokens.EXPECT().Create(gomock.Any(), gomock.Any()).AnyTimes()
+	return refreshTokens
+}
+
+// refreshPayload matches the payload of a stored refresh token for userID.
+func refreshPayload(userID uint32) gomock.Matcher {
+	return gomock.Cond(func(payload entities.JwtPayload) bool {
+		return payload.UserId == userID && payload.TokenID != ""
+	})
+}
+
 func TestAuthService_LoginRequestOtp(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -26,7 +75,7 @@ func TestAuthService_LoginRequestOtp(t *testing.T) {
 	mockOtpUsecase := mockusecases.NewMockOtpUsecase(ctrl)
 	cfg := &config.Config{}
 
-	service := NewAuthUsecase(mockUserRepo, mockJwtUsecase, cfg, mockOtpUsecase)
+	service := NewAuthUsecase(mockUserRepo, storeRefreshTokens(ctrl), mockJwtUsecase, cfg, mockOtpUsecase, ignoreActivity(ctrl))
 
 	tests := []struct {
 		name       string
@@ -39,12 +88,151 @@ func TestAuthService_LoginRequestOtp(t *testing.T) {
 			name: "successful OTP request",
 			req:  dto.LoginDTO{Phone: "+1234567890"},
 			setupMock: func() {
-				mockOtpUsecase.EXPECT().GenerateOTP().Return("12345", nil)
-				mockOtpUsecase.EXPECT().SaveOTP(gomock.Any(), "+1234567890", "12345").Return(nil)
-				mockOtpUsecase.EXPECT().SendOtpSms(gomock.Any(), "+1234567890", "12345").Return(nil)
+				mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelSMS).Return(nil)
+				mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), gomock.Any()).Return(entities.User{}, pgx.ErrNoRows)
+				mockOtpUsecase.EXPECT().CheckRateLimit(gomock.Any(), gomock.Any()).Return(nil)
+				mockOtpUsecase.EXPECT().GenerateOTP(entities.OtpPurposeLogin).Return("12345", nil)
+				mockOtpUsecase.EXPECT().SaveOTP(gomock.Any(), loginChallenge("+1234567890"), "12345").Return("challenge-1", nil)
+				mockOtpUsecase.EXPECT().SendOTP(gomock.Any(), sentChallenge("+1234567890"), "+1234567890", "12345").Return(nil)
+				mockOtpUsecase.EXPECT().GetStatus(gomock.Any(), sentChallenge("+1234567890")).Return(sentStatus(), nil)
+			},
+			wantErr: false,
+		},
+		{
+			name: "challenge is bound to device and client IP",
+			req:  dto.LoginDTO{Phone: "+1234567890", DeviceID: "device-a", Client: dto.ClientInfo{IP: "10.0.0.1"}},
+			setupMock: func() {
+				mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelSMS).Return(nil)
+				mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), gomock.Any()).Return(entities.User{}, pgx.ErrNoRows)
+				mockOtpUsecase.EXPECT().CheckRateLimit(gomock.Any(), gomock.Any()).Return(nil)
+				mockOtpUsecase.EXPECT().GenerateOTP(entities.OtpPurposeLogin).Return("12345", nil)
+				mockOtpUsecase.EXPECT().SaveOTP(gomock.Any(), entities.OtpChallenge{
+					Purpose:   entities.OtpPurposeLogin,
+					Phone:     "+1234567890",
+					DeviceID:  "device-a",
+					RequestIP: "10.0.0.1",
+					Channel:   entities.ChannelSMS,
+				}, "12345").Return("challenge-1", nil)
+				mockOtpUsecase.EXPECT().SendOTP(gomock.Any(), entities.OtpChallenge{
+					ID:        "challenge-1",
+					Purpose:   entities.OtpPurposeLogin,
+					Phone:     "+1234567890",
+					DeviceID:  "device-a",
+					RequestIP: "10.0.0.1",
+					Channel:   entities.ChannelSMS,
+				}, "+1234567890", "12345").Return(nil)
+				mockOtpUsecase.EXPECT().GetStatus(gomock.Any(), entities.OtpChallenge{
+					ID:        "challenge-1",
+					Purpose:   entities.OtpPurposeLogin,
+					Phone:     "+1234567890",
+					DeviceID:  "device-a",
+					RequestIP: "10.0.0.1",
+					Channel:   entities.ChannelSMS,
+				}).Return(sentStatus(), nil)
+			},
+			wantErr: false,
+		},
+		{
+			name: "delivery via voice call",
+			req:  dto.LoginDTO{Phone: "+1234567890", Channel: entities.ChannelVoice},
+			setupMock: func() {
+				voice := loginChallenge("+1234567890")
+				voice.Channel = entities.ChannelVoice
+				mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelVoice).Return(nil)
+				mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), gomock.Any()).Return(entities.User{}, pgx.ErrNoRows)
+				mockOtpUsecase.EXPECT().CheckRateLimit(gomock.Any(), voice).Return(nil)
+				mockOtpUsecase.EXPECT().GenerateOTP(entities.OtpPurposeLogin).Return("12345", nil)
+				mockOtpUsecase.EXPECT().SaveOTP(gomock.Any(), voice, "12345").Return("challenge-1", nil)
+				voice.ID = "challenge-1"
+				mockOtpUsecase.EXPECT().SendOTP(gomock.Any(), voice, "+1234567890", "12345").Return(nil)
+				mockOtpUsecase.EXPECT().GetStatus(gomock.Any(), voice).Return(sentStatus(), nil)
+			},
+			wantErr: false,
+		},
+		{
+			name: "banned users get no code but the same response",
+			req:  dto.LoginDTO{Phone: "+1234567890"},
+			setupMock: func() {
+				mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelSMS).Return(nil)
+				mockOtpUsecase.EXPECT().CheckRateLimit(gomock.Any(), loginChallenge("+1234567890")).Return(nil)
+				mockOtpUsecase.EXPECT().GenerateOTP(entities.OtpPurposeLogin).Return("12345", nil)
+				mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), "+1234567890").
+					Return(entities.User{Id: 123, Phone: "+1234567890", Status: entities.UserStatusBanned}, nil)
+				mockOtpUsecase.EXPECT().SaveOTP(gomock.Any(), loginChallenge("+1234567890"), "12345").Return("challenge-1", nil)
+				mockOtpUsecase.EXPECT().GetStatus(gomock.Any(), sentChallenge("+1234567890")).Return(sentStatus(), nil)
+			},
+			wantErr: false,
+		},
+		{
+			name: "disabled channel",
+			req:  dto.LoginDTO{Phone: "+1234567890", Channel: entities.ChannelTelegram},
+			setupMock: func() {
+				mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelTelegram).Return(errors.New("otp delivery via telegram is not available"))
+			},
+			wantErr:    true,
+			wantErrMsg: "not available",
+		},
+		{
+			name: "email is not sent without a verified address on the account",
+			req:  dto.LoginDTO{Phone: "+1234567890", Channel: entities.ChannelEmail},
+			setupMock: func() {
+				challenge := loginChallenge("+1234567890")
+				challenge.Channel = entities.ChannelEmail
+				saved := challenge
+				saved.ID = "challenge-1"
+				mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelEmail).Return(nil)
+				mockOtpUsecase.EXPECT().CheckRateLimit(gomock.Any(), challenge).Return(nil)
+				mockOtpUsecase.EXPECT().GenerateOTP(entities.OtpPurposeLogin).Return("12345", nil)
+				mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), "+1234567890").
+					Return(entities.User{Id: 1, Phone: "+1234567890", Email: "a@example.com"}, nil)
+				mockOtpUsecase.EXPECT().SaveOTP(gomock.Any(), challenge, "12345").Return("challenge-1", nil)
+				mockOtpUsecase.EXPECT().GetStatus(gomock.Any(), saved).Return(sentStatus(), nil)
+			},
+			wantErr: false,
+		},
+		{
+			name: "email is not sent without an account",
+			req:  dto.LoginDTO{Phone: "+1234567890", Channel: entities.ChannelEmail},
+			setupMock: func() {
+				challenge := loginChallenge("+1234567890")
+				challenge.Channel = entities.ChannelEmail
+				saved := challenge
+				saved.ID = "challenge-1"
+				mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelEmail).Return(nil)
+				mockOtpUsecase.EXPECT().CheckRateLimit(gomock.Any(), challenge).Return(nil)
+				mockOtpUsecase.EXPECT().GenerateOTP(entities.OtpPurposeLogin).Return("12345", nil)
+				mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), "+1234567890").Return(entities.User{}, pgx.ErrNoRows)
+				mockOtpUsecase.EXPECT().SaveOTP(gomock.Any(), challenge, "12345").Return("challenge-1", nil)
+				mockOtpUsecase.EXPECT().GetStatus(gomock.Any(), saved).Return(sentStatus(), nil)
 			},
 			wantErr: false,
 		},
+		{
+			name: "email goes to the verified address",
+			req:  dto.LoginDTO{Phone: "+1234567890", Channel: entities.ChannelEmail},
+			setupMock: func() {
+				challenge := loginChallenge("+1234567890")
+				challenge.Channel = entities.ChannelEmail
+				sent := challenge
+				sent.ID = "challenge-1"
+				mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelEmail).Return(nil)
+				mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), "+1234567890").
+					Return(entities.User{Id: 1, Phone: "+1234567890", Email: "a@example.com", EmailVerified: true}, nil)
+				mockOtpUsecase.EXPECT().CheckRateLimit(gomock.Any(), gomock.Any()).Return(nil)
+				mockOtpUsecase.EXPECT().GenerateOTP(entities.OtpPurposeLogin).Return("12345", nil)
+				mockOtpUsecase.EXPECT().SaveOTP(gomock.Any(), challenge, "12345").Return("challenge-1", nil)
+				mockOtpUsecase.EXPECT().SendOTP(gomock.Any(), sent, "a@example.com", "12345").Return(nil)
+				mockOtpUsecase.EXPECT().GetStatus(gomock.Any(), sent).Return(sentStatus(), nil)
+			},
+			wantErr: false,
+		},
+		{
+			name:       "unknown channel",
+			req:        dto.LoginDTO{Phone: "+1234567890", Channel: "fax"},
+			setupMock:  func() {},
+			wantErr:    true,
+			wantErrMsg: "validation",
+		},
 		{
 			name:       "invalid phone number (too short)",
 			req:        dto.LoginDTO{Phone: "123"},
@@ -66,11 +254,25 @@ func TestAuthService_LoginRequestOtp(t *testing.T) {
 			wantErr:    true,
 			wantErrMsg: "validation",
 		},
+		{
+			name: "rate limit exceeded",
+			req:  dto.LoginDTO{Phone: "+1234567890"},
+			setupMock: func() {
+				mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelSMS).Return(nil)
+				mockOtpUsecase.EXPECT().CheckRateLimit(gomock.Any(), loginChallenge("+1234567890")).Return(&ratelimit.ExceededError{
+					Rule: ratelimit.Rule{Limit: ratelimit.Limit{Name: "phone", Rate: 3, Period: 10 * time.Minute}, Key: "+1234567890"},
+				})
+			},
+			wantErr:    true,
+			wantErrMsg: "rate limit exceeded",
+		},
 		{
 			name: "OTP generation error",
 			req:  dto.LoginDTO{Phone: "+1234567890"},
 			setupMock: func() {
-				mockOtpUsecase.EXPECT().GenerateOTP().Return("", errors.New("generation failed"))
+				mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelSMS).Return(nil)
+				mockOtpUsecase.EXPECT().CheckRateLimit(gomock.Any(), gomock.Any()).Return(nil)
+				mockOtpUsecase.EXPECT().GenerateOTP(entities.OtpPurposeLogin).Return("", errors.New("generation failed"))
 			},
 			wantErr:    true,
 			wantErrMsg: "generation failed",
@@ -79,8 +281,11 @@ func TestAuthService_LoginRequestOtp(t *testing.T) {
 			name: "OTP save error",
 			req:  dto.LoginDTO{Phone: "+1234567890"},
 			setupMock: func() {
-				mockOtpUsecase.EXPECT().GenerateOTP().Return("12345", nil)
-				mockOtpUsecase.EXPECT().SaveOTP(gomock.Any(), "+1234567890", "12345").Return(errors.New("save failed"))
+				mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelSMS).Return(nil)
+				mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), gomock.Any()).Return(entities.User{}, pgx.ErrNoRows)
+				mockOtpUsecase.EXPECT().CheckRateLimit(gomock.Any(), gomock.Any()).Return(nil)
+				mockOtpUsecase.EXPECT().GenerateOTP(entities.OtpPurposeLogin).Return("12345", nil)
+				mockOtpUsecase.EXPECT().SaveOTP(gomock.Any(), loginChallenge("+1234567890"), "12345").Return("", errors.New("save failed"))
 			},
 			wantErr:    true,
 			wantErrMsg: "save failed",
@@ -89,9 +294,12 @@ func TestAuthService_LoginRequestOtp(t *testing.T) {
 			name: "SMS send error",
 			req:  dto.LoginDTO{Phone: "+1234567890"},
 			setupMock: func() {
-				mockOtpUsecase.EXPECT().GenerateOTP().Return("12345", nil)
-				mockOtpUsecase.EXPECT().SaveOTP(gomock.Any(), "+1234567890", "12345").Return(nil)
-				mockOtpUsecase.EXPECT().SendOtpSms(gomock.Any(), "+1234567890", "12345").Return(errors.New("SMS failed"))
+				mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelSMS).Return(nil)
+				mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), gomock.Any()).Return(entities.User{}, pgx.ErrNoRows)
+				mockOtpUsecase.EXPECT().CheckRateLimit(gomock.Any(), gomock.Any()).Return(nil)
+				mockOtpUsecase.EXPECT().GenerateOTP(entities.OtpPurposeLogin).Return("12345", nil)
+				mockOtpUsecase.EXPECT().SaveOTP(gomock.Any(), loginChallenge("+1234567890"), "12345").Return("challenge-1", nil)
+				mockOtpUsecase.EXPECT().SendOTP(gomock.Any(), sentChallenge("+1234567890"), "+1234567890", "12345").Return(errors.New("SMS failed"))
 			},
 			wantErr:    true,
 			wantErrMsg: "SMS failed",
@@ -102,20 +310,188 @@ func TestAuthService_LoginRequestOtp(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.setupMock()
 
-			err := service.LoginRequestOtp(context.Background(), tt.req)
+			status, err := service.LoginRequestOtp(context.Background(), tt.req)
 
 			if tt.wantErr {
 				assert.Error(t, err)
+				assert.Empty(t, status.ChallengeID)
 				if tt.wantErrMsg != "" {
 					assert.Contains(t, strings.ToLower(err.Error()), strings.ToLower(tt.wantErrMsg))
 				}
 			} else {
 				assert.NoError(t, err)
+				assert.Equal(t, sentStatus(), status)
 			}
 		})
 	}
 }
 
+func TestAuthService_ResendLoginOtp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockOtpUsecase := mockusecases.NewMockOtpUsecase(ctrl)
+	mockUserRepo := mockrepositories.NewMockUserRepository(ctrl)
+	service := NewAuthUsecase(mockUserRepo, storeRefreshTokens(ctrl), mockusecases.NewMockJwtUsecase(ctrl), &config.Config{}, mockOtpUsecase, ignoreActivity(ctrl))
+	req := dto.ResendOtpDTO{ChallengeID: "challenge-1", Phone: "+1234567890", DeviceID: "device-a", Channel: entities.ChannelVoice}
+	challenge := entities.OtpChallenge{
+		ID:       "challenge-1",
+		Purpose:  entities.OtpPurposeLogin,
+		Phone:    "+1234567890",
+		DeviceID: "device-a",
+		Channel:  entities.ChannelVoice,
+	}
+
+	t.Run("send via call instead", func(t *testing.T) {
+		reissued := challenge
+		reissued.Resends = 1
+		mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelVoice).Return(nil)
+		mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), gomock.Any()).Return(entities.User{}, pgx.ErrNoRows)
+		mockOtpUsecase.EXPECT().CheckRateLimit(gomock.Any(), challenge).Return(nil)
+		mockOtpUsecase.EXPECT().GenerateOTP(entities.OtpPurposeLogin).Return("54321", nil)
+		mockOtpUsecase.EXPECT().ReissueOTP(gomock.Any(), challenge, "54321").Return(reissued, nil)
+		mockOtpUsecase.EXPECT().SendOTP(gomock.Any(), reissued, "+1234567890", "54321").Return(nil)
+		mockOtpUsecase.EXPECT().GetStatus(gomock.Any(), reissued).Return(sentStatus(), nil)
+
+		status, err := service.ResendLoginOtp(context.Background(), req)
+		require.NoError(t, err)
+		assert.Equal(t, sentStatus(), status)
+	})
+
+	t.Run("unknown or expired challenge", func(t *testing.T) {
+		mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelVoice).Return(nil)
+		mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), gomock.Any()).Return(entities.User{}, pgx.ErrNoRows)
+		mockOtpUsecase.EXPECT().CheckRateLimit(gomock.Any(), challenge).Return(nil)
+		mockOtpUsecase.EXPECT().GenerateOTP(entities.OtpPurposeLogin).Return("54321", nil)
+		mockOtpUsecase.EXPECT().ReissueOTP(gomock.Any(), challenge, "54321").Return(entities.OtpChallenge{}, errors.New("invalid or expired otp"))
+
+		_, err := service.ResendLoginOtp(context.Background(), req)
+		assert.ErrorContains(t, err, "invalid or expired otp")
+	})
+
+	t.Run("channel is required", func(t *testing.T) {
+		noChannel := req
+		noChannel.Channel = ""
+		_, err := service.ResendLoginOtp(context.Background(), noChannel)
+		assert.Error(t, err)
+	})
+}
+
+func TestAuthService_TestPhones(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mockrepositories.NewMockUserRepository(ctrl)
+	mockJwtUsecase := mockusecases.NewMockJwtUsecase(ctrl)
+	mockOtpUsecase := mockusecases.NewMockOtpUsecase(ctrl)
+	cfg := &config.Config{
+		App:        config.App{Env: config.EnvStaging},
+		TestPhones: config.TestPhones{Numbers: []string{"+15550100"}, Prefixes: []string{"+1555019"}, OTP: "11111"},
+	}
+	service := NewAuthUsecase(mockUserRepo, storeRefreshTokens(ctrl), mockJwtUsecase, cfg, mockOtpUsecase, ignoreActivity(ctrl))
+
+	t.Run("test phones get the fixed code without sending or rate limiting", func(t *testing.T) {
+		for _, phone := range []string{"+15550100", "+15550199"} {
+			challenge := loginChallenge(phone)
+			mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelSMS).Return(nil)
+			mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), gomock.Any()).Return(entities.User{}, pgx.ErrNoRows)
+			mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, "11111").Return(nil)
+			mockOtpUsecase.EXPECT().SaveOTP(gomock.Any(), challenge, "11111").Return("challenge-1", nil)
+			challenge.ID = "challenge-1"
+			mockOtpUsecase.EXPECT().GetStatus(gomock.Any(), challenge).Return(sentStatus(), nil)
+
+			_, err := service.LoginRequestOtp(context.Background(), dto.LoginDTO{Phone: phone})
+			require.NoError(t, err, phone)
+		}
+	})
+
+	t.Run("resends reissue the fixed code", func(t *testing.T) {
+		challenge := entities.OtpChallenge{ID: "challenge-1", Purpose: entities.OtpPurposeLogin, Phone: "+15550100", Channel: entities.ChannelSMS}
+		mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelSMS).Return(nil)
+		mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), gomock.Any()).Return(entities.User{}, pgx.ErrNoRows)
+		mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, "11111").Return(nil)
+		mockOtpUsecase.EXPECT().ReissueOTP(gomock.Any(), challenge, "11111").Return(challenge, nil)
+		mockOtpUsecase.EXPECT().GetStatus(gomock.Any(), challenge).Return(sentStatus(), nil)
+
+		_, err := service.ResendLoginOtp(context.Background(), dto.ResendOtpDTO{ChallengeID: "challenge-1", Phone: "+15550100", Channel: entities.ChannelSMS})
+		require.NoError(t, err)
+	})
+
+	t.Run("a code that does not fit the policy is reported", func(t *testing.T) {
+		mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelSMS).Return(nil)
+		mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, "11111").Return(errors.New("otp must be 6 characters"))
+
+		_, err := service.LoginRequestOtp(context.Background(), dto.LoginDTO{Phone: "+15550100"})
+		assert.ErrorContains(t, err, "TEST_PHONE_OTP")
+	})
+
+	t.Run("users signing up with a test phone are flagged", func(t *testing.T) {
+		mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, "11111").Return(nil)
+		mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), verifiedChallenge("+15550100"), "11111").Return(nil)
+		mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), "+15550100").Return(entities.User{}, errors.New("no rows in result set"))
+		mockUserRepo.EXPECT().CreateUser(gomock.Any(), entities.User{Phone: "+15550100", IsTest: true}).Return(entities.User{Id: 7, Phone: "+15550100", IsTest: true}, nil)
+		mockJwtUsecase.EXPECT().GenerateToken(entities.JwtPayload{UserId: 7}).Return("token", nil)
+		mockJwtUsecase.EXPECT().GenerateRefreshToken(refreshPayload(7)).Return("refresh-token-7", nil)
+
+		_, err := service.VerifyLoginOTP(context.Background(), dto.VerifyLoginOTP{ChallengeID: "challenge-1", Phone: "+15550100", OTP: "11111"})
+		require.NoError(t, err)
+	})
+
+	t.Run("test phones are ignored in production unless allowed", func(t *testing.T) {
+		production := *cfg
+		production.App.Env = config.EnvProduction
+		assert.False(t, production.ActiveTestPhones().Match("+15550100"))
+
+		production.TestPhones.AllowInProduction = true
+		assert.True(t, production.ActiveTestPhones().Match("+15550100"))
+		assert.False(t, production.ActiveTestPhones().Match("+15550200"))
+	})
+}
+
+func TestAuthService_LoginOtpStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockOtpUsecase := mockusecases.NewMockOtpUsecase(ctrl)
+	service := NewAuthUsecase(mockrepositories.NewMockUserRepository(ctrl), storeRefreshTokens(ctrl), mockusecases.NewMockJwtUsecase(ctrl), &config.Config{}, mockOtpUsecase, ignoreActivity(ctrl))
+
+	t.Run("status of a login challenge", func(t *testing.T) {
+		mockOtpUsecase.EXPECT().GetStatus(gomock.Any(), entities.OtpChallenge{
+			ID:        "challenge-1",
+			Purpose:   entities.OtpPurposeLogin,
+			Phone:     "+1234567890",
+			DeviceID:  "device-a",
+			RequestIP: "10.0.0.1",
+		}).Return(sentStatus(), nil)
+
+		status, err := service.LoginOtpStatus(context.Background(), dto.OtpStatusQuery{
+			ChallengeID: "challenge-1",
+			Phone:       "+1234567890",
+			DeviceID:    "device-a",
+			Client:      dto.ClientInfo{IP: "10.0.0.1"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, sentStatus(), status)
+	})
+
+	t.Run("resend information without a challenge", func(t *testing.T) {
+		mockOtpUsecase.EXPECT().GetStatus(gomock.Any(), entities.OtpChallenge{
+			Purpose: entities.OtpPurposeLogin,
+			Phone:   "+1234567890",
+		}).Return(entities.OtpStatus{ResendAfter: 30 * time.Second}, nil)
+
+		status, err := service.LoginOtpStatus(context.Background(), dto.OtpStatusQuery{Phone: "+1234567890"})
+		require.NoError(t, err)
+		assert.False(t, status.Active)
+		assert.Equal(t, 30*time.Second, status.ResendAfter)
+	})
+
+	t.Run("phone is required", func(t *testing.T) {
+		_, err := service.LoginOtpStatus(context.Background(), dto.OtpStatusQuery{ChallengeID: "challenge-1"})
+		assert.Error(t, err)
+	})
+}
+
 func TestAuthService_VerifyLoginOTP(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -125,7 +501,7 @@ func TestAuthService_VerifyLoginOTP(t *testing.T) {
 	mockOtpUsecase := mockusecases.NewMockOtpUsecase(ctrl)
 	cfg := &config.Config{}
 
-	service := NewAuthUsecase(mockUserRepo, mockJwtUsecase, cfg, mockOtpUsecase)
+	service := NewAuthUsecase(mockUserRepo, storeRefreshTokens(ctrl), mockJwtUsecase, cfg, mockOtpUsecase, ignoreActivity(ctrl))
 
 	now := time.Now()
 	existingUser := entities.User{
@@ -144,44 +520,104 @@ func TestAuthService_VerifyLoginOTP(t *testing.T) {
 	}{
 		{
 			name: "successful verification - existing user",
-			body: dto.VerifyLoginOTP{Phone: "+1234567890", OTP: "12345"},
+			body: dto.VerifyLoginOTP{ChallengeID: "challenge-1", Phone: "+1234567890", OTP: "12345"},
 			setupMock: func() {
-				mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), "+1234567890", "12345").Return(nil)
+				mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, "12345").Return(nil)
+				mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), verifiedChallenge("+1234567890"), "12345").Return(nil)
 				mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), "+1234567890").Return(existingUser, nil)
 				mockJwtUsecase.EXPECT().GenerateToken(entities.JwtPayload{UserId: 123}).Return("jwt-token-123", nil)
+				mockJwtUsecase.EXPECT().GenerateRefreshToken(refreshPayload(123)).Return("refresh-token-123", nil)
+			},
+			wantJWT: "jwt-token-123",
+			wantErr: false,
+		},
+		{
+			name: "suspended user",
+			body: dto.VerifyLoginOTP{ChallengeID: "challenge-1", Phone: "+1234567890", OTP: "12345"},
+			setupMock: func() {
+				until := now.Add(time.Hour)
+				suspended := existingUser
+				suspended.Status = entities.UserStatusSuspended
+				suspended.SuspendedUntil = &until
+				mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, "12345").Return(nil)
+				mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), verifiedChallenge("+1234567890"), "12345").Return(nil)
+				mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), "+1234567890").Return(suspended, nil)
+			},
+			wantErr:    true,
+			wantErrMsg: "account is suspended until",
+		},
+		{
+			name: "signing in restores a deleted account",
+			body: dto.VerifyLoginOTP{ChallengeID: "challenge-1", Phone: "+1234567890", OTP: "12345"},
+			setupMock: func() {
+				deleted := existingUser
+				deleted.DeletedAt = &now
+				mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, "12345").Return(nil)
+				mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), verifiedChallenge("+1234567890"), "12345").Return(nil)
+				mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), "+1234567890").Return(deleted, nil)
+				mockUserRepo.EXPECT().Restore(gomock.Any(), uint32(123)).Return(existingUser, nil)
+				mockJwtUsecase.EXPECT().GenerateToken(entities.JwtPayload{UserId: 123}).Return("jwt-token-123", nil)
+				mockJwtUsecase.EXPECT().GenerateRefreshToken(refreshPayload(123)).Return("refresh-token-123", nil)
 			},
 			wantJWT: "jwt-token-123",
 			wantErr: false,
 		},
 		{
 			name: "successful verification - new user creation",
-			body: dto.VerifyLoginOTP{Phone: "+0987654321", OTP: "54321"},
+			body: dto.VerifyLoginOTP{ChallengeID: "challenge-1", Phone: "+0987654321", OTP: "54321"},
 			setupMock: func() {
-				mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), "+0987654321", "54321").Return(nil)
+				mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, "54321").Return(nil)
+				mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), verifiedChallenge("+0987654321"), "54321").Return(nil)
 				mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), "+0987654321").Return(entities.User{}, errors.New("no rows"))
 				newUser := entities.User{Id: 456, Phone: "+0987654321", CreatedAt: now}
 				mockUserRepo.EXPECT().CreateUser(gomock.Any(), entities.User{Phone: "+0987654321"}).Return(newUser, nil)
 				mockJwtUsecase.EXPECT().GenerateToken(entities.JwtPayload{UserId: 456}).Return("jwt-token-456", nil)
+				mockJwtUsecase.EXPECT().GenerateRefreshToken(refreshPayload(456)).Return("refresh-token-456", nil)
 			},
 			wantJWT: "jwt-token-456",
 			wantErr: false,
 		},
 		{
 			name: "successful verification - new user creation (not found error)",
-			body: dto.VerifyLoginOTP{Phone: "+0987654321", OTP: "54321"},
+			body: dto.VerifyLoginOTP{ChallengeID: "challenge-1", Phone: "+0987654321", OTP: "54321"},
 			setupMock: func() {
-				mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), "+0987654321", "54321").Return(nil)
+				mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, "54321").Return(nil)
+				mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), verifiedChallenge("+0987654321"), "54321").Return(nil)
 				mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), "+0987654321").Return(entities.User{}, errors.New("user not found"))
 				newUser := entities.User{Id: 456, Phone: "+0987654321", CreatedAt: now}
 				mockUserRepo.EXPECT().CreateUser(gomock.Any(), entities.User{Phone: "+0987654321"}).Return(newUser, nil)
 				mockJwtUsecase.EXPECT().GenerateToken(entities.JwtPayload{UserId: 456}).Return("jwt-token-456", nil)
+				mockJwtUsecase.EXPECT().GenerateRefreshToken(refreshPayload(456)).Return("refresh-token-456", nil)
 			},
 			wantJWT: "jwt-token-456",
 			wantErr: false,
 		},
+		{
+			name: "verification passes the device to the challenge",
+			body: dto.VerifyLoginOTP{ChallengeID: "challenge-1", Phone: "+1234567890", DeviceID: "device-a", OTP: "12345"},
+			setupMock: func() {
+				mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, "12345").Return(nil)
+				challenge := verifiedChallenge("+1234567890")
+				challenge.DeviceID = "device-a"
+				mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), challenge, "12345").Return(nil)
+				mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), "+1234567890").Return(existingUser, nil)
+				mockJwtUsecase.EXPECT().GenerateToken(entities.JwtPayload{UserId: 123}).Return("jwt-token-123", nil)
+				mockJwtUsecase.EXPECT().GenerateRefreshToken(refreshPayload(123)).Return("refresh-token-123", nil)
+			},
+			wantJWT: "jwt-token-123",
+			wantErr: false,
+		},
+		{
+			name:       "missing challenge id",
+			body:       dto.VerifyLoginOTP{Phone: "+1234567890", OTP: "12345"},
+			setupMock:  func() {},
+			wantJWT:    "",
+			wantErr:    true,
+			wantErrMsg: "validation",
+		},
 		{
 			name:       "invalid phone number",
-			body:       dto.VerifyLoginOTP{Phone: "123", OTP: "12345"},
+			body:       dto.VerifyLoginOTP{ChallengeID: "challenge-1", Phone: "123", OTP: "12345"},
 			setupMock:  func() {},
 			wantJWT:    "",
 			wantErr:    true,
@@ -189,25 +625,47 @@ func TestAuthService_VerifyLoginOTP(t *testing.T) {
 		},
 		{
 			name:       "invalid OTP format",
-			body:       dto.VerifyLoginOTP{Phone: "+1234567890", OTP: "123"},
+			body:       dto.VerifyLoginOTP{ChallengeID: "challenge-1", Phone: "+1234567890", OTP: "123"},
 			setupMock:  func() {},
 			wantJWT:    "",
 			wantErr:    true,
 			wantErrMsg: "validation",
 		},
 		{
-			name:       "non-numeric OTP",
-			body:       dto.VerifyLoginOTP{Phone: "+1234567890", OTP: "abcde"},
+			name: "OTP not matching the active policy",
+			body: dto.VerifyLoginOTP{ChallengeID: "challenge-1", Phone: "+1234567890", OTP: "abcde"},
+			setupMock: func() {
+				mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, "abcde").Return(errors.New("otp contains invalid characters"))
+			},
+			wantJWT:    "",
+			wantErr:    true,
+			wantErrMsg: "invalid characters",
+		},
+		{
+			name:       "non-alphanumeric OTP",
+			body:       dto.VerifyLoginOTP{ChallengeID: "challenge-1", Phone: "+1234567890", OTP: "12-45"},
 			setupMock:  func() {},
 			wantJWT:    "",
 			wantErr:    true,
 			wantErrMsg: "validation",
 		},
+		{
+			name: "OTP pasted with surrounding whitespace",
+			body: dto.VerifyLoginOTP{ChallengeID: "challenge-1", Phone: "+1234567890", OTP: " 12345\n"},
+			setupMock: func() {
+				mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, "12345").Return(nil)
+				mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), verifiedChallenge("+1234567890"), "12345").Return(errors.New("invalid OTP"))
+			},
+			wantJWT:    "",
+			wantErr:    true,
+			wantErrMsg: "invalid OTP",
+		},
 		{
 			name: "OTP verification failed",
-			body: dto.VerifyLoginOTP{Phone: "+1234567890", OTP: "12345"},
+			body: dto.VerifyLoginOTP{ChallengeID: "challenge-1", Phone: "+1234567890", OTP: "12345"},
 			setupMock: func() {
-				mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), "+1234567890", "12345").Return(errors.New("invalid OTP"))
+				mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, "12345").Return(nil)
+				mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), verifiedChallenge("+1234567890"), "12345").Return(errors.New("invalid OTP"))
 			},
 			wantJWT:    "",
 			wantErr:    true,
@@ -215,9 +673,10 @@ func TestAuthService_VerifyLoginOTP(t *testing.T) {
 		},
 		{
 			name: "user repository error (not user creation case)",
-			body: dto.VerifyLoginOTP{Phone: "+1234567890", OTP: "12345"},
+			body: dto.VerifyLoginOTP{ChallengeID: "challenge-1", Phone: "+1234567890", OTP: "12345"},
 			setupMock: func() {
-				mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), "+1234567890", "12345").Return(nil)
+				mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, "12345").Return(nil)
+				mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), verifiedChallenge("+1234567890"), "12345").Return(nil)
 				mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), "+1234567890").Return(entities.User{}, errors.New("database connection error"))
 			},
 			wantJWT:    "",
@@ -226,9 +685,10 @@ func TestAuthService_VerifyLoginOTP(t *testing.T) {
 		},
 		{
 			name: "user creation failed",
-			body: dto.VerifyLoginOTP{Phone: "+0987654321", OTP: "54321"},
+			body: dto.VerifyLoginOTP{ChallengeID: "challenge-1", Phone: "+0987654321", OTP: "54321"},
 			setupMock: func() {
-				mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), "+0987654321", "54321").Return(nil)
+				mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, "54321").Return(nil)
+				mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), verifiedChallenge("+0987654321"), "54321").Return(nil)
 				mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), "+0987654321").Return(entities.User{}, errors.New("no rows"))
 				mockUserRepo.EXPECT().CreateUser(gomock.Any(), entities.User{Phone: "+0987654321"}).Return(entities.User{}, errors.New("creation failed"))
 			},
@@ -238,9 +698,10 @@ func TestAuthService_VerifyLoginOTP(t *testing.T) {
 		},
 		{
 			name: "JWT generation failed",
-			body: dto.VerifyLoginOTP{Phone: "+1234567890", OTP: "12345"},
+			body: dto.VerifyLoginOTP{ChallengeID: "challenge-1", Phone: "+1234567890", OTP: "12345"},
 			setupMock: func() {
-				mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), "+1234567890", "12345").Return(nil)
+				mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, "12345").Return(nil)
+				mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), verifiedChallenge("+1234567890"), "12345").Return(nil)
 				mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), "+1234567890").Return(existingUser, nil)
 				mockJwtUsecase.EXPECT().GenerateToken(entities.JwtPayload{UserId: 123}).Return("", errors.New("JWT generation failed"))
 			},
@@ -264,7 +725,7 @@ func TestAuthService_VerifyLoginOTP(t *testing.T) {
 				}
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, tt.wantJWT, jwt)
+				assert.Equal(t, tt.wantJWT, jwt.AccessToken)
 			}
 		})
 	}
@@ -279,7 +740,7 @@ func TestAuthService_ValidateToken(t *testing.T) {
 	mockOtpUsecase := mockusecases.NewMockOtpUsecase(ctrl)
 	cfg := &config.Config{}
 
-	service := NewAuthUsecase(mockUserRepo, mockJwtUsecase, cfg, mockOtpUsecase)
+	service := NewAuthUsecase(mockUserRepo, storeRefreshTokens(ctrl), mockJwtUsecase, cfg, mockOtpUsecase, ignoreActivity(ctrl))
 
 	now := time.Now()
 	testUser := entities.User{
@@ -287,6 +748,15 @@ func TestAuthService_ValidateToken(t *testing.T) {
 		Phone:     "+1234567890",
 		CreatedAt: now,
 	}
+	suspendedUser := func(until time.Time) entities.User {
+		user := testUser
+		user.Status = entities.UserStatusSuspended
+		user.SuspendedUntil = &until
+		return user
+	}
+	revokedAt := now.Add(-time.Minute)
+	revokedUser := testUser
+	revokedUser.TokensInvalidBefore = &revokedAt
 
 	tests := []struct {
 		name       string
@@ -336,6 +806,72 @@ func TestAuthService_ValidateToken(t *testing.T) {
 			wantUser: testUser,
 			wantErr:  false,
 		},
+		{
+			name:  "token issued after revocation",
+			token: "valid-jwt-token",
+			setupMock: func() {
+				mockJwtUsecase.EXPECT().ValidateToken("valid-jwt-token").Return(entities.JwtPayload{UserId: 123, IssuedAt: now}, nil)
+				mockUserRepo.EXPECT().GetUserById(gomock.Any(), uint32(123)).Return(revokedUser, nil)
+			},
+			wantUser: revokedUser,
+			wantErr:  false,
+		},
+		{
+			name:  "token revoked by a phone change",
+			token: "valid-jwt-token",
+			setupMock: func() {
+				mockJwtUsecase.EXPECT().ValidateToken("valid-jwt-token").Return(entities.JwtPayload{UserId: 123, IssuedAt: now.Add(-2 * time.Minute)}, nil)
+				mockUserRepo.EXPECT().GetUserById(gomock.Any(), uint32(123)).Return(revokedUser, nil)
+			},
+			wantUser:   entities.User{},
+			wantErr:    true,
+			wantErrMsg: "revoked",
+		},
+		{
+			name:  "token without iat is revoked",
+			token: "valid-jwt-token",
+			setupMock: func() {
+				mockJwtUsecase.EXPECT().ValidateToken("valid-jwt-token").Return(entities.JwtPayload{UserId: 123}, nil)
+				mockUserRepo.EXPECT().GetUserById(gomock.Any(), uint32(123)).Return(revokedUser, nil)
+			},
+			wantUser:   entities.User{},
+			wantErr:    true,
+			wantErrMsg: "revoked",
+		},
+		{
+			name:  "deleted account",
+			token: "valid-jwt-token",
+			setupMock: func() {
+				deleted := testUser
+				deleted.DeletedAt = &now
+				mockJwtUsecase.EXPECT().ValidateToken("valid-jwt-token").Return(entities.JwtPayload{UserId: 123, IssuedAt: now}, nil)
+				mockUserRepo.EXPECT().GetUserById(gomock.Any(), uint32(123)).Return(deleted, nil)
+			},
+			wantUser:   entities.User{},
+			wantErr:    true,
+			wantErrMsg: "revoked",
+		},
+		{
+			name:  "suspended account",
+			token: "valid-jwt-token",
+			setupMock: func() {
+				mockJwtUsecase.EXPECT().ValidateToken("valid-jwt-token").Return(entities.JwtPayload{UserId: 123, IssuedAt: now}, nil)
+				mockUserRepo.EXPECT().GetUserById(gomock.Any(), uint32(123)).Return(suspendedUser(now.Add(time.Hour)), nil)
+			},
+			wantUser:   entities.User{},
+			wantErr:    true,
+			wantErrMsg: "account is suspended until",
+		},
+		{
+			name:  "expired suspension",
+			token: "valid-jwt-token",
+			setupMock: func() {
+				mockJwtUsecase.EXPECT().ValidateToken("valid-jwt-token").Return(entities.JwtPayload{UserId: 123, IssuedAt: now}, nil)
+				mockUserRepo.EXPECT().GetUserById(gomock.Any(), uint32(123)).Return(suspendedUser(now.Add(-time.Hour)), nil)
+			},
+			wantUser: suspendedUser(now.Add(-time.Hour)),
+			wantErr:  false,
+		},
 		{
 			name:       "empty token",
 			token:      "",
@@ -437,7 +973,7 @@ func TestAuthService_Integration(t *testing.T) {
 	mockOtpUsecase := mockusecases.NewMockOtpUsecase(ctrl)
 	cfg := &config.Config{}
 
-	service := NewAuthUsecase(mockUserRepo, mockJwtUsecase, cfg, mockOtpUsecase)
+	service := NewAuthUsecase(mockUserRepo, storeRefreshTokens(ctrl), mockJwtUsecase, cfg, mockOtpUsecase, ignoreActivity(ctrl))
 
 	phone := "+1234567890"
 	otp := "12345"
@@ -446,15 +982,20 @@ func TestAuthService_Integration(t *testing.T) {
 	// Full flow integration test
 	t.Run("complete authentication flow", func(t *testing.T) {
 		// Step 1: Request OTP
-		mockOtpUsecase.EXPECT().GenerateOTP().Return(otp, nil)
-		mockOtpUsecase.EXPECT().SaveOTP(gomock.Any(), phone, otp).Return(nil)
-		mockOtpUsecase.EXPECT().SendOtpSms(gomock.Any(), phone, otp).Return(nil)
+		mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelSMS).Return(nil)
+		mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), gomock.Any()).Return(entities.User{}, pgx.ErrNoRows)
+		mockOtpUsecase.EXPECT().CheckRateLimit(gomock.Any(), gomock.Any()).Return(nil)
+		mockOtpUsecase.EXPECT().GenerateOTP(entities.OtpPurposeLogin).Return(otp, nil)
+		mockOtpUsecase.EXPECT().SaveOTP(gomock.Any(), loginChallenge(phone), otp).Return("challenge-1", nil)
+		mockOtpUsecase.EXPECT().SendOTP(gomock.Any(), sentChallenge(phone), phone, otp).Return(nil)
+		mockOtpUsecase.EXPECT().GetStatus(gomock.Any(), sentChallenge(phone)).Return(sentStatus(), nil)
 
-		err := service.LoginRequestOtp(context.Background(), dto.LoginDTO{Phone: phone})
+		status, err := service.LoginRequestOtp(context.Background(), dto.LoginDTO{Phone: phone})
 		require.NoError(t, err)
 
 		// Step 2: Verify OTP and create new user
-		mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), phone, otp).Return(nil)
+		mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, otp).Return(nil)
+		mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), verifiedChallenge(phone), otp).Return(nil)
 		mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), phone).Return(entities.User{}, errors.New("no rows"))
 
 		newUser := entities.User{Id: 123, Phone: phone, CreatedAt: now}
@@ -462,19 +1003,21 @@ func TestAuthService_Integration(t *testing.T) {
 
 		jwtToken := "jwt-token-123"
 		mockJwtUsecase.EXPECT().GenerateToken(entities.JwtPayload{UserId: 123}).Return(jwtToken, nil)
+		mockJwtUsecase.EXPECT().GenerateRefreshToken(refreshPayload(123)).Return("refresh-token-123", nil)
 
 		token, err := service.VerifyLoginOTP(context.Background(), dto.VerifyLoginOTP{
-			Phone: phone,
-			OTP:   otp,
+			ChallengeID: status.ChallengeID,
+			Phone:       phone,
+			OTP:         otp,
 		})
 		require.NoError(t, err)
-		assert.Equal(t, jwtToken, token)
+		assert.Equal(t, jwtToken, token.AccessToken)
 
 		// Step 3: Validate the token
 		mockJwtUsecase.EXPECT().ValidateToken(jwtToken).Return(entities.JwtPayload{UserId: 123}, nil)
 		mockUserRepo.EXPECT().GetUserById(gomock.Any(), uint32(123)).Return(newUser, nil)
 
-		user, err := service.ValidateToken(context.Background(), token)
+		user, err := service.ValidateToken(context.Background(), token.AccessToken)
 		require.NoError(t, err)
 		assert.Equal(t, newUser, user)
 	})
@@ -483,33 +1026,289 @@ func TestAuthService_Integration(t *testing.T) {
 		existingUser := entities.User{Id: 456, Phone: phone, CreatedAt: now.Add(-time.Hour)}
 
 		// Step 1: Request OTP
-		mockOtpUsecase.EXPECT().GenerateOTP().Return(otp, nil)
-		mockOtpUsecase.EXPECT().SaveOTP(gomock.Any(), phone, otp).Return(nil)
-		mockOtpUsecase.EXPECT().SendOtpSms(gomock.Any(), phone, otp).Return(nil)
+		mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelSMS).Return(nil)
+		mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), gomock.Any()).Return(entities.User{}, pgx.ErrNoRows)
+		mockOtpUsecase.EXPECT().CheckRateLimit(gomock.Any(), gomock.Any()).Return(nil)
+		mockOtpUsecase.EXPECT().GenerateOTP(entities.OtpPurposeLogin).Return(otp, nil)
+		mockOtpUsecase.EXPECT().SaveOTP(gomock.Any(), loginChallenge(phone), otp).Return("challenge-1", nil)
+		mockOtpUsecase.EXPECT().SendOTP(gomock.Any(), sentChallenge(phone), phone, otp).Return(nil)
+		mockOtpUsecase.EXPECT().GetStatus(gomock.Any(), sentChallenge(phone)).Return(sentStatus(), nil)
 
-		err := service.LoginRequestOtp(context.Background(), dto.LoginDTO{Phone: phone})
+		status, err := service.LoginRequestOtp(context.Background(), dto.LoginDTO{Phone: phone})
 		require.NoError(t, err)
 
 		// Step 2: Verify OTP for existing user
-		mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), phone, otp).Return(nil)
+		mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, otp).Return(nil)
+		mockOtpUsecase.EXPECT().VerifyOTP(gomock.Any(), verifiedChallenge(phone), otp).Return(nil)
 		mockUserRepo.EXPECT().GetUserByPhone(gomock.Any(), phone).Return(existingUser, nil)
 
 		jwtToken := "jwt-token-456"
 		mockJwtUsecase.EXPECT().GenerateToken(entities.JwtPayload{UserId: 456}).Return(jwtToken, nil)
+		mockJwtUsecase.EXPECT().GenerateRefreshToken(refreshPayload(456)).Return("refresh-token-456", nil)
 
 		token, err := service.VerifyLoginOTP(context.Background(), dto.VerifyLoginOTP{
-			Phone: phone,
-			OTP:   otp,
+			ChallengeID: status.ChallengeID,
+			Phone:       phone,
+			OTP:         otp,
 		})
 		require.NoError(t, err)
-		assert.Equal(t, jwtToken, token)
+		assert.Equal(t, jwtToken, token.AccessToken)
 
 		// Step 3: Validate the token with Bearer prefix
 		mockJwtUsecase.EXPECT().ValidateToken(jwtToken).Return(entities.JwtPayload{UserId: 456}, nil)
 		mockUserRepo.EXPECT().GetUserById(gomock.Any(), uint32(456)).Return(existingUser, nil)
 
-		user, err := service.ValidateToken(context.Background(), "Bearer "+token)
+		user, err := service.ValidateToken(context.Background(), "Bearer "+token.AccessToken)
 		require.NoError(t, err)
 		assert.Equal(t, existingUser, user)
 	})
 }
+
+func TestAuthService_RefreshToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mockrepositories.NewMockUserRepository(ctrl)
+	mockRefreshTokens := mockrepositories.NewMockRefreshTokenRepository(ctrl)
+	mockJwtUsecase := mockusecases.NewMockJwtUsecase(ctrl)
+	service := NewAuthUsecase(mockUserRepo, mockRefreshTokens, mockJwtUsecase, &config.Config{}, mockusecases.NewMockOtpUsecase(ctrl), ignoreActivity(ctrl))
+
+	ctx := context.Background()
+	issuedAt := time.Now().Add(-time.Hour)
+	payload := entities.JwtPayload{UserId: 123, IssuedAt: issuedAt, TokenID: "token-1"}
+	user := entities.User{Id: 123, Phone: "+1234567890"}
+	stored := entities.RefreshToken{ID: "token-1", FamilyID: "family-1", UserID: 123, DeviceID: "device-1"}
+	client := dto.ClientInfo{IP: "203.0.113.7", UserAgent: "test-agent"}
+
+	t.Run("issues a new pair in the same session", func(t *testing.T) {
+		mockJwtUsecase.EXPECT().ValidateRefreshToken("refresh-token").Return(payload, nil)
+		mockUserRepo.EXPECT().GetUserById(ctx, uint32(123)).Return(user, nil)
+		mockRefreshTokens.EXPECT().Use(ctx, "token-1").Return(stored, true, nil)
+		mockJwtUsecase.EXPECT().GenerateToken(entities.JwtPayload{UserId: 123}).Return("jwt-token-123", nil)
+		var next entities.RefreshToken
+		mockRefreshTokens.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, token entities.RefreshToken) error {
+			next = token
+			return nil
+		})
+		mockJwtUsecase.EXPECT().GenerateRefreshToken(refreshPayload(123)).DoAndReturn(func(p entities.JwtPayload) (string, error) {
+			assert.Equal(t, next.ID, p.TokenID)
+			return "refresh-token-123", nil
+		})
+
+		tokens, err := service.RefreshToken(ctx, dto.RefreshTokenDTO{RefreshToken: "refresh-token", Client: client})
+		require.NoError(t, err)
+		assert.Equal(t, entities.AuthTokens{AccessToken: "jwt-token-123", RefreshToken: "refresh-token-123"}, tokens)
+		assert.NotEqual(t, "token-1", next.ID)
+		assert.Equal(t, "family-1", next.FamilyID)
+		assert.Equal(t, "device-1", next.DeviceID)
+		assert.Equal(t, client.IP, next.IP)
+		assert.WithinDuration(t, time.Now().Add(refreshTokenTTL), next.ExpiresAt, time.Minute)
+	})
+
+	t.Run("invalid refresh token", func(t *testing.T) {
+		mockJwtUsecase.EXPECT().ValidateRefreshToken("access-token").Return(entities.JwtPayload{}, errors.New("invalid refresh token"))
+
+		_, err := service.RefreshToken(ctx, dto.RefreshTokenDTO{RefreshToken: "access-token"})
+		assert.EqualError(t, err, "invalid refresh token")
+	})
+
+	t.Run("reused token signs out the session", func(t *testing.T) {
+		used := stored
+		usedAt := time.Now().Add(-time.Minute)
+		used.UsedAt = &usedAt
+		mockJwtUsecase.EXPECT().ValidateRefreshToken("refresh-token").Return(payload, nil)
+		mockUserRepo.EXPECT().GetUserById(ctx, uint32(123)).Return(user, nil)
+		mockRefreshTokens.EXPECT().Use(ctx, "token-1").Return(used, false, nil)
+		mockRefreshTokens.EXPECT().RevokeFamily(ctx, "token-1").Return(nil)
+
+		_, err := service.RefreshToken(ctx, dto.RefreshTokenDTO{RefreshToken: "refresh-token"})
+		assert.ErrorIs(t, err, errRefreshTokenReused)
+	})
+
+	t.Run("token of a signed out session", func(t *testing.T) {
+		revoked := stored
+		revokedAt := time.Now().Add(-time.Minute)
+		revoked.RevokedAt = &revokedAt
+		mockJwtUsecase.EXPECT().ValidateRefreshToken("refresh-token").Return(payload, nil)
+		mockUserRepo.EXPECT().GetUserById(ctx, uint32(123)).Return(user, nil)
+		mockRefreshTokens.EXPECT().Use(ctx, "token-1").Return(revoked, false, nil)
+
+		_, err := service.RefreshToken(ctx, dto.RefreshTokenDTO{RefreshToken: "refresh-token"})
+		assert.ErrorIs(t, err, errTokenRevoked)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		mockJwtUsecase.EXPECT().ValidateRefreshToken("refresh-token").Return(payload, nil)
+		mockUserRepo.EXPECT().GetUserById(ctx, uint32(123)).Return(user, nil)
+		mockRefreshTokens.EXPECT().Use(ctx, "token-1").Return(entities.RefreshToken{}, false, pgx.ErrNoRows)
+
+		_, err := service.RefreshToken(ctx, dto.RefreshTokenDTO{RefreshToken: "refresh-token"})
+		assert.ErrorIs(t, err, errTokenRevoked)
+	})
+
+	t.Run("token without an id", func(t *testing.T) {
+		mockJwtUsecase.EXPECT().ValidateRefreshToken("refresh-token").Return(entities.JwtPayload{UserId: 123, IssuedAt: issuedAt}, nil)
+		mockUserRepo.EXPECT().GetUserById(ctx, uint32(123)).Return(user, nil)
+
+		_, err := service.RefreshToken(ctx, dto.RefreshTokenDTO{RefreshToken: "refresh-token"})
+		assert.ErrorIs(t, err, errTokenRevoked)
+	})
+
+	t.Run("signed out since the token was issued", func(t *testing.T) {
+		signedOut := user
+		invalidBefore := issuedAt.Add(time.Minute)
+		signedOut.TokensInvalidBefore = &invalidBefore
+		mockJwtUsecase.EXPECT().ValidateRefreshToken("refresh-token").Return(payload, nil)
+		mockUserRepo.EXPECT().GetUserById(ctx, uint32(123)).Return(signedOut, nil)
+
+		_, err := service.RefreshToken(ctx, dto.RefreshTokenDTO{RefreshToken: "refresh-token"})
+		assert.ErrorIs(t, err, errTokenRevoked)
+	})
+
+	t.Run("banned user", func(t *testing.T) {
+		banned := user
+		banned.Status = entities.UserStatusBanned
+		mockJwtUsecase.EXPECT().ValidateRefreshToken("refresh-token").Return(payload, nil)
+		mockUserRepo.EXPECT().GetUserById(ctx, uint32(123)).Return(banned, nil)
+
+		_, err := service.RefreshToken(ctx, dto.RefreshTokenDTO{RefreshToken: "refresh-token"})
+		var blocked *UserBlockedError
+		assert.ErrorAs(t, err, &blocked)
+	})
+
+	t.Run("refresh token is required", func(t *testing.T) {
+		_, err := service.RefreshToken(ctx, dto.RefreshTokenDTO{})
+		assert.Error(t, err)
+	})
+}
+
+func TestAuthService_Logout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRefreshTokens := mockrepositories.NewMockRefreshTokenRepository(ctrl)
+	mockJwtUsecase := mockusecases.NewMockJwtUsecase(ctrl)
+	service := NewAuthUsecase(mockrepositories.NewMockUserRepository(ctrl), mockRefreshTokens, mockJwtUsecase, &config.Config{}, mockusecases.NewMockOtpUsecase(ctrl), ignoreActivity(ctrl))
+	ctx := context.Background()
+
+	t.Run("revokes the session", func(t *testing.T) {
+		mockJwtUsecase.EXPECT().ValidateRefreshToken("refresh-token").Return(entities.JwtPayload{UserId: 123, TokenID: "token-1"}, nil)
+		mockRefreshTokens.EXPECT().RevokeFamily(ctx, "token-1").Return(nil)
+
+		assert.NoError(t, service.Logout(ctx, dto.RefreshTokenDTO{RefreshToken: "refresh-token"}))
+	})
+
+	t.Run("invalid refresh token", func(t *testing.T) {
+		mockJwtUsecase.EXPECT().ValidateRefreshToken("access-token").Return(entities.JwtPayload{}, errors.New("invalid refresh token"))
+
+		assert.EqualError(t, service.Logout(ctx, dto.RefreshTokenDTO{RefreshToken: "access-token"}), "invalid refresh token")
+	})
+
+	t.Run("token without an id", func(t *testing.T) {
+		mockJwtUsecase.EXPECT().ValidateRefreshToken("refresh-token").Return(entities.JwtPayload{UserId: 123}, nil)
+
+		assert.ErrorIs(t, service.Logout(ctx, dto.RefreshTokenDTO{RefreshToken: "refresh-token"}), errTokenRevoked)
+	})
+}
+
+func TestAuthService_Activity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mockrepositories.NewMockUserRepository(ctrl)
+	mockJwtUsecase := mockusecases.NewMockJwtUsecase(ctrl)
+	mockOtpUsecase := mockusecases.NewMockOtpUsecase(ctrl)
+	mockActivity := mockusecases.NewMockActivityUsecase(ctrl)
+	service := NewAuthUsecase(mockUserRepo, storeRefreshTokens(ctrl), mockJwtUsecase, &config.Config{}, mockOtpUsecase, mockActivity)
+
+	ctx := context.Background()
+	phone := "+1234567890"
+	client := dto.ClientInfo{IP: "10.0.0.1", UserAgent: "app/1.0"}
+
+	t.Run("otp requests", func(t *testing.T) {
+		rateLimited := errors.New("too many requests")
+		mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelSMS).Return(nil)
+		mockOtpUsecase.EXPECT().CheckRateLimit(ctx, gomock.Any()).Return(rateLimited)
+		mockActivity.EXPECT().Record(ctx, entities.AuthEvent{
+			Kind:      entities.AuthEventOtpRequest,
+			Phone:     phone,
+			Channel:   entities.ChannelSMS,
+			DeviceID:  "device-a",
+			IP:        "10.0.0.1",
+			UserAgent: "app/1.0",
+		}, rateLimited)
+
+		_, err := service.LoginRequestOtp(ctx, dto.LoginDTO{Phone: phone, DeviceID: "device-a", Client: client})
+		assert.ErrorIs(t, err, rateLimited)
+	})
+
+	t.Run("withheld codes are recorded as failures", func(t *testing.T) {
+		mockOtpUsecase.EXPECT().ValidateChannel(entities.ChannelSMS).Return(nil)
+		mockOtpUsecase.EXPECT().CheckRateLimit(ctx, gomock.Any()).Return(nil)
+		mockOtpUsecase.EXPECT().GenerateOTP(entities.OtpPurposeLogin).Return("12345", nil)
+		mockUserRepo.EXPECT().GetUserByPhone(ctx, phone).Return(entities.User{Id: 7, Phone: phone, Status: entities.UserStatusBanned}, nil)
+		mockOtpUsecase.EXPECT().SaveOTP(ctx, gomock.Any(), "12345").Return("challenge-1", nil)
+		mockOtpUsecase.EXPECT().GetStatus(ctx, gomock.Any()).Return(sentStatus(), nil)
+		mockActivity.EXPECT().Record(ctx, gomock.Any(), gomock.Any()).Do(func(_ context.Context, _ entities.AuthEvent, err error) {
+			var blocked *UserBlockedError
+			assert.ErrorAs(t, err, &blocked)
+		})
+
+		_, err := service.LoginRequestOtp(ctx, dto.LoginDTO{Phone: phone})
+		assert.NoError(t, err)
+	})
+
+	t.Run("malformed requests are not recorded", func(t *testing.T) {
+		_, err := service.LoginRequestOtp(ctx, dto.LoginDTO{Phone: "123"})
+		assert.Error(t, err)
+	})
+
+	t.Run("failed verification", func(t *testing.T) {
+		wrongCode := errors.New("invalid otp")
+		mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, "12345").Return(nil)
+		mockOtpUsecase.EXPECT().VerifyOTP(ctx, verifiedChallenge(phone), "12345").Return(wrongCode)
+		mockActivity.EXPECT().Record(ctx, entities.AuthEvent{
+			Kind:      entities.AuthEventOtpVerify,
+			Phone:     phone,
+			IP:        "10.0.0.1",
+			UserAgent: "app/1.0",
+		}, wrongCode)
+
+		_, err := service.VerifyLoginOTP(ctx, dto.VerifyLoginOTP{ChallengeID: "challenge-1", Phone: phone, OTP: "12345", Client: client})
+		assert.ErrorIs(t, err, wrongCode)
+	})
+
+	t.Run("successful verification names the user", func(t *testing.T) {
+		userID := uint32(123)
+		mockOtpUsecase.EXPECT().ValidateFormat(entities.OtpPurposeLogin, "12345").Return(nil)
+		mockOtpUsecase.EXPECT().VerifyOTP(ctx, verifiedChallenge(phone), "12345").Return(nil)
+		mockUserRepo.EXPECT().GetUserByPhone(ctx, phone).Return(entities.User{Id: userID, Phone: phone}, nil)
+		mockJwtUsecase.EXPECT().GenerateToken(entities.JwtPayload{UserId: userID}).Return("jwt-token-123", nil)
+		mockJwtUsecase.EXPECT().GenerateRefreshToken(refreshPayload(userID)).Return("refresh-token-123", nil)
+		mockActivity.EXPECT().Record(ctx, entities.AuthEvent{
+			UserID:    &userID,
+			Kind:      entities.AuthEventOtpVerify,
+			Phone:     phone,
+			IP:        "10.0.0.1",
+			UserAgent: "app/1.0",
+		}, nil)
+
+		_, err := service.VerifyLoginOTP(ctx, dto.VerifyLoginOTP{ChallengeID: "challenge-1", Phone: phone, OTP: "12345", Client: client})
+		require.NoError(t, err)
+	})
+
+	t.Run("token refresh", func(t *testing.T) {
+		userID := uint32(123)
+		mockJwtUsecase.EXPECT().ValidateRefreshToken("refresh-token").Return(entities.JwtPayload{UserId: userID}, nil)
+		mockUserRepo.EXPECT().GetUserById(ctx, userID).Return(entities.User{Id: userID, DeletedAt: &time.Time{}}, nil)
+		mockActivity.EXPECT().Record(ctx, entities.AuthEvent{
+			UserID:    &userID,
+			Kind:      entities.AuthEventTokenRefresh,
+			IP:        "10.0.0.1",
+			UserAgent: "app/1.0",
+		}, errTokenRevoked)
+
+		_, err := service.RefreshToken(ctx, dto.RefreshTokenDTO{RefreshToken: "refresh-token", Client: client})
+		assert.ErrorIs(t, err, errTokenRevoked)
+	})
+}
@@ -0,0 +1,202 @@
+package usecases
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/config"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/logger"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/mailer"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/ratelimit"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrNoEmail                  = errors.New("add an email address to your profile first")
+	ErrEmailAlreadyVerified     = errors.New("email address is already verified")
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification link")
+)
+
+type emailVerification struct {
+	userRepository repositories.UserRepository
+	mailer         mailer.Mailer
+	limiter        *ratelimit.Limiter
+	settings       *config.Settings
+	cfg            config.EmailVerification
+	mailTimeout    time.Duration
+	key            []byte
+	l              logger.Logger
+	now            func() time.Time
+}
+
+// NewEmailVerificationUsecase sends signed, stateless verification links. The
+// signing key is derived from JWT_SECRET so the two never share a MAC key.
+func NewEmailVerificationUsecase(userRepository repositories.UserRepository, m mailer.Mailer, limiter *ratelimit.Limiter, settings *config.Settings, cfg *config.Config, l logger.Logger) EmailVerificationUsecase {
+	mac := hmac.New(sha256.New, []byte(cfg.AUTH.JwtSecret))
+	mac.Write([]byte("email-verification"))
+	return &emailVerification{
+		userRepository: userRepository,
+		mailer:         m,
+		limiter:        limiter,
+		settings:       settings,
+		cfg:            cfg.EmailVerification,
+		mailTimeout:    cfg.Mail.Timeout,
+		key:            mac.Sum(nil),
+		l:              l,
+		now:            time.Now,
+	}
+}
+
+func (e *emailVerification) SendVerification(ctx context.Context, userID uint32, client dto.ClientInfo) error {
+	user, err := e.userRepository.GetUserById(ctx, userID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case user.Email == "":
+		return ErrNoEmail
+	case user.EmailVerified:
+		return ErrEmailAlreadyVerified
+	}
+
+	if _, err := e.limiter.Allow(ctx, e.rateLimitRules(user, client)...); err != nil {
+		var exceeded *ratelimit.ExceededError
+		if errors.As(err, &exceeded) {
+			e.l.Warn("verification email for user %d from %s rejected by the %s rate limit", user.Id, client.IP, exceeded.Rule.Name)
+		}
+		return err
+	}
+
+	link, err := url.Parse(e.cfg.URL)
+	if err != nil {
+		return err
+	}
+	query := link.Query()
+	query.Set("token", e.token(user.Id, user.Email, e.now().Add(e.cfg.TTL)))
+	link.RawQuery = query.Encode()
+
+	ctx, cancel := context.WithTimeout(ctx, e.mailTimeout)
+	defer cancel()
+	return e.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Text: fmt.Sprintf("Open this link to verify your email address:\n\n%s\n\n"+
+			"The link is valid for %s. If you did not add this address to your account, ignore this email.\n",
+			link, e.cfg.TTL),
+	})
+}
+
+// rateLimitRules mirror the OTP limits: a resend cooldown, then per user, per
+// address, per IP and global limits within OTP_RATE_WINDOW.
+func (e *emailVerification) rateLimitRules(user entities.User, client dto.ClientInfo) []ratelimit.Rule {
+	policy := e.settings.Get().OTP
+	limit := func(name string, rate int) ratelimit.Limit {
+		return ratelimit.Limit{
+			Name:      name,
+			Rate:      rate,
+			Period:    policy.RateWindow,
+			Algorithm: ratelimit.Algorithm(policy.RateLimits.Algorithm),
+		}
+	}
+	userKey := strconv.FormatUint(uint64(user.Id), 10)
+
+	return []ratelimit.Rule{
+		{
+			Limit: ratelimit.Limit{Name: "resend", Rate: 1, Period: policy.ResendInterval, Algorithm: ratelimit.GCRA},
+			Key:   userKey,
+		},
+		{Limit: limit("user", policy.RateLimit), Key: userKey},
+		{Limit: limit("email", policy.RateLimit), Key: strings.ToLower(user.Email)},
+		{Limit: limit("ip", policy.RateLimits.PerIP), Key: client.IP},
+		{Limit: limit("global", policy.RateLimits.Global), Key: "all"},
+	}
+}
+
+func (e *emailVerification) Verify(ctx context.Context, userID uint32, req dto.VerifyEmailDTO) (entities.User, error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return entities.User{}, err
+	}
+	tokenUserID, emailHash, ok := e.parseToken(req.Token)
+	if !ok || tokenUserID != userID {
+		return entities.User{}, ErrInvalidVerificationToken
+	}
+
+	user, err := e.userRepository.GetUserById(ctx, userID)
+	if err != nil {
+		return entities.User{}, err
+	}
+	// the link is only good for the address it was sent to
+	if user.Email == "" || !hmac.Equal(emailHash, hashEmail(user.Email)) {
+		return entities.User{}, ErrInvalidVerificationToken
+	}
+	if user.EmailVerified {
+		return user, nil
+	}
+
+	user, err = e.userRepository.MarkEmailVerified(ctx, userID, user.Email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// the email changed since we read the user
+		return entities.User{}, ErrInvalidVerificationToken
+	}
+	return user, err
+}
+
+// token is "userID.expiry.emailHash.mac", each part base64url encoded where
+// needed. The address itself stays out of the link.
+func (e *emailVerification) token(userID uint32, email string, expiresAt time.Time) string {
+	payload := fmt.Sprintf("%d.%d.%s", userID, expiresAt.Unix(), base64.RawURLEncoding.EncodeToString(hashEmail(email)))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(e.sign(payload))
+}
+
+func (e *emailVerification) parseToken(token string) (userID uint32, emailHash []byte, ok bool) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return 0, nil, false
+	}
+	payload := token[:i]
+	mac, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil || !hmac.Equal(mac, e.sign(payload)) {
+		return 0, nil, false
+	}
+
+	parts := strings.Split(payload, ".")
+	if len(parts) != 3 {
+		return 0, nil, false
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, nil, false
+	}
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || !e.now().Before(time.Unix(expiry, 0)) {
+		return 0, nil, false
+	}
+	emailHash, err = base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return 0, nil, false
+	}
+	return uint32(id), emailHash, true
+}
+
+func (e *emailVerification) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, e.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func hashEmail(email string) []byte {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return sum[:16]
+}
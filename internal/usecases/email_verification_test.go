@@ -0,0 +1,150 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/config"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories/mockrepositories"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/mailer"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/ratelimit"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestEmailVerification(t *testing.T, cfg *config.Config) (*emailVerification, *mockrepositories.MockUserRepository, *mailer.Fake) {
+	ctrl := gomock.NewController(t)
+	repo := mockrepositories.NewMockUserRepository(ctrl)
+	fake := mailer.NewFake()
+	cfg.AUTH.JwtSecret = "test-secret"
+	cfg.Mail.Timeout = time.Second
+	cfg.EmailVerification = config.EmailVerification{URL: "https://app.example.com/verify-email?lang=en", TTL: time.Hour}
+	mockLogger := &MockLogger{}
+	mockLogger.On("Warn", mock.Anything, mock.Anything)
+
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), "email:ratelimit:")
+	e := NewEmailVerificationUsecase(repo, fake, limiter, config.NewSettings(cfg), cfg, mockLogger).(*emailVerification)
+	return e, repo, fake
+}
+
+// linkToken extracts the token from the verification link in the email.
+func linkToken(t *testing.T, message mailer.Message) string {
+	for _, field := range strings.Fields(message.Text) {
+		if u, err := url.Parse(field); err == nil && u.Host == "app.example.com" {
+			assert.Equal(t, "en", u.Query().Get("lang"))
+			return u.Query().Get("token")
+		}
+	}
+	t.Fatalf("no verification link in %q", message.Text)
+	return ""
+}
+
+func TestEmailVerification_SendVerification(t *testing.T) {
+	ctx := context.Background()
+	user := entities.User{Id: 7, Phone: "+1234567890", Email: "ada@example.com"}
+
+	t.Run("emails a link and verifies with its token", func(t *testing.T) {
+		e, repo, fake := newTestEmailVerification(t, testOtpConfig())
+		repo.EXPECT().GetUserById(ctx, uint32(7)).Return(user, nil).Times(2)
+
+		require.NoError(t, e.SendVerification(ctx, 7, dto.ClientInfo{IP: "10.0.0.1"}))
+		message, ok := fake.Last("ada@example.com")
+		require.True(t, ok)
+		assert.Equal(t, "Verify your email address", message.Subject)
+		assert.NotContains(t, message.Text, "ada@example.com")
+
+		verified := user
+		verified.EmailVerified = true
+		repo.EXPECT().MarkEmailVerified(ctx, uint32(7), "ada@example.com").Return(verified, nil)
+		got, err := e.Verify(ctx, 7, dto.VerifyEmailDTO{Token: linkToken(t, message)})
+		require.NoError(t, err)
+		assert.True(t, got.EmailVerified)
+	})
+
+	t.Run("needs an unverified address", func(t *testing.T) {
+		e, repo, fake := newTestEmailVerification(t, testOtpConfig())
+		verified := user
+		verified.EmailVerified = true
+		repo.EXPECT().GetUserById(ctx, uint32(1)).Return(entities.User{Id: 1}, nil)
+		repo.EXPECT().GetUserById(ctx, uint32(7)).Return(verified, nil)
+
+		assert.ErrorIs(t, e.SendVerification(ctx, 1, dto.ClientInfo{}), ErrNoEmail)
+		assert.ErrorIs(t, e.SendVerification(ctx, 7, dto.ClientInfo{}), ErrEmailAlreadyVerified)
+		assert.Empty(t, fake.Messages())
+	})
+
+	t.Run("is rate limited like otp requests", func(t *testing.T) {
+		cfg := testOtpConfig()
+		cfg.OTP.ResendInterval = time.Minute
+		e, repo, fake := newTestEmailVerification(t, cfg)
+		repo.EXPECT().GetUserById(ctx, uint32(7)).Return(user, nil).Times(2)
+
+		require.NoError(t, e.SendVerification(ctx, 7, dto.ClientInfo{IP: "10.0.0.1"}))
+		err := e.SendVerification(ctx, 7, dto.ClientInfo{IP: "10.0.0.1"})
+		var exceeded *ratelimit.ExceededError
+		require.ErrorAs(t, err, &exceeded)
+		assert.Equal(t, "resend", exceeded.Rule.Name)
+		assert.Len(t, fake.Messages(), 1)
+	})
+
+	t.Run("reports mailer failures", func(t *testing.T) {
+		e, repo, fake := newTestEmailVerification(t, testOtpConfig())
+		fake.Err = errors.New("relay down")
+		repo.EXPECT().GetUserById(ctx, uint32(7)).Return(user, nil)
+
+		assert.EqualError(t, e.SendVerification(ctx, 7, dto.ClientInfo{}), "relay down")
+	})
+}
+
+func TestEmailVerification_Verify(t *testing.T) {
+	ctx := context.Background()
+	user := entities.User{Id: 7, Phone: "+1234567890", Email: "ada@example.com"}
+
+	t.Run("rejects tampered, foreign and expired tokens", func(t *testing.T) {
+		e, repo, _ := newTestEmailVerification(t, testOtpConfig())
+		token := e.token(7, "ada@example.com", e.now().Add(time.Hour))
+		repo.EXPECT().GetUserById(ctx, uint32(7)).Return(user, nil).AnyTimes()
+
+		for name, token := range map[string]string{
+			"tampered":      strings.Replace(token, "7.", "8.", 1),
+			"garbage":       "not-a-token",
+			"expired":       e.token(7, "ada@example.com", e.now().Add(-time.Second)),
+			"other address": e.token(7, "eve@example.com", e.now().Add(time.Hour)),
+		} {
+			_, err := e.Verify(ctx, 7, dto.VerifyEmailDTO{Token: token})
+			assert.ErrorIs(t, err, ErrInvalidVerificationToken, name)
+		}
+
+		_, err := e.Verify(ctx, 8, dto.VerifyEmailDTO{Token: token})
+		assert.ErrorIs(t, err, ErrInvalidVerificationToken, "other user")
+	})
+
+	t.Run("email changed while verifying", func(t *testing.T) {
+		e, repo, _ := newTestEmailVerification(t, testOtpConfig())
+		repo.EXPECT().GetUserById(ctx, uint32(7)).Return(user, nil)
+		repo.EXPECT().MarkEmailVerified(ctx, uint32(7), "ada@example.com").Return(entities.User{}, pgx.ErrNoRows)
+
+		_, err := e.Verify(ctx, 7, dto.VerifyEmailDTO{Token: e.token(7, "Ada@Example.com", e.now().Add(time.Hour))})
+		assert.ErrorIs(t, err, ErrInvalidVerificationToken)
+	})
+
+	t.Run("verifying twice is a no-op", func(t *testing.T) {
+		e, repo, _ := newTestEmailVerification(t, testOtpConfig())
+		verified := user
+		verified.EmailVerified = true
+		repo.EXPECT().GetUserById(ctx, uint32(7)).Return(verified, nil)
+
+		got, err := e.Verify(ctx, 7, dto.VerifyEmailDTO{Token: e.token(7, "ada@example.com", e.now().Add(time.Hour))})
+		require.NoError(t, err)
+		assert.Equal(t, verified, got)
+	})
+}
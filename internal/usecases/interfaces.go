@@ -2,7 +2,7 @@ package usecases
 
 import (
 	"context"
-	"time"
+	"io"
 
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
@@ -10,26 +10,115 @@ import (
 
 type (
 	AuthService interface {
-		LoginRequestOtp(ctx context.Context, req dto.LoginDTO) (err error)
-		VerifyLoginOTP(ctx context.Context, body dto.VerifyLoginOTP) (jwt string, err error)
+		LoginRequestOtp(ctx context.Context, req dto.LoginDTO) (entities.OtpStatus, error)
+		ResendLoginOtp(ctx context.Context, req dto.ResendOtpDTO) (entities.OtpStatus, error)
+		LoginOtpStatus(ctx context.Context, query dto.OtpStatusQuery) (entities.OtpStatus, error)
+		VerifyLoginOTP(ctx context.Context, body dto.VerifyLoginOTP) (entities.AuthTokens, error)
+		// RefreshToken trades a refresh token for a new pair of tokens.
+		RefreshToken(ctx context.Context, body dto.RefreshTokenDTO) (entities.AuthTokens, error)
+		// Logout signs out the session the refresh token belongs to.
+		Logout(ctx context.Context, body dto.RefreshTokenDTO) error
 		ValidateToken(ctx context.Context, token string) (entities.User, error)
 	}
 
+	// ActivityUsecase keeps the login history: OTP requests, verifications
+	// and token refreshes.
+	ActivityUsecase interface {
+		// Record stores event as a success, or as a failure when err is set.
+		Record(ctx context.Context, event entities.AuthEvent, err error)
+		List(ctx context.Context, userID uint32, req dto.ListAuthEventsDTO) (entities.Page[entities.AuthEvent], error)
+		RunPurge(ctx context.Context)
+	}
+
 	OtpUsecase interface {
-		SendOtpSms(ctx context.Context, phone string, otp string) error
-		GenerateOTP() (string, error)
-		SaveOTP(ctx context.Context, phone string, otp string) error
-		VerifyOTP(ctx context.Context, phone string, otp string) error
+		// SendOTP queues the code for delivery to recipient over challenge.Channel.
+		SendOTP(ctx context.Context, challenge entities.OtpChallenge, recipient string, otp string) error
+		ValidateChannel(channel string) error
+		GenerateOTP(purpose entities.OtpPurpose) (string, error)
+		ValidateFormat(purpose entities.OtpPurpose, otp string) error
+		SaveOTP(ctx context.Context, challenge entities.OtpChallenge, otp string) (challengeID string, err error)
+		// ReissueOTP replaces the code of a pending challenge, e.g. to deliver
+		// it over another channel, and returns the updated challenge.
+		ReissueOTP(ctx context.Context, challenge entities.OtpChallenge, otp string) (entities.OtpChallenge, error)
+		VerifyOTP(ctx context.Context, challenge entities.OtpChallenge, otp string) error
+		// VerifyOTPs consumes every challenge when all codes match and none
+		// otherwise; a wrong code counts against its own challenge only.
+		VerifyOTPs(ctx context.Context, answers ...entities.OtpAnswer) error
+		CheckRateLimit(ctx context.Context, challenges ...entities.OtpChallenge) error
+		GetStatus(ctx context.Context, challenge entities.OtpChallenge) (entities.OtpStatus, error)
+		// Deliveries lists the messages sent for a challenge, including resends,
+		// with their delivery reports.
+		Deliveries(ctx context.Context, challengeID string) ([]entities.OutboxMessage, error)
+	}
+
+	OutboxUsecase interface {
+		Enqueue(ctx context.Context, message entities.OutboxMessage) error
+		Run(ctx context.Context)
+		ListMessages(ctx context.Context, status entities.OutboxStatus, limit uint32) ([]entities.OutboxMessage, error)
+		Stats(ctx context.Context) (entities.OutboxStats, error)
+		Retry(ctx context.Context, id int64) error
+		ListByDedupeKey(ctx context.Context, dedupeKey string) ([]entities.OutboxMessage, error)
+		RecordDeliveryReport(ctx context.Context, provider string, report dto.DeliveryReportDTO) error
+	}
+
+	// MessageSender delivers one outbox message over its channel and reports
+	// which provider accepted it.
+	MessageSender interface {
+		Send(ctx context.Context, message entities.OutboxMessage) (entities.Delivery, error)
 	}
 
 	JwtUsecase interface {
 		GenerateToken(payload entities.JwtPayload) (jwt string, err error)
 		ValidateToken(token string) (entities.JwtPayload, error)
 		GenerateRefreshToken(payload entities.JwtPayload) (refreshToken string, err error)
+		ValidateRefreshToken(token string) (entities.JwtPayload, error)
+	}
+
+	// AuditUsecase keeps the tamper-evident audit log of admin operations
+	// and security-relevant account changes.
+	AuditUsecase interface {
+		// Record appends entry with diff, marshalled to JSON, as its diff. An
+		// error means the entry was not stored.
+		Record(ctx context.Context, entry entities.AuditEntry, diff any) error
+		List(ctx context.Context, req dto.ListAuditDTO) (entities.Page[entities.AuditEntry], error)
+		// Verify checks the hash chain from the first entry to the last.
+		Verify(ctx context.Context) (entities.AuditVerification, error)
+	}
+
+	// UserTransferUsecase moves users in and out in bulk, as CSV or NDJSON.
+	UserTransferUsecase interface {
+		Export(ctx context.Context, w io.Writer, req dto.ExportUsersDTO) error
+		// Import returns the report so far along with an error that stops it.
+		Import(ctx context.Context, r io.Reader, req dto.ImportUsersDTO) (entities.UserImportReport, error)
 	}
 
 	UsersService interface {
 		GetUser(ctx context.Context, id uint32) (entities.User, error)
-		GetAllUsers(ctx context.Context, page, limit uint32, phoneSearchTerm *string, creationFrom, creationTo *time.Time) ([]entities.User, error)
+		UpdateProfile(ctx context.Context, id uint32, req dto.UpdateProfileDTO) (entities.User, error)
+		CheckStatusChange(actor entities.User, userID uint32, req dto.SetUserStatusDTO) error
+		SetStatus(ctx context.Context, actor entities.User, userID uint32, req dto.SetUserStatusDTO) (entities.User, error)
+		StatusHistory(ctx context.Context, userID uint32) ([]entities.UserStatusChange, error)
+		ListUsers(ctx context.Context, req dto.ListUsersDTO) (entities.Page[entities.User], error)
+	}
+
+	// PhoneChangeUsecase moves an account to a new phone number once both the
+	// current and the new number are verified.
+	PhoneChangeUsecase interface {
+		RequestOtp(ctx context.Context, userID uint32, req dto.RequestPhoneChangeDTO) (entities.PhoneChangeStatus, error)
+		Verify(ctx context.Context, userID uint32, req dto.VerifyPhoneChangeDTO) (entities.AuthTokens, error)
+	}
+
+	AccountUsecase interface {
+		RequestDeletionOtp(ctx context.Context, userID uint32, req dto.RequestAccountDeletionDTO) (entities.OtpStatus, error)
+		Delete(ctx context.Context, userID uint32, req dto.DeleteAccountDTO) (entities.User, error)
+		// Export gathers everything stored about the user.
+		Export(ctx context.Context, userID uint32) (entities.UserExport, error)
+		RunPurge(ctx context.Context)
+	}
+
+	EmailVerificationUsecase interface {
+		// SendVerification emails the user a link to verify their address.
+		SendVerification(ctx context.Context, userID uint32, client dto.ClientInfo) error
+		Verify(ctx context.Context, userID uint32, req dto.VerifyEmailDTO) (entities.User, error)
 	}
 )
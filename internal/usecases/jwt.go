@@ -9,6 +9,9 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// refreshTokenTTL is how long a refresh token can be traded for a new pair.
+const refreshTokenTTL = 7 * 24 * time.Hour
+
 type jwtUsecase struct {
 	jwtSecretKey string
 }
@@ -22,6 +25,7 @@ func NewJwtUsecase(jwtSecretKey string) JwtUsecase {
 func (j *jwtUsecase) GenerateToken(payload entities.JwtPayload) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"userId": payload.UserId,
+		"iat":    time.Now().Unix(),
 		"exp":    time.Now().Add(time.Hour * 1).Unix(),
 	})
 	tokenString, err := token.SignedString([]byte(j.jwtSecretKey))
@@ -35,7 +39,7 @@ func (j *jwtUsecase) GenerateToken(payload entities.JwtPayload) (string, error)
 func (j *jwtUsecase) ValidateToken(tokenString string) (entities.JwtPayload, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		return []byte(j.jwtSecretKey), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 	if err != nil {
 		return entities.JwtPayload{}, err
 	}
@@ -46,9 +50,13 @@ func (j *jwtUsecase) ValidateToken(tokenString string) (entities.JwtPayload, err
 		if ok && refresh {
 			return entities.JwtPayload{}, errors.New("invalid token")
 		}
-		return entities.JwtPayload{
+		payload := entities.JwtPayload{
 			UserId: uint32(userId),
-		}, nil
+		}
+		if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+			payload.IssuedAt = iat.Time
+		}
+		return payload, nil
 	} else {
 		return entities.JwtPayload{}, err
 	}
@@ -57,7 +65,9 @@ func (j *jwtUsecase) ValidateToken(tokenString string) (entities.JwtPayload, err
 func (j *jwtUsecase) GenerateRefreshToken(payload entities.JwtPayload) (string, error) {
 	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"userId":  payload.UserId,
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(),
+		"jti":     payload.TokenID,
+		"iat":     time.Now().Unix(),
+		"exp":     time.Now().Add(refreshTokenTTL).Unix(),
 		"refresh": true,
 	})
 	refreshTokenString, err := refreshToken.SignedString([]byte(j.jwtSecretKey))
@@ -67,3 +77,26 @@ func (j *jwtUsecase) GenerateRefreshToken(payload entities.JwtPayload) (string,
 
 	return refreshTokenString, nil
 }
+
+func (j *jwtUsecase) ValidateRefreshToken(tokenString string) (entities.JwtPayload, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(j.jwtSecretKey), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return entities.JwtPayload{}, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return entities.JwtPayload{}, errors.New("invalid token")
+	}
+	userId, _ := claims["userId"].(float64)
+	if refresh, _ := claims["refresh"].(bool); !refresh || userId <= 0 {
+		return entities.JwtPayload{}, errors.New("invalid refresh token")
+	}
+	payload := entities.JwtPayload{UserId: uint32(userId)}
+	payload.TokenID, _ = claims["jti"].(string)
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		payload.IssuedAt = iat.Time
+	}
+	return payload, nil
+}
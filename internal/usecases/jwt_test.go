@@ -80,8 +80,10 @@ func TestJwtUsecase_ValidateToken(t *testing.T) {
 		name        string
 		tokenString string
 		wantPayload entities.JwtPayload
-		wantErr     bool
-		setupToken  func() string
+		// wantIssuedAt expects the iat claim to be about now
+		wantIssuedAt bool
+		wantErr      bool
+		setupToken   func() string
 	}{
 		{
 			name: "valid token",
@@ -89,6 +91,20 @@ func TestJwtUsecase_ValidateToken(t *testing.T) {
 				token, _ := j.GenerateToken(entities.JwtPayload{UserId: 123})
 				return token
 			},
+			wantPayload:  entities.JwtPayload{UserId: 123},
+			wantIssuedAt: true,
+			wantErr:      false,
+		},
+		{
+			name: "token without iat",
+			setupToken: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+					"userId": float64(123),
+					"exp":    time.Now().Add(time.Hour).Unix(),
+				})
+				tokenString, _ := token.SignedString([]byte(secretKey))
+				return tokenString
+			},
 			wantPayload: entities.JwtPayload{UserId: 123},
 			wantErr:     false,
 		},
@@ -128,6 +144,18 @@ func TestJwtUsecase_ValidateToken(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "token signed with another HMAC algorithm",
+			setupToken: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
+					"userId": float64(123),
+					"exp":    time.Now().Add(time.Hour).Unix(),
+				})
+				tokenString, _ := token.SignedString([]byte(secretKey))
+				return tokenString
+			},
+			wantErr: true,
+		},
 		{
 			name: "token with wrong secret",
 			setupToken: func() string {
@@ -153,7 +181,12 @@ func TestJwtUsecase_ValidateToken(t *testing.T) {
 				assert.Zero(t, payload)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, tt.wantPayload, payload)
+				assert.Equal(t, tt.wantPayload.UserId, payload.UserId)
+				if tt.wantIssuedAt {
+					assert.WithinDuration(t, time.Now(), payload.IssuedAt, time.Minute)
+				} else {
+					assert.Zero(t, payload.IssuedAt)
+				}
 			}
 		})
 	}
@@ -231,7 +264,8 @@ func TestJwtUsecase_Integration(t *testing.T) {
 	// Validate token
 	validatedPayload, err := j.ValidateToken(token)
 	require.NoError(t, err)
-	assert.Equal(t, payload, validatedPayload)
+	assert.Equal(t, payload.UserId, validatedPayload.UserId)
+	assert.WithinDuration(t, time.Now(), validatedPayload.IssuedAt, time.Minute)
 
 	// Generate refresh token
 	refreshToken, err := j.GenerateRefreshToken(payload)
@@ -246,3 +280,31 @@ func TestJwtUsecase_Integration(t *testing.T) {
 	// Normal token and refresh token should be different
 	assert.NotEqual(t, token, refreshToken)
 }
+
+func TestJwtUsecase_ValidateRefreshToken(t *testing.T) {
+	j := NewJwtUsecase("test-secret-key")
+
+	refreshToken, err := j.GenerateRefreshToken(entities.JwtPayload{UserId: 123, TokenID: "token-1"})
+	require.NoError(t, err)
+	payload, err := j.ValidateRefreshToken(refreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(123), payload.UserId)
+	assert.Equal(t, "token-1", payload.TokenID)
+	assert.WithinDuration(t, time.Now(), payload.IssuedAt, time.Minute)
+
+	accessToken, err := j.GenerateToken(entities.JwtPayload{UserId: 123})
+	require.NoError(t, err)
+	_, err = j.ValidateRefreshToken(accessToken)
+	assert.EqualError(t, err, "invalid refresh token")
+
+	otherToken, err := NewJwtUsecase("other-secret").GenerateRefreshToken(entities.JwtPayload{UserId: 123})
+	require.NoError(t, err)
+	_, err = j.ValidateRefreshToken(otherToken)
+	assert.Error(t, err)
+
+	none := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"userId": 123, "refresh": true})
+	unsigned, err := none.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	_, err = j.ValidateRefreshToken(unsigned)
+	assert.Error(t, err)
+}
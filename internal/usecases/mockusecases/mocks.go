@@ -11,8 +11,8 @@ package mockusecases
 
 import (
 	context "context"
+	io "io"
 	reflect "reflect"
-	time "time"
 
 	dto "github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
 	entities "github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
@@ -43,12 +43,28 @@ func (m *MockAuthService) EXPECT() *MockAuthServiceMockRecorder {
 	return m.recorder
 }
 
+// LoginOtpStatus mocks base method.
+func (m *MockAuthService) LoginOtpStatus(ctx context.Context, query dto.OtpStatusQuery) (entities.OtpStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoginOtpStatus", ctx, query)
+	ret0, _ := ret[0].(entities.OtpStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoginOtpStatus indicates an expected call of LoginOtpStatus.
+func (mr *MockAuthServiceMockRecorder) LoginOtpStatus(ctx, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoginOtpStatus", reflect.TypeOf((*MockAuthService)(nil).LoginOtpStatus), ctx, query)
+}
+
 // LoginRequestOtp mocks base method.
-func (m *MockAuthService) LoginRequestOtp(ctx context.Context, req dto.LoginDTO) error {
+func (m *MockAuthService) LoginRequestOtp(ctx context.Context, req dto.LoginDTO) (entities.OtpStatus, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "LoginRequestOtp", ctx, req)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(entities.OtpStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // LoginRequestOtp indicates an expected call of LoginRequestOtp.
@@ -57,6 +73,50 @@ func (mr *MockAuthServiceMockRecorder) LoginRequestOtp(ctx, req any) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoginRequestOtp", reflect.TypeOf((*MockAuthService)(nil).LoginRequestOtp), ctx, req)
 }
 
+// Logout mocks base method.
+func (m *MockAuthService) Logout(ctx context.Context, body dto.RefreshTokenDTO) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Logout", ctx, body)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Logout indicates an expected call of Logout.
+func (mr *MockAuthServiceMockRecorder) Logout(ctx, body any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logout", reflect.TypeOf((*MockAuthService)(nil).Logout), ctx, body)
+}
+
+// RefreshToken mocks base method.
+func (m *MockAuthService) RefreshToken(ctx context.Context, body dto.RefreshTokenDTO) (entities.AuthTokens, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshToken", ctx, body)
+	ret0, _ := ret[0].(entities.AuthTokens)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshToken indicates an expected call of RefreshToken.
+func (mr *MockAuthServiceMockRecorder) RefreshToken(ctx, body any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshToken", reflect.TypeOf((*MockAuthService)(nil).RefreshToken), ctx, body)
+}
+
+// ResendLoginOtp mocks base method.
+func (m *MockAuthService) ResendLoginOtp(ctx context.Context, req dto.ResendOtpDTO) (entities.OtpStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResendLoginOtp", ctx, req)
+	ret0, _ := ret[0].(entities.OtpStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResendLoginOtp indicates an expected call of ResendLoginOtp.
+func (mr *MockAuthServiceMockRecorder) ResendLoginOtp(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResendLoginOtp", reflect.TypeOf((*MockAuthService)(nil).ResendLoginOtp), ctx, req)
+}
+
 // ValidateToken mocks base method.
 func (m *MockAuthService) ValidateToken(ctx context.Context, token string) (entities.User, error) {
 	m.ctrl.T.Helper()
@@ -73,10 +133,10 @@ func (mr *MockAuthServiceMockRecorder) ValidateToken(ctx, token any) *gomock.Cal
 }
 
 // VerifyLoginOTP mocks base method.
-func (m *MockAuthService) VerifyLoginOTP(ctx context.Context, body dto.VerifyLoginOTP) (string, error) {
+func (m *MockAuthService) VerifyLoginOTP(ctx context.Context, body dto.VerifyLoginOTP) (entities.AuthTokens, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "VerifyLoginOTP", ctx, body)
-	ret0, _ := ret[0].(string)
+	ret0, _ := ret[0].(entities.AuthTokens)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -87,6 +147,69 @@ func (mr *MockAuthServiceMockRecorder) VerifyLoginOTP(ctx, body any) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyLoginOTP", reflect.TypeOf((*MockAuthService)(nil).VerifyLoginOTP), ctx, body)
 }
 
+// MockActivityUsecase is a mock of ActivityUsecase interface.
+type MockActivityUsecase struct {
+	ctrl     *gomock.Controller
+	recorder *MockActivityUsecaseMockRecorder
+	isgomock struct{}
+}
+
+// MockActivityUsecaseMockRecorder is the mock recorder for MockActivityUsecase.
+type MockActivityUsecaseMockRecorder struct {
+	mock *MockActivityUsecase
+}
+
+// NewMockActivityUsecase creates a new mock instance.
+func NewMockActivityUsecase(ctrl *gomock.Controller) *MockActivityUsecase {
+	mock := &MockActivityUsecase{ctrl: ctrl}
+	mock.recorder = &MockActivityUsecaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockActivityUsecase) EXPECT() *MockActivityUsecaseMockRecorder {
+	return m.recorder
+}
+
+// List mocks base method.
+func (m *MockActivityUsecase) List(ctx context.Context, userID uint32, req dto.ListAuthEventsDTO) (entities.Page[entities.AuthEvent], error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, userID, req)
+	ret0, _ := ret[0].(entities.Page[entities.AuthEvent])
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockActivityUsecaseMockRecorder) List(ctx, userID, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockActivityUsecase)(nil).List), ctx, userID, req)
+}
+
+// Record mocks base method.
+func (m *MockActivityUsecase) Record(ctx context.Context, event entities.AuthEvent, err error) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Record", ctx, event, err)
+}
+
+// Record indicates an expected call of Record.
+func (mr *MockActivityUsecaseMockRecorder) Record(ctx, event, err any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Record", reflect.TypeOf((*MockActivityUsecase)(nil).Record), ctx, event, err)
+}
+
+// RunPurge mocks base method.
+func (m *MockActivityUsecase) RunPurge(ctx context.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RunPurge", ctx)
+}
+
+// RunPurge indicates an expected call of RunPurge.
+func (mr *MockActivityUsecaseMockRecorder) RunPurge(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunPurge", reflect.TypeOf((*MockActivityUsecase)(nil).RunPurge), ctx)
+}
+
 // MockOtpUsecase is a mock of OtpUsecase interface.
 type MockOtpUsecase struct {
 	ctrl     *gomock.Controller
@@ -111,61 +234,335 @@ func (m *MockOtpUsecase) EXPECT() *MockOtpUsecaseMockRecorder {
 	return m.recorder
 }
 
+// CheckRateLimit mocks base method.
+func (m *MockOtpUsecase) CheckRateLimit(ctx context.Context, challenges ...entities.OtpChallenge) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx}
+	for _, a := range challenges {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "CheckRateLimit", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckRateLimit indicates an expected call of CheckRateLimit.
+func (mr *MockOtpUsecaseMockRecorder) CheckRateLimit(ctx any, challenges ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx}, challenges...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckRateLimit", reflect.TypeOf((*MockOtpUsecase)(nil).CheckRateLimit), varargs...)
+}
+
+// Deliveries mocks base method.
+func (m *MockOtpUsecase) Deliveries(ctx context.Context, challengeID string) ([]entities.OutboxMessage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Deliveries", ctx, challengeID)
+	ret0, _ := ret[0].([]entities.OutboxMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Deliveries indicates an expected call of Deliveries.
+func (mr *MockOtpUsecaseMockRecorder) Deliveries(ctx, challengeID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deliveries", reflect.TypeOf((*MockOtpUsecase)(nil).Deliveries), ctx, challengeID)
+}
+
 // GenerateOTP mocks base method.
-func (m *MockOtpUsecase) GenerateOTP() (string, error) {
+func (m *MockOtpUsecase) GenerateOTP(purpose entities.OtpPurpose) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GenerateOTP")
+	ret := m.ctrl.Call(m, "GenerateOTP", purpose)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GenerateOTP indicates an expected call of GenerateOTP.
-func (mr *MockOtpUsecaseMockRecorder) GenerateOTP() *gomock.Call {
+func (mr *MockOtpUsecaseMockRecorder) GenerateOTP(purpose any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateOTP", reflect.TypeOf((*MockOtpUsecase)(nil).GenerateOTP), purpose)
+}
+
+// GetStatus mocks base method.
+func (m *MockOtpUsecase) GetStatus(ctx context.Context, challenge entities.OtpChallenge) (entities.OtpStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatus", ctx, challenge)
+	ret0, _ := ret[0].(entities.OtpStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStatus indicates an expected call of GetStatus.
+func (mr *MockOtpUsecaseMockRecorder) GetStatus(ctx, challenge any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateOTP", reflect.TypeOf((*MockOtpUsecase)(nil).GenerateOTP))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatus", reflect.TypeOf((*MockOtpUsecase)(nil).GetStatus), ctx, challenge)
+}
+
+// ReissueOTP mocks base method.
+func (m *MockOtpUsecase) ReissueOTP(ctx context.Context, challenge entities.OtpChallenge, otp string) (entities.OtpChallenge, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReissueOTP", ctx, challenge, otp)
+	ret0, _ := ret[0].(entities.OtpChallenge)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReissueOTP indicates an expected call of ReissueOTP.
+func (mr *MockOtpUsecaseMockRecorder) ReissueOTP(ctx, challenge, otp any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReissueOTP", reflect.TypeOf((*MockOtpUsecase)(nil).ReissueOTP), ctx, challenge, otp)
 }
 
 // SaveOTP mocks base method.
-func (m *MockOtpUsecase) SaveOTP(ctx context.Context, phone, otp string) error {
+func (m *MockOtpUsecase) SaveOTP(ctx context.Context, challenge entities.OtpChallenge, otp string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SaveOTP", ctx, phone, otp)
+	ret := m.ctrl.Call(m, "SaveOTP", ctx, challenge, otp)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveOTP indicates an expected call of SaveOTP.
+func (mr *MockOtpUsecaseMockRecorder) SaveOTP(ctx, challenge, otp any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOTP", reflect.TypeOf((*MockOtpUsecase)(nil).SaveOTP), ctx, challenge, otp)
+}
+
+// SendOTP mocks base method.
+func (m *MockOtpUsecase) SendOTP(ctx context.Context, challenge entities.OtpChallenge, recipient, otp string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendOTP", ctx, challenge, recipient, otp)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// SaveOTP indicates an expected call of SaveOTP.
-func (mr *MockOtpUsecaseMockRecorder) SaveOTP(ctx, phone, otp any) *gomock.Call {
+// SendOTP indicates an expected call of SendOTP.
+func (mr *MockOtpUsecaseMockRecorder) SendOTP(ctx, challenge, recipient, otp any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendOTP", reflect.TypeOf((*MockOtpUsecase)(nil).SendOTP), ctx, challenge, recipient, otp)
+}
+
+// ValidateChannel mocks base method.
+func (m *MockOtpUsecase) ValidateChannel(channel string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ValidateChannel", channel)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ValidateChannel indicates an expected call of ValidateChannel.
+func (mr *MockOtpUsecaseMockRecorder) ValidateChannel(channel any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOTP", reflect.TypeOf((*MockOtpUsecase)(nil).SaveOTP), ctx, phone, otp)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateChannel", reflect.TypeOf((*MockOtpUsecase)(nil).ValidateChannel), channel)
 }
 
-// SendOtpSms mocks base method.
-func (m *MockOtpUsecase) SendOtpSms(ctx context.Context, phone, otp string) error {
+// ValidateFormat mocks base method.
+func (m *MockOtpUsecase) ValidateFormat(purpose entities.OtpPurpose, otp string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SendOtpSms", ctx, phone, otp)
+	ret := m.ctrl.Call(m, "ValidateFormat", purpose, otp)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// SendOtpSms indicates an expected call of SendOtpSms.
-func (mr *MockOtpUsecaseMockRecorder) SendOtpSms(ctx, phone, otp any) *gomock.Call {
+// ValidateFormat indicates an expected call of ValidateFormat.
+func (mr *MockOtpUsecaseMockRecorder) ValidateFormat(purpose, otp any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendOtpSms", reflect.TypeOf((*MockOtpUsecase)(nil).SendOtpSms), ctx, phone, otp)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateFormat", reflect.TypeOf((*MockOtpUsecase)(nil).ValidateFormat), purpose, otp)
 }
 
 // VerifyOTP mocks base method.
-func (m *MockOtpUsecase) VerifyOTP(ctx context.Context, phone, otp string) error {
+func (m *MockOtpUsecase) VerifyOTP(ctx context.Context, challenge entities.OtpChallenge, otp string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "VerifyOTP", ctx, phone, otp)
+	ret := m.ctrl.Call(m, "VerifyOTP", ctx, challenge, otp)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // VerifyOTP indicates an expected call of VerifyOTP.
-func (mr *MockOtpUsecaseMockRecorder) VerifyOTP(ctx, phone, otp any) *gomock.Call {
+func (mr *MockOtpUsecaseMockRecorder) VerifyOTP(ctx, challenge, otp any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyOTP", reflect.TypeOf((*MockOtpUsecase)(nil).VerifyOTP), ctx, challenge, otp)
+}
+
+// VerifyOTPs mocks base method.
+func (m *MockOtpUsecase) VerifyOTPs(ctx context.Context, answers ...entities.OtpAnswer) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx}
+	for _, a := range answers {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "VerifyOTPs", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyOTPs indicates an expected call of VerifyOTPs.
+func (mr *MockOtpUsecaseMockRecorder) VerifyOTPs(ctx any, answers ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx}, answers...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyOTPs", reflect.TypeOf((*MockOtpUsecase)(nil).VerifyOTPs), varargs...)
+}
+
+// MockOutboxUsecase is a mock of OutboxUsecase interface.
+type MockOutboxUsecase struct {
+	ctrl     *gomock.Controller
+	recorder *MockOutboxUsecaseMockRecorder
+	isgomock struct{}
+}
+
+// MockOutboxUsecaseMockRecorder is the mock recorder for MockOutboxUsecase.
+type MockOutboxUsecaseMockRecorder struct {
+	mock *MockOutboxUsecase
+}
+
+// NewMockOutboxUsecase creates a new mock instance.
+func NewMockOutboxUsecase(ctrl *gomock.Controller) *MockOutboxUsecase {
+	mock := &MockOutboxUsecase{ctrl: ctrl}
+	mock.recorder = &MockOutboxUsecaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOutboxUsecase) EXPECT() *MockOutboxUsecaseMockRecorder {
+	return m.recorder
+}
+
+// Enqueue mocks base method.
+func (m *MockOutboxUsecase) Enqueue(ctx context.Context, message entities.OutboxMessage) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enqueue", ctx, message)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Enqueue indicates an expected call of Enqueue.
+func (mr *MockOutboxUsecaseMockRecorder) Enqueue(ctx, message any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enqueue", reflect.TypeOf((*MockOutboxUsecase)(nil).Enqueue), ctx, message)
+}
+
+// ListByDedupeKey mocks base method.
+func (m *MockOutboxUsecase) ListByDedupeKey(ctx context.Context, dedupeKey string) ([]entities.OutboxMessage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListByDedupeKey", ctx, dedupeKey)
+	ret0, _ := ret[0].([]entities.OutboxMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListByDedupeKey indicates an expected call of ListByDedupeKey.
+func (mr *MockOutboxUsecaseMockRecorder) ListByDedupeKey(ctx, dedupeKey any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListByDedupeKey", reflect.TypeOf((*MockOutboxUsecase)(nil).ListByDedupeKey), ctx, dedupeKey)
+}
+
+// ListMessages mocks base method.
+func (m *MockOutboxUsecase) ListMessages(ctx context.Context, status entities.OutboxStatus, limit uint32) ([]entities.OutboxMessage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMessages", ctx, status, limit)
+	ret0, _ := ret[0].([]entities.OutboxMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListMessages indicates an expected call of ListMessages.
+func (mr *MockOutboxUsecaseMockRecorder) ListMessages(ctx, status, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMessages", reflect.TypeOf((*MockOutboxUsecase)(nil).ListMessages), ctx, status, limit)
+}
+
+// RecordDeliveryReport mocks base method.
+func (m *MockOutboxUsecase) RecordDeliveryReport(ctx context.Context, provider string, report dto.DeliveryReportDTO) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordDeliveryReport", ctx, provider, report)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordDeliveryReport indicates an expected call of RecordDeliveryReport.
+func (mr *MockOutboxUsecaseMockRecorder) RecordDeliveryReport(ctx, provider, report any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordDeliveryReport", reflect.TypeOf((*MockOutboxUsecase)(nil).RecordDeliveryReport), ctx, provider, report)
+}
+
+// Retry mocks base method.
+func (m *MockOutboxUsecase) Retry(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Retry", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Retry indicates an expected call of Retry.
+func (mr *MockOutboxUsecaseMockRecorder) Retry(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Retry", reflect.TypeOf((*MockOutboxUsecase)(nil).Retry), ctx, id)
+}
+
+// Run mocks base method.
+func (m *MockOutboxUsecase) Run(ctx context.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Run", ctx)
+}
+
+// Run indicates an expected call of Run.
+func (mr *MockOutboxUsecaseMockRecorder) Run(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Run", reflect.TypeOf((*MockOutboxUsecase)(nil).Run), ctx)
+}
+
+// Stats mocks base method.
+func (m *MockOutboxUsecase) Stats(ctx context.Context) (entities.OutboxStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats", ctx)
+	ret0, _ := ret[0].(entities.OutboxStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *MockOutboxUsecaseMockRecorder) Stats(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockOutboxUsecase)(nil).Stats), ctx)
+}
+
+// MockMessageSender is a mock of MessageSender interface.
+type MockMessageSender struct {
+	ctrl     *gomock.Controller
+	recorder *MockMessageSenderMockRecorder
+	isgomock struct{}
+}
+
+// MockMessageSenderMockRecorder is the mock recorder for MockMessageSender.
+type MockMessageSenderMockRecorder struct {
+	mock *MockMessageSender
+}
+
+// NewMockMessageSender creates a new mock instance.
+func NewMockMessageSender(ctrl *gomock.Controller) *MockMessageSender {
+	mock := &MockMessageSender{ctrl: ctrl}
+	mock.recorder = &MockMessageSenderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMessageSender) EXPECT() *MockMessageSenderMockRecorder {
+	return m.recorder
+}
+
+// Send mocks base method.
+func (m *MockMessageSender) Send(ctx context.Context, message entities.OutboxMessage) (entities.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Send", ctx, message)
+	ret0, _ := ret[0].(entities.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Send indicates an expected call of Send.
+func (mr *MockMessageSenderMockRecorder) Send(ctx, message any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyOTP", reflect.TypeOf((*MockOtpUsecase)(nil).VerifyOTP), ctx, phone, otp)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockMessageSender)(nil).Send), ctx, message)
 }
 
 // MockJwtUsecase is a mock of JwtUsecase interface.
@@ -222,6 +619,21 @@ func (mr *MockJwtUsecaseMockRecorder) GenerateToken(payload any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateToken", reflect.TypeOf((*MockJwtUsecase)(nil).GenerateToken), payload)
 }
 
+// ValidateRefreshToken mocks base method.
+func (m *MockJwtUsecase) ValidateRefreshToken(token string) (entities.JwtPayload, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ValidateRefreshToken", token)
+	ret0, _ := ret[0].(entities.JwtPayload)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ValidateRefreshToken indicates an expected call of ValidateRefreshToken.
+func (mr *MockJwtUsecaseMockRecorder) ValidateRefreshToken(token any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateRefreshToken", reflect.TypeOf((*MockJwtUsecase)(nil).ValidateRefreshToken), token)
+}
+
 // ValidateToken mocks base method.
 func (m *MockJwtUsecase) ValidateToken(token string) (entities.JwtPayload, error) {
 	m.ctrl.T.Helper()
@@ -237,6 +649,127 @@ func (mr *MockJwtUsecaseMockRecorder) ValidateToken(token any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateToken", reflect.TypeOf((*MockJwtUsecase)(nil).ValidateToken), token)
 }
 
+// MockAuditUsecase is a mock of AuditUsecase interface.
+type MockAuditUsecase struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditUsecaseMockRecorder
+	isgomock struct{}
+}
+
+// MockAuditUsecaseMockRecorder is the mock recorder for MockAuditUsecase.
+type MockAuditUsecaseMockRecorder struct {
+	mock *MockAuditUsecase
+}
+
+// NewMockAuditUsecase creates a new mock instance.
+func NewMockAuditUsecase(ctrl *gomock.Controller) *MockAuditUsecase {
+	mock := &MockAuditUsecase{ctrl: ctrl}
+	mock.recorder = &MockAuditUsecaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuditUsecase) EXPECT() *MockAuditUsecaseMockRecorder {
+	return m.recorder
+}
+
+// List mocks base method.
+func (m *MockAuditUsecase) List(ctx context.Context, req dto.ListAuditDTO) (entities.Page[entities.AuditEntry], error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, req)
+	ret0, _ := ret[0].(entities.Page[entities.AuditEntry])
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockAuditUsecaseMockRecorder) List(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockAuditUsecase)(nil).List), ctx, req)
+}
+
+// Record mocks base method.
+func (m *MockAuditUsecase) Record(ctx context.Context, entry entities.AuditEntry, diff any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Record", ctx, entry, diff)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Record indicates an expected call of Record.
+func (mr *MockAuditUsecaseMockRecorder) Record(ctx, entry, diff any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Record", reflect.TypeOf((*MockAuditUsecase)(nil).Record), ctx, entry, diff)
+}
+
+// Verify mocks base method.
+func (m *MockAuditUsecase) Verify(ctx context.Context) (entities.AuditVerification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", ctx)
+	ret0, _ := ret[0].(entities.AuditVerification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Verify indicates an expected call of Verify.
+func (mr *MockAuditUsecaseMockRecorder) Verify(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockAuditUsecase)(nil).Verify), ctx)
+}
+
+// MockUserTransferUsecase is a mock of UserTransferUsecase interface.
+type MockUserTransferUsecase struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserTransferUsecaseMockRecorder
+	isgomock struct{}
+}
+
+// MockUserTransferUsecaseMockRecorder is the mock recorder for MockUserTransferUsecase.
+type MockUserTransferUsecaseMockRecorder struct {
+	mock *MockUserTransferUsecase
+}
+
+// NewMockUserTransferUsecase creates a new mock instance.
+func NewMockUserTransferUsecase(ctrl *gomock.Controller) *MockUserTransferUsecase {
+	mock := &MockUserTransferUsecase{ctrl: ctrl}
+	mock.recorder = &MockUserTransferUsecaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUserTransferUsecase) EXPECT() *MockUserTransferUsecaseMockRecorder {
+	return m.recorder
+}
+
+// Export mocks base method.
+func (m *MockUserTransferUsecase) Export(ctx context.Context, w io.Writer, req dto.ExportUsersDTO) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export", ctx, w, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Export indicates an expected call of Export.
+func (mr *MockUserTransferUsecaseMockRecorder) Export(ctx, w, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockUserTransferUsecase)(nil).Export), ctx, w, req)
+}
+
+// Import mocks base method.
+func (m *MockUserTransferUsecase) Import(ctx context.Context, r io.Reader, req dto.ImportUsersDTO) (entities.UserImportReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Import", ctx, r, req)
+	ret0, _ := ret[0].(entities.UserImportReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Import indicates an expected call of Import.
+func (mr *MockUserTransferUsecaseMockRecorder) Import(ctx, r, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Import", reflect.TypeOf((*MockUserTransferUsecase)(nil).Import), ctx, r, req)
+}
+
 // MockUsersService is a mock of UsersService interface.
 type MockUsersService struct {
 	ctrl     *gomock.Controller
@@ -261,19 +794,18 @@ func (m *MockUsersService) EXPECT() *MockUsersServiceMockRecorder {
 	return m.recorder
 }
 
-// GetAllUsers mocks base method.
-func (m *MockUsersService) GetAllUsers(ctx context.Context, page, limit uint32, phoneSearchTerm *string, creationFrom, creationTo *time.Time) ([]entities.User, error) {
+// CheckStatusChange mocks base method.
+func (m *MockUsersService) CheckStatusChange(actor entities.User, userID uint32, req dto.SetUserStatusDTO) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllUsers", ctx, page, limit, phoneSearchTerm, creationFrom, creationTo)
-	ret0, _ := ret[0].([]entities.User)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "CheckStatusChange", actor, userID, req)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetAllUsers indicates an expected call of GetAllUsers.
-func (mr *MockUsersServiceMockRecorder) GetAllUsers(ctx, page, limit, phoneSearchTerm, creationFrom, creationTo any) *gomock.Call {
+// CheckStatusChange indicates an expected call of CheckStatusChange.
+func (mr *MockUsersServiceMockRecorder) CheckStatusChange(actor, userID, req any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllUsers", reflect.TypeOf((*MockUsersService)(nil).GetAllUsers), ctx, page, limit, phoneSearchTerm, creationFrom, creationTo)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckStatusChange", reflect.TypeOf((*MockUsersService)(nil).CheckStatusChange), actor, userID, req)
 }
 
 // GetUser mocks base method.
@@ -290,3 +822,251 @@ func (mr *MockUsersServiceMockRecorder) GetUser(ctx, id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockUsersService)(nil).GetUser), ctx, id)
 }
+
+// ListUsers mocks base method.
+func (m *MockUsersService) ListUsers(ctx context.Context, req dto.ListUsersDTO) (entities.Page[entities.User], error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsers", ctx, req)
+	ret0, _ := ret[0].(entities.Page[entities.User])
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsers indicates an expected call of ListUsers.
+func (mr *MockUsersServiceMockRecorder) ListUsers(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsers", reflect.TypeOf((*MockUsersService)(nil).ListUsers), ctx, req)
+}
+
+// SetStatus mocks base method.
+func (m *MockUsersService) SetStatus(ctx context.Context, actor entities.User, userID uint32, req dto.SetUserStatusDTO) (entities.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetStatus", ctx, actor, userID, req)
+	ret0, _ := ret[0].(entities.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetStatus indicates an expected call of SetStatus.
+func (mr *MockUsersServiceMockRecorder) SetStatus(ctx, actor, userID, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetStatus", reflect.TypeOf((*MockUsersService)(nil).SetStatus), ctx, actor, userID, req)
+}
+
+// StatusHistory mocks base method.
+func (m *MockUsersService) StatusHistory(ctx context.Context, userID uint32) ([]entities.UserStatusChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StatusHistory", ctx, userID)
+	ret0, _ := ret[0].([]entities.UserStatusChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StatusHistory indicates an expected call of StatusHistory.
+func (mr *MockUsersServiceMockRecorder) StatusHistory(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StatusHistory", reflect.TypeOf((*MockUsersService)(nil).StatusHistory), ctx, userID)
+}
+
+// UpdateProfile mocks base method.
+func (m *MockUsersService) UpdateProfile(ctx context.Context, id uint32, req dto.UpdateProfileDTO) (entities.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateProfile", ctx, id, req)
+	ret0, _ := ret[0].(entities.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateProfile indicates an expected call of UpdateProfile.
+func (mr *MockUsersServiceMockRecorder) UpdateProfile(ctx, id, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProfile", reflect.TypeOf((*MockUsersService)(nil).UpdateProfile), ctx, id, req)
+}
+
+// MockPhoneChangeUsecase is a mock of PhoneChangeUsecase interface.
+type MockPhoneChangeUsecase struct {
+	ctrl     *gomock.Controller
+	recorder *MockPhoneChangeUsecaseMockRecorder
+	isgomock struct{}
+}
+
+// MockPhoneChangeUsecaseMockRecorder is the mock recorder for MockPhoneChangeUsecase.
+type MockPhoneChangeUsecaseMockRecorder struct {
+	mock *MockPhoneChangeUsecase
+}
+
+// NewMockPhoneChangeUsecase creates a new mock instance.
+func NewMockPhoneChangeUsecase(ctrl *gomock.Controller) *MockPhoneChangeUsecase {
+	mock := &MockPhoneChangeUsecase{ctrl: ctrl}
+	mock.recorder = &MockPhoneChangeUsecaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPhoneChangeUsecase) EXPECT() *MockPhoneChangeUsecaseMockRecorder {
+	return m.recorder
+}
+
+// RequestOtp mocks base method.
+func (m *MockPhoneChangeUsecase) RequestOtp(ctx context.Context, userID uint32, req dto.RequestPhoneChangeDTO) (entities.PhoneChangeStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestOtp", ctx, userID, req)
+	ret0, _ := ret[0].(entities.PhoneChangeStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RequestOtp indicates an expected call of RequestOtp.
+func (mr *MockPhoneChangeUsecaseMockRecorder) RequestOtp(ctx, userID, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestOtp", reflect.TypeOf((*MockPhoneChangeUsecase)(nil).RequestOtp), ctx, userID, req)
+}
+
+// Verify mocks base method.
+func (m *MockPhoneChangeUsecase) Verify(ctx context.Context, userID uint32, req dto.VerifyPhoneChangeDTO) (entities.AuthTokens, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", ctx, userID, req)
+	ret0, _ := ret[0].(entities.AuthTokens)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Verify indicates an expected call of Verify.
+func (mr *MockPhoneChangeUsecaseMockRecorder) Verify(ctx, userID, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockPhoneChangeUsecase)(nil).Verify), ctx, userID, req)
+}
+
+// MockAccountUsecase is a mock of AccountUsecase interface.
+type MockAccountUsecase struct {
+	ctrl     *gomock.Controller
+	recorder *MockAccountUsecaseMockRecorder
+	isgomock struct{}
+}
+
+// MockAccountUsecaseMockRecorder is the mock recorder for MockAccountUsecase.
+type MockAccountUsecaseMockRecorder struct {
+	mock *MockAccountUsecase
+}
+
+// NewMockAccountUsecase creates a new mock instance.
+func NewMockAccountUsecase(ctrl *gomock.Controller) *MockAccountUsecase {
+	mock := &MockAccountUsecase{ctrl: ctrl}
+	mock.recorder = &MockAccountUsecaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAccountUsecase) EXPECT() *MockAccountUsecaseMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method.
+func (m *MockAccountUsecase) Delete(ctx context.Context, userID uint32, req dto.DeleteAccountDTO) (entities.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, userID, req)
+	ret0, _ := ret[0].(entities.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockAccountUsecaseMockRecorder) Delete(ctx, userID, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockAccountUsecase)(nil).Delete), ctx, userID, req)
+}
+
+// Export mocks base method.
+func (m *MockAccountUsecase) Export(ctx context.Context, userID uint32) (entities.UserExport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export", ctx, userID)
+	ret0, _ := ret[0].(entities.UserExport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Export indicates an expected call of Export.
+func (mr *MockAccountUsecaseMockRecorder) Export(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockAccountUsecase)(nil).Export), ctx, userID)
+}
+
+// RequestDeletionOtp mocks base method.
+func (m *MockAccountUsecase) RequestDeletionOtp(ctx context.Context, userID uint32, req dto.RequestAccountDeletionDTO) (entities.OtpStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestDeletionOtp", ctx, userID, req)
+	ret0, _ := ret[0].(entities.OtpStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RequestDeletionOtp indicates an expected call of RequestDeletionOtp.
+func (mr *MockAccountUsecaseMockRecorder) RequestDeletionOtp(ctx, userID, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestDeletionOtp", reflect.TypeOf((*MockAccountUsecase)(nil).RequestDeletionOtp), ctx, userID, req)
+}
+
+// RunPurge mocks base method.
+func (m *MockAccountUsecase) RunPurge(ctx context.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RunPurge", ctx)
+}
+
+// RunPurge indicates an expected call of RunPurge.
+func (mr *MockAccountUsecaseMockRecorder) RunPurge(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunPurge", reflect.TypeOf((*MockAccountUsecase)(nil).RunPurge), ctx)
+}
+
+// MockEmailVerificationUsecase is a mock of EmailVerificationUsecase interface.
+type MockEmailVerificationUsecase struct {
+	ctrl     *gomock.Controller
+	recorder *MockEmailVerificationUsecaseMockRecorder
+	isgomock struct{}
+}
+
+// MockEmailVerificationUsecaseMockRecorder is the mock recorder for MockEmailVerificationUsecase.
+type MockEmailVerificationUsecaseMockRecorder struct {
+	mock *MockEmailVerificationUsecase
+}
+
+// NewMockEmailVerificationUsecase creates a new mock instance.
+func NewMockEmailVerificationUsecase(ctrl *gomock.Controller) *MockEmailVerificationUsecase {
+	mock := &MockEmailVerificationUsecase{ctrl: ctrl}
+	mock.recorder = &MockEmailVerificationUsecaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEmailVerificationUsecase) EXPECT() *MockEmailVerificationUsecaseMockRecorder {
+	return m.recorder
+}
+
+// SendVerification mocks base method.
+func (m *MockEmailVerificationUsecase) SendVerification(ctx context.Context, userID uint32, client dto.ClientInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendVerification", ctx, userID, client)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendVerification indicates an expected call of SendVerification.
+func (mr *MockEmailVerificationUsecaseMockRecorder) SendVerification(ctx, userID, client any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendVerification", reflect.TypeOf((*MockEmailVerificationUsecase)(nil).SendVerification), ctx, userID, client)
+}
+
+// Verify mocks base method.
+func (m *MockEmailVerificationUsecase) Verify(ctx context.Context, userID uint32, req dto.VerifyEmailDTO) (entities.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", ctx, userID, req)
+	ret0, _ := ret[0].(entities.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Verify indicates an expected call of Verify.
+func (mr *MockEmailVerificationUsecaseMockRecorder) Verify(ctx, userID, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockEmailVerificationUsecase)(nil).Verify), ctx, userID, req)
+}
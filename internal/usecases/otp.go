@@ -2,82 +2,478 @@ package usecases
 
 import (
 	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/config"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/logger"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/ratelimit"
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	numericAlphabet = "0123456789"
+	// alphanumericAlphabet leaves out characters that are easy to confuse
+	// when read from an SMS: 0/O, 1/I/L.
+	alphanumericAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+)
+
+const maxOtpTxRetries = 10
+
+var errInvalidOtp = errors.New("invalid or expired otp")
+
 type otp struct {
 	redisClient *redis.Client
 	l           logger.Logger
+	settings    *config.Settings
+	limiter     *ratelimit.Limiter
+	outbox      OutboxUsecase
+	pepper      []byte
 }
 
-func NewOtpUsecase(redisClient *redis.Client, l logger.Logger) OtpUsecase {
+func NewOtpUsecase(redisClient *redis.Client, l logger.Logger, settings *config.Settings, limiter *ratelimit.Limiter, outbox OutboxUsecase, pepper string) OtpUsecase {
 	return &otp{
 		redisClient: redisClient,
 		l:           l,
+		settings:    settings,
+		limiter:     limiter,
+		outbox:      outbox,
+		pepper:      []byte(pepper),
+	}
+}
+
+func (o *otp) policy(purpose entities.OtpPurpose) config.OtpPolicy {
+	return o.settings.Get().OTP.Policy(string(purpose))
+}
+
+func (o *otp) GenerateOTP(purpose entities.OtpPurpose) (string, error) {
+	p := o.policy(purpose)
+	alphabet := alphabetOf(p.Alphabet)
+
+	code := make([]byte, p.Length)
+	for i := range code {
+		chars := alphabet
+		// numeric codes never start with 0 so they survive being handled as numbers
+		if i == 0 && alphabet == numericAlphabet {
+			chars = alphabet[1:]
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = chars[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// ValidateFormat checks the code as it will be compared, so a code pasted
+// with surrounding whitespace or in lower case is accepted.
+func (o *otp) ValidateFormat(purpose entities.OtpPurpose, otpCode string) error {
+	p := o.policy(purpose)
+	otpCode = normalizeOtp(otpCode)
+	if len(otpCode) != p.Length {
+		return fmt.Errorf("otp must be %d characters long", p.Length)
+	}
+	alphabet := alphabetOf(p.Alphabet)
+	for _, c := range otpCode {
+		if !strings.ContainsRune(alphabet, c) {
+			return fmt.Errorf("otp contains invalid characters")
+		}
+	}
+	return nil
+}
+
+// SendOTP hands the code to the outbox so a slow or failing gateway does not
+// hold up the request. The message is deduplicated per issued code and
+// dropped once the code has expired.
+func (o *otp) SendOTP(ctx context.Context, challenge entities.OtpChallenge, recipient string, otpCode string) error {
+	dedupeKey := otpDedupeKey(challenge.ID)
+	if challenge.Resends > 0 {
+		dedupeKey = fmt.Sprintf("%s:%d", dedupeKey, challenge.Resends)
+	}
+	ttl := o.policy(challenge.Purpose).TTL
+	body := otpCode
+	if challenge.Channel == entities.ChannelSMS {
+		body = smsBody(o.settings.Get().OTP.SMS, challenge.Platform, otpCode, ttl)
+	}
+	expiresAt := time.Now().Add(ttl)
+	return o.outbox.Enqueue(ctx, entities.OutboxMessage{
+		DedupeKey: dedupeKey,
+		Channel:   challenge.Channel,
+		Recipient: recipient,
+		Body:      body,
+		ExpiresAt: &expiresAt,
+	})
+}
+
+// smsBody renders the OTP SMS, adding the line the client platform's OTP
+// autofill looks for when it is configured.
+func smsBody(t config.OtpSmsTemplate, platform, code string, ttl time.Duration) string {
+	minutes := strconv.Itoa(int((ttl + time.Minute - 1) / time.Minute))
+	body := strings.NewReplacer("{code}", code, "{minutes}", minutes).Replace(t.Template)
+	switch {
+	case platform == entities.PlatformAndroid && t.AndroidAppHash != "":
+		body += "\n\n" + t.AndroidAppHash
+	case (platform == entities.PlatformIOS || platform == entities.PlatformWeb) && t.Domain != "":
+		body += "\n\n@" + t.Domain + " #" + code
+	}
+	return body
+}
+
+func (o *otp) Deliveries(ctx context.Context, challengeID string) ([]entities.OutboxMessage, error) {
+	return o.outbox.ListByDedupeKey(ctx, otpDedupeKey(challengeID))
+}
+
+// otpDedupeKey keys the first message of a challenge; resends append ":<n>".
+func otpDedupeKey(challengeID string) string {
+	return "otp:" + challengeID
+}
+
+// ValidateChannel accepts the delivery channels enabled in OTP_CHANNELS.
+func (o *otp) ValidateChannel(channel string) error {
+	if !slices.Contains(o.settings.Get().OTP.Channels, channel) {
+		return fmt.Errorf("otp delivery via %s is not available", channel)
 	}
+	return nil
 }
 
-func (o *otp) GenerateOTP() (string, error) {
-	num, err := rand.Int(rand.Reader, big.NewInt(90000))
+// SaveOTP opens a new challenge for the code and returns its ID. Only a keyed
+// hash of the code is stored, so reading Redis (or its AOF/RDB files) is not
+// enough to log in as a user with a pending code.
+func (o *otp) SaveOTP(ctx context.Context, challenge entities.OtpChallenge, otpCode string) (string, error) {
+	id, err := newChallengeID()
 	if err != nil {
 		return "", err
 	}
-	otpCode := fmt.Sprintf("%05d", num.Int64()+10000)
-	return otpCode, nil
+	challenge.ID = id
+
+	key := otpChallengeKey(id)
+	pipe := o.redisClient.TxPipeline()
+	pipe.HSet(ctx, key,
+		"hash", o.hashOtp(challenge, otpCode),
+		"purpose", string(challenge.Purpose),
+		"phone", challenge.Phone,
+		"device_id", challenge.DeviceID,
+		"created_at", time.Now().UTC().Format(time.RFC3339),
+		"attempts", 0,
+		"request_ip", challenge.RequestIP,
+		"channel", challenge.Channel,
+		"resends", 0,
+	)
+	pipe.Expire(ctx, key, o.policy(challenge.Purpose).TTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+
+	o.l.Info("otp challenge %s opened for %s (purpose %s, channel %s, ip %s)", id, challenge.Phone, challenge.Purpose, challenge.Channel, challenge.RequestIP)
+	return id, nil
 }
 
-func (o *otp) SendOtpSms(ctx context.Context, phoneNumber string, otpCode string) error {
-	if err := o.validateOtpRateLimit(ctx, phoneNumber); err != nil {
-		return err
+// ReissueOTP swaps in a new code, channel and TTL for a pending challenge and
+// resets its attempt counter; the previous code stops working. Like
+// VerifyOTP it runs as a WATCH/MULTI transaction.
+func (o *otp) ReissueOTP(ctx context.Context, challenge entities.OtpChallenge, otpCode string) (entities.OtpChallenge, error) {
+	key := otpChallengeKey(challenge.ID)
+	ttl := o.policy(challenge.Purpose).TTL
+
+	for i := 0; i < maxOtpTxRetries; i++ {
+		err := o.redisClient.Watch(ctx, func(tx *redis.Tx) error {
+			stored, err := tx.HGetAll(ctx, key).Result()
+			if err != nil {
+				return err
+			}
+			if len(stored) == 0 || !o.isBound(stored, challenge) {
+				return errInvalidOtp
+			}
+			challenge.Resends, _ = strconv.Atoi(stored["resends"])
+			challenge.Resends++
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.HSet(ctx, key,
+					"hash", o.hashOtp(challenge, otpCode),
+					"channel", challenge.Channel,
+					"attempts", 0,
+					"resends", challenge.Resends,
+				)
+				pipe.Expire(ctx, key, ttl)
+				return nil
+			})
+			return err
+		}, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return entities.OtpChallenge{}, err
+		}
+
+		o.l.Info("otp challenge %s reissued via %s (resend %d)", challenge.ID, challenge.Channel, challenge.Resends)
+		return challenge, nil
 	}
+	return entities.OtpChallenge{}, errInvalidOtp
+}
 
-	o.l.Info(fmt.Sprintf("Sending OTP %s to phone number %s", otpCode, phoneNumber))
-	return nil
+// VerifyOTP checks otpCode against the challenge identified by challenge.ID,
+// which must also match the phone, device and purpose it was opened for.
+// A successful verification consumes the challenge.
+func (o *otp) VerifyOTP(ctx context.Context, challenge entities.OtpChallenge, otpCode string) error {
+	return o.VerifyOTPs(ctx, entities.OtpAnswer{Challenge: challenge, Code: otpCode})
 }
 
-func (o *otp) SaveOTP(ctx context.Context, phoneNumber string, otpCode string) error {
-	key := fmt.Sprintf("otp:code:%s", phoneNumber)
-	if err := o.redisClient.Set(ctx, key, otpCode, 2*time.Minute).Err(); err != nil {
+// VerifyOTPs checks several codes at once, e.g. for both numbers of a phone
+// change, so a typo in one code does not use up the other challenge.
+//
+// The read-compare-delete runs as an optimistic WATCH/MULTI transaction so
+// concurrent requests with the right codes cannot both succeed, and concurrent
+// wrong guesses cannot slip past the attempt counter.
+func (o *otp) VerifyOTPs(ctx context.Context, answers ...entities.OtpAnswer) error {
+	if len(answers) == 0 {
+		return errInvalidOtp
+	}
+	keys := make([]string, len(answers))
+	ids := make([]string, len(answers))
+	for i, answer := range answers {
+		keys[i] = otpChallengeKey(answer.Challenge.ID)
+		ids[i] = answer.Challenge.ID
+	}
+	challenges := strings.Join(ids, ", ")
+
+	for i := 0; i < maxOtpTxRetries; i++ {
+		err := o.redisClient.Watch(ctx, func(tx *redis.Tx) error {
+			return o.consumeChallenges(ctx, tx, keys, answers)
+		}, keys...)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil && !errors.Is(err, errInvalidOtp) {
+			o.l.Error("failed to verify otp challenge %s: %v", challenges, err)
+			return errInvalidOtp
+		}
+		if err == nil {
+			o.l.Info("otp challenge %s verified", challenges)
+		}
 		return err
 	}
-	return nil
+
+	o.l.Warn("otp challenge %s gave up after %d conflicting verifications", challenges, maxOtpTxRetries)
+	return errInvalidOtp
 }
 
-func (o *otp) VerifyOTP(ctx context.Context, phoneNumber string, otpCode string) error {
-	key := fmt.Sprintf("otp:code:%s", phoneNumber)
-	val, err := o.redisClient.Get(ctx, key).Result()
+func (o *otp) consumeChallenges(ctx context.Context, tx *redis.Tx, keys []string, answers []entities.OtpAnswer) error {
+	missing := false
+	// attempts of each challenge whose code did not match, by answer index
+	wrong := map[int]int{}
+	for i, key := range keys {
+		stored, err := tx.HGetAll(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		challenge := answers[i].Challenge
+		// a challenge opened for another phone, device or purpose is not the
+		// one the code was meant for, and keeps its attempts
+		if len(stored) == 0 || !o.isBound(stored, challenge) {
+			missing = true
+			continue
+		}
+		expected := o.hashOtp(challenge, answers[i].Code)
+		if !hmac.Equal([]byte(stored["hash"]), []byte(expected)) {
+			attempts, _ := strconv.Atoi(stored["attempts"])
+			wrong[i] = attempts + 1
+		}
+	}
+
+	if !missing && len(wrong) == 0 {
+		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, keys...)
+			return nil
+		})
+		return err
+	}
+
+	// invalidate a challenge once the policy's max attempts are used up
+	_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, attempts := range wrong {
+			if attempts >= o.policy(answers[i].Challenge.Purpose).MaxAttempts {
+				pipe.Del(ctx, keys[i])
+			} else {
+				pipe.HIncrBy(ctx, keys[i], "attempts", 1)
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("invalid or expired otp")
+		return err
 	}
-	if val != otpCode {
-		return fmt.Errorf("invalid or expired otp")
+	for i, attempts := range wrong {
+		o.l.Warn("otp challenge %s failed verification (attempt %d)", answers[i].Challenge.ID, attempts)
 	}
-	// consume OTP
-	_ = o.redisClient.Del(ctx, key).Err()
-	return nil
+	return errInvalidOtp
 }
 
-func (o *otp) validateOtpRateLimit(ctx context.Context, phoneNumber string) error {
-	key := fmt.Sprintf("otp:10m:%s", phoneNumber)
+// hashOtp binds the code to its challenge, phone and purpose so a hash cannot
+// be replayed under another challenge.
+func (o *otp) hashOtp(challenge entities.OtpChallenge, otpCode string) string {
+	mac := hmac.New(sha256.New, o.pepper)
+	for _, part := range []string{challenge.ID, string(challenge.Purpose), challenge.Phone, normalizeOtp(otpCode)} {
+		mac.Write([]byte(part))
+		mac.Write([]byte{0})
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CheckRateLimit enforces the resend interval and counts an OTP request
+// against the per-phone, per-device, per-IP, per-subnet and global limits. It
+// returns a *ratelimit.ExceededError naming the first limit that was hit.
+// Several challenges sent together are counted all or nothing.
+func (o *otp) CheckRateLimit(ctx context.Context, challenges ...entities.OtpChallenge) error {
+	var rules []ratelimit.Rule
+	phones := make([]string, len(challenges))
+	for i, challenge := range challenges {
+		rules = append(rules, o.resendRule(challenge))
+		rules = append(rules, o.rateLimitRules(challenge)...)
+		phones[i] = challenge.Phone
+	}
+	_, err := o.limiter.Allow(ctx, rules...)
+	var exceeded *ratelimit.ExceededError
+	if errors.As(err, &exceeded) && len(challenges) > 0 {
+		o.l.Warn("otp request for %s from %s rejected by the %s rate limit", strings.Join(phones, ", "), challenges[0].RequestIP, exceeded.Rule.Name)
+	}
+	return err
+}
 
-	count, err := o.redisClient.Incr(ctx, key).Result()
+// GetStatus reports the state of challenge and when the phone may request a
+// new code, without counting towards any limit. A challenge that expired, was
+// used up or belongs to another phone, device or purpose is reported inactive.
+func (o *otp) GetStatus(ctx context.Context, challenge entities.OtpChallenge) (entities.OtpStatus, error) {
+	status := entities.OtpStatus{ChallengeID: challenge.ID}
+
+	if challenge.ID != "" {
+		key := otpChallengeKey(challenge.ID)
+		pipe := o.redisClient.Pipeline()
+		fields := pipe.HGetAll(ctx, key)
+		ttl := pipe.PTTL(ctx, key)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return entities.OtpStatus{}, err
+		}
+
+		stored := fields.Val()
+		if len(stored) > 0 && o.isBound(stored, challenge) && ttl.Val() > 0 {
+			attempts, _ := strconv.Atoi(stored["attempts"])
+			status.Active = true
+			status.Channel = stored["channel"]
+			status.ExpiresIn = ttl.Val()
+			status.AttemptsRemaining = max(o.policy(challenge.Purpose).MaxAttempts-attempts, 0)
+		}
+	}
+
+	cooldown, err := o.limiter.Peek(ctx, o.resendRule(challenge))
 	if err != nil {
-		return err
+		return entities.OtpStatus{}, err
+	}
+	limits, err := o.limiter.Peek(ctx, o.rateLimitRules(challenge)...)
+	if err != nil {
+		return entities.OtpStatus{}, err
 	}
 
-	if count == 1 {
-		o.redisClient.Expire(ctx, key, 10*time.Minute)
+	if limits.Allowed {
+		status.RequestsRemaining = limits.Remaining
+	} else {
+		status.ResendAfter = limits.RetryAfter
+	}
+	if !cooldown.Allowed {
+		status.ResendAfter = max(status.ResendAfter, cooldown.RetryAfter)
 	}
+	return status, nil
+}
 
-	if count > 3 {
-		return fmt.Errorf("rate limit exceeded: max 3 OTPs per 10 minutes")
+// resendRule allows one request per OTP_RESEND_INTERVAL for a phone and
+// purpose. GCRA with a rate of 1 is exactly a cooldown.
+func (o *otp) resendRule(challenge entities.OtpChallenge) ratelimit.Rule {
+	return ratelimit.Rule{
+		Limit: ratelimit.Limit{
+			Name:      "resend",
+			Rate:      1,
+			Period:    o.settings.Get().OTP.ResendInterval,
+			Algorithm: ratelimit.GCRA,
+		},
+		Key: string(challenge.Purpose) + ":" + challenge.Phone,
 	}
+}
 
-	return nil
+func (o *otp) rateLimitRules(challenge entities.OtpChallenge) []ratelimit.Rule {
+	policy := o.settings.Get().OTP
+	limit := func(name string, rate int) ratelimit.Limit {
+		return ratelimit.Limit{
+			Name:      name,
+			Rate:      rate,
+			Period:    policy.RateWindow,
+			Algorithm: ratelimit.Algorithm(policy.RateLimits.Algorithm),
+		}
+	}
+
+	return []ratelimit.Rule{
+		{Limit: limit("phone", policy.RateLimit), Key: challenge.Phone},
+		{Limit: limit("device", policy.RateLimits.PerDevice), Key: challenge.DeviceID},
+		{Limit: limit("ip", policy.RateLimits.PerIP), Key: challenge.RequestIP},
+		{Limit: limit("subnet", policy.RateLimits.PerSubnet), Key: ratelimit.Subnet(challenge.RequestIP)},
+		{Limit: limit("global", policy.RateLimits.Global), Key: "all"},
+	}
+}
+
+func (o *otp) isBound(stored map[string]string, challenge entities.OtpChallenge) bool {
+	return stored["purpose"] == string(challenge.Purpose) &&
+		stored["phone"] == challenge.Phone &&
+		stored["device_id"] == challenge.DeviceID
+}
+
+func otpChallengeKey(id string) string {
+	return fmt.Sprintf("otp:challenge:%s", id)
+}
+
+func newChallengeID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func alphabetOf(name string) string {
+	if name == config.OtpAlphabetAlphanumeric {
+		return alphanumericAlphabet
+	}
+	return numericAlphabet
+}
+
+// normalizeOtp makes alphanumeric codes case-insensitive.
+func normalizeOtp(otpCode string) string {
+	return strings.ToUpper(strings.TrimSpace(otpCode))
+}
+
+// issueOtp generates, saves and sends a code for challenge, whose rate limits
+// the caller has already checked.
+func issueOtp(ctx context.Context, otpUsecase OtpUsecase, challenge entities.OtpChallenge, recipient string) (entities.OtpStatus, error) {
+	code, err := otpUsecase.GenerateOTP(challenge.Purpose)
+	if err != nil {
+		return entities.OtpStatus{}, err
+	}
+	challenge.ID, err = otpUsecase.SaveOTP(ctx, challenge, code)
+	if err != nil {
+		return entities.OtpStatus{}, err
+	}
+	if err := otpUsecase.SendOTP(ctx, challenge, recipient, code); err != nil {
+		return entities.OtpStatus{}, err
+	}
+	return otpUsecase.GetStatus(ctx, challenge)
 }
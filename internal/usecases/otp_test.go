@@ -2,12 +2,21 @@ package usecases
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/config"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/usecases/mockusecases"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/ratelimit"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 )
 
 // MockLogger is a mock for logger.Logger interface
@@ -35,6 +44,125 @@ func (m *MockLogger) Fatal(message interface{}, args ...interface{}) {
 	m.Called(message, args)
 }
 
+func testOtpConfig() *config.Config {
+	return &config.Config{
+		OTP: config.OTP{
+			Length:      5,
+			Alphabet:    config.OtpAlphabetNumeric,
+			TTL:         2 * time.Minute,
+			MaxAttempts: 5,
+			RateLimit:   3,
+			RateWindow:  10 * time.Minute,
+			Channels:    []string{entities.ChannelSMS, entities.ChannelVoice},
+			SMS: config.OtpSmsTemplate{
+				Template:       "Your code is {code}, valid for {minutes} minutes",
+				AndroidAppHash: "FA+9qCX9VSu",
+				Domain:         "example.com",
+			},
+			RateLimits: config.OtpRateLimits{
+				Algorithm: string(ratelimit.SlidingWindow),
+				PerIP:     20,
+				PerSubnet: 60,
+				PerDevice: 5,
+			},
+		},
+	}
+}
+
+func testOtpSettings() *config.Settings {
+	return config.NewSettings(testOtpConfig())
+}
+
+func TestOtpUsecase_SendOTP(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockOutbox := mockusecases.NewMockOutboxUsecase(ctrl)
+	o := NewOtpUsecase(nil, &MockLogger{}, testOtpSettings(), nil, mockOutbox, "test-pepper-0123456789")
+
+	t.Run("queues the code on the challenge's channel", func(t *testing.T) {
+		challenge := entities.OtpChallenge{ID: "challenge-1", Purpose: entities.OtpPurposeLogin, Phone: "+1234567890", Channel: entities.ChannelSMS}
+		mockOutbox.EXPECT().Enqueue(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, m entities.OutboxMessage) error {
+			assert.Equal(t, "otp:challenge-1", m.DedupeKey)
+			assert.Equal(t, entities.ChannelSMS, m.Channel)
+			assert.Equal(t, "+1234567890", m.Recipient)
+			assert.Equal(t, "Your code is 12345, valid for 2 minutes", m.Body)
+			require.NotNil(t, m.ExpiresAt)
+			assert.WithinDuration(t, time.Now().Add(2*time.Minute), *m.ExpiresAt, time.Second)
+			return nil
+		})
+
+		require.NoError(t, o.SendOTP(context.Background(), challenge, "+1234567890", "12345"))
+	})
+
+	t.Run("reissued codes get their own dedupe key", func(t *testing.T) {
+		challenge := entities.OtpChallenge{ID: "challenge-1", Purpose: entities.OtpPurposeLogin, Phone: "+1234567890", Channel: entities.ChannelVoice, Resends: 2}
+		mockOutbox.EXPECT().Enqueue(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, m entities.OutboxMessage) error {
+			assert.Equal(t, "otp:challenge-1:2", m.DedupeKey)
+			assert.Equal(t, entities.ChannelVoice, m.Channel)
+			return nil
+		})
+
+		require.NoError(t, o.SendOTP(context.Background(), challenge, "+1234567890", "12345"))
+	})
+}
+
+func TestOtpUsecase_SendOTPTemplates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockOutbox := mockusecases.NewMockOutboxUsecase(ctrl)
+	o := NewOtpUsecase(nil, &MockLogger{}, testOtpSettings(), nil, mockOutbox, "test-pepper-0123456789")
+
+	tests := []struct {
+		channel  string
+		platform string
+		want     string
+	}{
+		{entities.ChannelSMS, "", "Your code is 12345, valid for 2 minutes"},
+		{entities.ChannelSMS, entities.PlatformAndroid, "Your code is 12345, valid for 2 minutes\n\nFA+9qCX9VSu"},
+		{entities.ChannelSMS, entities.PlatformIOS, "Your code is 12345, valid for 2 minutes\n\n@example.com #12345"},
+		{entities.ChannelSMS, entities.PlatformWeb, "Your code is 12345, valid for 2 minutes\n\n@example.com #12345"},
+		{entities.ChannelVoice, entities.PlatformAndroid, "12345"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.channel+" "+tt.platform, func(t *testing.T) {
+			challenge := entities.OtpChallenge{ID: "challenge-1", Purpose: entities.OtpPurposeLogin, Channel: tt.channel, Platform: tt.platform}
+			mockOutbox.EXPECT().Enqueue(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, m entities.OutboxMessage) error {
+				assert.Equal(t, tt.want, m.Body)
+				return nil
+			})
+			require.NoError(t, o.SendOTP(context.Background(), challenge, "+1234567890", "12345"))
+		})
+	}
+
+	t.Run("platform lines are left out until configured", func(t *testing.T) {
+		body := smsBody(config.OtpSmsTemplate{Template: "Code: {code}"}, entities.PlatformAndroid, "12345", time.Minute)
+		assert.Equal(t, "Code: 12345", body)
+	})
+}
+
+func TestOtpUsecase_Deliveries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockOutbox := mockusecases.NewMockOutboxUsecase(ctrl)
+	o := NewOtpUsecase(nil, &MockLogger{}, testOtpSettings(), nil, mockOutbox, "test-pepper-0123456789")
+
+	messages := []entities.OutboxMessage{{ID: 1, DedupeKey: "otp:challenge-1"}, {ID: 2, DedupeKey: "otp:challenge-1:1"}}
+	mockOutbox.EXPECT().ListByDedupeKey(gomock.Any(), "otp:challenge-1").Return(messages, nil)
+
+	got, err := o.Deliveries(context.Background(), "challenge-1")
+	require.NoError(t, err)
+	assert.Equal(t, messages, got)
+}
+
+func TestOtpUsecase_ValidateChannel(t *testing.T) {
+	o := NewOtpUsecase(nil, &MockLogger{}, testOtpSettings(), nil, nil, "test-pepper-0123456789")
+
+	assert.NoError(t, o.ValidateChannel(entities.ChannelSMS))
+	assert.NoError(t, o.ValidateChannel(entities.ChannelVoice))
+	assert.ErrorContains(t, o.ValidateChannel(entities.ChannelTelegram), "not available")
+	assert.Error(t, o.ValidateChannel("fax"))
+}
+
 func TestOtpUsecase_GenerateOTP(t *testing.T) {
 	// We can test GenerateOTP directly since it doesn't depend on external services
 
@@ -44,10 +172,11 @@ func TestOtpUsecase_GenerateOTP(t *testing.T) {
 	o := &otp{
 		redisClient: nil, // Not used in GenerateOTP
 		l:           mockLogger,
+		settings:    testOtpSettings(),
 	}
 
 	t.Run("successful OTP generation", func(t *testing.T) {
-		otpCode, err := o.GenerateOTP()
+		otpCode, err := o.GenerateOTP(entities.OtpPurposeLogin)
 
 		assert.NoError(t, err)
 		assert.Len(t, otpCode, 5)
@@ -63,7 +192,7 @@ func TestOtpUsecase_GenerateOTP(t *testing.T) {
 
 		// Generate multiple OTPs and ensure they're different
 		for i := 0; i < 10; i++ {
-			otpCode, err := o.GenerateOTP()
+			otpCode, err := o.GenerateOTP(entities.OtpPurposeLogin)
 			assert.NoError(t, err)
 			codes[otpCode] = true
 		}
@@ -73,6 +202,48 @@ func TestOtpUsecase_GenerateOTP(t *testing.T) {
 	})
 }
 
+func TestOtpUsecase_Policy(t *testing.T) {
+	cfg := testOtpConfig()
+	cfg.OTP.Alphabet = config.OtpAlphabetAlphanumeric
+	cfg.OTP.AccountDeletion = config.OtpPolicyOverride{Length: 8}
+	cfg.OTP.PhoneChange = config.OtpPolicyOverride{Alphabet: config.OtpAlphabetNumeric}
+
+	o := &otp{settings: config.NewSettings(cfg), l: &MockLogger{}}
+
+	t.Run("alphanumeric codes skip ambiguous characters", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			otpCode, err := o.GenerateOTP(entities.OtpPurposeLogin)
+			require.NoError(t, err)
+			require.Len(t, otpCode, 5)
+			require.Regexp(t, `^[2-9A-HJKMNP-Z]{5}$`, otpCode)
+		}
+	})
+
+	t.Run("per-purpose length override", func(t *testing.T) {
+		otpCode, err := o.GenerateOTP(entities.OtpPurposeAccountDeletion)
+		require.NoError(t, err)
+		assert.Len(t, otpCode, 8)
+	})
+
+	t.Run("per-purpose alphabet override", func(t *testing.T) {
+		otpCode, err := o.GenerateOTP(entities.OtpPurposePhoneChange)
+		require.NoError(t, err)
+		assert.Regexp(t, `^[1-9]\d{4}$`, otpCode)
+	})
+
+	t.Run("format validation follows the policy", func(t *testing.T) {
+		assert.NoError(t, o.ValidateFormat(entities.OtpPurposeLogin, "AB3CD"))
+		assert.NoError(t, o.ValidateFormat(entities.OtpPurposeLogin, "ab3cd"))
+		assert.NoError(t, o.ValidateFormat(entities.OtpPurposeLogin, " AB3CD\n"))
+		assert.Error(t, o.ValidateFormat(entities.OtpPurposeLogin, "AB 3CD"))
+		assert.Error(t, o.ValidateFormat(entities.OtpPurposeLogin, "AB0CD"))
+		assert.Error(t, o.ValidateFormat(entities.OtpPurposeLogin, "AB3C"))
+		assert.Error(t, o.ValidateFormat(entities.OtpPurposeAccountDeletion, "AB3CD"))
+		assert.Error(t, o.ValidateFormat(entities.OtpPurposePhoneChange, "AB3CD"))
+		assert.NoError(t, o.ValidateFormat(entities.OtpPurposePhoneChange, "12345"))
+	})
+}
+
 // For the other tests that require Redis interaction, we'll create integration-style tests
 // that can be run with a real Redis instance, or we'll test the logic separately
 
@@ -82,11 +253,12 @@ func TestOtpUsecase_GenerateOTPEdgeCases(t *testing.T) {
 	o := &otp{
 		redisClient: nil,
 		l:           mockLogger,
+		settings:    testOtpSettings(),
 	}
 
 	// Test that all generated OTPs are valid
 	for i := 0; i < 100; i++ {
-		otpCode, err := o.GenerateOTP()
+		otpCode, err := o.GenerateOTP(entities.OtpPurposeLogin)
 		require.NoError(t, err)
 		require.Len(t, otpCode, 5)
 		require.Regexp(t, `^\d{5}$`, otpCode)
@@ -127,57 +299,392 @@ func TestOtpUsecaseWithRealRedis(t *testing.T) {
 
 	mockLogger := &MockLogger{}
 	mockLogger.On("Info", mock.AnythingOfType("string"), mock.Anything).Maybe()
+	mockLogger.On("Warn", mock.AnythingOfType("string"), mock.Anything).Maybe()
+	mockLogger.On("Error", mock.Anything, mock.Anything).Maybe()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockOutbox := mockusecases.NewMockOutboxUsecase(ctrl)
 
-	o := NewOtpUsecase(redisClient, mockLogger)
+	limiter := ratelimit.NewLimiter(ratelimit.NewRedisStore(redisClient), "otp:ratelimit:")
+	o := NewOtpUsecase(redisClient, mockLogger, testOtpSettings(), limiter, mockOutbox, "test-pepper-0123456789")
 
 	phoneNumber := "+1234567890"
+	newChallenge := func(phone string) entities.OtpChallenge {
+		return entities.OtpChallenge{Purpose: entities.OtpPurposeLogin, Phone: phone, DeviceID: "device-a", RequestIP: "127.0.0.1"}
+	}
 
 	t.Run("complete OTP flow with real Redis", func(t *testing.T) {
 		// Generate OTP
-		otpCode, err := o.GenerateOTP()
+		otpCode, err := o.GenerateOTP(entities.OtpPurposeLogin)
 		require.NoError(t, err)
 		require.Len(t, otpCode, 5)
 
 		// Save OTP
-		err = o.SaveOTP(ctx, phoneNumber, otpCode)
+		challenge := newChallenge(phoneNumber)
+		challenge.ID, err = o.SaveOTP(ctx, challenge, otpCode)
 		require.NoError(t, err)
+		require.NotEmpty(t, challenge.ID)
 
 		// Verify correct OTP
-		err = o.VerifyOTP(ctx, phoneNumber, otpCode)
+		err = o.VerifyOTP(ctx, challenge, otpCode)
 		require.NoError(t, err)
 
 		// Try to verify again (should fail because OTP is consumed)
-		err = o.VerifyOTP(ctx, phoneNumber, otpCode)
+		err = o.VerifyOTP(ctx, challenge, otpCode)
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "invalid or expired otp")
 	})
 
+	t.Run("stores a keyed hash instead of the code", func(t *testing.T) {
+		otpCode, err := o.GenerateOTP(entities.OtpPurposeLogin)
+		require.NoError(t, err)
+		challengeID, err := o.SaveOTP(ctx, newChallenge(phoneNumber), otpCode)
+		require.NoError(t, err)
+
+		stored, err := redisClient.HGetAll(ctx, otpChallengeKey(challengeID)).Result()
+		require.NoError(t, err)
+		assert.NotContains(t, stored["hash"], otpCode)
+		assert.Len(t, stored["hash"], 64)
+		assert.Equal(t, "login", stored["purpose"])
+		assert.Equal(t, phoneNumber, stored["phone"])
+		assert.Equal(t, "device-a", stored["device_id"])
+		assert.Equal(t, "127.0.0.1", stored["request_ip"])
+		assert.Equal(t, "0", stored["attempts"])
+		assert.NotEmpty(t, stored["created_at"])
+		for _, v := range stored {
+			assert.NotEqual(t, otpCode, v)
+		}
+	})
+
 	t.Run("verify wrong OTP", func(t *testing.T) {
 		// Generate and save OTP
-		otpCode, err := o.GenerateOTP()
+		otpCode, err := o.GenerateOTP(entities.OtpPurposeLogin)
 		require.NoError(t, err)
 
-		err = o.SaveOTP(ctx, phoneNumber+"_wrong", otpCode)
+		challenge := newChallenge(phoneNumber + "_wrong")
+		challenge.ID, err = o.SaveOTP(ctx, challenge, otpCode)
 		require.NoError(t, err)
 
 		// Try to verify with wrong OTP
-		err = o.VerifyOTP(ctx, phoneNumber+"_wrong", "00000")
+		err = o.VerifyOTP(ctx, challenge, "00000")
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "invalid or expired otp")
 	})
 
-	t.Run("rate limiting", func(t *testing.T) {
-		testPhone := "+1111111111"
+	t.Run("parallel challenges for the same phone are independent", func(t *testing.T) {
+		first, err := o.GenerateOTP(entities.OtpPurposeLogin)
+		require.NoError(t, err)
+		second, err := o.GenerateOTP(entities.OtpPurposeLogin)
+		require.NoError(t, err)
+
+		challengeA := newChallenge(phoneNumber + "_parallel")
+		challengeA.ID, err = o.SaveOTP(ctx, challengeA, first)
+		require.NoError(t, err)
+		challengeB := newChallenge(phoneNumber + "_parallel")
+		challengeB.DeviceID = "device-b"
+		challengeB.ID, err = o.SaveOTP(ctx, challengeB, second)
+		require.NoError(t, err)
+		require.NotEqual(t, challengeA.ID, challengeB.ID)
+
+		require.NoError(t, o.VerifyOTP(ctx, challengeB, second))
+		require.NoError(t, o.VerifyOTP(ctx, challengeA, first))
+	})
+
+	t.Run("answers verified together are consumed all or nothing", func(t *testing.T) {
+		first, err := o.GenerateOTP(entities.OtpPurposeLogin)
+		require.NoError(t, err)
+		second, err := o.GenerateOTP(entities.OtpPurposeLogin)
+		require.NoError(t, err)
+
+		challengeA := newChallenge(phoneNumber + "_together")
+		challengeA.ID, err = o.SaveOTP(ctx, challengeA, first)
+		require.NoError(t, err)
+		challengeB := newChallenge(phoneNumber + "_together_new")
+		challengeB.ID, err = o.SaveOTP(ctx, challengeB, second)
+		require.NoError(t, err)
+
+		err = o.VerifyOTPs(ctx,
+			entities.OtpAnswer{Challenge: challengeA, Code: first},
+			entities.OtpAnswer{Challenge: challengeB, Code: "00000"},
+		)
+		require.Error(t, err)
+
+		require.NoError(t, o.VerifyOTPs(ctx,
+			entities.OtpAnswer{Challenge: challengeA, Code: first},
+			entities.OtpAnswer{Challenge: challengeB, Code: second},
+		))
+		assert.Error(t, o.VerifyOTP(ctx, challengeA, first))
+		assert.Error(t, o.VerifyOTP(ctx, challengeB, second))
+	})
+
+	t.Run("challenge is bound to phone, device and purpose", func(t *testing.T) {
+		otpCode, err := o.GenerateOTP(entities.OtpPurposeLogin)
+		require.NoError(t, err)
+		challenge := newChallenge(phoneNumber + "_bound")
+		challenge.ID, err = o.SaveOTP(ctx, challenge, otpCode)
+		require.NoError(t, err)
+
+		otherPhone := challenge
+		otherPhone.Phone = "+1999999999"
+		assert.Error(t, o.VerifyOTP(ctx, otherPhone, otpCode))
+
+		otherDevice := challenge
+		otherDevice.DeviceID = "device-b"
+		assert.Error(t, o.VerifyOTP(ctx, otherDevice, otpCode))
+
+		otherPurpose := challenge
+		otherPurpose.Purpose = entities.OtpPurposeAccountDeletion
+		assert.Error(t, o.VerifyOTP(ctx, otherPurpose, otpCode))
+
+		assert.NoError(t, o.VerifyOTP(ctx, challenge, otpCode))
+	})
+
+	t.Run("guesses for another phone or purpose keep the challenge's attempts", func(t *testing.T) {
+		otpCode, err := o.GenerateOTP(entities.OtpPurposeLogin)
+		require.NoError(t, err)
+		challenge := newChallenge(phoneNumber + "_unbound_attempts")
+		challenge.ID, err = o.SaveOTP(ctx, challenge, otpCode)
+		require.NoError(t, err)
+
+		otherPurpose := challenge
+		otherPurpose.Purpose = entities.OtpPurposeAccountDeletion
+		for i := 0; i < 5; i++ {
+			require.Error(t, o.VerifyOTP(ctx, otherPurpose, "00000"))
+		}
+		stored, err := redisClient.HGetAll(ctx, otpChallengeKey(challenge.ID)).Result()
+		require.NoError(t, err)
+		assert.Equal(t, "0", stored["attempts"])
+
+		assert.NoError(t, o.VerifyOTP(ctx, challenge, otpCode))
+	})
+
+	t.Run("code is invalidated after max attempts", func(t *testing.T) {
+		otpCode, err := o.GenerateOTP(entities.OtpPurposeLogin)
+		require.NoError(t, err)
+		challenge := newChallenge(phoneNumber + "_attempts")
+		challenge.ID, err = o.SaveOTP(ctx, challenge, otpCode)
+		require.NoError(t, err)
+
+		for i := 0; i < 5; i++ {
+			require.Error(t, o.VerifyOTP(ctx, challenge, "00000"))
+		}
+
+		err = o.VerifyOTP(ctx, challenge, otpCode)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid or expired otp")
+	})
+
+	t.Run("rate limiting per phone", func(t *testing.T) {
+		challenge := entities.OtpChallenge{Purpose: entities.OtpPurposeLogin, Phone: "+1111111111", RequestIP: "10.1.0.1"}
 
 		// First 3 requests should succeed
 		for i := 0; i < 3; i++ {
-			err := o.SendOtpSms(ctx, testPhone, "12345")
+			err := o.CheckRateLimit(ctx, challenge)
 			assert.NoError(t, err, "Request %d should succeed", i+1)
 		}
 
 		// 4th request should fail due to rate limiting
-		err := o.SendOtpSms(ctx, testPhone, "12345")
-		assert.Error(t, err)
+		err := o.CheckRateLimit(ctx, challenge)
+		var exceeded *ratelimit.ExceededError
+		require.ErrorAs(t, err, &exceeded)
+		assert.Equal(t, "phone", exceeded.Rule.Name)
+		assert.Greater(t, exceeded.Result.RetryAfter, time.Duration(0))
 		assert.Contains(t, err.Error(), "rate limit exceeded")
 	})
+
+	t.Run("rate limiting per IP across many phones", func(t *testing.T) {
+		var err error
+		for i := 0; i < 21 && err == nil; i++ {
+			err = o.CheckRateLimit(ctx, entities.OtpChallenge{Phone: fmt.Sprintf("+1444%07d", i), RequestIP: "10.2.0.1"})
+		}
+		var exceeded *ratelimit.ExceededError
+		require.ErrorAs(t, err, &exceeded)
+		assert.Equal(t, "ip", exceeded.Rule.Name)
+
+		// the rejected request used up none of its phone's quota
+		status, err := o.GetStatus(ctx, entities.OtpChallenge{Purpose: entities.OtpPurposeLogin, Phone: "+14440000020", RequestIP: "10.2.0.2"})
+		require.NoError(t, err)
+		assert.Equal(t, 3, status.RequestsRemaining)
+	})
+
+	t.Run("challenges sent together are charged all or nothing", func(t *testing.T) {
+		current := entities.OtpChallenge{Purpose: entities.OtpPurposePhoneChange, Phone: "+1888888881", RequestIP: "10.6.0.1"}
+		next := current
+		next.Phone = "+1888888882"
+		for i := 0; i < 3; i++ {
+			require.NoError(t, o.CheckRateLimit(ctx, entities.OtpChallenge{Purpose: entities.OtpPurposeLogin, Phone: next.Phone, RequestIP: "10.6.0.2"}))
+		}
+
+		err := o.CheckRateLimit(ctx, current, next)
+		var exceeded *ratelimit.ExceededError
+		require.ErrorAs(t, err, &exceeded)
+		assert.Equal(t, "phone", exceeded.Rule.Name)
+
+		status, err := o.GetStatus(ctx, current)
+		require.NoError(t, err)
+		assert.Equal(t, 3, status.RequestsRemaining)
+	})
+
+	t.Run("rate limiting per device", func(t *testing.T) {
+		var err error
+		for i := 0; i < 6 && err == nil; i++ {
+			err = o.CheckRateLimit(ctx, entities.OtpChallenge{Phone: fmt.Sprintf("+1555%07d", i), DeviceID: "device-bomb", RequestIP: fmt.Sprintf("10.3.%d.1", i)})
+		}
+		var exceeded *ratelimit.ExceededError
+		require.ErrorAs(t, err, &exceeded)
+		assert.Equal(t, "device", exceeded.Rule.Name)
+	})
+
+	t.Run("resend interval and status", func(t *testing.T) {
+		cfg := testOtpConfig()
+		cfg.OTP.ResendInterval = time.Minute
+		o := NewOtpUsecase(redisClient, mockLogger, config.NewSettings(cfg), limiter, mockOutbox, "test-pepper-0123456789")
+		challenge := entities.OtpChallenge{Purpose: entities.OtpPurposeLogin, Phone: "+1666666666", DeviceID: "device-s", RequestIP: "10.5.0.1"}
+
+		status, err := o.GetStatus(ctx, challenge)
+		require.NoError(t, err)
+		assert.False(t, status.Active)
+		assert.Zero(t, status.ResendAfter)
+		assert.Equal(t, 3, status.RequestsRemaining)
+
+		require.NoError(t, o.CheckRateLimit(ctx, challenge))
+		otpCode, err := o.GenerateOTP(entities.OtpPurposeLogin)
+		require.NoError(t, err)
+		challenge.ID, err = o.SaveOTP(ctx, challenge, otpCode)
+		require.NoError(t, err)
+		require.Error(t, o.VerifyOTP(ctx, challenge, "00000"))
+
+		status, err = o.GetStatus(ctx, challenge)
+		require.NoError(t, err)
+		assert.True(t, status.Active)
+		assert.InDelta(t, (2 * time.Minute).Seconds(), status.ExpiresIn.Seconds(), 2)
+		assert.Equal(t, 4, status.AttemptsRemaining)
+		assert.InDelta(t, time.Minute.Seconds(), status.ResendAfter.Seconds(), 2)
+		assert.Equal(t, 2, status.RequestsRemaining)
+
+		err = o.CheckRateLimit(ctx, challenge)
+		var exceeded *ratelimit.ExceededError
+		require.ErrorAs(t, err, &exceeded)
+		assert.Equal(t, "resend", exceeded.Rule.Name)
+
+		other := challenge
+		other.Phone = "+1777777777"
+		status, err = o.GetStatus(ctx, other)
+		require.NoError(t, err)
+		assert.False(t, status.Active, "a challenge is only visible to the phone it was sent to")
+		assert.Zero(t, status.ResendAfter)
+	})
+
+	t.Run("reissuing a code switches channel and invalidates the old code", func(t *testing.T) {
+		challenge := newChallenge(phoneNumber + "_reissue")
+		challenge.Channel = entities.ChannelSMS
+		oldCode, err := o.GenerateOTP(entities.OtpPurposeLogin)
+		require.NoError(t, err)
+		challenge.ID, err = o.SaveOTP(ctx, challenge, oldCode)
+		require.NoError(t, err)
+		require.Error(t, o.VerifyOTP(ctx, challenge, "00000"))
+
+		newCode := "98765"
+		if oldCode == newCode {
+			newCode = "98764"
+		}
+		challenge.Channel = entities.ChannelVoice
+		reissued, err := o.ReissueOTP(ctx, challenge, newCode)
+		require.NoError(t, err)
+		assert.Equal(t, 1, reissued.Resends)
+
+		status, err := o.GetStatus(ctx, reissued)
+		require.NoError(t, err)
+		assert.Equal(t, entities.ChannelVoice, status.Channel)
+		assert.Equal(t, 5, status.AttemptsRemaining, "a new code gets a fresh attempt budget")
+
+		assert.Error(t, o.VerifyOTP(ctx, challenge, oldCode))
+		assert.NoError(t, o.VerifyOTP(ctx, challenge, newCode))
+
+		_, err = o.ReissueOTP(ctx, challenge, newCode)
+		assert.Error(t, err, "consumed challenges cannot be reissued")
+	})
+
+	t.Run("reissue requires the same phone and device", func(t *testing.T) {
+		challenge := newChallenge(phoneNumber + "_reissue_bound")
+		otpCode, err := o.GenerateOTP(entities.OtpPurposeLogin)
+		require.NoError(t, err)
+		challenge.ID, err = o.SaveOTP(ctx, challenge, otpCode)
+		require.NoError(t, err)
+
+		other := challenge
+		other.DeviceID = "device-b"
+		_, err = o.ReissueOTP(ctx, other, "11111")
+		assert.Error(t, err)
+		assert.NoError(t, o.VerifyOTP(ctx, challenge, otpCode))
+	})
+
+	t.Run("concurrent verifications consume a challenge only once", func(t *testing.T) {
+		otpCode, err := o.GenerateOTP(entities.OtpPurposeLogin)
+		require.NoError(t, err)
+		challenge := newChallenge(phoneNumber + "_race")
+		challenge.ID, err = o.SaveOTP(ctx, challenge, otpCode)
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		var successes atomic.Int32
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if o.VerifyOTP(ctx, challenge, otpCode) == nil {
+					successes.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(1), successes.Load())
+	})
+
+	t.Run("concurrent wrong guesses cannot exceed max attempts", func(t *testing.T) {
+		otpCode, err := o.GenerateOTP(entities.OtpPurposeLogin)
+		require.NoError(t, err)
+		challenge := newChallenge(phoneNumber + "_guesses")
+		challenge.ID, err = o.SaveOTP(ctx, challenge, otpCode)
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_ = o.VerifyOTP(ctx, challenge, "00000")
+			}()
+		}
+		wg.Wait()
+
+		exists, err := redisClient.Exists(ctx, otpChallengeKey(challenge.ID)).Result()
+		require.NoError(t, err)
+		assert.Zero(t, exists)
+		assert.Error(t, o.VerifyOTP(ctx, challenge, otpCode))
+	})
+
+	t.Run("concurrent requests respect the rate limit", func(t *testing.T) {
+		challenge := entities.OtpChallenge{Purpose: entities.OtpPurposeLogin, Phone: "+1222222222", RequestIP: "10.4.0.1"}
+
+		var wg sync.WaitGroup
+		var allowed atomic.Int32
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if o.CheckRateLimit(ctx, challenge) == nil {
+					allowed.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(3), allowed.Load())
+		ttl, err := redisClient.PTTL(ctx, "otp:ratelimit:phone:"+challenge.Phone).Result()
+		require.NoError(t, err)
+		assert.Greater(t, ttl, time.Duration(0))
+	})
 }
@@ -0,0 +1,274 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/config"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/logger"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/secretbox"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/sms"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+// outboxPurgeEvery also bounds how long an expired OTP stays readable in a dead letter.
+const outboxPurgeEvery = time.Minute
+
+var (
+	ErrOutboxMessageNotRetryable = errors.New("message not found, not dead-lettered or already expired")
+	ErrDeliveryReportUnmatched   = errors.New("no message with this provider message id")
+)
+
+type outbox struct {
+	repository repositories.OutboxRepository
+	l          logger.Logger
+	cfg        config.Outbox
+	senders    map[string]MessageSender
+	box        *secretbox.Box
+	wake       chan struct{}
+	now        func() time.Time
+}
+
+// NewOutboxUsecase stores message bodies sealed with box: they can hold
+// one-time codes and links, which must not be readable from the database.
+func NewOutboxUsecase(repository repositories.OutboxRepository, l logger.Logger, cfg config.Outbox, senders map[string]MessageSender, box *secretbox.Box) OutboxUsecase {
+	return &outbox{
+		repository: repository,
+		l:          l,
+		cfg:        cfg,
+		senders:    senders,
+		box:        box,
+		wake:       make(chan struct{}, 1),
+		now:        time.Now,
+	}
+}
+
+// Enqueue stores message for delivery by the worker pool. Enqueueing a
+// message whose DedupeKey was seen before is a no-op.
+func (o *outbox) Enqueue(ctx context.Context, message entities.OutboxMessage) error {
+	if _, ok := o.senders[message.Channel]; !ok {
+		return fmt.Errorf("no sender for channel %q", message.Channel)
+	}
+	if message.MaxAttempts == 0 {
+		message.MaxAttempts = o.cfg.MaxAttempts
+	}
+	var err error
+	if message.Body, err = o.box.Seal(message.Body, message.DedupeKey); err != nil {
+		return err
+	}
+
+	inserted, err := o.repository.Enqueue(ctx, message)
+	if err != nil {
+		return err
+	}
+	if !inserted {
+		o.l.Info("outbox message %s already queued", message.DedupeKey)
+		return nil
+	}
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run delivers due messages with cfg.Workers workers until ctx is done. Several
+// instances may run against the same database.
+func (o *outbox) Run(ctx context.Context) {
+	jobs := make(chan entities.OutboxMessage)
+	var wg sync.WaitGroup
+	for i := 0; i < o.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for message := range jobs {
+				o.deliver(ctx, message)
+			}
+		}()
+	}
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	poll := time.NewTicker(o.cfg.PollInterval)
+	defer poll.Stop()
+	purge := time.NewTicker(outboxPurgeEvery)
+	defer purge.Stop()
+
+	for {
+		o.dispatch(ctx, jobs)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+		case <-o.wake:
+		case <-purge.C:
+			o.purge(ctx)
+		}
+	}
+}
+
+// dispatch hands claimed messages to the workers until nothing is due.
+func (o *outbox) dispatch(ctx context.Context, jobs chan<- entities.OutboxMessage) {
+	for ctx.Err() == nil {
+		messages, err := o.repository.Claim(ctx, o.cfg.Workers, o.cfg.LeaseTimeout)
+		if err != nil {
+			o.l.Error("failed to claim outbox messages: %v", err)
+			return
+		}
+		for _, message := range messages {
+			select {
+			case jobs <- message:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if len(messages) < o.cfg.Workers {
+			return
+		}
+	}
+}
+
+func (o *outbox) deliver(ctx context.Context, message entities.OutboxMessage) {
+	if message.ExpiresAt != nil && !o.now().Before(*message.ExpiresAt) {
+		o.markDead(ctx, message, "expired before delivery")
+		return
+	}
+	sender, ok := o.senders[message.Channel]
+	if !ok {
+		o.markDead(ctx, message, fmt.Sprintf("no sender for channel %q", message.Channel))
+		return
+	}
+
+	body, err := o.box.Open(message.Body, message.DedupeKey)
+	if err != nil {
+		o.markDead(ctx, message, "message body cannot be decrypted")
+		return
+	}
+	message.Body = body
+
+	sendCtx, cancel := context.WithTimeout(ctx, o.cfg.SendTimeout)
+	delivery, err := sender.Send(sendCtx, message)
+	cancel()
+
+	if err == nil {
+		if err := o.repository.MarkSent(ctx, message.ID, delivery); err != nil {
+			o.l.Error("failed to mark outbox message %d as sent: %v", message.ID, err)
+		}
+		return
+	}
+
+	if message.Attempts >= message.MaxAttempts {
+		o.markDead(ctx, message, err.Error())
+		return
+	}
+	next := o.now().Add(o.backoff(message.Attempts))
+	o.l.Warn("delivery of outbox message %d failed (attempt %d/%d), retrying at %s: %v",
+		message.ID, message.Attempts, message.MaxAttempts, next.Format(time.RFC3339), err)
+	if err := o.repository.MarkFailed(ctx, message.ID, err.Error(), next); err != nil {
+		o.l.Error("failed to reschedule outbox message %d: %v", message.ID, err)
+	}
+}
+
+func (o *outbox) markDead(ctx context.Context, message entities.OutboxMessage, reason string) {
+	o.l.Error("outbox message %d to %s dead-lettered after %d attempts: %s", message.ID, message.Recipient, message.Attempts, reason)
+	if err := o.repository.MarkDead(ctx, message.ID, reason); err != nil {
+		o.l.Error("failed to dead-letter outbox message %d: %v", message.ID, err)
+	}
+}
+
+// backoff doubles the delay with every attempt, capped at BackoffMax, and
+// adds up to 20% jitter so retries of a failing gateway spread out.
+func (o *outbox) backoff(attempt int) time.Duration {
+	delay := o.cfg.BackoffMax
+	if attempt < 32 {
+		delay = min(o.cfg.BackoffBase<<(attempt-1), o.cfg.BackoffMax)
+	}
+	return delay + time.Duration(rand.Int64N(int64(delay)/5+1))
+}
+
+func (o *outbox) purge(ctx context.Context) {
+	deleted, err := o.repository.Purge(ctx, o.now().Add(-o.cfg.Retention))
+	if err != nil {
+		o.l.Error("failed to purge outbox: %v", err)
+		return
+	}
+	if deleted > 0 {
+		o.l.Info("purged %d finished outbox messages", deleted)
+	}
+}
+
+func (o *outbox) ListMessages(ctx context.Context, status entities.OutboxStatus, limit uint32) ([]entities.OutboxMessage, error) {
+	return o.repository.List(ctx, status, limit)
+}
+
+func (o *outbox) Stats(ctx context.Context) (entities.OutboxStats, error) {
+	return o.repository.Stats(ctx)
+}
+
+func (o *outbox) Retry(ctx context.Context, id int64) error {
+	err := o.repository.Requeue(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrOutboxMessageNotRetryable
+	}
+	if err != nil {
+		return err
+	}
+
+	o.l.Info("outbox message %d requeued", id)
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (o *outbox) ListByDedupeKey(ctx context.Context, dedupeKey string) ([]entities.OutboxMessage, error) {
+	return o.repository.ListByDedupeKey(ctx, dedupeKey)
+}
+
+// RecordDeliveryReport stores a provider's delivery report on the message it
+// sent. Reports can arrive before the worker has stored the provider's message
+// ID; they come back as ErrDeliveryReportUnmatched so the provider retries.
+func (o *outbox) RecordDeliveryReport(ctx context.Context, provider string, report dto.DeliveryReportDTO) error {
+	if err := utils.ValidateStruct(report); err != nil {
+		return err
+	}
+	reportedAt := o.now()
+	if report.Timestamp != nil {
+		reportedAt = *report.Timestamp
+	}
+
+	matched, err := o.repository.RecordDelivery(ctx, entities.DeliveryReport{
+		Provider:          provider,
+		ProviderMessageID: report.MessageID,
+		Status:            entities.DeliveryStatus(report.Status),
+		ErrorCode:         report.ErrorCode,
+		ReportedAt:        reportedAt,
+	})
+	if err != nil {
+		return err
+	}
+	if !matched {
+		return ErrDeliveryReportUnmatched
+	}
+
+	sms.RecordDeliveryReport(provider, report.Status)
+	switch entities.DeliveryStatus(report.Status) {
+	case entities.DeliveryStatusAccepted, entities.DeliveryStatusDelivered:
+	default:
+		o.l.Warn("%s reports message %s as %s (error code %q)", provider, report.MessageID, report.Status, report.ErrorCode)
+	}
+	return nil
+}
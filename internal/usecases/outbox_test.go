@@ -0,0 +1,244 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/config"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories/mockrepositories"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/usecases/mockusecases"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/secretbox"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func testOutboxConfig() config.Outbox {
+	return config.Outbox{
+		Workers:      2,
+		PollInterval: 10 * time.Millisecond,
+		MaxAttempts:  3,
+		BackoffBase:  time.Second,
+		BackoffMax:   10 * time.Second,
+		SendTimeout:  time.Second,
+		LeaseTimeout: time.Minute,
+		Retention:    time.Hour,
+	}
+}
+
+func newTestOutbox(t *testing.T) (*outbox, *mockrepositories.MockOutboxRepository, *mockusecases.MockMessageSender) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	mockLogger := &MockLogger{}
+	mockLogger.On("Info", mock.Anything, mock.Anything).Maybe()
+	mockLogger.On("Warn", mock.Anything, mock.Anything).Maybe()
+	mockLogger.On("Error", mock.Anything, mock.Anything).Maybe()
+
+	repo := mockrepositories.NewMockOutboxRepository(ctrl)
+	sender := mockusecases.NewMockMessageSender(ctrl)
+	box, err := secretbox.New("test-outbox-body-key")
+	require.NoError(t, err)
+	o := NewOutboxUsecase(repo, mockLogger, testOutboxConfig(), map[string]MessageSender{entities.ChannelSMS: sender}, box).(*outbox)
+	return o, repo, sender
+}
+
+// sealed returns message as the repository stores it.
+func sealed(t *testing.T, o *outbox, message entities.OutboxMessage) entities.OutboxMessage {
+	var err error
+	message.Body, err = o.box.Seal(message.Body, message.DedupeKey)
+	require.NoError(t, err)
+	return message
+}
+
+func TestOutboxUsecase_Enqueue(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("applies the default attempt budget", func(t *testing.T) {
+		o, repo, _ := newTestOutbox(t)
+		repo.EXPECT().Enqueue(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, message entities.OutboxMessage) (bool, error) {
+			assert.Equal(t, 3, message.MaxAttempts)
+			assert.NotContains(t, message.Body, "12345", "bodies are stored encrypted")
+			body, err := o.box.Open(message.Body, "otp:1")
+			require.NoError(t, err)
+			assert.Equal(t, "12345", body)
+			return true, nil
+		})
+
+		err := o.Enqueue(ctx, entities.OutboxMessage{DedupeKey: "otp:1", Channel: entities.ChannelSMS, Recipient: "+1234567890", Body: "12345"})
+		require.NoError(t, err)
+		assert.Len(t, o.wake, 1, "workers are woken up")
+	})
+
+	t.Run("duplicates are ignored", func(t *testing.T) {
+		o, repo, _ := newTestOutbox(t)
+		repo.EXPECT().Enqueue(ctx, gomock.Any()).Return(false, nil)
+
+		require.NoError(t, o.Enqueue(ctx, entities.OutboxMessage{DedupeKey: "otp:1", Channel: entities.ChannelSMS}))
+		assert.Empty(t, o.wake)
+	})
+
+	t.Run("unknown channel", func(t *testing.T) {
+		o, _, _ := newTestOutbox(t)
+		err := o.Enqueue(ctx, entities.OutboxMessage{DedupeKey: "otp:1", Channel: "pigeon"})
+		assert.ErrorContains(t, err, "no sender")
+	})
+}
+
+func TestOutboxUsecase_Deliver(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	message := func(attempts int) entities.OutboxMessage {
+		expiresAt := now.Add(time.Minute)
+		return entities.OutboxMessage{ID: 7, DedupeKey: "otp:7", Channel: entities.ChannelSMS, Recipient: "+1234567890", Body: "12345", Attempts: attempts, MaxAttempts: 3, ExpiresAt: &expiresAt}
+	}
+
+	t.Run("delivered messages are marked sent", func(t *testing.T) {
+		o, repo, sender := newTestOutbox(t)
+		o.now = func() time.Time { return now }
+		delivery := entities.Delivery{Provider: "primary", ProviderMessageID: "msg-1"}
+		sender.EXPECT().Send(gomock.Any(), message(1)).Return(delivery, nil)
+		repo.EXPECT().MarkSent(ctx, int64(7), delivery).Return(nil)
+
+		o.deliver(ctx, sealed(t, o, message(1)))
+	})
+
+	t.Run("bodies that do not decrypt are dead-lettered without sending", func(t *testing.T) {
+		o, repo, _ := newTestOutbox(t)
+		o.now = func() time.Time { return now }
+		repo.EXPECT().MarkDead(ctx, int64(7), "message body cannot be decrypted").Return(nil)
+
+		o.deliver(ctx, message(1))
+	})
+
+	t.Run("failures are retried with exponential backoff", func(t *testing.T) {
+		o, repo, sender := newTestOutbox(t)
+		o.now = func() time.Time { return now }
+		sender.EXPECT().Send(gomock.Any(), gomock.Any()).Return(entities.Delivery{}, errors.New("gateway timeout"))
+		repo.EXPECT().MarkFailed(ctx, int64(7), "gateway timeout", gomock.Any()).DoAndReturn(
+			func(_ context.Context, _ int64, _ string, next time.Time) error {
+				delay := next.Sub(now)
+				assert.GreaterOrEqual(t, delay, 2*time.Second)
+				assert.LessOrEqual(t, delay, 2400*time.Millisecond)
+				return nil
+			})
+
+		o.deliver(ctx, sealed(t, o, message(2)))
+	})
+
+	t.Run("the last failed attempt dead-letters the message", func(t *testing.T) {
+		o, repo, sender := newTestOutbox(t)
+		o.now = func() time.Time { return now }
+		sender.EXPECT().Send(gomock.Any(), gomock.Any()).Return(entities.Delivery{}, errors.New("invalid number"))
+		repo.EXPECT().MarkDead(ctx, int64(7), "invalid number").Return(nil)
+
+		o.deliver(ctx, sealed(t, o, message(3)))
+	})
+
+	t.Run("expired messages are dead-lettered without sending", func(t *testing.T) {
+		o, repo, _ := newTestOutbox(t)
+		o.now = func() time.Time { return now.Add(time.Hour) }
+		repo.EXPECT().MarkDead(ctx, int64(7), "expired before delivery").Return(nil)
+
+		o.deliver(ctx, sealed(t, o, message(1)))
+	})
+}
+
+func TestOutboxUsecase_Backoff(t *testing.T) {
+	o, _, _ := newTestOutbox(t)
+	for attempt, base := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 80: 10 * time.Second} {
+		delay := o.backoff(attempt)
+		assert.GreaterOrEqual(t, delay, base, "attempt %d", attempt)
+		assert.LessOrEqual(t, delay, base+base/5, "attempt %d", attempt)
+	}
+}
+
+func TestOutboxUsecase_Run(t *testing.T) {
+	o, repo, sender := newTestOutbox(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	queued := entities.OutboxMessage{ID: 1, DedupeKey: "otp:1", Channel: entities.ChannelSMS, Recipient: "+1234567890", Body: "12345", Attempts: 1, MaxAttempts: 3}
+	repo.EXPECT().Claim(gomock.Any(), 2, time.Minute).Return([]entities.OutboxMessage{sealed(t, o, queued)}, nil)
+	repo.EXPECT().Claim(gomock.Any(), 2, time.Minute).Return(nil, nil).AnyTimes()
+	sender.EXPECT().Send(gomock.Any(), queued).Return(entities.Delivery{Provider: "console"}, nil)
+	delivered := make(chan struct{})
+	repo.EXPECT().MarkSent(gomock.Any(), int64(1), entities.Delivery{Provider: "console"}).DoAndReturn(func(context.Context, int64, entities.Delivery) error {
+		close(delivered)
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		o.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-delivered:
+	case <-time.After(time.Second):
+		t.Fatal("message was not delivered")
+	}
+	cancel()
+	<-done
+}
+
+func TestOutboxUsecase_Retry(t *testing.T) {
+	ctx := context.Background()
+	o, repo, _ := newTestOutbox(t)
+
+	repo.EXPECT().Requeue(ctx, int64(1)).Return(nil)
+	require.NoError(t, o.Retry(ctx, 1))
+
+	repo.EXPECT().Requeue(ctx, int64(2)).Return(pgx.ErrNoRows)
+	assert.ErrorIs(t, o.Retry(ctx, 2), ErrOutboxMessageNotRetryable)
+}
+
+func TestOutboxUsecase_RecordDeliveryReport(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("stores the report on the matching message", func(t *testing.T) {
+		o, repo, _ := newTestOutbox(t)
+		o.now = func() time.Time { return now }
+		repo.EXPECT().RecordDelivery(ctx, entities.DeliveryReport{
+			Provider:          "primary",
+			ProviderMessageID: "msg-1",
+			Status:            entities.DeliveryStatusUndelivered,
+			ErrorCode:         "absent_subscriber",
+			ReportedAt:        now,
+		}).Return(true, nil)
+
+		require.NoError(t, o.RecordDeliveryReport(ctx, "primary", dto.DeliveryReportDTO{MessageID: "msg-1", Status: "undelivered", ErrorCode: "absent_subscriber"}))
+	})
+
+	t.Run("keeps the carrier's timestamp", func(t *testing.T) {
+		o, repo, _ := newTestOutbox(t)
+		reportedAt := now.Add(-time.Minute)
+		repo.EXPECT().RecordDelivery(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, report entities.DeliveryReport) (bool, error) {
+			assert.Equal(t, reportedAt, report.ReportedAt)
+			return true, nil
+		})
+
+		require.NoError(t, o.RecordDeliveryReport(ctx, "primary", dto.DeliveryReportDTO{MessageID: "msg-1", Status: "delivered", Timestamp: &reportedAt}))
+	})
+
+	t.Run("unknown message ids are reported", func(t *testing.T) {
+		o, repo, _ := newTestOutbox(t)
+		repo.EXPECT().RecordDelivery(ctx, gomock.Any()).Return(false, nil)
+
+		assert.ErrorIs(t, o.RecordDeliveryReport(ctx, "primary", dto.DeliveryReportDTO{MessageID: "msg-9", Status: "delivered"}), ErrDeliveryReportUnmatched)
+	})
+
+	t.Run("unknown statuses are rejected", func(t *testing.T) {
+		o, _, _ := newTestOutbox(t)
+
+		assert.Error(t, o.RecordDeliveryReport(ctx, "primary", dto.DeliveryReportDTO{MessageID: "msg-1", Status: "lost"}))
+	})
+}
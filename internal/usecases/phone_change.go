@@ -0,0 +1,161 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrPhoneTaken                     = errors.New("phone number is already registered")
+	ErrSamePhone                      = errors.New("new phone number is the same as the current one")
+	ErrPhoneChangeFallbackUnavailable = errors.New("replacing a lost phone number needs a verified email address on the account")
+	errPhoneChanged                   = errors.New("phone number changed during verification, start over")
+)
+
+type phoneChange struct {
+	userRepository repositories.UserRepository
+	refreshTokens  repositories.RefreshTokenRepository
+	otpUsecase     OtpUsecase
+	jwtUsecase     JwtUsecase
+}
+
+func NewPhoneChangeUsecase(userRepository repositories.UserRepository, refreshTokens repositories.RefreshTokenRepository, otpUsecase OtpUsecase, jwtUsecase JwtUsecase) PhoneChangeUsecase {
+	return &phoneChange{
+		userRepository: userRepository,
+		refreshTokens:  refreshTokens,
+		otpUsecase:     otpUsecase,
+		jwtUsecase:     jwtUsecase,
+	}
+}
+
+// RequestOtp opens one challenge for the current number, or for the verified
+// email when the number was lost, and one for the new number. Both count
+// against the OTP rate limits before either code is sent.
+func (p *phoneChange) RequestOtp(ctx context.Context, userID uint32, req dto.RequestPhoneChangeDTO) (entities.PhoneChangeStatus, error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return entities.PhoneChangeStatus{}, err
+	}
+	user, err := p.userRepository.GetUserById(ctx, userID)
+	if err != nil {
+		return entities.PhoneChangeStatus{}, err
+	}
+	if req.NewPhone == user.Phone {
+		return entities.PhoneChangeStatus{}, ErrSamePhone
+	}
+	if err := p.checkPhoneFree(ctx, req.NewPhone); err != nil {
+		return entities.PhoneChangeStatus{}, err
+	}
+
+	channel := req.Channel
+	if channel == "" {
+		channel = entities.ChannelSMS
+	}
+	if err := p.otpUsecase.ValidateChannel(channel); err != nil {
+		return entities.PhoneChangeStatus{}, err
+	}
+	current := entities.OtpChallenge{
+		Purpose:   entities.OtpPurposePhoneChange,
+		Phone:     user.Phone,
+		DeviceID:  req.DeviceID,
+		RequestIP: req.Client.IP,
+		Channel:   channel,
+		Platform:  req.Platform,
+	}
+	currentRecipient := user.Phone
+	if req.OldPhoneLost {
+		if !user.EmailVerified {
+			return entities.PhoneChangeStatus{}, ErrPhoneChangeFallbackUnavailable
+		}
+		current.Channel = entities.ChannelEmail
+		currentRecipient = user.Email
+	}
+	next := current
+	next.Phone = req.NewPhone
+	next.Channel = channel
+
+	// Neither number is charged unless both may get a code.
+	if err := p.otpUsecase.CheckRateLimit(ctx, current, next); err != nil {
+		return entities.PhoneChangeStatus{}, err
+	}
+	var status entities.PhoneChangeStatus
+	if status.Current, err = issueOtp(ctx, p.otpUsecase, current, currentRecipient); err != nil {
+		return entities.PhoneChangeStatus{}, err
+	}
+	if status.New, err = issueOtp(ctx, p.otpUsecase, next, req.NewPhone); err != nil {
+		return entities.PhoneChangeStatus{}, err
+	}
+	return status, nil
+}
+
+// Verify checks both codes, moves the account to the new number and revokes
+// every token issued so far. The caller gets a fresh pair of tokens, in a new
+// session like after a login.
+func (p *phoneChange) Verify(ctx context.Context, userID uint32, req dto.VerifyPhoneChangeDTO) (entities.AuthTokens, error) {
+	req.CurrentOTP = strings.TrimSpace(req.CurrentOTP)
+	req.NewOTP = strings.TrimSpace(req.NewOTP)
+	if err := utils.ValidateStruct(req); err != nil {
+		return entities.AuthTokens{}, err
+	}
+	for _, code := range []string{req.CurrentOTP, req.NewOTP} {
+		if err := p.otpUsecase.ValidateFormat(entities.OtpPurposePhoneChange, code); err != nil {
+			return entities.AuthTokens{}, err
+		}
+	}
+	user, err := p.userRepository.GetUserById(ctx, userID)
+	if err != nil {
+		return entities.AuthTokens{}, err
+	}
+	if req.NewPhone == user.Phone {
+		return entities.AuthTokens{}, ErrSamePhone
+	}
+
+	current := entities.OtpChallenge{
+		ID:       req.CurrentChallengeID,
+		Purpose:  entities.OtpPurposePhoneChange,
+		Phone:    user.Phone,
+		DeviceID: req.DeviceID,
+	}
+	next := current
+	next.ID = req.NewChallengeID
+	next.Phone = req.NewPhone
+	// a typo in one code must not use up the other challenge
+	err = p.otpUsecase.VerifyOTPs(ctx,
+		entities.OtpAnswer{Challenge: current, Code: req.CurrentOTP},
+		entities.OtpAnswer{Challenge: next, Code: req.NewOTP},
+	)
+	if err != nil {
+		return entities.AuthTokens{}, err
+	}
+
+	user, err = p.userRepository.ChangePhone(ctx, user.Id, user.Phone, req.NewPhone)
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == "users_phone_key":
+		return entities.AuthTokens{}, ErrPhoneTaken
+	case errors.Is(err, pgx.ErrNoRows):
+		return entities.AuthTokens{}, errPhoneChanged
+	case err != nil:
+		return entities.AuthTokens{}, err
+	}
+	return issueTokens(ctx, p.jwtUsecase, p.refreshTokens, user.Id, "", req.DeviceID, req.Client)
+}
+
+func (p *phoneChange) checkPhoneFree(ctx context.Context, phone string) error {
+	_, err := p.userRepository.GetUserByPhone(ctx, phone)
+	switch {
+	case err == nil:
+		return ErrPhoneTaken
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil
+	default:
+		return err
+	}
+}
@@ -0,0 +1,194 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories/mockrepositories"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/usecases/mockusecases"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/ratelimit"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func phoneChangeChallenge(id, phone, channel string) entities.OtpChallenge {
+	return entities.OtpChallenge{
+		ID:        id,
+		Purpose:   entities.OtpPurposePhoneChange,
+		Phone:     phone,
+		DeviceID:  "device-a",
+		RequestIP: "10.0.0.1",
+		Channel:   channel,
+	}
+}
+
+func TestPhoneChangeUsecase_RequestOtp(t *testing.T) {
+	ctx := context.Background()
+	user := entities.User{Id: 7, Phone: "+1000000001", Email: "ada@example.com"}
+	req := dto.RequestPhoneChangeDTO{NewPhone: "+1000000002", DeviceID: "device-a", Client: dto.ClientInfo{IP: "10.0.0.1"}}
+
+	setup := func(t *testing.T) (PhoneChangeUsecase, *mockrepositories.MockUserRepository, *mockusecases.MockOtpUsecase) {
+		ctrl := gomock.NewController(t)
+		repo := mockrepositories.NewMockUserRepository(ctrl)
+		otp := mockusecases.NewMockOtpUsecase(ctrl)
+		return NewPhoneChangeUsecase(repo, mockrepositories.NewMockRefreshTokenRepository(ctrl), otp, mockusecases.NewMockJwtUsecase(ctrl)), repo, otp
+	}
+	expectSent := func(otp *mockusecases.MockOtpUsecase, challenge entities.OtpChallenge, recipient, code string) {
+		saved := challenge
+		challenge.ID = ""
+		otp.EXPECT().GenerateOTP(entities.OtpPurposePhoneChange).Return(code, nil)
+		otp.EXPECT().SaveOTP(ctx, challenge, code).Return(saved.ID, nil)
+		otp.EXPECT().SendOTP(ctx, saved, recipient, code).Return(nil)
+		otp.EXPECT().GetStatus(ctx, saved).Return(entities.OtpStatus{ChallengeID: saved.ID, Active: true, Channel: saved.Channel}, nil)
+	}
+
+	t.Run("sends codes to both numbers", func(t *testing.T) {
+		p, repo, otp := setup(t)
+		repo.EXPECT().GetUserById(ctx, uint32(7)).Return(user, nil)
+		repo.EXPECT().GetUserByPhone(ctx, "+1000000002").Return(entities.User{}, pgx.ErrNoRows)
+		otp.EXPECT().ValidateChannel(entities.ChannelSMS).Return(nil)
+		otp.EXPECT().CheckRateLimit(ctx, gomock.Any(), gomock.Any()).Return(nil)
+		expectSent(otp, phoneChangeChallenge("current-1", "+1000000001", entities.ChannelSMS), "+1000000001", "11111")
+		expectSent(otp, phoneChangeChallenge("new-1", "+1000000002", entities.ChannelSMS), "+1000000002", "22222")
+
+		status, err := p.RequestOtp(ctx, 7, req)
+		require.NoError(t, err)
+		assert.Equal(t, "current-1", status.Current.ChallengeID)
+		assert.Equal(t, "new-1", status.New.ChallengeID)
+	})
+
+	t.Run("lost number falls back to the verified email", func(t *testing.T) {
+		p, repo, otp := setup(t)
+		verified := user
+		verified.EmailVerified = true
+		lost := req
+		lost.OldPhoneLost = true
+		repo.EXPECT().GetUserById(ctx, uint32(7)).Return(verified, nil)
+		repo.EXPECT().GetUserByPhone(ctx, "+1000000002").Return(entities.User{}, pgx.ErrNoRows)
+		otp.EXPECT().ValidateChannel(entities.ChannelSMS).Return(nil)
+		otp.EXPECT().CheckRateLimit(ctx, gomock.Any(), gomock.Any()).Return(nil)
+		expectSent(otp, phoneChangeChallenge("current-1", "+1000000001", entities.ChannelEmail), "ada@example.com", "11111")
+		expectSent(otp, phoneChangeChallenge("new-1", "+1000000002", entities.ChannelSMS), "+1000000002", "22222")
+
+		status, err := p.RequestOtp(ctx, 7, lost)
+		require.NoError(t, err)
+		assert.Equal(t, entities.ChannelEmail, status.Current.Channel)
+	})
+
+	t.Run("lost number needs a verified email", func(t *testing.T) {
+		p, repo, otp := setup(t)
+		lost := req
+		lost.OldPhoneLost = true
+		repo.EXPECT().GetUserById(ctx, uint32(7)).Return(user, nil)
+		repo.EXPECT().GetUserByPhone(ctx, "+1000000002").Return(entities.User{}, pgx.ErrNoRows)
+		otp.EXPECT().ValidateChannel(entities.ChannelSMS).Return(nil)
+
+		_, err := p.RequestOtp(ctx, 7, lost)
+		assert.ErrorIs(t, err, ErrPhoneChangeFallbackUnavailable)
+	})
+
+	t.Run("new number must be free and different", func(t *testing.T) {
+		p, repo, _ := setup(t)
+		repo.EXPECT().GetUserById(ctx, uint32(7)).Return(user, nil).Times(2)
+		repo.EXPECT().GetUserByPhone(ctx, "+1000000002").Return(entities.User{Id: 8, Phone: "+1000000002"}, nil)
+
+		_, err := p.RequestOtp(ctx, 7, req)
+		assert.ErrorIs(t, err, ErrPhoneTaken)
+
+		same := req
+		same.NewPhone = user.Phone
+		_, err = p.RequestOtp(ctx, 7, same)
+		assert.ErrorIs(t, err, ErrSamePhone)
+	})
+
+	t.Run("no code is sent when a rate limit is hit", func(t *testing.T) {
+		p, repo, otp := setup(t)
+		repo.EXPECT().GetUserById(ctx, uint32(7)).Return(user, nil)
+		repo.EXPECT().GetUserByPhone(ctx, "+1000000002").Return(entities.User{}, pgx.ErrNoRows)
+		otp.EXPECT().ValidateChannel(entities.ChannelSMS).Return(nil)
+		otp.EXPECT().CheckRateLimit(ctx, gomock.Any(), gomock.Any()).Return(&ratelimit.ExceededError{})
+
+		_, err := p.RequestOtp(ctx, 7, req)
+		var exceeded *ratelimit.ExceededError
+		assert.ErrorAs(t, err, &exceeded)
+	})
+}
+
+func TestPhoneChangeUsecase_Verify(t *testing.T) {
+	ctx := context.Background()
+	user := entities.User{Id: 7, Phone: "+1000000001"}
+	req := dto.VerifyPhoneChangeDTO{
+		CurrentChallengeID: "current-1",
+		CurrentOTP:         "11111",
+		NewChallengeID:     "new-1",
+		NewPhone:           "+1000000002",
+		NewOTP:             "22222",
+		DeviceID:           "device-a",
+	}
+	current := entities.OtpChallenge{ID: "current-1", Purpose: entities.OtpPurposePhoneChange, Phone: "+1000000001", DeviceID: "device-a"}
+	next := entities.OtpChallenge{ID: "new-1", Purpose: entities.OtpPurposePhoneChange, Phone: "+1000000002", DeviceID: "device-a"}
+
+	setup := func(t *testing.T) (PhoneChangeUsecase, *mockrepositories.MockUserRepository, *mockusecases.MockOtpUsecase, *mockusecases.MockJwtUsecase) {
+		ctrl := gomock.NewController(t)
+		repo := mockrepositories.NewMockUserRepository(ctrl)
+		otp := mockusecases.NewMockOtpUsecase(ctrl)
+		jwt := mockusecases.NewMockJwtUsecase(ctrl)
+		otp.EXPECT().ValidateFormat(entities.OtpPurposePhoneChange, gomock.Any()).Return(nil).AnyTimes()
+		repo.EXPECT().GetUserById(ctx, uint32(7)).Return(user, nil)
+		return NewPhoneChangeUsecase(repo, storeRefreshTokens(ctrl), otp, jwt), repo, otp, jwt
+	}
+
+	t.Run("changes the phone and issues new tokens", func(t *testing.T) {
+		p, repo, otp, jwt := setup(t)
+		otp.EXPECT().VerifyOTPs(ctx, entities.OtpAnswer{Challenge: current, Code: "11111"}, entities.OtpAnswer{Challenge: next, Code: "22222"}).Return(nil)
+		repo.EXPECT().ChangePhone(ctx, uint32(7), "+1000000001", "+1000000002").Return(entities.User{Id: 7, Phone: "+1000000002"}, nil)
+		jwt.EXPECT().GenerateToken(entities.JwtPayload{UserId: 7}).Return("new-token", nil)
+		jwt.EXPECT().GenerateRefreshToken(refreshPayload(7)).Return("new-refresh-token", nil)
+
+		tokens, err := p.Verify(ctx, 7, req)
+		require.NoError(t, err)
+		assert.Equal(t, entities.AuthTokens{AccessToken: "new-token", RefreshToken: "new-refresh-token"}, tokens)
+	})
+
+	t.Run("both codes are checked together", func(t *testing.T) {
+		p, _, otp, _ := setup(t)
+		otp.EXPECT().VerifyOTPs(ctx, entities.OtpAnswer{Challenge: current, Code: "11111"}, entities.OtpAnswer{Challenge: next, Code: "22222"}).Return(errInvalidOtp)
+
+		_, err := p.Verify(ctx, 7, req)
+		assert.ErrorIs(t, err, errInvalidOtp)
+	})
+
+	t.Run("new number taken in the meantime", func(t *testing.T) {
+		p, repo, otp, _ := setup(t)
+		otp.EXPECT().VerifyOTPs(ctx, gomock.Any(), gomock.Any()).Return(nil)
+		repo.EXPECT().ChangePhone(ctx, uint32(7), "+1000000001", "+1000000002").
+			Return(entities.User{}, &pgconn.PgError{Code: uniqueViolation, ConstraintName: "users_phone_key"})
+
+		_, err := p.Verify(ctx, 7, req)
+		assert.ErrorIs(t, err, ErrPhoneTaken)
+	})
+
+	t.Run("phone changed concurrently", func(t *testing.T) {
+		p, repo, otp, _ := setup(t)
+		otp.EXPECT().VerifyOTPs(ctx, gomock.Any(), gomock.Any()).Return(nil)
+		repo.EXPECT().ChangePhone(ctx, uint32(7), "+1000000001", "+1000000002").Return(entities.User{}, pgx.ErrNoRows)
+
+		_, err := p.Verify(ctx, 7, req)
+		assert.ErrorIs(t, err, errPhoneChanged)
+	})
+
+	t.Run("database errors pass through", func(t *testing.T) {
+		p, repo, otp, _ := setup(t)
+		otp.EXPECT().VerifyOTPs(ctx, gomock.Any(), gomock.Any()).Return(nil)
+		repo.EXPECT().ChangePhone(ctx, uint32(7), "+1000000001", "+1000000002").Return(entities.User{}, errors.New("connection reset"))
+
+		_, err := p.Verify(ctx, 7, req)
+		assert.EqualError(t, err, "connection reset")
+	})
+}
@@ -0,0 +1,83 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/logger"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/mailer"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/sms"
+)
+
+type consoleSender struct {
+	l       logger.Logger
+	channel string
+}
+
+// NewConsoleSender prints messages for channel to the log instead of
+// delivering them. It stands in for a real provider during development.
+func NewConsoleSender(l logger.Logger, channel string) MessageSender {
+	return &consoleSender{l: l, channel: channel}
+}
+
+func (s *consoleSender) Send(_ context.Context, message entities.OutboxMessage) (entities.Delivery, error) {
+	switch s.channel {
+	case entities.ChannelSMS:
+		s.l.Info(fmt.Sprintf("Sending OTP %s to phone number %s", message.Body, message.Recipient))
+	case entities.ChannelVoice:
+		s.l.Info(fmt.Sprintf("Calling %s: %q", message.Recipient, voiceScript(message.Body)))
+	case entities.ChannelEmail:
+		s.l.Info(fmt.Sprintf("Emailing OTP %s to %s", message.Body, message.Recipient))
+	default:
+		s.l.Info(fmt.Sprintf("Sending OTP %s to %s via %s", message.Body, message.Recipient, s.channel))
+	}
+	return entities.Delivery{Provider: "console"}, nil
+}
+
+type smsSender struct {
+	router *sms.Router
+}
+
+// NewSmsSender delivers SMS through the router's gateways, failing over
+// between them.
+func NewSmsSender(router *sms.Router) MessageSender {
+	return &smsSender{router: router}
+}
+
+func (s *smsSender) Send(ctx context.Context, message entities.OutboxMessage) (entities.Delivery, error) {
+	delivery, err := s.router.Send(ctx, message.Recipient, message.Body)
+	if err != nil {
+		return entities.Delivery{}, err
+	}
+	return entities.Delivery{Provider: delivery.Provider, ProviderMessageID: delivery.MessageID}, nil
+}
+
+type emailSender struct {
+	mailer mailer.Mailer
+}
+
+// NewEmailSender emails OTP codes to the account's verified address.
+func NewEmailSender(m mailer.Mailer) MessageSender {
+	return &emailSender{mailer: m}
+}
+
+func (s *emailSender) Send(ctx context.Context, message entities.OutboxMessage) (entities.Delivery, error) {
+	err := s.mailer.Send(ctx, mailer.Message{
+		To:      message.Recipient,
+		Subject: "Your verification code",
+		Text:    fmt.Sprintf("Your verification code is %s\n\nIf you did not request this code, ignore this email.\n", message.Body),
+	})
+	if err != nil {
+		return entities.Delivery{}, err
+	}
+	return entities.Delivery{Provider: "email"}, nil
+}
+
+// voiceScript spells the code out character by character, twice, so a
+// text-to-speech engine reads "1 2 3" rather than "one hundred twenty-three".
+func voiceScript(code string) string {
+	spelled := strings.Join(strings.Split(code, ""), ", ")
+	return fmt.Sprintf("Your verification code is %s. Again, your code is %s.", spelled, spelled)
+}
@@ -0,0 +1,78 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/mailer"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/sms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsoleSender(t *testing.T) {
+	message := entities.OutboxMessage{Recipient: "+1234567890", Body: "123"}
+
+	t.Run("voice calls spell the code out", func(t *testing.T) {
+		mockLogger := &MockLogger{}
+		mockLogger.On("Info", `Calling +1234567890: "Your verification code is 1, 2, 3. Again, your code is 1, 2, 3."`, mock.Anything).Once()
+
+		delivery, err := NewConsoleSender(mockLogger, entities.ChannelVoice).Send(context.Background(), message)
+		require.NoError(t, err)
+		assert.Equal(t, "console", delivery.Provider)
+		mockLogger.AssertExpectations(t)
+	})
+
+	t.Run("every channel has a sender", func(t *testing.T) {
+		mockLogger := &MockLogger{}
+		mockLogger.On("Info", mock.Anything, mock.Anything)
+		for _, channel := range entities.Channels {
+			_, err := NewConsoleSender(mockLogger, channel).Send(context.Background(), message)
+			assert.NoError(t, err, channel)
+		}
+		assert.Len(t, mockLogger.Calls, len(entities.Channels))
+	})
+}
+
+type fakeProvider struct {
+	name string
+	err  error
+}
+
+func (p fakeProvider) Name() string { return p.name }
+
+func (p fakeProvider) Send(context.Context, string, string) (string, error) {
+	return p.name + "-1", p.err
+}
+
+func TestSmsSender(t *testing.T) {
+	router, err := sms.NewRouter([]sms.Provider{
+		fakeProvider{name: "sender-test-primary", err: errors.New("gateway down")},
+		fakeProvider{name: "sender-test-backup"},
+	}, nil, sms.RouterOptions{BreakerFailures: 3, BreakerCooldown: time.Minute})
+	require.NoError(t, err)
+
+	delivery, err := NewSmsSender(router).Send(context.Background(), entities.OutboxMessage{Recipient: "+1234567890", Body: "123"})
+	require.NoError(t, err)
+	assert.Equal(t, entities.Delivery{Provider: "sender-test-backup", ProviderMessageID: "sender-test-backup-1"}, delivery)
+}
+
+func TestEmailSender(t *testing.T) {
+	fake := mailer.NewFake()
+	delivery, err := NewEmailSender(fake).Send(context.Background(), entities.OutboxMessage{Recipient: "ada@example.com", Body: "12345"})
+	require.NoError(t, err)
+	assert.Equal(t, "email", delivery.Provider)
+
+	message, ok := fake.Last("ada@example.com")
+	require.True(t, ok)
+	assert.Equal(t, "Your verification code", message.Subject)
+	assert.Contains(t, message.Text, "12345")
+
+	fake.Err = errors.New("relay down")
+	_, err = NewEmailSender(fake).Send(context.Background(), entities.OutboxMessage{Recipient: "ada@example.com", Body: "12345"})
+	assert.EqualError(t, err, "relay down")
+}
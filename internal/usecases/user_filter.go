@@ -0,0 +1,165 @@
+package usecases
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/filterql"
+)
+
+// maxFilterTerms bounds the size of the query one filter can produce.
+const maxFilterTerms = 20
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+var phoneFilterPattern = regexp.MustCompile(`^\+?[0-9]{1,20}$`)
+
+// userFilter builds the filter for listings and exports.
+func userFilter(req dto.UserFilterDTO) (entities.UserFilter, error) {
+	conditions, err := parseUserFilter(req.Filter)
+	if err != nil {
+		return entities.UserFilter{}, err
+	}
+	// search, created_from and created_to predate filter and are kept as
+	// shorthands for it.
+	if search := strings.TrimSpace(req.Search); search != "" {
+		conditions = append(conditions, entities.UserCondition{Field: entities.UserFieldPhone, Op: entities.UserFilterContains, Value: search})
+	}
+	if req.CreatedFrom != nil {
+		conditions = append(conditions, entities.UserCondition{Field: entities.UserFieldCreatedAt, Op: entities.UserFilterGte, Value: *req.CreatedFrom})
+	}
+	if req.CreatedTo != nil {
+		conditions = append(conditions, entities.UserCondition{Field: entities.UserFieldCreatedAt, Op: entities.UserFilterLte, Value: *req.CreatedTo})
+	}
+	return entities.UserFilter{Conditions: conditions}, nil
+}
+
+// parseUserFilter turns a filter expression such as
+// `status:active created_at>2025-01-01 phone:^98912` into conditions.
+//
+//   - phone, email and display_name match a substring, or a prefix when the
+//     value starts with ^. A phone prefix matches with or without a leading +.
+//   - locale, timezone and status match the whole value.
+//   - email_verified and is_test take true or false.
+//   - created_at takes >, >=, < or <= and a date (2025-01-01) or an RFC 3339
+//     time. A date covers the whole day in UTC, so created_at<=2025-01-01
+//     includes users created that day.
+//
+// A leading - negates a term.
+func parseUserFilter(expr string) ([]entities.UserCondition, error) {
+	terms, err := filterql.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+	}
+	if len(terms) > maxFilterTerms {
+		return nil, fmt.Errorf("%w: more than %d terms", ErrInvalidFilter, maxFilterTerms)
+	}
+	var conditions []entities.UserCondition
+	for _, term := range terms {
+		condition, err := userCondition(term)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidFilter, term, err)
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions, nil
+}
+
+func userCondition(term filterql.Term) (entities.UserCondition, error) {
+	condition := entities.UserCondition{
+		Field:   entities.UserFilterField(term.Field),
+		Op:      entities.UserFilterEq,
+		Negated: term.Negated,
+	}
+	if condition.Field != entities.UserFieldCreatedAt && term.Op != filterql.OpEq {
+		return entities.UserCondition{}, fmt.Errorf("%s only supports :", term.Field)
+	}
+
+	switch condition.Field {
+	case entities.UserFieldPhone, entities.UserFieldEmail, entities.UserFieldDisplayName:
+		value, prefix := strings.CutPrefix(term.Value, "^")
+		condition.Op = entities.UserFilterContains
+		if prefix {
+			condition.Op = entities.UserFilterPrefix
+		}
+		switch {
+		case value == "":
+			return entities.UserCondition{}, errors.New("empty value")
+		case condition.Field == entities.UserFieldPhone && !phoneFilterPattern.MatchString(value):
+			return entities.UserCondition{}, errors.New("phone takes digits with an optional leading +")
+		case len(value) > 254:
+			return entities.UserCondition{}, errors.New("value is too long")
+		}
+		condition.Value = value
+
+	case entities.UserFieldLocale, entities.UserFieldTimezone:
+		if term.Value == "" || len(term.Value) > 64 {
+			return entities.UserCondition{}, errors.New("value must have 1 to 64 characters")
+		}
+		condition.Value = term.Value
+
+	case entities.UserFieldStatus:
+		switch status := entities.UserStatus(term.Value); status {
+		case entities.UserStatusActive, entities.UserStatusSuspended, entities.UserStatusBanned:
+			condition.Value = string(status)
+		default:
+			return entities.UserCondition{}, errors.New("status is active, suspended or banned")
+		}
+
+	case entities.UserFieldEmailVerified, entities.UserFieldIsTest:
+		value, err := strconv.ParseBool(term.Value)
+		if err != nil {
+			return entities.UserCondition{}, fmt.Errorf("%s is true or false", term.Field)
+		}
+		condition.Value = value
+
+	case entities.UserFieldCreatedAt:
+		return createdAtCondition(term)
+
+	default:
+		return entities.UserCondition{}, errors.New("unknown field, use phone, email, email_verified, display_name, locale, timezone, status, is_test or created_at")
+	}
+	return condition, nil
+}
+
+func createdAtCondition(term filterql.Term) (entities.UserCondition, error) {
+	condition := entities.UserCondition{Field: entities.UserFieldCreatedAt, Negated: term.Negated}
+	at, err := time.Parse(time.RFC3339, term.Value)
+	day := false
+	if err != nil {
+		if at, err = time.Parse(time.DateOnly, term.Value); err != nil {
+			return entities.UserCondition{}, errors.New("created_at takes a date (2025-01-01) or an RFC 3339 time")
+		}
+		day = true
+	}
+
+	switch term.Op {
+	case filterql.OpGt:
+		condition.Op = entities.UserFilterGt
+	case filterql.OpGte:
+		condition.Op = entities.UserFilterGte
+	case filterql.OpLt:
+		condition.Op = entities.UserFilterLt
+	case filterql.OpLte:
+		condition.Op = entities.UserFilterLte
+	default:
+		return entities.UserCondition{}, errors.New("created_at supports >, >=, < and <=")
+	}
+	// A date stands for the whole day: after it means from the next day on.
+	if day && (condition.Op == entities.UserFilterGt || condition.Op == entities.UserFilterLte) {
+		at = at.AddDate(0, 0, 1)
+		if condition.Op == entities.UserFilterGt {
+			condition.Op = entities.UserFilterGte
+		} else {
+			condition.Op = entities.UserFilterLt
+		}
+	}
+	condition.Value = at
+	return condition, nil
+}
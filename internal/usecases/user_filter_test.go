@@ -0,0 +1,72 @@
+package usecases
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUserFilter(t *testing.T) {
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	nextDay := day.AddDate(0, 0, 1)
+	at := time.Date(2025, 1, 1, 12, 30, 0, 0, time.FixedZone("", 3*3600+1800))
+
+	for expr, want := range map[string]entities.UserCondition{
+		"phone:98912":                          {Field: entities.UserFieldPhone, Op: entities.UserFilterContains, Value: "98912"},
+		"phone:^+98912":                        {Field: entities.UserFieldPhone, Op: entities.UserFilterPrefix, Value: "+98912"},
+		"email:^ada@":                          {Field: entities.UserFieldEmail, Op: entities.UserFilterPrefix, Value: "ada@"},
+		`display_name:"Ada L"`:                 {Field: entities.UserFieldDisplayName, Op: entities.UserFilterContains, Value: "Ada L"},
+		"locale:fa-IR":                         {Field: entities.UserFieldLocale, Op: entities.UserFilterEq, Value: "fa-IR"},
+		"timezone:Asia/Tehran":                 {Field: entities.UserFieldTimezone, Op: entities.UserFilterEq, Value: "Asia/Tehran"},
+		"-status:banned":                       {Field: entities.UserFieldStatus, Op: entities.UserFilterEq, Value: "banned", Negated: true},
+		"email_verified:false":                 {Field: entities.UserFieldEmailVerified, Op: entities.UserFilterEq, Value: false},
+		"is_test:true":                         {Field: entities.UserFieldIsTest, Op: entities.UserFilterEq, Value: true},
+		"created_at>=2025-01-01":               {Field: entities.UserFieldCreatedAt, Op: entities.UserFilterGte, Value: day},
+		"created_at<2025-01-01":                {Field: entities.UserFieldCreatedAt, Op: entities.UserFilterLt, Value: day},
+		"created_at>2025-01-01":                {Field: entities.UserFieldCreatedAt, Op: entities.UserFilterGte, Value: nextDay},
+		"created_at<=2025-01-01":               {Field: entities.UserFieldCreatedAt, Op: entities.UserFilterLt, Value: nextDay},
+		"created_at>2025-01-01T12:30:00+03:30": {Field: entities.UserFieldCreatedAt, Op: entities.UserFilterGt, Value: at},
+	} {
+		conditions, err := parseUserFilter(expr)
+		require.NoError(t, err, expr)
+		require.Len(t, conditions, 1, expr)
+		got := conditions[0]
+		if want.Field == entities.UserFieldCreatedAt {
+			assert.True(t, want.Value.(time.Time).Equal(got.Value.(time.Time)), expr)
+			got.Value = want.Value
+		}
+		assert.Equal(t, want, got, expr)
+	}
+
+	conditions, err := parseUserFilter("status:active created_at>2025-01-01 phone:^98912")
+	require.NoError(t, err)
+	assert.Len(t, conditions, 3)
+
+	conditions, err = parseUserFilter("")
+	require.NoError(t, err)
+	assert.Empty(t, conditions)
+}
+
+func TestParseUserFilterErrors(t *testing.T) {
+	for expr, want := range map[string]string{
+		"status:":               "expected a value at position 7",
+		"password:secret":       "password:secret: unknown field",
+		"status:frozen":         "status is active, suspended or banned",
+		"status>active":         "status only supports :",
+		`phone:"98912' OR 1=1"`: "phone takes digits",
+		"phone:^":               "empty value",
+		"email_verified:maybe":  "email_verified is true or false",
+		"created_at:2025-01-01": "created_at supports >, >=, < and <=",
+		"created_at>yesterday":  "created_at takes a date",
+		"created_at>2025-13-01": "created_at takes a date",
+		strings.Repeat("is_test:true ", maxFilterTerms+1): "more than 20 terms",
+	} {
+		_, err := parseUserFilter(expr)
+		assert.ErrorIs(t, err, ErrInvalidFilter, expr)
+		assert.ErrorContains(t, err, want, expr)
+	}
+}
@@ -0,0 +1,438 @@
+package usecases
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/utils"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	// userTransferBatch is how many users an export reads, and an import
+	// checks and inserts, at a time.
+	userTransferBatch = 500
+	// maxImportRowErrors caps the errors listed in an import report; the
+	// counts stay exact.
+	maxImportRowErrors = 1000
+	maxImportLineSize  = 64 * 1024
+)
+
+var ErrInvalidImport = errors.New("invalid import file")
+
+// userExportColumns are the CSV columns, and the NDJSON keys, of an export.
+// An import reads the ones in userImportColumns.
+var userExportColumns = []string{
+	"id", "phone", "email", "email_verified", "display_name", "locale", "timezone",
+	"status", "status_reason", "suspended_until", "is_test", "created_at", "updated_at",
+}
+
+var userImportColumns = []string{"phone", "email", "display_name", "locale", "timezone"}
+
+var phoneSeparators = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "")
+
+var normalizedPhonePattern = regexp.MustCompile(`^\+?[0-9]{7,19}$`)
+
+// csvFormulaPrefixes start a cell that spreadsheets would run as a formula.
+const csvFormulaPrefixes = "=+-@\t\r"
+
+var plainPhonePattern = regexp.MustCompile(`^\+[0-9]+$`)
+
+type userRecord struct {
+	ID             uint32     `json:"id"`
+	Phone          string     `json:"phone"`
+	Email          string     `json:"email,omitempty"`
+	EmailVerified  bool       `json:"email_verified"`
+	DisplayName    string     `json:"display_name,omitempty"`
+	Locale         string     `json:"locale,omitempty"`
+	Timezone       string     `json:"timezone,omitempty"`
+	Status         string     `json:"status"`
+	StatusReason   string     `json:"status_reason,omitempty"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+	IsTest         bool       `json:"is_test"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func newUserRecord(u entities.User) userRecord {
+	return userRecord{
+		ID:             u.Id,
+		Phone:          u.Phone,
+		Email:          u.Email,
+		EmailVerified:  u.EmailVerified,
+		DisplayName:    u.DisplayName,
+		Locale:         u.Locale,
+		Timezone:       u.Timezone,
+		Status:         string(u.Status),
+		StatusReason:   u.StatusReason,
+		SuspendedUntil: u.SuspendedUntil,
+		IsTest:         u.IsTest,
+		CreatedAt:      u.CreatedAt.UTC(),
+		UpdatedAt:      u.UpdatedAt.UTC(),
+	}
+}
+
+func (r userRecord) csv() []string {
+	suspendedUntil := ""
+	if r.SuspendedUntil != nil {
+		suspendedUntil = r.SuspendedUntil.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		strconv.FormatUint(uint64(r.ID), 10), csvEscape(r.Phone), csvEscape(r.Email), strconv.FormatBool(r.EmailVerified),
+		csvEscape(r.DisplayName), csvEscape(r.Locale), csvEscape(r.Timezone), r.Status, csvEscape(r.StatusReason), suspendedUntil,
+		strconv.FormatBool(r.IsTest), r.CreatedAt.Format(time.RFC3339), r.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// csvEscape prefixes a cell that a spreadsheet would run as a formula with
+// ', which makes it text. Phone numbers such as +98912... are numbers, not
+// formulas, and are left alone so exports import again as they are.
+func csvEscape(cell string) string {
+	if cell == "" || !strings.ContainsRune(csvFormulaPrefixes, rune(cell[0])) || plainPhonePattern.MatchString(cell) {
+		return cell
+	}
+	return "'" + cell
+}
+
+// csvUnescape undoes csvEscape.
+func csvUnescape(cell string) string {
+	if rest, ok := strings.CutPrefix(cell, "'"); ok && rest != "" && strings.ContainsRune(csvFormulaPrefixes, rune(rest[0])) {
+		return rest
+	}
+	return cell
+}
+
+type userTransfer struct {
+	userRepository repositories.UserRepository
+}
+
+func NewUserTransferUsecase(userRepository repositories.UserRepository) UserTransferUsecase {
+	return &userTransfer{userRepository: userRepository}
+}
+
+// Export writes the users matching the filter to w, a batch at a time, so
+// memory use does not grow with the number of users. The filter is checked
+// before anything is written. If w can Flush, every batch is flushed.
+func (t *userTransfer) Export(ctx context.Context, w io.Writer, req dto.ExportUsersDTO) error {
+	if err := utils.ValidateStruct(req); err != nil {
+		return err
+	}
+	filter, err := userFilter(req.UserFilterDTO)
+	if err != nil {
+		return err
+	}
+
+	var write func(entities.User) error
+	var flush func() error
+	if req.Format == "ndjson" {
+		encoder := json.NewEncoder(w)
+		write = func(u entities.User) error { return encoder.Encode(newUserRecord(u)) }
+		flush = func() error { return nil }
+	} else {
+		cw := csv.NewWriter(w)
+		if err := cw.Write(userExportColumns); err != nil {
+			return err
+		}
+		write = func(u entities.User) error { return cw.Write(newUserRecord(u).csv()) }
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	}
+
+	query := entities.UserQuery{UserFilter: filter, Sort: entities.UserSortID, Limit: userTransferBatch}
+	for {
+		users, err := t.userRepository.ListUsers(ctx, query)
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			if err := write(u); err != nil {
+				return err
+			}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		if flusher, ok := w.(interface{ Flush() }); ok {
+			flusher.Flush()
+		}
+		if len(users) < userTransferBatch {
+			return nil
+		}
+		query.After = &entities.UserCursor{Sort: entities.UserSortID, ID: users[len(users)-1].Id}
+	}
+}
+
+// importRow is a row read from an import file, or the error reading it.
+type importRow struct {
+	n    int
+	user dto.ImportUserDTO
+	err  error
+}
+
+// Import creates a user for every valid row whose phone and email are not
+// registered yet. Phones are normalized before they are compared, and rows
+// repeating an earlier row's phone or email are skipped. Rows are checked and
+// inserted a batch at a time; on a dry run nothing is inserted. Batches are
+// committed as they go, so when the import stops on an error the report
+// covers the rows read up to then, including the users already created.
+func (t *userTransfer) Import(ctx context.Context, r io.Reader, req dto.ImportUsersDTO) (entities.UserImportReport, error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return entities.UserImportReport{}, err
+	}
+	next, err := importReader(r, req.Format)
+	if err != nil {
+		return entities.UserImportReport{}, err
+	}
+
+	report := entities.UserImportReport{DryRun: req.DryRun, Errors: []entities.UserImportRowError{}}
+	seenPhones := make(map[string]int)
+	seenEmails := make(map[string]int)
+	batch := make([]importRow, 0, userTransferBatch)
+	for {
+		row, ok, err := next()
+		if err != nil {
+			return report, err
+		}
+		if ok {
+			report.Rows++
+			if row.err == nil {
+				row.err = normalizeImportRow(&row.user)
+			}
+			if row.err != nil {
+				addImportError(&report, row, false)
+				continue
+			}
+			row.err = firstSeen(seenPhones, row.user.Phone, row.n, "phone")
+			if row.err == nil && row.user.Email != "" {
+				row.err = firstSeen(seenEmails, row.user.Email, row.n, "email")
+			}
+			if row.err != nil {
+				addImportError(&report, row, true)
+				continue
+			}
+			batch = append(batch, row)
+		}
+		if len(batch) == userTransferBatch || !ok && len(batch) > 0 {
+			if err := t.importBatch(ctx, batch, &report); err != nil {
+				return report, err
+			}
+			batch = batch[:0]
+		}
+		if !ok {
+			return report, nil
+		}
+	}
+}
+
+func (t *userTransfer) importBatch(ctx context.Context, batch []importRow, report *entities.UserImportReport) error {
+	phones := make([]string, 0, len(batch))
+	emails := make([]string, 0, len(batch))
+	for _, row := range batch {
+		phones = append(phones, row.user.Phone)
+		if row.user.Email != "" {
+			emails = append(emails, row.user.Email)
+		}
+	}
+	takenPhones, takenEmails, err := t.userRepository.TakenPhonesAndEmails(ctx, phones, emails)
+	if err != nil {
+		return err
+	}
+
+	users := make([]entities.User, 0, len(batch))
+	rows := make([]importRow, 0, len(batch))
+	for _, row := range batch {
+		switch {
+		case slices.Contains(takenPhones, row.user.Phone):
+			row.err = errors.New("phone is already registered")
+			addImportError(report, row, true)
+		case row.user.Email != "" && slices.Contains(takenEmails, row.user.Email):
+			row.err = ErrEmailTaken
+			addImportError(report, row, true)
+		default:
+			users = append(users, entities.User{
+				Phone:       row.user.Phone,
+				Email:       row.user.Email,
+				DisplayName: row.user.DisplayName,
+				Locale:      row.user.Locale,
+				Timezone:    row.user.Timezone,
+			})
+			rows = append(rows, row)
+		}
+	}
+	if report.DryRun || len(users) == 0 {
+		report.Created += len(users)
+		return nil
+	}
+
+	created, err := t.userRepository.CreateUsers(ctx, users)
+	if err != nil {
+		return err
+	}
+	report.Created += len(created)
+	// Rows registered between the check and the insert are skipped.
+	for _, row := range rows {
+		if !slices.ContainsFunc(created, func(u entities.User) bool { return u.Phone == row.user.Phone }) {
+			row.err = errors.New("phone or email was registered during the import")
+			addImportError(report, row, true)
+		}
+	}
+	return nil
+}
+
+func addImportError(report *entities.UserImportReport, row importRow, skipped bool) {
+	if skipped {
+		report.Skipped++
+	} else {
+		report.Failed++
+	}
+	if len(report.Errors) < maxImportRowErrors {
+		report.Errors = append(report.Errors, entities.UserImportRowError{
+			Row:     row.n,
+			Phone:   row.user.Phone,
+			Skipped: skipped,
+			Error:   row.err.Error(),
+		})
+	}
+}
+
+func firstSeen(seen map[string]int, key string, row int, what string) error {
+	if first, ok := seen[key]; ok {
+		return fmt.Errorf("same %s as row %d", what, first)
+	}
+	seen[key] = row
+	return nil
+}
+
+func normalizeImportRow(user *dto.ImportUserDTO) error {
+	phone, err := normalizePhone(user.Phone)
+	if err != nil {
+		return err
+	}
+	user.Phone = phone
+	user.Email = strings.ToLower(strings.TrimSpace(user.Email))
+	user.DisplayName = strings.TrimSpace(user.DisplayName)
+	user.Locale = strings.TrimSpace(user.Locale)
+	user.Timezone = strings.TrimSpace(user.Timezone)
+
+	err = utils.ValidateStruct(*user)
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		// Name the column, not the Go field.
+		name := validationErrs[0].Field()
+		if f, ok := reflect.TypeOf(*user).FieldByName(validationErrs[0].StructField()); ok {
+			name = f.Tag.Get("json")
+		}
+		return fmt.Errorf("invalid %s", name)
+	}
+	return err
+}
+
+// normalizePhone drops the separators people put in phone numbers and turns
+// an international 00 prefix into +.
+func normalizePhone(phone string) (string, error) {
+	normalized := phoneSeparators.Replace(strings.TrimSpace(phone))
+	if rest, ok := strings.CutPrefix(normalized, "00"); ok {
+		normalized = "+" + rest
+	}
+	if !normalizedPhonePattern.MatchString(normalized) {
+		return "", fmt.Errorf("invalid phone number %q", phone)
+	}
+	return normalized, nil
+}
+
+// importReader returns a function yielding the rows of r one at a time, and
+// false once r is exhausted. Rows that cannot be parsed carry their error;
+// a file that cannot be read on is an ErrInvalidImport, and rows imported up
+// to then are kept.
+func importReader(r io.Reader, format string) (func() (importRow, bool, error), error) {
+	if format == "ndjson" {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 4096), maxImportLineSize)
+		n := 0
+		return func() (importRow, bool, error) {
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				if line == "" {
+					continue
+				}
+				n++
+				row := importRow{n: n}
+				decoder := json.NewDecoder(strings.NewReader(line))
+				decoder.DisallowUnknownFields()
+				if err := decoder.Decode(&row.user); err != nil {
+					row.err = fmt.Errorf("invalid JSON: %v", err)
+				}
+				return row, true, nil
+			}
+			if err := scanner.Err(); err != nil {
+				return importRow{}, false, fmt.Errorf("%w: line after row %d: %v", ErrInvalidImport, n, err)
+			}
+			return importRow{}, false, nil
+		}, nil
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot read the CSV header: %v", ErrInvalidImport, err)
+	}
+	columns := make([]int, len(userImportColumns))
+	for i, name := range userImportColumns {
+		columns[i] = slices.Index(header, name)
+	}
+	if columns[0] < 0 {
+		return nil, fmt.Errorf("%w: the CSV header has no phone column", ErrInvalidImport)
+	}
+	for _, name := range header {
+		if !slices.Contains(userImportColumns, name) {
+			return nil, fmt.Errorf("%w: unknown CSV column %q, use %s", ErrInvalidImport, name, strings.Join(userImportColumns, ", "))
+		}
+	}
+
+	n := 0
+	return func() (importRow, bool, error) {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return importRow{}, false, nil
+		}
+		n++
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return importRow{n: n, err: parseErr.Err}, true, nil
+		}
+		if err != nil {
+			return importRow{}, false, fmt.Errorf("%w: row %d: %v", ErrInvalidImport, n, err)
+		}
+		field := func(i int) string {
+			if columns[i] < 0 || columns[i] >= len(record) {
+				return ""
+			}
+			return csvUnescape(record[columns[i]])
+		}
+		return importRow{n: n, user: dto.ImportUserDTO{
+			Phone:       field(0),
+			Email:       field(1),
+			DisplayName: field(2),
+			Locale:      field(3),
+			Timezone:    field(4),
+		}}, true, nil
+	}, nil
+}
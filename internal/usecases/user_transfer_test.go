@@ -0,0 +1,269 @@
+package usecases
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories/mockrepositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestUserTransfer(t *testing.T) (UserTransferUsecase, *mockrepositories.MockUserRepository) {
+	ctrl := gomock.NewController(t)
+	repo := mockrepositories.NewMockUserRepository(ctrl)
+	return NewUserTransferUsecase(repo), repo
+}
+
+// flushRecorder counts flushes, like an HTTP response writer.
+type flushRecorder struct {
+	bytes.Buffer
+	flushes int
+}
+
+func (f *flushRecorder) Flush() { f.flushes++ }
+
+func TestUserTransfer_Export(t *testing.T) {
+	ctx := context.Background()
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	user := entities.User{
+		Id:            7,
+		Phone:         "+989121234567",
+		Email:         "ada@example.com",
+		EmailVerified: true,
+		DisplayName:   "Ada, L",
+		Status:        entities.UserStatusActive,
+		CreatedAt:     createdAt,
+		UpdatedAt:     createdAt,
+	}
+
+	t.Run("csv pages through users in batches", func(t *testing.T) {
+		transfer, repo := newTestUserTransfer(t)
+		batch := make([]entities.User, userTransferBatch)
+		for i := range batch {
+			batch[i] = entities.User{Id: uint32(i + 1), Phone: fmt.Sprintf("+1%09d", i+1), CreatedAt: createdAt, UpdatedAt: createdAt}
+		}
+		filter := entities.UserFilter{Conditions: []entities.UserCondition{{Field: entities.UserFieldStatus, Op: entities.UserFilterEq, Value: "active"}}}
+		gomock.InOrder(
+			repo.EXPECT().ListUsers(ctx, entities.UserQuery{UserFilter: filter, Sort: entities.UserSortID, Limit: userTransferBatch}).Return(batch, nil),
+			repo.EXPECT().ListUsers(ctx, entities.UserQuery{
+				UserFilter: filter,
+				Sort:       entities.UserSortID,
+				After:      &entities.UserCursor{Sort: entities.UserSortID, ID: userTransferBatch},
+				Limit:      userTransferBatch,
+			}).Return([]entities.User{user}, nil),
+		)
+
+		var out flushRecorder
+		err := transfer.Export(ctx, &out, dto.ExportUsersDTO{UserFilterDTO: dto.UserFilterDTO{Filter: "status:active"}})
+		require.NoError(t, err)
+		assert.Equal(t, 2, out.flushes)
+
+		records, err := csv.NewReader(&out).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, userTransferBatch+2)
+		assert.Equal(t, userExportColumns, records[0])
+		assert.Equal(t, []string{
+			"7", "+989121234567", "ada@example.com", "true", "Ada, L", "", "", "active", "", "", "false",
+			"2025-01-02T03:04:05Z", "2025-01-02T03:04:05Z",
+		}, records[len(records)-1])
+	})
+
+	t.Run("csv cells that look like formulas are escaped", func(t *testing.T) {
+		transfer, repo := newTestUserTransfer(t)
+		hostile := user
+		hostile.DisplayName = `=HYPERLINK("http://evil.example","x")`
+		hostile.StatusReason = "@SUM(1)"
+		hostile.Locale = "-1+1"
+		repo.EXPECT().ListUsers(ctx, gomock.Any()).Return([]entities.User{hostile}, nil)
+
+		var out bytes.Buffer
+		require.NoError(t, transfer.Export(ctx, &out, dto.ExportUsersDTO{}))
+		records, err := csv.NewReader(&out).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, "+989121234567", records[1][1])
+		assert.Equal(t, `'=HYPERLINK("http://evil.example","x")`, records[1][4])
+		assert.Equal(t, "'-1+1", records[1][5])
+		assert.Equal(t, "'@SUM(1)", records[1][8])
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		transfer, repo := newTestUserTransfer(t)
+		repo.EXPECT().ListUsers(ctx, gomock.Any()).Return([]entities.User{user, {Id: 8, Phone: "+1000000008"}}, nil)
+
+		var out bytes.Buffer
+		require.NoError(t, transfer.Export(ctx, &out, dto.ExportUsersDTO{Format: "ndjson"}))
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 2)
+		assert.JSONEq(t, `{"id":7,"phone":"+989121234567","email":"ada@example.com","email_verified":true,"display_name":"Ada, L",
+			"status":"active","is_test":false,"created_at":"2025-01-02T03:04:05Z","updated_at":"2025-01-02T03:04:05Z"}`, lines[0])
+	})
+
+	t.Run("writes nothing for an invalid filter", func(t *testing.T) {
+		transfer, _ := newTestUserTransfer(t)
+
+		var out bytes.Buffer
+		err := transfer.Export(ctx, &out, dto.ExportUsersDTO{UserFilterDTO: dto.UserFilterDTO{Filter: "password:x"}})
+		assert.ErrorIs(t, err, ErrInvalidFilter)
+		assert.Zero(t, out.Len())
+	})
+}
+
+func TestUserTransfer_Import(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("normalizes, dedupes and reports every row", func(t *testing.T) {
+		transfer, repo := newTestUserTransfer(t)
+		file := strings.Join([]string{
+			"phone,email,display_name",
+			"0098 912 123-4567,Ada@Example.com,Ada",
+			"+98 (912) 123 4567,,Ada again",
+			"+989120000000,ada@example.com,",
+			"+989121111111,,Taken",
+			"not a phone,,",
+			"+989122222222,not-an-email,",
+			"+989123333333,eve@example.com,Eve",
+		}, "\n")
+		repo.EXPECT().TakenPhonesAndEmails(ctx,
+			[]string{"+989121234567", "+989121111111", "+989123333333"},
+			[]string{"ada@example.com", "eve@example.com"},
+		).Return([]string{"+989121111111"}, []string{"eve@example.com"}, nil)
+		repo.EXPECT().CreateUsers(ctx, []entities.User{{Phone: "+989121234567", Email: "ada@example.com", DisplayName: "Ada"}}).
+			Return([]entities.User{{Id: 1, Phone: "+989121234567"}}, nil)
+
+		report, err := transfer.Import(ctx, strings.NewReader(file), dto.ImportUsersDTO{})
+		require.NoError(t, err)
+		assert.Equal(t, entities.UserImportReport{
+			Rows:    7,
+			Created: 1,
+			Skipped: 4,
+			Failed:  2,
+			Errors: []entities.UserImportRowError{
+				{Row: 2, Phone: "+989121234567", Skipped: true, Error: "same phone as row 1"},
+				{Row: 3, Phone: "+989120000000", Skipped: true, Error: "same email as row 1"},
+				{Row: 5, Phone: "not a phone", Error: `invalid phone number "not a phone"`},
+				{Row: 6, Phone: "+989122222222", Error: "invalid email"},
+				{Row: 4, Phone: "+989121111111", Skipped: true, Error: "phone is already registered"},
+				{Row: 7, Phone: "+989123333333", Skipped: true, Error: ErrEmailTaken.Error()},
+			},
+		}, report)
+	})
+
+	t.Run("dry run creates nothing", func(t *testing.T) {
+		transfer, repo := newTestUserTransfer(t)
+		repo.EXPECT().TakenPhonesAndEmails(ctx, []string{"+989121234567"}, []string{}).Return(nil, nil, nil)
+
+		report, err := transfer.Import(ctx, strings.NewReader("phone\n+989121234567\n"), dto.ImportUsersDTO{DryRun: true})
+		require.NoError(t, err)
+		assert.Equal(t, entities.UserImportReport{DryRun: true, Rows: 1, Created: 1, Errors: []entities.UserImportRowError{}}, report)
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		transfer, repo := newTestUserTransfer(t)
+		file := `{"phone":"+989121234567","locale":"fa-IR","timezone":"Asia/Tehran"}
+
+{"phone":"+989120000000","password":"x"}
+{"phone":`
+		repo.EXPECT().TakenPhonesAndEmails(ctx, gomock.Any(), gomock.Any()).Return(nil, nil, nil)
+		repo.EXPECT().CreateUsers(ctx, []entities.User{{Phone: "+989121234567", Locale: "fa-IR", Timezone: "Asia/Tehran"}}).
+			Return([]entities.User{{Id: 1, Phone: "+989121234567"}}, nil)
+
+		report, err := transfer.Import(ctx, strings.NewReader(file), dto.ImportUsersDTO{Format: "ndjson"})
+		require.NoError(t, err)
+		assert.Equal(t, 3, report.Rows)
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 2, report.Failed)
+		assert.Contains(t, report.Errors[0].Error, `unknown field "password"`)
+	})
+
+	t.Run("users registered during the import are skipped", func(t *testing.T) {
+		transfer, repo := newTestUserTransfer(t)
+		repo.EXPECT().TakenPhonesAndEmails(ctx, gomock.Any(), gomock.Any()).Return(nil, nil, nil)
+		repo.EXPECT().CreateUsers(ctx, gomock.Any()).Return([]entities.User{{Id: 1, Phone: "+989121234567"}}, nil)
+
+		report, err := transfer.Import(ctx, strings.NewReader("phone\n+989121234567\n+989120000000\n"), dto.ImportUsersDTO{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 1, report.Skipped)
+	})
+
+	t.Run("inserts in batches", func(t *testing.T) {
+		transfer, repo := newTestUserTransfer(t)
+		var file strings.Builder
+		file.WriteString("phone\n")
+		for i := range userTransferBatch + 1 {
+			fmt.Fprintf(&file, "+1%09d\n", i)
+		}
+		repo.EXPECT().TakenPhonesAndEmails(ctx, gomock.Any(), gomock.Any()).Return(nil, nil, nil).Times(2)
+		repo.EXPECT().CreateUsers(ctx, gomock.Len(userTransferBatch)).Return(make([]entities.User, userTransferBatch), nil)
+		repo.EXPECT().CreateUsers(ctx, gomock.Len(1)).Return([]entities.User{{Phone: fmt.Sprintf("+1%09d", userTransferBatch)}}, nil)
+
+		report, err := transfer.Import(ctx, strings.NewReader(file.String()), dto.ImportUsersDTO{})
+		require.NoError(t, err)
+		assert.Equal(t, userTransferBatch+1, report.Rows)
+	})
+
+	t.Run("rejects files it cannot read", func(t *testing.T) {
+		for name, file := range map[string]string{
+			"empty":          "",
+			"no phone":       "email\nada@example.com\n",
+			"unknown column": "phone,password\n+989121234567,x\n",
+		} {
+			transfer, _ := newTestUserTransfer(t)
+			_, err := transfer.Import(ctx, strings.NewReader(file), dto.ImportUsersDTO{})
+			assert.ErrorIs(t, err, ErrInvalidImport, name)
+		}
+
+		transfer, _ := newTestUserTransfer(t)
+		_, err := transfer.Import(ctx, strings.NewReader(strings.Repeat("x", maxImportLineSize+1)), dto.ImportUsersDTO{Format: "ndjson"})
+		assert.ErrorIs(t, err, ErrInvalidImport, "line too long")
+	})
+
+	t.Run("database errors abort", func(t *testing.T) {
+		transfer, repo := newTestUserTransfer(t)
+		repo.EXPECT().TakenPhonesAndEmails(ctx, gomock.Any(), gomock.Any()).Return(nil, nil, errors.New("connection reset"))
+
+		_, err := transfer.Import(ctx, strings.NewReader("phone\n+989121234567\n"), dto.ImportUsersDTO{})
+		assert.EqualError(t, err, "connection reset")
+	})
+
+	t.Run("an abort reports the batches already created", func(t *testing.T) {
+		transfer, repo := newTestUserTransfer(t)
+		var file strings.Builder
+		file.WriteString("phone\n")
+		for i := range userTransferBatch + 1 {
+			fmt.Fprintf(&file, "+1%09d\n", i)
+		}
+		gomock.InOrder(
+			repo.EXPECT().TakenPhonesAndEmails(ctx, gomock.Any(), gomock.Any()).Return(nil, nil, nil),
+			repo.EXPECT().CreateUsers(ctx, gomock.Len(userTransferBatch)).Return(make([]entities.User, userTransferBatch), nil),
+			repo.EXPECT().TakenPhonesAndEmails(ctx, gomock.Any(), gomock.Any()).Return(nil, nil, errors.New("connection reset")),
+		)
+
+		report, err := transfer.Import(ctx, strings.NewReader(file.String()), dto.ImportUsersDTO{})
+		assert.EqualError(t, err, "connection reset")
+		assert.Equal(t, userTransferBatch+1, report.Rows)
+		assert.Equal(t, userTransferBatch, report.Created)
+	})
+
+	t.Run("formula escapes from an export are undone", func(t *testing.T) {
+		transfer, repo := newTestUserTransfer(t)
+		repo.EXPECT().TakenPhonesAndEmails(ctx, gomock.Any(), gomock.Any()).Return(nil, nil, nil)
+		repo.EXPECT().CreateUsers(ctx, []entities.User{{Phone: "+989121234567", DisplayName: "=1+1"}}).
+			Return([]entities.User{{Phone: "+989121234567"}}, nil)
+
+		report, err := transfer.Import(ctx, strings.NewReader("phone,display_name\n+989121234567,'=1+1\n"), dto.ImportUsersDTO{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Created)
+	})
+}
@@ -2,12 +2,56 @@ package usecases
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/pagination"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/utils"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// uniqueViolation is the SQLSTATE of a unique constraint violation.
+const uniqueViolation = "23505"
+
+const (
+	defaultUsersPageSize = 10
+	maxUsersPageSize     = 100
+)
+
+var (
+	ErrEmailTaken            = errors.New("email address is already in use")
+	ErrCannotChangeOwnStatus = errors.New("admins cannot change their own status")
+	ErrSuspensionInPast      = errors.New("suspended_until must be in the future")
+	ErrInvalidPageSize       = fmt.Errorf("limit must be between 1 and %d", maxUsersPageSize)
+)
+
+// UserBlockedError is returned when a suspended or banned user signs in or
+// uses a token.
+type UserBlockedError struct {
+	Status entities.UserStatus
+	Until  *time.Time
+}
+
+func (e *UserBlockedError) Error() string {
+	if e.Status == entities.UserStatusSuspended && e.Until != nil {
+		return fmt.Sprintf("account is suspended until %s", e.Until.UTC().Format(time.RFC3339))
+	}
+	return fmt.Sprintf("account is %s", e.Status)
+}
+
+// checkNotBlocked returns a *UserBlockedError for blocked users.
+func checkNotBlocked(user entities.User) error {
+	if !user.Blocked(time.Now()) {
+		return nil
+	}
+	return &UserBlockedError{Status: user.Status, Until: user.SuspendedUntil}
+}
+
 type usersUsecase struct {
 	usersRepo repositories.UserRepository
 }
@@ -22,13 +66,134 @@ func (u *usersUsecase) GetUser(ctx context.Context, id uint32) (entities.User, e
 	return u.usersRepo.GetUserById(ctx, id)
 }
 
-func (u *usersUsecase) GetAllUsers(ctx context.Context, page, limit uint32, phoneSearchTerm *string, creationFrom, creationTo *time.Time) ([]entities.User, error) {
-	if page == 0 {
-		page = 1
+func (u *usersUsecase) UpdateProfile(ctx context.Context, id uint32, req dto.UpdateProfileDTO) (entities.User, error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return entities.User{}, err
+	}
+	update := entities.UserProfileUpdate{
+		DisplayName: trimmed(req.DisplayName),
+		Email:       trimmed(req.Email),
+		Locale:      trimmed(req.Locale),
+		Timezone:    trimmed(req.Timezone),
+		AvatarURL:   trimmed(req.AvatarURL),
+	}
+	if update.Email != nil {
+		email := strings.ToLower(*update.Email)
+		update.Email = &email
+	}
+	user, err := u.usersRepo.UpdateProfile(ctx, id, update)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == "users_email_key" {
+		return entities.User{}, ErrEmailTaken
+	}
+	return user, err
+}
+
+// CheckStatusChange reports why actor may not make the status change, if at
+// all, so it can be refused before it is audited.
+func (u *usersUsecase) CheckStatusChange(actor entities.User, userID uint32, req dto.SetUserStatusDTO) error {
+	if err := utils.ValidateStruct(req); err != nil {
+		return err
+	}
+	if actor.Id == userID {
+		return ErrCannotChangeOwnStatus
+	}
+	if req.SuspendedUntil != nil && !req.SuspendedUntil.After(time.Now()) {
+		return ErrSuspensionInPast
+	}
+	return nil
+}
+
+// SetStatus changes a user's status on behalf of actor and records it in the
+// status history.
+func (u *usersUsecase) SetStatus(ctx context.Context, actor entities.User, userID uint32, req dto.SetUserStatusDTO) (entities.User, error) {
+	if err := u.CheckStatusChange(actor, userID, req); err != nil {
+		return entities.User{}, err
+	}
+	return u.usersRepo.SetStatus(ctx, entities.UserStatusChange{
+		UserID:         userID,
+		Status:         entities.UserStatus(req.Status),
+		Reason:         strings.TrimSpace(req.Reason),
+		SuspendedUntil: req.SuspendedUntil,
+		ActorID:        &actor.Id,
+	})
+}
+
+func (u *usersUsecase) StatusHistory(ctx context.Context, userID uint32) ([]entities.UserStatusChange, error) {
+	return u.usersRepo.ListStatusHistory(ctx, userID)
+}
+
+func trimmed(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	t := strings.TrimSpace(*s)
+	return &t
+}
+
+// ListUsers returns one page of users. Without a sort users are listed by id,
+// oldest first, as before cursors existed.
+func (u *usersUsecase) ListUsers(ctx context.Context, req dto.ListUsersDTO) (entities.Page[entities.User], error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return entities.Page[entities.User]{}, err
+	}
+	limit := defaultUsersPageSize
+	if req.Limit != nil {
+		limit = *req.Limit
+	}
+	if limit < 1 || limit > maxUsersPageSize {
+		return entities.Page[entities.User]{}, ErrInvalidPageSize
+	}
+	filter, err := userFilter(req.UserFilterDTO)
+	if err != nil {
+		return entities.Page[entities.User]{}, err
+	}
+	query := entities.UserQuery{
+		UserFilter: filter,
+		Sort:       entities.UserSortField(req.Sort),
+		Desc:       req.Order == "desc",
+		// One extra row tells whether there is a next page.
+		Limit: limit + 1,
+	}
+	if query.Sort == "" {
+		query.Sort = entities.UserSortID
+	}
+	if req.Cursor != "" {
+		var after entities.UserCursor
+		if err := pagination.DecodeCursor(req.Cursor, &after); err != nil {
+			return entities.Page[entities.User]{}, err
+		}
+		if after.Sort != query.Sort || after.Desc != query.Desc || (query.Sort == entities.UserSortCreatedAt && after.CreatedAt == nil) {
+			return entities.Page[entities.User]{}, pagination.ErrInvalidCursor
+		}
+		query.After = &after
+	}
+
+	users, err := u.usersRepo.ListUsers(ctx, query)
+	if err != nil {
+		return entities.Page[entities.User]{}, err
+	}
+	page := entities.Page[entities.User]{Items: users}
+	if len(users) > limit {
+		page.Items = users[:limit]
+		last := page.Items[limit-1]
+		cursor := entities.UserCursor{Sort: query.Sort, Desc: query.Desc, ID: last.Id}
+		switch query.Sort {
+		case entities.UserSortCreatedAt:
+			cursor.CreatedAt = &last.CreatedAt
+		case entities.UserSortPhone:
+			cursor.Phone = last.Phone
+		}
+		if page.NextCursor, err = pagination.EncodeCursor(cursor); err != nil {
+			return entities.Page[entities.User]{}, err
+		}
 	}
-	if limit == 0 {
-		limit = 10
+	if req.IncludeTotal {
+		total, err := u.usersRepo.CountUsers(ctx, query.UserFilter)
+		if err != nil {
+			return entities.Page[entities.User]{}, err
+		}
+		page.Total = &total
 	}
-	skip := (page - 1) * limit
-	return u.usersRepo.GetAllUsers(ctx, skip, limit, phoneSearchTerm, creationFrom, creationTo)
+	return page, nil
 }
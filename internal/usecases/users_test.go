@@ -3,11 +3,15 @@ package usecases
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/dto"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/entities"
 	"github.com/MostajeranMohammad/dekamond-auth-challenge/internal/repositories/mockrepositories"
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/pagination"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
@@ -91,7 +95,7 @@ func TestUsersUsecase_GetUser(t *testing.T) {
 	}
 }
 
-func TestUsersUsecase_GetAllUsers(t *testing.T) {
+func TestUsersUsecase_ListUsers(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
@@ -105,145 +109,121 @@ func TestUsersUsecase_GetAllUsers(t *testing.T) {
 	}
 
 	tests := []struct {
-		name          string
-		page          uint32
-		limit         uint32
-		phoneSearch   *string
-		creationFrom  *time.Time
-		creationTo    *time.Time
-		setupMock     func()
-		wantUsers     []entities.User
-		wantErr       bool
-		wantErrMsg    string
-		expectedSkip  uint32
-		expectedLimit uint32
+		name       string
+		req        dto.ListUsersDTO
+		setupMock  func()
+		wantPage   entities.Page[entities.User]
+		wantErr    bool
+		wantErrMsg string
 	}{
 		{
-			name:  "successful retrieval with default pagination",
-			page:  0, // Should default to 1
-			limit: 0, // Should default to 10
+			name: "defaults to ten users by id",
+			req:  dto.ListUsersDTO{},
 			setupMock: func() {
-				mockRepo.EXPECT().GetAllUsers(
-					gomock.Any(),
-					uint32(0),  // skip = (1-1) * 10 = 0
-					uint32(10), // default limit
-					(*string)(nil),
-					(*time.Time)(nil),
-					(*time.Time)(nil),
-				).Return(sampleUsers, nil)
+				mockRepo.EXPECT().ListUsers(gomock.Any(), entities.UserQuery{Sort: entities.UserSortID, Limit: 11}).Return(sampleUsers, nil)
 			},
-			wantUsers:     sampleUsers,
-			wantErr:       false,
-			expectedSkip:  0,
-			expectedLimit: 10,
+			wantPage: entities.Page[entities.User]{Items: sampleUsers},
 		},
 		{
-			name:  "successful retrieval with custom pagination",
-			page:  2,
-			limit: 5,
+			name: "with phone search and date range",
+			req: dto.ListUsersDTO{UserFilterDTO: dto.UserFilterDTO{
+				Search:      " +1234 ",
+				CreatedFrom: timePtr(now.Add(-2 * time.Hour)),
+				CreatedTo:   timePtr(now),
+			}},
 			setupMock: func() {
-				mockRepo.EXPECT().GetAllUsers(
-					gomock.Any(),
-					uint32(5), // skip = (2-1) * 5 = 5
-					uint32(5), // limit
-					(*string)(nil),
-					(*time.Time)(nil),
-					(*time.Time)(nil),
-				).Return(sampleUsers, nil)
+				mockRepo.EXPECT().ListUsers(gomock.Any(), entities.UserQuery{
+					UserFilter: entities.UserFilter{Conditions: []entities.UserCondition{
+						{Field: entities.UserFieldPhone, Op: entities.UserFilterContains, Value: "+1234"},
+						{Field: entities.UserFieldCreatedAt, Op: entities.UserFilterGte, Value: now.Add(-2 * time.Hour)},
+						{Field: entities.UserFieldCreatedAt, Op: entities.UserFilterLte, Value: now},
+					}},
+					Sort:  entities.UserSortID,
+					Limit: 11,
+				}).Return([]entities.User{sampleUsers[0]}, nil)
 			},
-			wantUsers:     sampleUsers,
-			wantErr:       false,
-			expectedSkip:  5,
-			expectedLimit: 5,
+			wantPage: entities.Page[entities.User]{Items: []entities.User{sampleUsers[0]}},
 		},
 		{
-			name:        "with phone search",
-			page:        1,
-			limit:       10,
-			phoneSearch: stringPtr("+1234"),
+			name: "with a filter expression",
+			req:  dto.ListUsersDTO{UserFilterDTO: dto.UserFilterDTO{Filter: "status:suspended -email_verified:true"}},
 			setupMock: func() {
-				mockRepo.EXPECT().GetAllUsers(
-					gomock.Any(),
-					uint32(0),
-					uint32(10),
-					stringPtr("+1234"),
-					(*time.Time)(nil),
-					(*time.Time)(nil),
-				).Return([]entities.User{sampleUsers[0]}, nil)
+				mockRepo.EXPECT().ListUsers(gomock.Any(), entities.UserQuery{
+					UserFilter: entities.UserFilter{Conditions: []entities.UserCondition{
+						{Field: entities.UserFieldStatus, Op: entities.UserFilterEq, Value: "suspended"},
+						{Field: entities.UserFieldEmailVerified, Op: entities.UserFilterEq, Value: true, Negated: true},
+					}},
+					Sort:  entities.UserSortID,
+					Limit: 11,
+				}).Return(sampleUsers, nil)
 			},
-			wantUsers: []entities.User{sampleUsers[0]},
-			wantErr:   false,
+			wantPage: entities.Page[entities.User]{Items: sampleUsers},
 		},
 		{
-			name:         "with date range",
-			page:         1,
-			limit:        10,
-			creationFrom: timePtr(now.Add(-2 * time.Hour)),
-			creationTo:   timePtr(now),
+			name:       "invalid filter",
+			req:        dto.ListUsersDTO{UserFilterDTO: dto.UserFilterDTO{Filter: "status:frozen"}},
+			setupMock:  func() {},
+			wantErr:    true,
+			wantErrMsg: "invalid filter: status:frozen: status is active, suspended or banned",
+		},
+		{
+			name: "a full page links to the next one",
+			req:  dto.ListUsersDTO{Limit: intPtr(1), Sort: "phone", Order: "desc"},
 			setupMock: func() {
-				mockRepo.EXPECT().GetAllUsers(
-					gomock.Any(),
-					uint32(0),
-					uint32(10),
-					(*string)(nil),
-					timePtr(now.Add(-2*time.Hour)),
-					timePtr(now),
-				).Return(sampleUsers, nil)
+				mockRepo.EXPECT().ListUsers(gomock.Any(), entities.UserQuery{Sort: entities.UserSortPhone, Desc: true, Limit: 2}).Return(sampleUsers, nil)
+			},
+			wantPage: entities.Page[entities.User]{
+				Items:      sampleUsers[:1],
+				NextCursor: mustCursor(entities.UserCursor{Sort: entities.UserSortPhone, Desc: true, Phone: "+1234567890", ID: 1}),
 			},
-			wantUsers: sampleUsers,
-			wantErr:   false,
 		},
 		{
-			name:  "repository error",
-			page:  1,
-			limit: 10,
+			name: "counts matching users on request",
+			req:  dto.ListUsersDTO{UserFilterDTO: dto.UserFilterDTO{Search: "+1"}, IncludeTotal: true},
 			setupMock: func() {
-				mockRepo.EXPECT().GetAllUsers(
-					gomock.Any(),
-					uint32(0),
-					uint32(10),
-					(*string)(nil),
-					(*time.Time)(nil),
-					(*time.Time)(nil),
-				).Return(nil, errors.New("database error"))
+				mockRepo.EXPECT().ListUsers(gomock.Any(), gomock.Any()).Return(sampleUsers, nil)
+				mockRepo.EXPECT().CountUsers(gomock.Any(), entities.UserFilter{Conditions: []entities.UserCondition{
+					{Field: entities.UserFieldPhone, Op: entities.UserFilterContains, Value: "+1"},
+				}}).Return(int64(2), nil)
 			},
-			wantUsers:  nil,
-			wantErr:    true,
-			wantErrMsg: "database error",
+			wantPage: entities.Page[entities.User]{Items: sampleUsers, Total: int64Ptr(2)},
 		},
 		{
-			name:  "empty result",
-			page:  1,
-			limit: 10,
+			name: "repository error",
+			req:  dto.ListUsersDTO{},
 			setupMock: func() {
-				mockRepo.EXPECT().GetAllUsers(
-					gomock.Any(),
-					uint32(0),
-					uint32(10),
-					(*string)(nil),
-					(*time.Time)(nil),
-					(*time.Time)(nil),
-				).Return([]entities.User{}, nil)
+				mockRepo.EXPECT().ListUsers(gomock.Any(), gomock.Any()).Return(nil, errors.New("database error"))
 			},
-			wantUsers: []entities.User{},
-			wantErr:   false,
+			wantErr:    true,
+			wantErrMsg: "database error",
 		},
 		{
-			name:  "page 3 with limit 7",
-			page:  3,
-			limit: 7,
+			name: "empty result",
+			req:  dto.ListUsersDTO{},
 			setupMock: func() {
-				mockRepo.EXPECT().GetAllUsers(
-					gomock.Any(),
-					uint32(14), // skip = (3-1) * 7 = 14
-					uint32(7),
-					(*string)(nil),
-					(*time.Time)(nil),
-					(*time.Time)(nil),
-				).Return([]entities.User{}, nil)
+				mockRepo.EXPECT().ListUsers(gomock.Any(), gomock.Any()).Return([]entities.User{}, nil)
 			},
-			wantUsers: []entities.User{},
-			wantErr:   false,
+			wantPage: entities.Page[entities.User]{Items: []entities.User{}},
+		},
+		{
+			name:       "limit above the maximum",
+			req:        dto.ListUsersDTO{Limit: intPtr(101)},
+			setupMock:  func() {},
+			wantErr:    true,
+			wantErrMsg: "limit must be between 1 and 100",
+		},
+		{
+			name:       "zero limit",
+			req:        dto.ListUsersDTO{Limit: intPtr(0)},
+			setupMock:  func() {},
+			wantErr:    true,
+			wantErrMsg: "limit must be between 1 and 100",
+		},
+		{
+			name:      "unknown sort field",
+			req:       dto.ListUsersDTO{Sort: "email"},
+			setupMock: func() {},
+			wantErr:   true,
 		},
 	}
 
@@ -251,110 +231,62 @@ func TestUsersUsecase_GetAllUsers(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.setupMock()
 
-			users, err := service.GetAllUsers(
-				context.Background(),
-				tt.page,
-				tt.limit,
-				tt.phoneSearch,
-				tt.creationFrom,
-				tt.creationTo,
-			)
+			page, err := service.ListUsers(context.Background(), tt.req)
 
 			if tt.wantErr {
 				assert.Error(t, err)
 				if tt.wantErrMsg != "" {
 					assert.Contains(t, err.Error(), tt.wantErrMsg)
 				}
-				assert.Nil(t, users)
+				assert.Nil(t, page.Items)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, tt.wantUsers, users)
+				assert.Equal(t, tt.wantPage, page)
 			}
 		})
 	}
 }
 
-func TestUsersUsecase_PaginationCalculation(t *testing.T) {
+func TestUsersUsecase_ListUsersCursor(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockRepo := mockrepositories.NewMockUserRepository(ctrl)
 	service := NewUsersService(mockRepo)
+	ctx := context.Background()
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
 
-	tests := []struct {
-		name          string
-		page          uint32
-		limit         uint32
-		expectedSkip  uint32
-		expectedLimit uint32
-	}{
-		{
-			name:          "page 0 defaults to 1",
-			page:          0,
-			limit:         5,
-			expectedSkip:  0, // (1-1) * 5 = 0
-			expectedLimit: 5,
-		},
-		{
-			name:          "limit 0 defaults to 10",
-			page:          1,
-			limit:         0,
-			expectedSkip:  0, // (1-1) * 10 = 0
-			expectedLimit: 10,
-		},
-		{
-			name:          "both 0 use defaults",
-			page:          0,
-			limit:         0,
-			expectedSkip:  0, // (1-1) * 10 = 0
-			expectedLimit: 10,
-		},
-		{
-			name:          "page 1",
-			page:          1,
-			limit:         20,
-			expectedSkip:  0, // (1-1) * 20 = 0
-			expectedLimit: 20,
-		},
-		{
-			name:          "page 2",
-			page:          2,
-			limit:         20,
-			expectedSkip:  20, // (2-1) * 20 = 20
-			expectedLimit: 20,
-		},
-		{
-			name:          "page 5, limit 3",
-			page:          5,
-			limit:         3,
-			expectedSkip:  12, // (5-1) * 3 = 12
-			expectedLimit: 3,
-		},
-	}
+	t.Run("resumes after the last user of the previous page", func(t *testing.T) {
+		first := entities.User{Id: 9, Phone: "+1000000009", CreatedAt: createdAt}
+		mockRepo.EXPECT().ListUsers(ctx, entities.UserQuery{Sort: entities.UserSortCreatedAt, Desc: true, Limit: 2}).
+			Return([]entities.User{first, {Id: 8}}, nil)
+		page, err := service.ListUsers(ctx, dto.ListUsersDTO{Limit: intPtr(1), Sort: "created_at", Order: "desc"})
+		require.NoError(t, err)
+		require.NotEmpty(t, page.NextCursor)
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			mockRepo.EXPECT().GetAllUsers(
-				gomock.Any(),
-				tt.expectedSkip,
-				tt.expectedLimit,
-				(*string)(nil),
-				(*time.Time)(nil),
-				(*time.Time)(nil),
-			).Return([]entities.User{}, nil)
-
-			_, err := service.GetAllUsers(
-				context.Background(),
-				tt.page,
-				tt.limit,
-				nil,
-				nil,
-				nil,
-			)
-
-			assert.NoError(t, err)
-		})
-	}
+		mockRepo.EXPECT().ListUsers(ctx, entities.UserQuery{
+			Sort:  entities.UserSortCreatedAt,
+			Desc:  true,
+			After: &entities.UserCursor{Sort: entities.UserSortCreatedAt, Desc: true, CreatedAt: &createdAt, ID: 9},
+			Limit: 2,
+		}).Return([]entities.User{{Id: 8}}, nil)
+		page, err = service.ListUsers(ctx, dto.ListUsersDTO{Limit: intPtr(1), Sort: "created_at", Order: "desc", Cursor: page.NextCursor})
+		require.NoError(t, err)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("rejects cursors for another order", func(t *testing.T) {
+		byID := mustCursor(entities.UserCursor{Sort: entities.UserSortID, ID: 9})
+		for name, req := range map[string]dto.ListUsersDTO{
+			"other sort":      {Sort: "phone", Cursor: byID},
+			"other direction": {Order: "desc", Cursor: byID},
+			"missing key":     {Sort: "created_at", Cursor: mustCursor(entities.UserCursor{Sort: entities.UserSortCreatedAt, ID: 9})},
+			"garbage":         {Cursor: "not-a-cursor"},
+		} {
+			_, err := service.ListUsers(ctx, req)
+			assert.ErrorIs(t, err, pagination.ErrInvalidCursor, name)
+		}
+	})
 }
 
 func TestUsersUsecase_Integration(t *testing.T) {
@@ -379,21 +311,132 @@ func TestUsersUsecase_Integration(t *testing.T) {
 	assert.Equal(t, testUser.Id, user.Id)
 	assert.Equal(t, testUser.Phone, user.Phone)
 
-	// Test getting all users
-	allUsers := []entities.User{testUser}
-	mockRepo.EXPECT().GetAllUsers(
-		gomock.Any(),
-		uint32(0),
-		uint32(10),
-		(*string)(nil),
-		(*time.Time)(nil),
-		(*time.Time)(nil),
-	).Return(allUsers, nil)
-
-	users, err := service.GetAllUsers(context.Background(), 1, 10, nil, nil, nil)
+	// Test listing users
+	mockRepo.EXPECT().ListUsers(gomock.Any(), entities.UserQuery{Sort: entities.UserSortID, Limit: 11}).Return([]entities.User{testUser}, nil)
+
+	page, err := service.ListUsers(context.Background(), dto.ListUsersDTO{})
 	require.NoError(t, err)
-	assert.Len(t, users, 1)
-	assert.Equal(t, testUser.Id, users[0].Id)
+	assert.Len(t, page.Items, 1)
+	assert.Equal(t, testUser.Id, page.Items[0].Id)
+}
+
+func TestUsersUsecase_UpdateProfile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mockrepositories.NewMockUserRepository(ctrl)
+	service := NewUsersService(mockRepo)
+	ctx := context.Background()
+
+	t.Run("normalizes and forwards only the given fields", func(t *testing.T) {
+		updated := entities.User{Id: 1, Phone: "+1234567890", DisplayName: "Sara", Email: "sara@example.com"}
+		mockRepo.EXPECT().UpdateProfile(gomock.Any(), uint32(1), entities.UserProfileUpdate{
+			DisplayName: stringPtr("Sara"),
+			Email:       stringPtr("sara@example.com"),
+			AvatarURL:   stringPtr(""),
+		}).Return(updated, nil)
+
+		user, err := service.UpdateProfile(ctx, 1, dto.UpdateProfileDTO{
+			DisplayName: stringPtr("  Sara "),
+			Email:       stringPtr("Sara@Example.com"),
+			AvatarURL:   stringPtr(""),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, updated, user)
+	})
+
+	t.Run("accepts locales and time zones", func(t *testing.T) {
+		mockRepo.EXPECT().UpdateProfile(gomock.Any(), uint32(1), gomock.Any()).Return(entities.User{Id: 1}, nil)
+
+		_, err := service.UpdateProfile(ctx, 1, dto.UpdateProfileDTO{Locale: stringPtr("fa-IR"), Timezone: stringPtr("Asia/Tehran")})
+		require.NoError(t, err)
+	})
+
+	t.Run("rejects invalid values", func(t *testing.T) {
+		for name, req := range map[string]dto.UpdateProfileDTO{
+			"email":      {Email: stringPtr("not-an-email")},
+			"locale":     {Locale: stringPtr("not a locale")},
+			"timezone":   {Timezone: stringPtr("Mars/Olympus_Mons")},
+			"avatar url": {AvatarURL: stringPtr("javascript:alert(1)")},
+			"name":       {DisplayName: stringPtr(strings.Repeat("a", 65))},
+		} {
+			_, err := service.UpdateProfile(ctx, 1, req)
+			assert.Error(t, err, name)
+		}
+	})
+
+	t.Run("emails used by another account are reported", func(t *testing.T) {
+		mockRepo.EXPECT().UpdateProfile(gomock.Any(), uint32(1), gomock.Any()).Return(entities.User{}, &pgconn.PgError{Code: "23505", ConstraintName: "users_email_key"})
+
+		_, err := service.UpdateProfile(ctx, 1, dto.UpdateProfileDTO{Email: stringPtr("taken@example.com")})
+		assert.ErrorIs(t, err, ErrEmailTaken)
+	})
+}
+
+func TestUsersUsecase_SetStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mockrepositories.NewMockUserRepository(ctrl)
+	service := NewUsersService(mockRepo)
+	ctx := context.Background()
+	admin := entities.User{Id: 1, Phone: "+1000000000"}
+
+	t.Run("suspends with a reason and records the admin", func(t *testing.T) {
+		until := time.Now().Add(24 * time.Hour)
+		suspended := entities.User{Id: 7, Status: entities.UserStatusSuspended, StatusReason: "spam", SuspendedUntil: &until}
+		mockRepo.EXPECT().SetStatus(gomock.Any(), entities.UserStatusChange{
+			UserID:         7,
+			Status:         entities.UserStatusSuspended,
+			Reason:         "spam",
+			SuspendedUntil: &until,
+			ActorID:        &admin.Id,
+		}).Return(suspended, nil)
+
+		user, err := service.SetStatus(ctx, admin, 7, dto.SetUserStatusDTO{Status: "suspended", Reason: " spam ", SuspendedUntil: &until})
+		require.NoError(t, err)
+		assert.Equal(t, suspended, user)
+	})
+
+	t.Run("reactivating needs no reason", func(t *testing.T) {
+		mockRepo.EXPECT().SetStatus(gomock.Any(), gomock.Any()).Return(entities.User{Id: 7, Status: entities.UserStatusActive}, nil)
+
+		_, err := service.SetStatus(ctx, admin, 7, dto.SetUserStatusDTO{Status: "active"})
+		require.NoError(t, err)
+	})
+
+	t.Run("rejects invalid changes", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour)
+		future := time.Now().Add(time.Hour)
+		_, err := service.SetStatus(ctx, admin, 1, dto.SetUserStatusDTO{Status: "banned", Reason: "test"})
+		assert.ErrorIs(t, err, ErrCannotChangeOwnStatus)
+		_, err = service.SetStatus(ctx, admin, 7, dto.SetUserStatusDTO{Status: "suspended", Reason: "spam", SuspendedUntil: &past})
+		assert.ErrorIs(t, err, ErrSuspensionInPast)
+
+		for name, req := range map[string]dto.SetUserStatusDTO{
+			"unknown status":          {Status: "frozen", Reason: "spam"},
+			"ban without reason":      {Status: "banned"},
+			"suspension without end":  {Status: "suspended", Reason: "spam"},
+			"ban with suspension end": {Status: "banned", Reason: "spam", SuspendedUntil: &future},
+		} {
+			_, err := service.SetStatus(ctx, admin, 7, req)
+			assert.Error(t, err, name)
+		}
+	})
+}
+
+func TestUsersUsecase_StatusHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mockrepositories.NewMockUserRepository(ctrl)
+	service := NewUsersService(mockRepo)
+	history := []entities.UserStatusChange{{ID: 2, UserID: 7, Status: entities.UserStatusActive}, {ID: 1, UserID: 7, Status: entities.UserStatusBanned}}
+	mockRepo.EXPECT().ListStatusHistory(gomock.Any(), uint32(7)).Return(history, nil)
+
+	got, err := service.StatusHistory(context.Background(), 7)
+	require.NoError(t, err)
+	assert.Equal(t, history, got)
 }
 
 // Helper functions
@@ -404,3 +447,19 @@ func stringPtr(s string) *string {
 func timePtr(t time.Time) *time.Time {
 	return &t
 }
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func int64Ptr(i int64) *int64 {
+	return &i
+}
+
+func mustCursor(key entities.UserCursor) string {
+	cursor, err := pagination.EncodeCursor(key)
+	if err != nil {
+		panic(err)
+	}
+	return cursor
+}
@@ -0,0 +1,172 @@
+// Package filterql parses filter expressions such as
+//
+//	status:active created_at>2025-01-01 phone:^98912 -display_name:"test user"
+//
+// into terms. Terms are separated by spaces and all of them must match. A term
+// is a field, an operator (: > >= < <=) and a value, which may be quoted to
+// contain spaces. A leading - negates the term. What the fields and values
+// mean is up to the caller; this package only splits the expression.
+package filterql
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Op string
+
+const (
+	OpEq  Op = ":"
+	OpGt  Op = ">"
+	OpGte Op = ">="
+	OpLt  Op = "<"
+	OpLte Op = "<="
+)
+
+type Term struct {
+	Field   string
+	Op      Op
+	Value   string
+	Negated bool
+	// Pos is the byte offset of the term in the expression.
+	Pos int
+}
+
+func (t Term) String() string {
+	value := t.Value
+	if value == "" || strings.ContainsAny(value, " \t\"\\") {
+		value = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+	}
+	if t.Negated {
+		return "-" + t.Field + string(t.Op) + value
+	}
+	return t.Field + string(t.Op) + value
+}
+
+// SyntaxError reports where an expression stopped making sense.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+// Parse splits expr into terms. An empty expression has no terms.
+func Parse(expr string) ([]Term, error) {
+	p := parser{s: expr}
+	var terms []Term
+	for {
+		p.skipSpace()
+		if p.done() {
+			return terms, nil
+		}
+		term, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) done() bool { return p.pos >= len(p.s) }
+
+func (p *parser) skipSpace() {
+	for !p.done() && isSpace(p.s[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *parser) fail(msg string) error {
+	return &SyntaxError{Pos: p.pos, Msg: msg}
+}
+
+func (p *parser) term() (Term, error) {
+	term := Term{Pos: p.pos}
+	if p.s[p.pos] == '-' {
+		term.Negated = true
+		p.pos++
+	}
+
+	start := p.pos
+	for !p.done() && isFieldChar(p.s[p.pos], p.pos == start) {
+		p.pos++
+	}
+	if p.pos == start {
+		return Term{}, p.fail("expected a field name")
+	}
+	term.Field = p.s[start:p.pos]
+
+	switch {
+	case strings.HasPrefix(p.s[p.pos:], ">="):
+		term.Op = OpGte
+	case strings.HasPrefix(p.s[p.pos:], "<="):
+		term.Op = OpLte
+	case strings.HasPrefix(p.s[p.pos:], ":"):
+		term.Op = OpEq
+	case strings.HasPrefix(p.s[p.pos:], ">"):
+		term.Op = OpGt
+	case strings.HasPrefix(p.s[p.pos:], "<"):
+		term.Op = OpLt
+	default:
+		return Term{}, p.fail(fmt.Sprintf("expected an operator after %q", term.Field))
+	}
+	p.pos += len(term.Op)
+
+	value, err := p.value()
+	if err != nil {
+		return Term{}, err
+	}
+	term.Value = value
+	return term, nil
+}
+
+func (p *parser) value() (string, error) {
+	if p.done() || isSpace(p.s[p.pos]) {
+		return "", p.fail("expected a value")
+	}
+	if p.s[p.pos] != '"' {
+		start := p.pos
+		for !p.done() && !isSpace(p.s[p.pos]) {
+			if p.s[p.pos] == '"' {
+				return "", p.fail("unexpected quote")
+			}
+			p.pos++
+		}
+		return p.s[start:p.pos], nil
+	}
+
+	start := p.pos
+	p.pos++
+	var sb strings.Builder
+	for !p.done() {
+		c := p.s[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			if !p.done() && !isSpace(p.s[p.pos]) {
+				return "", p.fail("expected a space after the closing quote")
+			}
+			return sb.String(), nil
+		case c == '\\' && p.pos+1 < len(p.s):
+			sb.WriteByte(p.s[p.pos+1])
+			p.pos += 2
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", &SyntaxError{Pos: start, Msg: "unterminated quote"}
+}
+
+func isSpace(c byte) bool { return c == ' ' || c == '\t' }
+
+func isFieldChar(c byte, first bool) bool {
+	return c >= 'a' && c <= 'z' || c == '_' || !first && c >= '0' && c <= '9'
+}
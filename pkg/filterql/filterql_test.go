@@ -0,0 +1,54 @@
+package filterql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	terms, err := Parse(` status:active  created_at>=2025-01-01 phone:^98912 -display_name:"Ada \"L\"" n<3`)
+	require.NoError(t, err)
+	assert.Equal(t, []Term{
+		{Field: "status", Op: OpEq, Value: "active", Pos: 1},
+		{Field: "created_at", Op: OpGte, Value: "2025-01-01", Pos: 16},
+		{Field: "phone", Op: OpEq, Value: "^98912", Pos: 39},
+		{Field: "display_name", Op: OpEq, Value: `Ada "L"`, Negated: true, Pos: 52},
+		{Field: "n", Op: OpLt, Value: "3", Pos: 78},
+	}, terms)
+
+	terms, err = Parse("   ")
+	require.NoError(t, err)
+	assert.Empty(t, terms)
+}
+
+func TestParseErrors(t *testing.T) {
+	for expr, want := range map[string]string{
+		"active":           `expected an operator after "active" at position 6`,
+		":active":          "expected a field name at position 0",
+		"Status:active":    "expected a field name at position 0",
+		"status:":          "expected a value at position 7",
+		"status: active":   "expected a value at position 7",
+		`name:"Ada`:        "unterminated quote at position 5",
+		`name:"Ada"x`:      "expected a space after the closing quote at position 10",
+		`name:Ada"`:        "unexpected quote at position 8",
+		"created_at=2025":  `expected an operator after "created_at" at position 10`,
+		"-":                "expected a field name at position 1",
+		"status:active  -": "expected a field name at position 16",
+	} {
+		_, err := Parse(expr)
+		var syntaxErr *SyntaxError
+		require.ErrorAs(t, err, &syntaxErr, expr)
+		assert.EqualError(t, err, want, expr)
+	}
+}
+
+func TestTermString(t *testing.T) {
+	for _, expr := range []string{"status:active", "-phone:^98912", `display_name:"Ada \"L\""`, `email:""`, "created_at<=2025-01-01"} {
+		terms, err := Parse(expr)
+		require.NoError(t, err)
+		require.Len(t, terms, 1)
+		assert.Equal(t, expr, terms[0].String())
+	}
+}
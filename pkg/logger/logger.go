@@ -20,24 +20,27 @@ type zapLogger struct {
 	sugared *zap.SugaredLogger
 }
 
-func New(logLevel string) (Logger, *zap.Logger) {
-	var level zapcore.Level
-
+// ParseLevel maps a level name to a zap level, defaulting to info.
+func ParseLevel(logLevel string) zapcore.Level {
 	switch strings.ToLower(logLevel) {
 	case "error":
-		level = zapcore.ErrorLevel
+		return zapcore.ErrorLevel
 	case "warn":
-		level = zapcore.WarnLevel
+		return zapcore.WarnLevel
 	case "info":
-		level = zapcore.InfoLevel
+		return zapcore.InfoLevel
 	case "debug":
-		level = zapcore.DebugLevel
+		return zapcore.DebugLevel
 	default:
-		level = zapcore.InfoLevel
+		return zapcore.InfoLevel
 	}
+}
 
+// New builds the logger on top of level so callers can change the log level
+// at runtime with level.SetLevel.
+func New(level zap.AtomicLevel) (Logger, *zap.Logger) {
 	config := zap.NewProductionConfig()
-	config.Level = zap.NewAtomicLevelAt(level)
+	config.Level = level
 	config.EncoderConfig.TimeKey = "time"
 	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 
@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"context"
+	"sync"
+)
+
+// Fake records messages instead of sending them. Err, if set, is returned
+// from Send without recording the message.
+type Fake struct {
+	mu       sync.Mutex
+	messages []Message
+	Err      error
+}
+
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+func (f *Fake) Send(_ context.Context, message Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	f.messages = append(f.messages, message)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first.
+func (f *Fake) Messages() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Message(nil), f.messages...)
+}
+
+// Last returns the latest message sent to to.
+func (f *Fake) Last(to string) (Message, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.messages) - 1; i >= 0; i-- {
+		if f.messages[i].To == to {
+			return f.messages[i], true
+		}
+	}
+	return Message{}, false
+}
@@ -0,0 +1,41 @@
+// Package pagination encodes the opaque cursors of keyset-paginated lists.
+// A cursor holds the sort key of the last row of a page; the next page starts
+// right after it.
+package pagination
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor returns key as URL-safe base64 of its JSON encoding.
+func EncodeCursor(key any) (string, error) {
+	b, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeCursor reverses EncodeCursor. Anything that is not a cursor for the
+// type of key yields ErrInvalidCursor.
+func DecodeCursor(cursor string, key any) error {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(key); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if decoder.More() {
+		return ErrInvalidCursor
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package pagination
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testKey struct {
+	CreatedAt *time.Time `json:"c,omitempty"`
+	ID        uint32     `json:"i"`
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	cursor, err := EncodeCursor(testKey{CreatedAt: &createdAt, ID: 42})
+	require.NoError(t, err)
+	assert.NotContains(t, cursor, "=")
+
+	var key testKey
+	require.NoError(t, DecodeCursor(cursor, &key))
+	assert.Equal(t, uint32(42), key.ID)
+	assert.True(t, createdAt.Equal(*key.CreatedAt))
+}
+
+func TestDecodeCursorRejectsGarbage(t *testing.T) {
+	unknown, err := EncodeCursor(map[string]any{"x": 1})
+	require.NoError(t, err)
+	number, err := EncodeCursor(1)
+	require.NoError(t, err)
+
+	for name, cursor := range map[string]string{
+		"not base64":    "%%%",
+		"not json":      "bm90IGpzb24",
+		"unknown field": unknown,
+		"wrong type":    number,
+	} {
+		var key testKey
+		assert.ErrorIs(t, DecodeCursor(cursor, &key), ErrInvalidCursor, name)
+	}
+}
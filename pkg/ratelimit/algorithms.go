@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// The Redis scripts in redis.go implement the same arithmetic in Lua; keep
+// both in sync. With consume unset the algorithms only report whether the
+// next event would be allowed, leaving the state untouched.
+
+type slidingState struct {
+	windowStart time.Time
+	curr        int
+	prev        int
+}
+
+func slidingWindow(state slidingState, now time.Time, limit Limit, consume bool) (slidingState, Result) {
+	period := limit.Period
+	ws := now.Truncate(period)
+	if !state.windowStart.Equal(ws) {
+		if ws.Sub(state.windowStart) == period {
+			state.prev = state.curr
+		} else {
+			state.prev = 0
+		}
+		state.curr = 0
+		state.windowStart = ws
+	}
+
+	elapsed := now.Sub(ws)
+	weight := 1 - float64(elapsed)/float64(period)
+	estimate := float64(state.prev)*weight + float64(state.curr)
+	rate := float64(limit.Rate)
+
+	res := Result{Limit: limit.Rate}
+	if estimate+1 > rate {
+		var retry float64
+		if float64(state.curr)+1 > rate {
+			// wait for the next window and for the current one to decay enough
+			retry = float64(period-elapsed) + float64(period)*(1-(rate-1)/float64(state.curr))
+		} else {
+			retry = float64(period)*(1-(rate-1-float64(state.curr))/float64(state.prev)) - float64(elapsed)
+		}
+		res.RetryAfter = time.Duration(math.Ceil(retry))
+		res.ResetAfter = slidingResetAfter(state, ws, now, period)
+		return state, res
+	}
+
+	res.Allowed = true
+	if consume {
+		state.curr++
+		estimate++
+	}
+	res.Remaining = int(math.Floor(rate - estimate))
+	res.ResetAfter = slidingResetAfter(state, ws, now, period)
+	return state, res
+}
+
+// slidingResetAfter is how long until every recorded event has left the window.
+func slidingResetAfter(state slidingState, ws, now time.Time, period time.Duration) time.Duration {
+	if state.curr > 0 {
+		return ws.Add(2 * period).Sub(now)
+	}
+	return ws.Add(period).Sub(now)
+}
+
+// gcra tracks the theoretical arrival time (tat) of the next event.
+func gcra(tat time.Time, now time.Time, limit Limit, consume bool) (time.Time, Result) {
+	interval := limit.Period / time.Duration(limit.Rate)
+	if tat.Before(now) {
+		tat = now
+	}
+	newTat := tat.Add(interval)
+	allowAt := newTat.Add(-time.Duration(limit.Rate) * interval)
+
+	res := Result{Limit: limit.Rate}
+	if now.Before(allowAt) {
+		res.RetryAfter = allowAt.Sub(now)
+		res.ResetAfter = tat.Sub(now)
+		return tat, res
+	}
+
+	res.Allowed = true
+	res.Remaining = int(now.Sub(allowAt) / interval)
+	if !consume {
+		res.Remaining++
+		res.ResetAfter = tat.Sub(now)
+		return tat, res
+	}
+	res.ResetAfter = newTat.Sub(now)
+	return newTat, res
+}
@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const memorySweepEvery = 1024
+
+type memoryEntry struct {
+	sliding   slidingState
+	tat       time.Time
+	expiresAt time.Time
+}
+
+// MemoryStore keeps limiter state in process memory. It suits tests and
+// single-instance deployments; use RedisStore when running several replicas.
+type MemoryStore struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	entries map[string]*memoryEntry
+	calls   int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithClock(time.Now)
+}
+
+func NewMemoryStoreWithClock(now func() time.Time) *MemoryStore {
+	return &MemoryStore{
+		now:     now,
+		entries: make(map[string]*memoryEntry),
+	}
+}
+
+func (m *MemoryStore) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	return m.run(key, limit, true), nil
+}
+
+func (m *MemoryStore) Peek(_ context.Context, key string, limit Limit) (Result, error) {
+	return m.run(key, limit, false), nil
+}
+
+func (m *MemoryStore) run(key string, limit Limit, consume bool) Result {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.calls++
+	if m.calls%memorySweepEvery == 0 {
+		m.sweep(now)
+	}
+
+	var entry memoryEntry
+	if stored, ok := m.entries[key]; ok && !now.After(stored.expiresAt) {
+		entry = *stored
+	}
+
+	var res Result
+	if limit.Algorithm == GCRA {
+		entry.tat, res = gcra(entry.tat, now, limit, consume)
+		entry.expiresAt = entry.tat
+	} else {
+		entry.sliding, res = slidingWindow(entry.sliding, now, limit, consume)
+		entry.expiresAt = entry.sliding.windowStart.Add(2 * limit.Period)
+	}
+	if consume {
+		m.entries[key] = &entry
+	}
+	return res
+}
+
+func (m *MemoryStore) sweep(now time.Time) {
+	for key, entry := range m.entries {
+		if now.After(entry.expiresAt) {
+			delete(m.entries, key)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware answers 429 Too Many Requests once any rule returned by rules is
+// exceeded. Store errors are recorded on the context and the request is let
+// through, so a Redis outage does not take the API down with it.
+func Middleware(limiter *Limiter, rules func(c *gin.Context) []Rule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res, err := limiter.Allow(c.Request.Context(), rules(c)...)
+		var exceeded *ExceededError
+		if errors.As(err, &exceeded) {
+			SetHeaders(c.Writer.Header(), res)
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			_ = c.Error(err)
+			c.Next()
+			return
+		}
+
+		SetHeaders(c.Writer.Header(), res)
+		c.Next()
+	}
+}
+
+// SetHeaders writes X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset, plus Retry-After when the request was rejected.
+func SetHeaders(h http.Header, res Result) {
+	if res.Limit == 0 {
+		return
+	}
+	h.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+	if !res.Allowed {
+		h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
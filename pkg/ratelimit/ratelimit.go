@@ -0,0 +1,163 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"time"
+)
+
+type Algorithm string
+
+const (
+	// SlidingWindow weighs the previous fixed window by how much of it still
+	// overlaps the sliding window, which stops bursts across window boundaries.
+	SlidingWindow Algorithm = "sliding_window"
+	// GCRA (generic cell rate algorithm) spaces events evenly and allows a
+	// burst of up to Rate events.
+	GCRA Algorithm = "gcra"
+)
+
+// Limit allows Rate events per Period.
+type Limit struct {
+	Name      string
+	Rate      int
+	Period    time.Duration
+	Algorithm Algorithm
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+// Store records events and applies a limit's algorithm atomically per key.
+// Peek reports whether an event would be allowed without recording it.
+type Store interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+	Peek(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Rule applies a limit to one dimension of a request, e.g. the phone number
+// or client IP. Rules with an empty Key or a non-positive Rate are skipped.
+type Rule struct {
+	Limit
+	Key string
+}
+
+type ExceededError struct {
+	Rule   Rule
+	Result Result
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("rate limit exceeded: max %d requests per %s for %s, retry after %s",
+		e.Rule.Rate, e.Rule.Period, e.Rule.Name, e.Result.RetryAfter.Round(time.Second))
+}
+
+type Limiter struct {
+	store  Store
+	prefix string
+}
+
+func NewLimiter(store Store, prefix string) *Limiter {
+	return &Limiter{store: store, prefix: prefix}
+}
+
+// Allow records an event against every rule, but only when none of them is
+// exceeded: a rejected request uses up no quota, so a client hitting a shared
+// limit cannot drain another dimension such as a victim's phone number. The
+// first exceeded rule is returned as an *ExceededError with its result.
+// Otherwise Allow returns the result of the rule with the fewest remaining
+// requests.
+func (l *Limiter) Allow(ctx context.Context, rules ...Rule) (Result, error) {
+	for _, rule := range rules {
+		if rule.skip() {
+			continue
+		}
+		res, err := l.store.Peek(ctx, l.key(rule), rule.Limit)
+		if err != nil {
+			return Result{}, err
+		}
+		if !res.Allowed {
+			return res, &ExceededError{Rule: rule, Result: res}
+		}
+	}
+
+	tightest := Result{Allowed: true, Remaining: math.MaxInt}
+	for _, rule := range rules {
+		if rule.skip() {
+			continue
+		}
+		// A concurrent request can still take the last slot between the
+		// peek and here; that request is rejected like any other.
+		res, err := l.store.Allow(ctx, l.key(rule), rule.Limit)
+		if err != nil {
+			return Result{}, err
+		}
+		if !res.Allowed {
+			return res, &ExceededError{Rule: rule, Result: res}
+		}
+		if res.Remaining < tightest.Remaining {
+			tightest = res
+		}
+	}
+	if tightest.Remaining == math.MaxInt {
+		tightest.Remaining = 0
+	}
+	return tightest, nil
+}
+
+// Peek evaluates every rule without recording an event. When some rules would
+// reject the next request it returns the one that blocks the longest,
+// otherwise the rule with the fewest remaining requests.
+func (l *Limiter) Peek(ctx context.Context, rules ...Rule) (Result, error) {
+	tightest := Result{Allowed: true, Remaining: math.MaxInt}
+	for _, rule := range rules {
+		if rule.skip() {
+			continue
+		}
+		res, err := l.store.Peek(ctx, l.key(rule), rule.Limit)
+		if err != nil {
+			return Result{}, err
+		}
+		switch {
+		case !res.Allowed:
+			if tightest.Allowed || res.RetryAfter > tightest.RetryAfter {
+				tightest = res
+			}
+		case tightest.Allowed && res.Remaining < tightest.Remaining:
+			tightest = res
+		}
+	}
+	if tightest.Remaining == math.MaxInt {
+		tightest.Remaining = 0
+	}
+	return tightest, nil
+}
+
+func (l *Limiter) key(rule Rule) string {
+	return l.prefix + rule.Name + ":" + rule.Key
+}
+
+func (r Rule) skip() bool {
+	return r.Key == "" || r.Rate <= 0 || r.Period <= 0
+}
+
+// Subnet returns the /24 network of an IPv4 address or the /64 network of an
+// IPv6 address, so clients rotating through neighbouring addresses share a
+// limit. It returns "" for unparsable input.
+func Subnet(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
@@ -0,0 +1,279 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+func newClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	limit := Limit{Name: "phone", Rate: 3, Period: 10 * time.Minute, Algorithm: SlidingWindow}
+	ctx := context.Background()
+
+	t.Run("allows up to the rate and reports remaining", func(t *testing.T) {
+		store := NewMemoryStoreWithClock(newClock().Now)
+		for i := 2; i >= 0; i-- {
+			res, err := store.Allow(ctx, "k", limit)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, i, res.Remaining)
+			assert.Equal(t, 3, res.Limit)
+		}
+
+		res, err := store.Allow(ctx, "k", limit)
+		require.NoError(t, err)
+		assert.False(t, res.Allowed)
+		assert.Greater(t, res.RetryAfter, time.Duration(0))
+	})
+
+	t.Run("blocks bursts across a window boundary", func(t *testing.T) {
+		clock := newClock()
+		store := NewMemoryStoreWithClock(clock.Now)
+		clock.Advance(9*time.Minute + 59*time.Second)
+		for i := 0; i < 3; i++ {
+			res, _ := store.Allow(ctx, "k", limit)
+			require.True(t, res.Allowed)
+		}
+
+		clock.Advance(2 * time.Second)
+		res, _ := store.Allow(ctx, "k", limit)
+		assert.False(t, res.Allowed, "a fixed window would allow 3 more requests here")
+
+		clock.Advance(res.RetryAfter)
+		res, _ = store.Allow(ctx, "k", limit)
+		assert.True(t, res.Allowed)
+	})
+
+	t.Run("keys are independent", func(t *testing.T) {
+		store := NewMemoryStoreWithClock(newClock().Now)
+		for i := 0; i < 3; i++ {
+			_, _ = store.Allow(ctx, "a", limit)
+		}
+		res, _ := store.Allow(ctx, "b", limit)
+		assert.True(t, res.Allowed)
+	})
+}
+
+func TestGCRA(t *testing.T) {
+	limit := Limit{Name: "ip", Rate: 3, Period: 30 * time.Second, Algorithm: GCRA}
+	ctx := context.Background()
+	clock := newClock()
+	store := NewMemoryStoreWithClock(clock.Now)
+
+	for i := 2; i >= 0; i-- {
+		res, err := store.Allow(ctx, "k", limit)
+		require.NoError(t, err)
+		require.True(t, res.Allowed)
+		assert.Equal(t, i, res.Remaining)
+	}
+
+	res, _ := store.Allow(ctx, "k", limit)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 10*time.Second, res.RetryAfter)
+
+	clock.Advance(10 * time.Second)
+	res, _ = store.Allow(ctx, "k", limit)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+}
+
+func TestPeek(t *testing.T) {
+	ctx := context.Background()
+	for _, algorithm := range []Algorithm{SlidingWindow, GCRA} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			clock := newClock()
+			store := NewMemoryStoreWithClock(clock.Now)
+			limit := Limit{Name: "phone", Rate: 2, Period: time.Minute, Algorithm: algorithm}
+
+			for i := 0; i < 3; i++ {
+				res, err := store.Peek(ctx, "k", limit)
+				require.NoError(t, err)
+				assert.True(t, res.Allowed)
+				assert.Equal(t, 2, res.Remaining, "peeking must not consume")
+			}
+
+			_, _ = store.Allow(ctx, "k", limit)
+			res, _ := store.Peek(ctx, "k", limit)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, 1, res.Remaining)
+
+			_, _ = store.Allow(ctx, "k", limit)
+			res, _ = store.Peek(ctx, "k", limit)
+			assert.False(t, res.Allowed)
+			assert.Greater(t, res.RetryAfter, time.Duration(0))
+
+			clock.Advance(res.RetryAfter)
+			res, _ = store.Peek(ctx, "k", limit)
+			assert.True(t, res.Allowed)
+		})
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewLimiter(NewMemoryStoreWithClock(newClock().Now), "test:")
+	perPhone := Limit{Name: "phone", Rate: 5, Period: time.Minute}
+	perIP := Limit{Name: "ip", Rate: 2, Period: time.Minute}
+
+	rules := func(phone string) []Rule {
+		return []Rule{
+			{Limit: perPhone, Key: phone},
+			{Limit: perIP, Key: "10.0.0.1"},
+			{Limit: Limit{Name: "device", Rate: 1, Period: time.Minute}, Key: ""},
+		}
+	}
+
+	res, err := limiter.Allow(ctx, rules("+1")...)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Remaining, "the tightest rule is reported")
+
+	_, err = limiter.Allow(ctx, rules("+2")...)
+	require.NoError(t, err)
+
+	res, err = limiter.Allow(ctx, rules("+3")...)
+	var exceeded *ExceededError
+	require.True(t, errors.As(err, &exceeded))
+	assert.Equal(t, "ip", exceeded.Rule.Name)
+	assert.False(t, res.Allowed)
+	assert.Contains(t, err.Error(), "rate limit exceeded")
+
+	res, err = limiter.Allow(ctx)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	// The rejected requests above used up none of the per-phone quota.
+	res, err = limiter.Peek(ctx, Rule{Limit: perPhone, Key: "+3"})
+	require.NoError(t, err)
+	assert.Equal(t, perPhone.Rate, res.Remaining)
+
+	cooldown := Rule{Limit: Limit{Name: "resend", Rate: 1, Period: 30 * time.Second, Algorithm: GCRA}, Key: "+4"}
+	_, err = limiter.Allow(ctx, cooldown)
+	require.NoError(t, err)
+	res, err = limiter.Peek(ctx, cooldown, Rule{Limit: perIP, Key: "10.0.0.1"}, Rule{Limit: perPhone, Key: "+4"})
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, perIP.Rate, res.Limit)
+	assert.Greater(t, res.RetryAfter, 30*time.Second, "the ip limit blocks longer than the cooldown")
+}
+
+func TestSubnet(t *testing.T) {
+	assert.Equal(t, "192.168.10.0/24", Subnet("192.168.10.77"))
+	assert.Equal(t, "2001:db8:1:2::/64", Subnet("2001:db8:1:2:3:4:5:6"))
+	assert.Equal(t, "", Subnet("not-an-ip"))
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := NewLimiter(NewMemoryStoreWithClock(newClock().Now), "http:")
+	router := gin.New()
+	router.Use(Middleware(limiter, func(c *gin.Context) []Rule {
+		return []Rule{{Limit: Limit{Name: "ip", Rate: 1, Period: time.Minute}, Key: c.ClientIP()}}
+	}))
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("X-RateLimit-Reset"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+// TestRedisStore requires a Redis instance on localhost:6379 and is skipped otherwise.
+func TestRedisStore(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 1})
+	ctx := context.Background()
+	if err := redisClient.Ping(ctx).Err(); err != nil {
+		t.Skip("Redis is not available, skipping integration test")
+	}
+	defer func() {
+		redisClient.FlushDB(ctx)
+		redisClient.Close()
+	}()
+
+	store := NewRedisStore(redisClient)
+	for _, algorithm := range []Algorithm{SlidingWindow, GCRA} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			limit := Limit{Name: "phone", Rate: 3, Period: 10 * time.Minute, Algorithm: algorithm}
+			key := "test:ratelimit:" + string(algorithm)
+
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			allowed := 0
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					res, err := store.Allow(ctx, key, limit)
+					if assert.NoError(t, err) && res.Allowed {
+						mu.Lock()
+						allowed++
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+			assert.Equal(t, 3, allowed)
+
+			res, err := store.Allow(ctx, key, limit)
+			require.NoError(t, err)
+			assert.False(t, res.Allowed)
+			assert.Greater(t, res.RetryAfter, time.Duration(0))
+
+			peeked, err := store.Peek(ctx, key, limit)
+			require.NoError(t, err)
+			assert.False(t, peeked.Allowed)
+
+			ttl, err := redisClient.PTTL(ctx, key).Result()
+			require.NoError(t, err)
+			assert.Greater(t, ttl, time.Duration(0))
+
+			fresh, err := store.Peek(ctx, key+":fresh", limit)
+			require.NoError(t, err)
+			assert.True(t, fresh.Allowed)
+			assert.Equal(t, 3, fresh.Remaining)
+			exists, err := redisClient.Exists(ctx, key+":fresh").Result()
+			require.NoError(t, err)
+			assert.Zero(t, exists, "peeking must not write")
+		})
+	}
+}
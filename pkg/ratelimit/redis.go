@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Both scripts read the clock from Redis (TIME) so replicas with skewed clocks
+// agree, take ARGV {rate, period_ms, consume} and return
+// {allowed, remaining, retry_after_ms, reset_after_ms}. With consume set to 0
+// they only peek and write nothing.
+
+var slidingWindowScript = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local rate = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local consume = ARGV[3] == "1"
+local ws = now - (now % period)
+
+local state = redis.call("HMGET", KEYS[1], "ws", "curr", "prev")
+local sws = tonumber(state[1]) or ws
+local curr = tonumber(state[2]) or 0
+local prev = tonumber(state[3]) or 0
+if sws ~= ws then
+	if ws - sws == period then prev = curr else prev = 0 end
+	curr = 0
+end
+
+local elapsed = now - ws
+local estimate = prev * (1 - elapsed / period) + curr
+local allowed = 0
+local remaining = 0
+local retry = 0
+if estimate + 1 > rate then
+	if curr + 1 > rate then
+		retry = (period - elapsed) + period * (1 - (rate - 1) / curr)
+	else
+		retry = period * (1 - (rate - 1 - curr) / prev) - elapsed
+	end
+else
+	allowed = 1
+	if consume then
+		curr = curr + 1
+		estimate = estimate + 1
+	end
+	remaining = math.floor(rate - estimate)
+end
+
+local reset = ws + period - now
+if curr > 0 then reset = reset + period end
+
+if consume then
+	redis.call("HSET", KEYS[1], "ws", string.format("%.0f", ws), "curr", curr, "prev", prev)
+	redis.call("PEXPIRE", KEYS[1], 2 * period)
+end
+return {allowed, remaining, math.ceil(retry), reset}
+`)
+
+var gcraScript = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + tonumber(t[2]) / 1000
+local rate = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local consume = ARGV[3] == "1"
+local interval = period / rate
+
+local tat = tonumber(redis.call("GET", KEYS[1])) or now
+if tat < now then tat = now end
+local new_tat = tat + interval
+local allow_at = new_tat - rate * interval
+
+if now < allow_at then
+	return {0, 0, math.ceil(allow_at - now), math.ceil(tat - now)}
+end
+if not consume then
+	return {1, math.floor((now - allow_at) / interval) + 1, 0, math.ceil(tat - now)}
+end
+
+redis.call("SET", KEYS[1], string.format("%.3f", new_tat), "PX", math.ceil(new_tat - now))
+return {1, math.floor((now - allow_at) / interval), 0, math.ceil(new_tat - now)}
+`)
+
+type RedisStore struct {
+	client redis.Scripter
+}
+
+func NewRedisStore(client redis.Scripter) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (r *RedisStore) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	return r.run(ctx, key, limit, 1)
+}
+
+func (r *RedisStore) Peek(ctx context.Context, key string, limit Limit) (Result, error) {
+	return r.run(ctx, key, limit, 0)
+}
+
+func (r *RedisStore) run(ctx context.Context, key string, limit Limit, consume int) (Result, error) {
+	script := slidingWindowScript
+	if limit.Algorithm == GCRA {
+		script = gcraScript
+	}
+
+	values, err := script.Run(ctx, r.client, []string{key}, limit.Rate, limit.Period.Milliseconds(), consume).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(values) != 4 {
+		return Result{}, fmt.Errorf("unexpected rate limit script reply %v", values)
+	}
+
+	return Result{
+		Allowed:    values[0] == 1,
+		Limit:      limit.Rate,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,53 @@
+// Package requestid tags every request with an ID that ties together its log
+// lines, audit entries and the response the client got.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header carries the ID in both directions.
+const Header = "X-Request-ID"
+
+// accepted bounds the IDs taken from clients and proxies, since they end up in
+// logs and the audit log.
+var accepted = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type contextKey struct{}
+
+// Middleware keeps a well-formed X-Request-ID from the client or proxy and
+// generates one otherwise. The ID is echoed in the response and stored in the
+// request context.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(Header)
+		if !accepted.MatchString(id) {
+			id = New()
+		}
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), id))
+		c.Header(Header, id)
+		c.Next()
+	}
+}
+
+// New returns a random 128-bit ID in hex.
+func New() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID, or "" outside a request.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
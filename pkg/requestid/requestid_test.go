@@ -0,0 +1,59 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func serve(t *testing.T, header string) (response string, seen string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/", func(c *gin.Context) {
+		seen = FromContext(c.Request.Context())
+		c.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if header != "" {
+		req.Header.Set(Header, header)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Header().Get(Header), seen
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Run("keeps the caller's id", func(t *testing.T) {
+		response, seen := serve(t, "edge-7f3a:42")
+		assert.Equal(t, "edge-7f3a:42", response)
+		assert.Equal(t, "edge-7f3a:42", seen)
+	})
+
+	t.Run("generates one when missing", func(t *testing.T) {
+		response, seen := serve(t, "")
+		assert.Len(t, response, 32)
+		assert.Equal(t, response, seen)
+	})
+
+	t.Run("replaces malformed ids", func(t *testing.T) {
+		for _, header := range []string{"has space", "line\nbreak", strings.Repeat("a", 129)} {
+			response, seen := serve(t, header)
+			assert.Len(t, response, 32, header)
+			assert.Equal(t, response, seen, header)
+		}
+	})
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Empty(t, FromContext(context.Background()))
+	assert.Equal(t, "abc", FromContext(NewContext(context.Background(), "abc")))
+	assert.NotEqual(t, New(), New())
+}
@@ -0,0 +1,69 @@
+// Package secretbox encrypts short secrets, such as message bodies holding
+// one-time codes, before they are written to the database.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// prefix marks sealed values so they are never mistaken for plaintext.
+const prefix = "v1:"
+
+var ErrInvalidSealed = errors.New("invalid sealed value")
+
+// Box seals values with AES-256-GCM under a key derived from a secret.
+type Box struct {
+	aead cipher.AEAD
+}
+
+func New(secret string) (*Box, error) {
+	if secret == "" {
+		return nil, errors.New("secretbox: empty secret")
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts plaintext bound to context, which Open must be given again,
+// so a sealed value copied to another row does not open.
+func (b *Box) Seal(plaintext, context string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), []byte(context))
+	return prefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open reverses Seal. A value that was not sealed with this key and context
+// yields ErrInvalidSealed.
+func (b *Box) Open(sealed, context string) (string, error) {
+	encoded, ok := strings.CutPrefix(sealed, prefix)
+	if !ok {
+		return "", ErrInvalidSealed
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) < b.aead.NonceSize() {
+		return "", ErrInvalidSealed
+	}
+	nonce, ciphertext := raw[:b.aead.NonceSize()], raw[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, []byte(context))
+	if err != nil {
+		return "", ErrInvalidSealed
+	}
+	return string(plaintext), nil
+}
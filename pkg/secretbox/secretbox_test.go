@@ -0,0 +1,43 @@
+package secretbox
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBox(t *testing.T) {
+	box, err := New("a-long-enough-secret")
+	require.NoError(t, err)
+
+	sealed, err := box.Seal("Your code is 12345", "otp:abc")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sealed, "v1:"))
+	assert.NotContains(t, sealed, "12345")
+
+	again, err := box.Seal("Your code is 12345", "otp:abc")
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "every seal uses a fresh nonce")
+
+	opened, err := box.Open(sealed, "otp:abc")
+	require.NoError(t, err)
+	assert.Equal(t, "Your code is 12345", opened)
+
+	_, err = box.Open(sealed, "otp:other")
+	assert.ErrorIs(t, err, ErrInvalidSealed, "wrong context")
+
+	other, err := New("another-long-secret")
+	require.NoError(t, err)
+	_, err = other.Open(sealed, "otp:abc")
+	assert.ErrorIs(t, err, ErrInvalidSealed, "wrong key")
+
+	for _, bad := range []string{"Your code is 12345", "v1:", "v1:!!!", sealed[:len(sealed)-2]} {
+		_, err = box.Open(bad, "otp:abc")
+		assert.ErrorIs(t, err, ErrInvalidSealed, bad)
+	}
+
+	_, err = New("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,48 @@
+package sms
+
+import (
+	"expvar"
+	"sync"
+)
+
+// Provider metrics are published under the "sms_providers" expvar, e.g.
+// {"primary": {"sent": 10, "failed": 1, "skipped": 0, "circuit": "closed"}}.
+var providersVar = expvar.NewMap("sms_providers")
+
+type providerMetrics struct {
+	sent    *expvar.Int
+	failed  *expvar.Int
+	skipped *expvar.Int
+}
+
+func publishProviderMetrics(name string, breaker *Breaker) *providerMetrics {
+	m := &providerMetrics{sent: new(expvar.Int), failed: new(expvar.Int), skipped: new(expvar.Int)}
+
+	vars := new(expvar.Map).Init()
+	vars.Set("sent", m.sent)
+	vars.Set("failed", m.failed)
+	vars.Set("skipped", m.skipped)
+	vars.Set("circuit", expvar.Func(func() any { return breaker.State() }))
+	providersVar.Set(name, vars)
+	return m
+}
+
+// Delivery reports are counted per provider and status under the
+// "sms_delivery_reports" expvar, e.g. {"primary": {"delivered": 9, "undelivered": 1}}.
+// A rising share of failures for one provider points at carrier problems.
+var (
+	deliveryReportsVar = expvar.NewMap("sms_delivery_reports")
+	deliveryReportsMu  sync.Mutex
+)
+
+func RecordDeliveryReport(provider, status string) {
+	deliveryReportsMu.Lock()
+	defer deliveryReportsMu.Unlock()
+
+	counts, ok := deliveryReportsVar.Get(provider).(*expvar.Map)
+	if !ok {
+		counts = new(expvar.Map).Init()
+		deliveryReportsVar.Set(provider, counts)
+	}
+	counts.Add(status, 1)
+}
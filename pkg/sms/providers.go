@@ -0,0 +1,124 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/MostajeranMohammad/dekamond-auth-challenge/pkg/logger"
+)
+
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+type consoleProvider struct {
+	name string
+	l    logger.Logger
+}
+
+// NewConsoleProvider logs messages instead of sending them.
+func NewConsoleProvider(name string, l logger.Logger) Provider {
+	return &consoleProvider{name: name, l: l}
+}
+
+func (p *consoleProvider) Name() string { return p.name }
+
+func (p *consoleProvider) Send(_ context.Context, to, body string) (string, error) {
+	p.l.Info(fmt.Sprintf("Sending SMS via %s to phone number %s: %s", p.name, to, body))
+	return "", nil
+}
+
+type httpProvider struct {
+	name   string
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewHTTPProvider posts {"to": ..., "body": ...} as JSON to endpoint with an
+// optional bearer token. Any 2xx answer counts as accepted; a JSON
+// {"message_id": ...} in the response is returned as the message ID.
+func NewHTTPProvider(name, endpoint, token string, client *http.Client) Provider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &httpProvider{name: name, url: endpoint, token: token, client: client}
+}
+
+func (p *httpProvider) Name() string { return p.name }
+
+func (p *httpProvider) Send(ctx context.Context, to, body string) (string, error) {
+	payload, err := json.Marshal(map[string]string{"to": to, "body": body})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("gateway answered %s: %s", resp.Status, strings.TrimSpace(string(raw)))
+	}
+
+	var accepted struct {
+		MessageID string `json:"message_id"`
+	}
+	_ = json.Unmarshal(raw, &accepted)
+	return accepted.MessageID, nil
+}
+
+// ParseProvider builds a provider from "name=console://" or
+// "name=https://TOKEN@gateway.example/send". The token, if any, is sent as a
+// bearer token and stripped from the URL.
+func ParseProvider(spec string, l logger.Logger) (Provider, error) {
+	name, rawURL, ok := strings.Cut(spec, "=")
+	if !ok || !providerNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid sms provider %q: want name=url with a lowercase name", spec)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sms provider %s: %w", name, err)
+	}
+
+	switch u.Scheme {
+	case "console":
+		return NewConsoleProvider(name, l), nil
+	case "http", "https":
+		var token string
+		if u.User != nil {
+			token = u.User.Username()
+			if password, ok := u.User.Password(); ok {
+				token = password
+			}
+			u.User = nil
+		}
+		return NewHTTPProvider(name, u.String(), token, nil), nil
+	default:
+		return nil, fmt.Errorf("invalid sms provider %s: unsupported scheme %q", name, u.Scheme)
+	}
+}
+
+// ParseRoute parses "+98=primary|backup".
+func ParseRoute(spec string) (Route, error) {
+	prefix, providers, ok := strings.Cut(spec, "=")
+	if !ok || prefix == "" || providers == "" {
+		return Route{}, fmt.Errorf("invalid sms route %q: want prefix=provider|provider", spec)
+	}
+	return Route{Prefix: prefix, Providers: strings.Split(providers, "|")}, nil
+}
@@ -0,0 +1,132 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Provider delivers a text message through one SMS gateway and returns the
+// gateway's message ID, if it assigns one.
+type Provider interface {
+	Name() string
+	Send(ctx context.Context, to, body string) (messageID string, err error)
+}
+
+// Delivery tells which provider accepted a message.
+type Delivery struct {
+	Provider  string
+	MessageID string
+}
+
+// Route sends numbers starting with Prefix (e.g. "+98") through Providers,
+// tried in order. The route with the longest matching prefix wins.
+type Route struct {
+	Prefix    string
+	Providers []string
+}
+
+type RouterOptions struct {
+	// BreakerFailures consecutive failures open a provider's circuit for BreakerCooldown.
+	BreakerFailures int
+	BreakerCooldown time.Duration
+	// AttemptTimeout bounds each provider attempt so a hanging gateway leaves
+	// time for the next one.
+	AttemptTimeout time.Duration
+}
+
+// Router fails over between providers. Numbers without a matching route go
+// through every provider in the order they were given.
+type Router struct {
+	providers map[string]Provider
+	fallback  []string
+	routes    []Route
+	breakers  map[string]*Breaker
+	metrics   map[string]*providerMetrics
+	opts      RouterOptions
+}
+
+func NewRouter(providers []Provider, routes []Route, opts RouterOptions) (*Router, error) {
+	if len(providers) == 0 {
+		return nil, errors.New("at least one sms provider is required")
+	}
+
+	r := &Router{
+		providers: make(map[string]Provider, len(providers)),
+		breakers:  make(map[string]*Breaker, len(providers)),
+		metrics:   make(map[string]*providerMetrics, len(providers)),
+		routes:    routes,
+		opts:      opts,
+	}
+	for _, p := range providers {
+		if _, ok := r.providers[p.Name()]; ok {
+			return nil, fmt.Errorf("duplicate sms provider %q", p.Name())
+		}
+		r.providers[p.Name()] = p
+		r.fallback = append(r.fallback, p.Name())
+		r.breakers[p.Name()] = NewBreaker(opts.BreakerFailures, opts.BreakerCooldown)
+		r.metrics[p.Name()] = publishProviderMetrics(p.Name(), r.breakers[p.Name()])
+	}
+	for _, route := range routes {
+		for _, name := range route.Providers {
+			if _, ok := r.providers[name]; !ok {
+				return nil, fmt.Errorf("route %s uses unknown sms provider %q", route.Prefix, name)
+			}
+		}
+	}
+	return r, nil
+}
+
+// Send tries the providers routed for to until one accepts the message.
+// Providers whose circuit is open are skipped.
+func (r *Router) Send(ctx context.Context, to, body string) (Delivery, error) {
+	var errs []error
+	for _, name := range r.route(to) {
+		breaker, metrics := r.breakers[name], r.metrics[name]
+		if !breaker.Allow() {
+			metrics.skipped.Add(1)
+			errs = append(errs, fmt.Errorf("%s: circuit open", name))
+			continue
+		}
+
+		messageID, err := r.attempt(ctx, r.providers[name], to, body)
+		if err != nil {
+			breaker.Failure()
+			metrics.failed.Add(1)
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		breaker.Success()
+		metrics.sent.Add(1)
+		return Delivery{Provider: name, MessageID: messageID}, nil
+	}
+	return Delivery{}, fmt.Errorf("no sms provider delivered the message: %w", errors.Join(errs...))
+}
+
+func (r *Router) attempt(ctx context.Context, p Provider, to, body string) (string, error) {
+	if r.opts.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.opts.AttemptTimeout)
+		defer cancel()
+	}
+	return p.Send(ctx, to, body)
+}
+
+func (r *Router) route(to string) []string {
+	best := -1
+	for i, route := range r.routes {
+		if strings.HasPrefix(to, route.Prefix) && (best < 0 || len(route.Prefix) > len(r.routes[best].Prefix)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return r.fallback
+	}
+	return r.routes[best].Providers
+}
@@ -0,0 +1,223 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time { return f.now }
+
+func (f *fakeClock) Advance(d time.Duration) { f.now = f.now.Add(d) }
+
+type fakeProvider struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) Send(context.Context, string, string) (string, error) {
+	p.calls++
+	if p.err != nil {
+		return "", p.err
+	}
+	return p.name + "-msg", nil
+}
+
+func TestBreaker(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	b := NewBreaker(2, time.Minute)
+	b.now = clock.Now
+
+	require.True(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, BreakerClosed, b.State())
+	require.True(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, BreakerOpen, b.State())
+	assert.False(t, b.Allow(), "open circuits reject calls")
+
+	clock.Advance(time.Minute)
+	require.True(t, b.Allow(), "a trial call goes through after the cooldown")
+	assert.Equal(t, BreakerHalfOpen, b.State())
+	assert.False(t, b.Allow(), "only one trial call at a time")
+	b.Failure()
+	assert.Equal(t, BreakerOpen, b.State(), "a failed trial opens the circuit again")
+
+	clock.Advance(time.Minute)
+	require.True(t, b.Allow())
+	b.Success()
+	assert.Equal(t, BreakerClosed, b.State())
+	assert.True(t, b.Allow())
+}
+
+func TestRouter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("fails over to the next provider", func(t *testing.T) {
+		primary := &fakeProvider{name: "router-primary", err: errors.New("gateway down")}
+		backup := &fakeProvider{name: "router-backup"}
+		r, err := NewRouter([]Provider{primary, backup}, nil, RouterOptions{BreakerFailures: 2, BreakerCooldown: time.Minute})
+		require.NoError(t, err)
+
+		delivery, err := r.Send(ctx, "+1234567890", "hi")
+		require.NoError(t, err)
+		assert.Equal(t, Delivery{Provider: "router-backup", MessageID: "router-backup-msg"}, delivery)
+		assert.Equal(t, int64(1), r.metrics["router-primary"].failed.Value())
+		assert.Equal(t, int64(1), r.metrics["router-backup"].sent.Value())
+	})
+
+	t.Run("skips providers with an open circuit", func(t *testing.T) {
+		primary := &fakeProvider{name: "router-primary", err: errors.New("gateway down")}
+		backup := &fakeProvider{name: "router-backup"}
+		r, err := NewRouter([]Provider{primary, backup}, nil, RouterOptions{BreakerFailures: 2, BreakerCooldown: time.Minute})
+		require.NoError(t, err)
+
+		for range 4 {
+			_, err := r.Send(ctx, "+1234567890", "hi")
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 2, primary.calls)
+		assert.Equal(t, 4, backup.calls)
+		assert.Equal(t, int64(2), r.metrics["router-primary"].skipped.Value())
+	})
+
+	t.Run("reports every failure when no provider delivers", func(t *testing.T) {
+		r, err := NewRouter([]Provider{
+			&fakeProvider{name: "router-primary", err: errors.New("gateway down")},
+			&fakeProvider{name: "router-backup", err: errors.New("quota exceeded")},
+		}, nil, RouterOptions{BreakerFailures: 3})
+		require.NoError(t, err)
+
+		_, err = r.Send(ctx, "+1234567890", "hi")
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "router-primary: gateway down")
+		assert.ErrorContains(t, err, "router-backup: quota exceeded")
+	})
+
+	t.Run("routes by the longest matching prefix", func(t *testing.T) {
+		global := &fakeProvider{name: "router-global"}
+		local := &fakeProvider{name: "router-local"}
+		tehran := &fakeProvider{name: "router-tehran"}
+		r, err := NewRouter([]Provider{global, local, tehran}, []Route{
+			{Prefix: "+98", Providers: []string{"router-local", "router-global"}},
+			{Prefix: "+9821", Providers: []string{"router-tehran"}},
+		}, RouterOptions{BreakerFailures: 3})
+		require.NoError(t, err)
+
+		for number, provider := range map[string]string{"+989121234567": "router-local", "+982112345678": "router-tehran", "+14155550100": "router-global"} {
+			delivery, err := r.Send(ctx, number, "hi")
+			require.NoError(t, err)
+			assert.Equal(t, provider, delivery.Provider, number)
+		}
+	})
+
+	t.Run("rejects invalid setups", func(t *testing.T) {
+		_, err := NewRouter(nil, nil, RouterOptions{})
+		assert.Error(t, err)
+
+		p := &fakeProvider{name: "router-primary"}
+		_, err = NewRouter([]Provider{p, p}, nil, RouterOptions{})
+		assert.ErrorContains(t, err, "duplicate")
+
+		_, err = NewRouter([]Provider{p}, []Route{{Prefix: "+98", Providers: []string{"missing"}}}, RouterOptions{})
+		assert.ErrorContains(t, err, "unknown sms provider")
+	})
+}
+
+func TestHTTPProvider(t *testing.T) {
+	var got map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		_, _ = w.Write([]byte(`{"message_id":"abc123"}`))
+	}))
+	defer server.Close()
+
+	p := NewHTTPProvider("gw", server.URL, "secret", nil)
+	id, err := p.Send(context.Background(), "+1234567890", "code 123")
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", id)
+	assert.Equal(t, map[string]string{"to": "+1234567890", "body": "code 123"}, got)
+
+	_, err = NewHTTPProvider("gw", server.URL, "wrong", nil).Send(context.Background(), "+1234567890", "code 123")
+	assert.ErrorContains(t, err, "401")
+}
+
+func TestParse(t *testing.T) {
+	p, err := ParseProvider("local=console://", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "local", p.Name())
+
+	p, err = ParseProvider("gw=https://tok@sms.example.com/send", nil)
+	require.NoError(t, err)
+	hp := p.(*httpProvider)
+	assert.Equal(t, "https://sms.example.com/send", hp.url)
+	assert.Equal(t, "tok", hp.token)
+
+	for _, spec := range []string{"console://", "Bad=console://", "gw=ftp://host"} {
+		_, err := ParseProvider(spec, nil)
+		assert.Error(t, err, spec)
+	}
+
+	route, err := ParseRoute("+98=local|gw")
+	require.NoError(t, err)
+	assert.Equal(t, Route{Prefix: "+98", Providers: []string{"local", "gw"}}, route)
+	_, err = ParseRoute("+98")
+	assert.Error(t, err)
+}
+
+func TestWebhookVerifier(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	v := NewWebhookVerifier(map[string]string{"primary": "s3cret"}, 5*time.Minute)
+	v.now = clock.Now
+	body := []byte(`{"message_id":"abc","status":"delivered"}`)
+	timestamp := strconv.FormatInt(clock.now.Unix(), 10)
+	signature := Sign("s3cret", timestamp, body)
+
+	assert.NoError(t, v.Verify("primary", timestamp, signature, body))
+	assert.NoError(t, v.Verify("primary", timestamp, strings.TrimPrefix(signature, "sha256="), body))
+	assert.ErrorIs(t, v.Verify("primary", timestamp, signature, []byte(`{"message_id":"abc","status":"failed"}`)), ErrInvalidSignature)
+	assert.ErrorIs(t, v.Verify("primary", timestamp, Sign("other", timestamp, body), body), ErrInvalidSignature)
+	assert.ErrorIs(t, v.Verify("primary", "", signature, body), ErrInvalidSignature)
+	assert.ErrorIs(t, v.Verify("backup", timestamp, signature, body), ErrUnknownWebhookProvider)
+
+	clock.Advance(6 * time.Minute)
+	assert.ErrorIs(t, v.Verify("primary", timestamp, signature, body), ErrInvalidSignature, "replayed reports are rejected")
+
+	provider, secret, err := ParseWebhookSecret("primary=s3=cret")
+	require.NoError(t, err)
+	assert.Equal(t, "primary", provider)
+	assert.Equal(t, "s3=cret", secret)
+	_, _, err = ParseWebhookSecret("primary=")
+	assert.Error(t, err)
+}
+
+func TestRecordDeliveryReport(t *testing.T) {
+	RecordDeliveryReport("metrics-test", "delivered")
+	RecordDeliveryReport("metrics-test", "delivered")
+	RecordDeliveryReport("metrics-test", "undelivered")
+
+	counts := deliveryReportsVar.Get("metrics-test").(*expvar.Map)
+	assert.Equal(t, "2", counts.Get("delivered").String())
+	assert.Equal(t, "1", counts.Get("undelivered").String())
+}
@@ -0,0 +1,78 @@
+package sms
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Signature"
+	TimestampHeader = "X-Signature-Timestamp"
+)
+
+var (
+	ErrUnknownWebhookProvider = errors.New("no webhook secret configured for provider")
+	ErrInvalidSignature       = errors.New("invalid webhook signature")
+)
+
+// WebhookVerifier checks delivery report signatures. Providers sign
+// "<unix timestamp>.<raw body>" with HMAC-SHA256 and their own secret and send
+// the hex digest, optionally prefixed with "sha256=", in X-Signature along
+// with the timestamp in X-Signature-Timestamp. Signatures older than the
+// tolerance are rejected so captured reports cannot be replayed.
+type WebhookVerifier struct {
+	secrets   map[string]string
+	tolerance time.Duration
+	now       func() time.Time
+}
+
+func NewWebhookVerifier(secrets map[string]string, tolerance time.Duration) *WebhookVerifier {
+	return &WebhookVerifier{secrets: secrets, tolerance: tolerance, now: time.Now}
+}
+
+func (v *WebhookVerifier) Verify(provider, timestamp, signature string, body []byte) error {
+	secret, ok := v.secrets[provider]
+	if !ok {
+		return ErrUnknownWebhookProvider
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := v.now().Sub(time.Unix(unix, 0)); age > v.tolerance || age < -v.tolerance {
+		return fmt.Errorf("%w: timestamp outside the allowed window", ErrInvalidSignature)
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !hmac.Equal(got, sign(secret, timestamp, body)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Sign returns the X-Signature value for body, as a provider would send it.
+func Sign(secret, timestamp string, body []byte) string {
+	return "sha256=" + hex.EncodeToString(sign(secret, timestamp, body))
+}
+
+func sign(secret, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// ParseWebhookSecret parses "provider=secret".
+func ParseWebhookSecret(spec string) (provider, secret string, err error) {
+	provider, secret, ok := strings.Cut(spec, "=")
+	if !ok || !providerNamePattern.MatchString(provider) || secret == "" {
+		return "", "", errors.New("invalid sms webhook secret: want provider=secret")
+	}
+	return provider, secret, nil
+}